@@ -0,0 +1,230 @@
+package flexkube
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+// Apply deploys all configured resources in dependency order: PKI, etcd, API Load Balancer pools,
+// controlplane, kubelet pools and container groups.
+//
+// Changes to all resources are calculated upfront and presented as a single plan, which needs to
+// be confirmed only once. State is persisted after deploying each resource, so if deployment of one
+// of the resources fails, already deployed resources don't need to be deployed again.
+func (r *Resource) Apply() error {
+	if r.PlanOut != "" || r.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are only supported when deploying single resource",
+			PlanOutFlag, PlanInFlag)
+	}
+
+	return r.withStateLock(r.apply)
+}
+
+// apply plans and deploys all configured resources. It must be called while holding the state lock.
+func (r *Resource) apply() error {
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	pkiChanged, err := r.generatePKI((*pki.PKI).Generate)
+	if err != nil {
+		return fmt.Errorf("generating PKI: %w", err)
+	}
+
+	deployments, err := r.deployments()
+	if err != nil {
+		return fmt.Errorf("getting resources to deploy: %w", err)
+	}
+
+	changed, err := r.planDeployments(deployments)
+	if err != nil {
+		return fmt.Errorf("planning deployments: %w", err)
+	}
+
+	return r.deployChanges(pkiChanged, changed)
+}
+
+// deployChanges runs pre-flight checks for given changed resources, asks user for confirmation
+// and deploys them, after persisting generated PKI, if it has changed.
+func (r *Resource) deployChanges(pkiChanged bool, changed []*deployment) error {
+	if !r.Noop {
+		for _, d := range changed {
+			if err := r.preflight(d.name, d.resource); err != nil {
+				return err
+			}
+		}
+	}
+
+	changedNames := []string{}
+
+	if pkiChanged {
+		changedNames = append(changedNames, "pki")
+	}
+
+	for _, d := range changed {
+		changedNames = append(changedNames, d.name)
+	}
+
+	confirmed, err := r.confirmChanges("updated", changedNames)
+	if err != nil || !confirmed {
+		return err
+	}
+
+	if pkiChanged {
+		if err := r.StateToFile(nil); err != nil {
+			return fmt.Errorf("saving PKI: %w", err)
+		}
+	}
+
+	return r.deployAll(changed)
+}
+
+// confirmChanges prints names of resources, which will be changed and asks user for confirmation.
+// It returns false, if there is nothing to change, if it is a no-op run or if user aborted.
+func (r *Resource) confirmChanges(action string, changedNames []string) (bool, error) {
+	if len(changedNames) == 0 {
+		fmt.Println("No changes required for any resource")
+
+		return false, nil
+	}
+
+	fmt.Printf("Following resources will be %s, in order: %s\n\n", action, strings.Join(changedNames, ", "))
+
+	if r.Noop {
+		return false, nil
+	}
+
+	return r.confirm()
+}
+
+// deployAll deploys given resources one by one, persisting the state after each of them.
+func (r *Resource) deployAll(deployments []*deployment) error {
+	for _, d := range deployments {
+		fmt.Printf("Deploying %s\n", d.name)
+
+		if err := r.deployAndSave(d.resource, d.saveStateF); err != nil {
+			return fmt.Errorf("deploying %s: %w", d.name, err)
+		}
+	}
+
+	return nil
+}
+
+// generatePKI loads configured PKI, generates it using given function and stores it in the state,
+// without persisting it, so other resources can use it. It returns true if PKI state has changed.
+func (r *Resource) generatePKI(generate func(*pki.PKI) error) (bool, error) {
+	if r.PKI == nil {
+		return false, nil
+	}
+
+	fmt.Println("Generating PKI...")
+
+	previousPKI, err := yaml.Marshal(r.State.PKI)
+	if err != nil {
+		return false, fmt.Errorf("serializing PKI state: %w", err)
+	}
+
+	p, err := r.getPKI()
+	if err != nil {
+		return false, fmt.Errorf("loading PKI configuration: %w", err)
+	}
+
+	if err := generate(p); err != nil {
+		return false, fmt.Errorf("generating: %w", err)
+	}
+
+	r.State.PKI = p
+
+	currentPKI, err := yaml.Marshal(r.State.PKI)
+	if err != nil {
+		return false, fmt.Errorf("serializing generated PKI: %w", err)
+	}
+
+	changed := string(previousPKI) != string(currentPKI)
+
+	if changed {
+		fmt.Printf("PKI certificates will be updated\n\n")
+	}
+
+	return changed, nil
+}
+
+// deployments returns all configured resources, which run containers, in the order they
+// should be deployed.
+func (r *Resource) deployments() ([]*deployment, error) {
+	deployments := []*deployment{}
+
+	if r.Etcd != nil {
+		d, err := r.etcdDeployment()
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	for _, name := range poolNames(apiLoadBalancerPoolNames(r.APILoadBalancerPools), nil) {
+		d, err := r.apiLoadBalancerPoolDeployment(name)
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	if r.Controlplane != nil {
+		d, err := r.controlplaneDeployment()
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	for _, name := range poolNames(kubeletPoolNames(r.KubeletPools), nil) {
+		d, err := r.kubeletPoolDeployment(name)
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	for _, name := range poolNames(groupNames(r.Containers), nil) {
+		d, err := r.containersDeployment(name)
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	return deployments, nil
+}
+
+// planDeployments checks current state of given resources and prints required changes.
+// It returns resources, which needs to be deployed.
+func (r *Resource) planDeployments(deployments []*deployment) ([]*deployment, error) {
+	changed := []*deployment{}
+
+	for _, d := range deployments {
+		fmt.Printf("Planning %s\n", d.name)
+
+		diff, err := r.checkState(d.resource)
+		if err != nil {
+			return nil, fmt.Errorf("checking current state of %s: %w", d.name, err)
+		}
+
+		fmt.Println()
+
+		if diff != "" {
+			changed = append(changed, d)
+		}
+	}
+
+	return changed, nil
+}
@@ -8,6 +8,8 @@ import (
 	"runtime/debug"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/flexkube/libflexkube/pkg/host"
 )
 
 const (
@@ -19,6 +21,36 @@ const (
 
 	// NoopFlag is const for --noop flag.
 	NoopFlag = "noop"
+
+	// PlanOutFlag is const for --plan-out flag.
+	PlanOutFlag = "plan-out"
+
+	// PlanInFlag is const for --plan-in flag.
+	PlanInFlag = "plan-in"
+
+	// ParallelismFlag is const for --parallelism flag.
+	ParallelismFlag = "parallelism"
+
+	// OutputFlag is const for --output flag.
+	OutputFlag = "output"
+
+	// SkipStateFlag is const for --skip-state flag.
+	SkipStateFlag = "skip-state"
+
+	// AllFlag is const for --all flag.
+	AllFlag = "all"
+
+	// StateBackendFlag is const for --state-backend flag.
+	StateBackendFlag = "state-backend"
+
+	// ShowSecretsFlag is const for --show-secrets flag.
+	ShowSecretsFlag = "show-secrets"
+
+	// LockTimeoutFlag is const for --lock-timeout flag.
+	LockTimeoutFlag = "lock-timeout"
+
+	// SkipPreflightFlag is const for --skip-preflight flag.
+	SkipPreflightFlag = "skip-preflight"
 )
 
 // Run executes flexkube CLI binary with given arguments (usually os.Args).
@@ -35,6 +67,35 @@ func Run(args []string) int {
 				Name:  NoopFlag,
 				Usage: "Only checks the status of the deployment, but does not do any changes",
 			},
+			&cli.StringFlag{
+				Name:  PlanOutFlag,
+				Usage: "Saves deployment plan to a given file instead of executing it",
+			},
+			&cli.StringFlag{
+				Name:  PlanInFlag,
+				Usage: "Executes deployment plan from a given file, if it is still up to date",
+			},
+			&cli.IntFlag{
+				Name:  ParallelismFlag,
+				Usage: "Number of containers of a single resource, which can be deployed at the same time",
+			},
+			&cli.StringFlag{
+				Name: StateBackendFlag,
+				Usage: "Where to store the state, overrides configuration. One of 'file:<path>', " +
+					"'directory:<path>', 'secret:<namespace>/<name>' or 'configmap:<namespace>/<name>'",
+			},
+			&cli.BoolFlag{
+				Name:  ShowSecretsFlag,
+				Usage: "Includes sensitive values like private keys in printed diffs instead of their checksums",
+			},
+			&cli.StringFlag{
+				Name:  LockTimeoutFlag,
+				Usage: "How long to wait for the state lock held by someone else, e.g. '5m'",
+			},
+			&cli.BoolFlag{
+				Name:  SkipPreflightFlag,
+				Usage: "Deploys resources without checking first, if hosts meet their requirements",
+			},
 		},
 		Commands: []*cli.Command{
 			kubeletPoolCommand(),
@@ -45,11 +106,16 @@ func Run(args []string) int {
 			kubeconfigCommand(),
 			containersCommand(),
 			templateCommand(),
+			validateCommand(),
+			applyCommand(),
+			destroyCommand(),
+			forceUnlockCommand(),
 		},
 	}
 
 	if err := app.Run(args); err != nil {
-		fmt.Printf("Execution failed: %v\n", err)
+		// Print errors to stderr, so they don't break machine-readable output.
+		fmt.Fprintf(os.Stderr, "Execution failed: %v\n", err)
 
 		return 1
 	}
@@ -116,6 +182,62 @@ func pkiCommand() *cli.Command {
 		Action: func(c *cli.Context) error {
 			return withResource(c, pkiAction)
 		},
+		Subcommands: []*cli.Command{
+			{
+				Name: "rotate",
+				Usage: "renews certificates expiring within their renew threshold and restarts etcd, " +
+					"controlplane and kubelet pool containers using them",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiRotateAction)
+				},
+			},
+			{
+				Name:  "inspect",
+				Usage: "prints information about all certificates stored in the state and fails, if any of them expires soon",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    OutputFlag,
+						Aliases: []string{"o"},
+						Usage:   fmt.Sprintf("Format of the report, either %q or %q", InspectOutputTable, InspectOutputJSON),
+						Value:   InspectOutputTable,
+					},
+					&cli.DurationFlag{
+						Name:  ExpiryThresholdFlag,
+						Usage: "Exit with an error, if any of the certificates expires within given duration, e.g. '720h'",
+						Value: defaultExpiryThreshold,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiInspectAction)
+				},
+			},
+			{
+				Name: "csr",
+				Usage: "creates certificate signing requests for intermediate CA certificates, which must be " +
+					"signed by root CA without private key, and writes them to given directory",
+				ArgsUsage: "[OUTPUT DIRECTORY]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiCSRAction)
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "imports externally signed intermediate CA certificate, optionally followed by it's chain",
+				ArgsUsage: "[CA NAME] [CERTIFICATE FILE PATH]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiImportAction)
+				},
+			},
+			{
+				Name: "rotate-ca",
+				Usage: "advances staged rotation of CA certificates: first starts trusting new CA certificates, " +
+					"then re-issues certificates using them and finally stops trusting old CA certificates",
+				ArgsUsage: "[CA NAME...]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiRotateCAAction)
+				},
+			},
+		},
 	}
 }
 
@@ -149,6 +271,73 @@ func containersCommand() *cli.Command {
 	}
 }
 
+func validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "validates configuration of all resources without connecting to any hosts",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    OutputFlag,
+				Aliases: []string{"o"},
+				Usage:   fmt.Sprintf("Format of the validation report, either %q or %q", ValidateOutputText, ValidateOutputJSON),
+				Value:   ValidateOutputText,
+			},
+			&cli.BoolFlag{
+				Name: SkipStateFlag,
+				Usage: "Validate only configuration, without loading the state from the state backend. " +
+					"Resources using certificates generated by PKI may fail validation without the state",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return withResource(c, validateAction)
+		},
+	}
+}
+
+func applyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "apply",
+		Usage: "deploys all configured resources in dependency order",
+		Action: func(c *cli.Context) error {
+			return withResource(c, applyAction)
+		},
+	}
+}
+
+func destroyCommand() *cli.Command {
+	return &cli.Command{
+		Name: "destroy",
+		Usage: fmt.Sprintf("removes containers of given resource using information from the state, "+
+			"where resource is one of %s, %s, %s, %s or %s",
+			EtcdResource, ControlplaneResource, KubeletPoolResource, APILoadBalancerPoolResource, ContainersResource),
+		ArgsUsage: "[RESOURCE] [NAME]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  AllFlag,
+				Usage: "Destroys all resources stored in the state in reverse dependency order",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return withResource(c, destroyAction)
+		},
+	}
+}
+
+func forceUnlockCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "force-unlock",
+		Usage: "removes the state lock left by interrupted run, regardless of who holds it",
+		Action: func(c *cli.Context) error {
+			return withResource(c, forceUnlockAction)
+		},
+	}
+}
+
+// forceUnlockAction implements 'force-unlock' subcommand.
+func forceUnlockAction(_ *cli.Context, r *Resource) error {
+	return r.ForceUnlock()
+}
+
 // apiLoadBalancerPoolAction implements 'apiloadbalancer-pool' subcommand.
 func apiLoadBalancerPoolAction(c *cli.Context, resource *Resource) error {
 	poolName, err := getPoolName(c)
@@ -242,6 +431,91 @@ func pkiAction(_ *cli.Context, r *Resource) error {
 	return r.RunPKI()
 }
 
+// pkiRotateAction implements 'pki rotate' subcommand.
+func pkiRotateAction(_ *cli.Context, r *Resource) error {
+	return r.RotatePKI()
+}
+
+// pkiInspectAction implements 'pki inspect' subcommand.
+func pkiInspectAction(c *cli.Context, r *Resource) error {
+	return r.InspectPKI(c.String(OutputFlag), c.Duration(ExpiryThresholdFlag))
+}
+
+// pkiCSRAction implements 'pki csr' subcommand.
+func pkiCSRAction(c *cli.Context, r *Resource) error {
+	if c.NArg() > 1 {
+		return fmt.Errorf("only one output directory can be specified")
+	}
+
+	directory := c.Args().Get(0)
+	if directory == "" {
+		directory = "."
+	}
+
+	return r.ExportCertificateSigningRequests(directory)
+}
+
+// pkiImportAction implements 'pki import' subcommand.
+func pkiImportAction(c *cli.Context, r *Resource) error {
+	if c.NArg() != 2 { //nolint:gomnd // CA name and certificate file path.
+		return fmt.Errorf("CA name and certificate file path must be specified")
+	}
+
+	return r.ImportCertificate(c.Args().Get(0), c.Args().Get(1))
+}
+
+// pkiRotateCAAction implements 'pki rotate-ca' subcommand.
+func pkiRotateCAAction(c *cli.Context, r *Resource) error {
+	return r.RotateCA(c.Args().Slice())
+}
+
+// applyAction implements 'apply' subcommand.
+func applyAction(_ *cli.Context, r *Resource) error {
+	return r.Apply()
+}
+
+// destroyAction implements 'destroy' subcommand.
+func destroyAction(c *cli.Context, r *Resource) error {
+	if c.Bool(AllFlag) {
+		if c.NArg() > 0 {
+			return fmt.Errorf("--%s flag can't be used together with resource name", AllFlag)
+		}
+
+		return r.DestroyAll()
+	}
+
+	if c.NArg() == 0 || c.NArg() > 2 {
+		return fmt.Errorf("resource type and optionally resource name must be specified")
+	}
+
+	return r.Destroy(c.Args().Get(0), c.Args().Get(1))
+}
+
+// validateAction implements 'validate' subcommand.
+func validateAction(c *cli.Context, r *Resource) error {
+	report := r.ValidateAll()
+
+	switch output := c.String(OutputFlag); output {
+	case ValidateOutputText:
+		fmt.Print(report.Text())
+	case ValidateOutputJSON:
+		o, err := report.JSON()
+		if err != nil {
+			return fmt.Errorf("formatting validation report: %w", err)
+		}
+
+		fmt.Println(o)
+	default:
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	if !report.Valid {
+		return fmt.Errorf("configuration is not valid")
+	}
+
+	return nil
+}
+
 func getPoolName(c *cli.Context) (string, error) {
 	if c.NArg() > 1 {
 		return "", fmt.Errorf("only one pool can be managed at a time")
@@ -266,21 +540,71 @@ func containersAction(c *cli.Context, resource *Resource) error {
 
 // withResource is a helper for action functions.
 func withResource(cliCtx *cli.Context, resourceF func(*cli.Context, *Resource) error) error {
-	resource, err := LoadResourceFromFiles()
+	var stateBackendConfig *StateBackendConfig
+
+	if cliCtx.IsSet(StateBackendFlag) {
+		config, err := ParseStateBackend(cliCtx.String(StateBackendFlag))
+		if err != nil {
+			return fmt.Errorf("parsing --%s flag: %w", StateBackendFlag, err)
+		}
+
+		stateBackendConfig = config
+	}
+
+	resource, err := loadConfig(stateBackendConfig)
 	if err != nil {
-		return fmt.Errorf("reading configuration and state failed: %w", err)
+		return fmt.Errorf("reading configuration failed: %w", err)
+	}
+
+	// Loading the state may require contacting the state backend, which is not always desired,
+	// e.g. when validating configuration in CI.
+	if !cliCtx.Bool(SkipStateFlag) {
+		if err := resource.loadState(); err != nil {
+			return fmt.Errorf("reading state failed: %w", err)
+		}
 	}
 
 	resource.Confirmed = cliCtx.Bool(YesFlag)
 	resource.Noop = cliCtx.Bool(NoopFlag)
+	resource.ShowSecrets = cliCtx.Bool(ShowSecretsFlag)
+	resource.SkipPreflight = cliCtx.Bool(SkipPreflightFlag)
+
+	resource.PlanOut = cliCtx.String(PlanOutFlag)
+	resource.PlanIn = cliCtx.String(PlanInFlag)
+
+	if cliCtx.IsSet(ParallelismFlag) {
+		resource.Parallelism = cliCtx.Int(ParallelismFlag)
+	}
+
+	if cliCtx.IsSet(LockTimeoutFlag) {
+		resource.LockTimeout = cliCtx.String(LockTimeoutFlag)
+	}
+
+	if resource.Parallelism < 0 {
+		return fmt.Errorf("--%s must not be negative", ParallelismFlag)
+	}
 
 	if resource.Confirmed && resource.Noop {
 		return fmt.Errorf("--%s and --%s flags are mutually exclusive", YesFlag, NoopFlag)
 	}
 
+	if resource.PlanOut != "" && resource.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are mutually exclusive", PlanOutFlag, PlanInFlag)
+	}
+
+	if resource.Noop && resource.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are mutually exclusive", NoopFlag, PlanInFlag)
+	}
+
 	if resource.Noop {
 		fmt.Println("No-op run, no changes will be made.")
 	}
 
-	return resourceF(cliCtx, resource)
+	err = resourceF(cliCtx, resource)
+
+	if closeErr := host.CloseConnections(); closeErr != nil {
+		fmt.Printf("Failed closing connections to hosts: %v\n", closeErr)
+	}
+
+	return err
 }
@@ -0,0 +1,114 @@
+package flexkube
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+// csrFileMode is a file mode used for writing certificate signing requests.
+const csrFileMode = 0o600
+
+// ExportCertificateSigningRequests creates certificate signing requests for intermediate CA
+// certificates, which are not signed yet, and writes them to given directory. Generated private
+// keys are persisted in the state before requests are written, so signed certificates can always
+// be imported.
+//
+// This allows to sign intermediate CA certificates using the root CA, which private key is not
+// available, for example because it is kept offline.
+func (r *Resource) ExportCertificateSigningRequests(directory string) error {
+	return r.withStateLock(func() error {
+		csrs := map[string]string{}
+
+		err := r.updatePKI(func(p *pki.PKI) error {
+			var err error
+
+			if csrs, err = p.CertificateSigningRequests(); err != nil {
+				return fmt.Errorf("creating certificate signing requests: %w", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeCertificateSigningRequests(directory, csrs)
+	})
+}
+
+// writeCertificateSigningRequests writes given certificate signing requests to given directory.
+func writeCertificateSigningRequests(directory string, csrs map[string]string) error {
+	if len(csrs) == 0 {
+		fmt.Println("All intermediate CA certificates are already signed")
+
+		return nil
+	}
+
+	names := []string{}
+
+	for name := range csrs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(directory, strings.ReplaceAll(name, "/", "-")+".csr")
+
+		if err := os.WriteFile(path, []byte(csrs[name]), csrFileMode); err != nil {
+			return fmt.Errorf("writing %q certificate signing request: %w", name, err)
+		}
+
+		fmt.Printf("Certificate signing request for %s written to %s\n", name, path)
+	}
+
+	return nil
+}
+
+// ImportCertificate imports intermediate CA certificate with given name, e.g. 'kubernetes/ca',
+// signed externally, from given file and persists it in the state. File must contain PEM encoded
+// certificate, optionally followed by certificates of intermediate CAs, which signed it.
+func (r *Resource) ImportCertificate(name, path string) error {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("reading certificate file: %w", err)
+	}
+
+	return r.withStateLock(func() error {
+		return r.updatePKI(func(p *pki.PKI) error {
+			if err := p.ImportCertificate(name, string(data)); err != nil {
+				return fmt.Errorf("importing certificate: %w", err)
+			}
+
+			fmt.Printf("Certificate %s imported\n", name)
+
+			return nil
+		})
+	})
+}
+
+// updatePKI loads configured PKI, updates it using given function and persists it in the state.
+// It must be called while holding the state lock.
+func (r *Resource) updatePKI(update func(*pki.PKI) error) error {
+	p, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("loading PKI configuration: %w", err)
+	}
+
+	if err := update(p); err != nil {
+		return err
+	}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	r.State.PKI = p
+
+	return r.StateToFile(nil)
+}
@@ -0,0 +1,72 @@
+package flexkube
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+// testKubernetesCAPrivateKey returns private key of Kubernetes CA stored in state.yaml in
+// the current working directory.
+func testKubernetesCAPrivateKey(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile("state.yaml")
+	if err != nil {
+		t.Fatalf("Reading state.yaml: %v", err)
+	}
+
+	r := &Resource{}
+
+	if err := yaml.Unmarshal(data, r); err != nil {
+		t.Fatalf("Parsing state.yaml: %v", err)
+	}
+
+	if r.State == nil || r.State.PKI == nil || r.State.PKI.Kubernetes == nil || r.State.PKI.Kubernetes.CA == nil {
+		t.Fatalf("State should contain Kubernetes CA, got: %s", data)
+	}
+
+	return string(r.State.PKI.Kubernetes.CA.PrivateKey)
+}
+
+// Run() pki csr tests.
+//
+//nolint:paralleltest // Test changes working directory, which is global for the process.
+func TestPKICSRSavesStateBeforeWritingRequests(t *testing.T) {
+	rootCA := &pki.PKI{}
+
+	if err := rootCA.Generate(); err != nil {
+		t.Fatalf("Generating root CA should succeed, got: %v", err)
+	}
+
+	config := "pki:\n  rootCA:\n    x509Certificate: |\n      " +
+		strings.ReplaceAll(strings.TrimSpace(string(rootCA.RootCA.X509Certificate)), "\n", "\n      ") +
+		"\n  kubernetes: {}\n"
+
+	withTestWorkingDirectory(t, config, "{}\n")
+
+	if code := Run([]string{"flexkube", "pki", "csr", "non-existing"}); code == 0 {
+		t.Fatalf("Writing certificate signing requests to non-existing directory should fail")
+	}
+
+	privateKey := testKubernetesCAPrivateKey(t)
+	if privateKey == "" {
+		t.Fatalf("Private key should be saved in the state, even if writing requests fails")
+	}
+
+	if code := Run([]string{"flexkube", "pki", "csr"}); code != 0 {
+		t.Fatalf("Writing certificate signing requests should succeed, got exit code %d", code)
+	}
+
+	if _, err := os.Stat("kubernetes-ca.csr"); err != nil {
+		t.Fatalf("Certificate signing request for Kubernetes CA should be written, got: %v", err)
+	}
+
+	if testKubernetesCAPrivateKey(t) != privateKey {
+		t.Fatalf("Private key saved in the state should be reused")
+	}
+}
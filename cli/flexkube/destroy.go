@@ -0,0 +1,249 @@
+package flexkube
+
+import (
+	"fmt"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/resource"
+	"github.com/flexkube/libflexkube/pkg/etcd"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+const (
+	// EtcdResource is a name of etcd resource.
+	EtcdResource = "etcd"
+
+	// ControlplaneResource is a name of controlplane resource.
+	ControlplaneResource = "controlplane"
+
+	// KubeletPoolResource is a name of kubelet pool resource.
+	KubeletPoolResource = "kubelet-pool"
+
+	// APILoadBalancerPoolResource is a name of API Load Balancer pool resource.
+	APILoadBalancerPoolResource = "apiloadbalancer-pool"
+
+	// ContainersResource is a name of containers group resource.
+	ContainersResource = "containers"
+)
+
+// Destroy removes all containers of a given resource, in reverse order, using only information
+// stored in the state. Once the containers are removed, resource is removed from the state.
+//
+// Name is required for pools and container groups. For etcd, name of the member can be given, then
+// only this member is removed from the cluster using etcd API before its container is removed.
+func (r *Resource) Destroy(resourceType, name string) error {
+	return r.withStateLock(func() error {
+		d, err := r.destroyDeployment(resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		return r.destroy([]*deployment{d})
+	})
+}
+
+// DestroyAll removes all resources stored in the state in reverse dependency order: container
+// groups, kubelet pools, controlplane, API Load Balancer pools and etcd.
+//
+// PKI is kept in the state, so re-created resources use the same certificates.
+func (r *Resource) DestroyAll() error {
+	return r.withStateLock(r.destroyAll)
+}
+
+// destroyAll removes all resources stored in the state. It must be called while holding the state lock.
+func (r *Resource) destroyAll() error {
+	deployments := []*deployment{}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	targets := []struct {
+		resourceType string
+		names        []string
+	}{
+		{ContainersResource, poolNames(groupNames(r.State.Containers), nil)},
+		{KubeletPoolResource, poolNames(groupNames(r.State.KubeletPools), nil)},
+		{ControlplaneResource, optionalName(r.State.Controlplane != nil)},
+		{APILoadBalancerPoolResource, poolNames(groupNames(r.State.APILoadBalancerPools), nil)},
+		{EtcdResource, optionalName(r.State.Etcd != nil)},
+	}
+
+	for _, target := range targets {
+		for _, name := range target.names {
+			d, err := r.destroyDeployment(target.resourceType, name)
+			if err != nil {
+				return err
+			}
+
+			deployments = append(deployments, d)
+		}
+	}
+
+	return r.destroy(deployments)
+}
+
+// optionalName returns list with single empty name if resource is found.
+func optionalName(found bool) []string {
+	if !found {
+		return nil
+	}
+
+	return []string{""}
+}
+
+// destroy plans removal of given resources, asks for confirmation and removes them one by one.
+func (r *Resource) destroy(deployments []*deployment) error {
+	if r.PlanOut != "" || r.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are not supported when destroying resources",
+			PlanOutFlag, PlanInFlag)
+	}
+
+	changed, err := r.planDeployments(deployments)
+	if err != nil {
+		return fmt.Errorf("planning removal: %w", err)
+	}
+
+	changedNames := []string{}
+
+	for _, d := range changed {
+		changedNames = append(changedNames, d.name)
+	}
+
+	confirmed, err := r.confirmChanges("destroyed", changedNames)
+	if err != nil || !confirmed {
+		return err
+	}
+
+	return r.deployAll(changed)
+}
+
+// destroyDeployment returns deployment, which removes containers of given resource.
+func (r *Resource) destroyDeployment(resourceType, name string) (*deployment, error) {
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	if name == "" && resourceType != EtcdResource && resourceType != ControlplaneResource {
+		return nil, fmt.Errorf("name of %s must be specified", resourceType)
+	}
+
+	if name != "" && resourceType == ControlplaneResource {
+		return nil, fmt.Errorf("%s does not accept a name", resourceType)
+	}
+
+	switch resourceType {
+	case EtcdResource:
+		return r.etcdDestroyDeployment(name)
+	case ControlplaneResource:
+		return stateDestroyDeployment(resourceType, r.State.Controlplane, r.Parallelism,
+			func(state *container.ContainersState) {
+				r.State.Controlplane = state
+			})
+	case KubeletPoolResource:
+		return stateDestroyDeployment(fmt.Sprintf("%s/%s", resourceType, name), r.State.KubeletPools[name],
+			r.Parallelism, func(state *container.ContainersState) {
+				setOrPrune(r.State.KubeletPools, name, state)
+			})
+	case APILoadBalancerPoolResource:
+		return stateDestroyDeployment(fmt.Sprintf("%s/%s", resourceType, name), r.State.APILoadBalancerPools[name],
+			r.Parallelism, func(state *container.ContainersState) {
+				setOrPrune(r.State.APILoadBalancerPools, name, state)
+			})
+	case ContainersResource:
+		return stateDestroyDeployment(fmt.Sprintf("%s/%s", resourceType, name), r.State.Containers[name],
+			r.Parallelism, func(state *container.ContainersState) {
+				setOrPrune(r.State.Containers, name, state)
+			})
+	default:
+		return nil, fmt.Errorf("unknown resource type %q", resourceType)
+	}
+}
+
+// etcdDestroyDeployment returns deployment, which removes either the entire etcd cluster or, if
+// member name is given, only a single member.
+func (r *Resource) etcdDestroyDeployment(memberName string) (*deployment, error) {
+	saveStateF := func(state *container.ContainersState) {
+		r.State.Etcd = state
+	}
+
+	if memberName == "" {
+		return stateDestroyDeployment(EtcdResource, r.State.Etcd, r.Parallelism, saveStateF)
+	}
+
+	if r.State.Etcd == nil {
+		return nil, fmt.Errorf("state of %s not found", EtcdResource)
+	}
+
+	cluster := &etcd.Cluster{
+		State:          *r.State.Etcd,
+		DestroyMembers: []string{memberName},
+		Parallelism:    r.Parallelism,
+	}
+
+	etcdResource, err := validateAndNew(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("getting etcd member %q from the state: %w", memberName, err)
+	}
+
+	return &deployment{
+		name:       fmt.Sprintf("%s/%s", EtcdResource, memberName),
+		resource:   etcdResource,
+		saveStateF: pruneStateF(saveStateF),
+	}, nil
+}
+
+// stateDestroyDeployment returns deployment, which removes all containers stored in the given state.
+func stateDestroyDeployment(
+	name string,
+	state *container.ContainersState,
+	parallelism int,
+	saveStateF func(*container.ContainersState),
+) (*deployment, error) {
+	if state == nil {
+		return nil, fmt.Errorf("state of %s not found", name)
+	}
+
+	containers := &resource.Containers{
+		State:       *state,
+		Parallelism: parallelism,
+	}
+
+	containersResource, err := validateAndNew(containers)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s from the state: %w", name, err)
+	}
+
+	return &deployment{
+		name:       name,
+		resource:   containersResource,
+		saveStateF: pruneStateF(saveStateF),
+	}, nil
+}
+
+// pruneStateF wraps given function, so it receives remaining state of the resource after
+// the deployment or nil, if all containers has been removed.
+func pruneStateF(saveStateF func(*container.ContainersState)) func(types.Resource) {
+	return func(r types.Resource) {
+		state := r.Containers().ToExported().PreviousState
+
+		if len(state) == 0 {
+			saveStateF(nil)
+
+			return
+		}
+
+		saveStateF(&state)
+	}
+}
+
+// setOrPrune sets given state in the map or removes the entry, if state is nil.
+func setOrPrune(states map[string]*container.ContainersState, name string, state *container.ContainersState) {
+	if state == nil {
+		delete(states, name)
+
+		return
+	}
+
+	states[name] = state
+}
@@ -0,0 +1,272 @@
+package flexkube
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+)
+
+// DirectoryStateBackendConfig configures state backend, which stores the state of each resource
+// in a separate YAML file in a given directory, using the following layout:
+//
+//	<path>/pki.yaml
+//	<path>/etcd.yaml
+//	<path>/controlplane.yaml
+//	<path>/kubelet-pools/<name>.yaml
+//	<path>/apiloadbalancer-pools/<name>.yaml
+//	<path>/containers/<name>.yaml
+//
+// This allows to review changes made to each resource separately.
+type DirectoryStateBackendConfig struct {
+	// Path is a path to the directory, where state is stored. The directory will be created
+	// if it does not exist.
+	//
+	// Example value: 'state'.
+	Path string `json:"path,omitempty"`
+}
+
+// directoryStateBackend implements StateBackend using a directory.
+type directoryStateBackend struct {
+	path  string
+	codec *stateCodec
+}
+
+// Validate validates directory state backend configuration.
+func (c *DirectoryStateBackendConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path must be set")
+	}
+
+	return nil
+}
+
+// New validates directory state backend configuration and returns state backend.
+func (c *DirectoryStateBackendConfig) New() (StateBackend, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating configuration: %w", err)
+	}
+
+	codec, err := newStateCodec(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.new(codec), nil
+}
+
+// new returns state backend using given codec.
+func (c *DirectoryStateBackendConfig) new(codec *stateCodec) StateBackend {
+	return &directoryStateBackend{
+		path:  c.Path,
+		codec: codec,
+	}
+}
+
+// groupDirectories returns pointers to state maps of resources, which may have multiple instances,
+// together with directory names, where they are stored.
+func groupDirectories(state *ResourceState) map[string]*map[string]*container.ContainersState {
+	return map[string]*map[string]*container.ContainersState{
+		"kubelet-pools":         &state.KubeletPools,
+		"apiloadbalancer-pools": &state.APILoadBalancerPools,
+		"containers":            &state.Containers,
+	}
+}
+
+// Load is part of StateBackend interface.
+func (d *directoryStateBackend) Load() (*ResourceState, error) {
+	if _, err := os.Stat(d.path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil // Missing state is not an error.
+	}
+
+	state := &ResourceState{}
+
+	files := map[string]interface{}{
+		"pki.yaml":          &state.PKI,
+		"etcd.yaml":         &state.Etcd,
+		"controlplane.yaml": &state.Controlplane,
+	}
+
+	for file, target := range files {
+		if err := d.codec.readFile(filepath.Join(d.path, file), target); err != nil {
+			return nil, err
+		}
+	}
+
+	for dir, groups := range groupDirectories(state) {
+		names, err := stateFileNames(filepath.Join(d.path, dir))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			group := &container.ContainersState{}
+
+			if err := d.codec.readFile(filepath.Join(d.path, dir, name+".yaml"), group); err != nil {
+				return nil, err
+			}
+
+			if *groups == nil {
+				*groups = map[string]*container.ContainersState{}
+			}
+
+			(*groups)[name] = group
+		}
+	}
+
+	return state, nil
+}
+
+// Save is part of StateBackend interface.
+func (d *directoryStateBackend) Save(state *ResourceState) error {
+	if state == nil {
+		state = &ResourceState{}
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+		remove  bool
+	}{
+		{"pki.yaml", state.PKI, state.PKI == nil},
+		{"etcd.yaml", state.Etcd, state.Etcd == nil},
+		{"controlplane.yaml", state.Controlplane, state.Controlplane == nil},
+	}
+
+	// Validate all names before writing anything, so state is not partially written.
+	for dir, groups := range groupDirectories(state) {
+		for name := range *groups {
+			if err := validateStateFileName(name); err != nil {
+				return fmt.Errorf("saving state into %q directory: %w", dir, err)
+			}
+		}
+	}
+
+	// #nosec G115 // Constant conversion.
+	if err := os.MkdirAll(d.path, fs.FileMode(stateDirectoryPermissions)); err != nil {
+		return fmt.Errorf("creating state directory %q: %w", d.path, err)
+	}
+
+	for _, file := range files {
+		if err := d.writeStateFile(filepath.Join(d.path, file.name), file.content, file.remove); err != nil {
+			return err
+		}
+	}
+
+	for dir, groups := range groupDirectories(state) {
+		if err := d.saveGroups(filepath.Join(d.path, dir), *groups); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveGroups writes each given group into a separate file in a given directory and removes files
+// of groups, which are no longer present in the state.
+func (d *directoryStateBackend) saveGroups(dir string, groups map[string]*container.ContainersState) error {
+	existingNames, err := stateFileNames(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range existingNames {
+		if _, ok := groups[name]; !ok {
+			if err := d.writeStateFile(filepath.Join(dir, name+".yaml"), nil, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	// #nosec G115 // Constant conversion.
+	if err := os.MkdirAll(dir, fs.FileMode(stateDirectoryPermissions)); err != nil {
+		return fmt.Errorf("creating state directory %q: %w", dir, err)
+	}
+
+	for name, group := range groups {
+		if err := d.writeStateFile(filepath.Join(dir, name+".yaml"), group, group == nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateStateFileName checks, that given name of the pool or the group can be safely used as
+// a state file name, so state is not written outside of the state directory.
+func validateStateFileName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("name %q is not allowed", name)
+	}
+
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("name %q must not contain path separators", name)
+	}
+
+	return nil
+}
+
+// Lock is part of StateBackend interface.
+func (d *directoryStateBackend) Lock(lock *StateLock) error {
+	// #nosec G115 // Constant conversion.
+	if err := os.MkdirAll(d.path, fs.FileMode(stateDirectoryPermissions)); err != nil {
+		return fmt.Errorf("creating state directory %q: %w", d.path, err)
+	}
+
+	return lockFile(filepath.Join(d.path, ".lock"), lock)
+}
+
+// Unlock is part of StateBackend interface.
+func (d *directoryStateBackend) Unlock() error {
+	return unlockFile(filepath.Join(d.path, ".lock"))
+}
+
+// CurrentLock is part of StateBackend interface.
+func (d *directoryStateBackend) CurrentLock() (*StateLock, error) {
+	return lockFromFile(filepath.Join(d.path, ".lock"))
+}
+
+// stateFileNames returns names of state files in a given directory, without the extension.
+func stateFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading state directory %q: %w", dir, err)
+	}
+
+	names := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+
+	return names, nil
+}
+
+// writeStateFile writes given content to a given state file. If remove is true, the file is removed instead.
+func (d *directoryStateBackend) writeStateFile(path string, content interface{}, remove bool) error {
+	if remove {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing state file %q: %w", path, err)
+		}
+
+		return nil
+	}
+
+	return d.codec.writeFile(path, content)
+}
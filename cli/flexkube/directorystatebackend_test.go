@@ -0,0 +1,104 @@
+package flexkube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+)
+
+func testDirectoryStateBackend(t *testing.T) (StateBackend, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "state")
+
+	backend, err := (&DirectoryStateBackendConfig{Path: path}).New()
+	if err != nil {
+		t.Fatalf("Creating directory state backend should succeed, got: %v", err)
+	}
+
+	return backend, path
+}
+
+// New() tests.
+func TestDirectoryStateBackendNewEmptyPath(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (&DirectoryStateBackendConfig{}).New(); err == nil {
+		t.Fatalf("Creating directory state backend without path should fail")
+	}
+}
+
+// Load() and Save() tests.
+func TestDirectoryStateBackend(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := testDirectoryStateBackend(t)
+
+	testStateBackendRoundTrip(t, backend)
+}
+
+func TestDirectoryStateBackendLayout(t *testing.T) {
+	t.Parallel()
+
+	backend, path := testDirectoryStateBackend(t)
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	for _, file := range []string{
+		"etcd.yaml",
+		"kubelet-pools/workers.yaml",
+		"containers/foo.yaml",
+		"containers/bar.yaml",
+	} {
+		if _, err := os.Stat(filepath.Join(path, file)); err != nil {
+			t.Fatalf("State file %q should exist, got: %v", file, err)
+		}
+	}
+
+	for _, file := range []string{"pki.yaml", "controlplane.yaml"} {
+		if _, err := os.Stat(filepath.Join(path, file)); !os.IsNotExist(err) {
+			t.Fatalf("State file %q of not existing resource should not be created, got: %v", file, err)
+		}
+	}
+}
+
+func TestDirectoryStateBackendSaveBadName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"", ".", "..", "../foo", "foo/bar", `foo\bar`} {
+		name := name
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backend, path := testDirectoryStateBackend(t)
+
+			state := &ResourceState{
+				Containers: map[string]*container.ContainersState{
+					name: testContainersState("foo"),
+				},
+			}
+
+			if err := backend.Save(state); err == nil {
+				t.Fatalf("Saving state with group name %q should fail", name)
+			}
+
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatalf("Nothing should be written when saving state fails, got: %v", err)
+			}
+		})
+	}
+}
+
+// Lock(), Unlock() and CurrentLock() tests.
+func TestDirectoryStateBackendLock(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := testDirectoryStateBackend(t)
+
+	testStateBackendLock(t, backend)
+}
@@ -0,0 +1,191 @@
+package flexkube
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FileStateBackendConfig configures state backend, which stores the state in a single YAML file.
+type FileStateBackendConfig struct {
+	// Path is a path to the file, where state is stored.
+	//
+	// Example value: 'state.yaml'.
+	Path string `json:"path,omitempty"`
+}
+
+// fileStateBackend implements StateBackend using a single file.
+type fileStateBackend struct {
+	path  string
+	codec *stateCodec
+}
+
+// Validate validates file state backend configuration.
+func (c *FileStateBackendConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path must be set")
+	}
+
+	return nil
+}
+
+// New validates file state backend configuration and returns state backend.
+func (c *FileStateBackendConfig) New() (StateBackend, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating configuration: %w", err)
+	}
+
+	codec, err := newStateCodec(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.new(codec), nil
+}
+
+// new returns state backend using given codec.
+func (c *FileStateBackendConfig) new(codec *stateCodec) StateBackend {
+	return &fileStateBackend{
+		path:  c.Path,
+		codec: codec,
+	}
+}
+
+// Load is part of StateBackend interface.
+func (f *fileStateBackend) Load() (*ResourceState, error) {
+	r := &Resource{}
+
+	if err := f.codec.readFile(f.path, r); err != nil {
+		return nil, err
+	}
+
+	return r.State, nil
+}
+
+// Save is part of StateBackend interface.
+func (f *fileStateBackend) Save(state *ResourceState) error {
+	return f.codec.writeFile(f.path, &Resource{
+		State: state,
+	})
+}
+
+// Lock is part of StateBackend interface.
+func (f *fileStateBackend) Lock(lock *StateLock) error {
+	return lockFile(f.path+".lock", lock)
+}
+
+// Unlock is part of StateBackend interface.
+func (f *fileStateBackend) Unlock() error {
+	return unlockFile(f.path + ".lock")
+}
+
+// CurrentLock is part of StateBackend interface.
+func (f *fileStateBackend) CurrentLock() (*StateLock, error) {
+	return lockFromFile(f.path + ".lock")
+}
+
+// readFile reads given state file into given target. If the file does not exist, target
+// is not modified.
+func (s *stateCodec) readFile(path string, target interface{}) error {
+	content, err := readYamlFile(path)
+	if err != nil {
+		return fmt.Errorf("reading state file %q: %w", path, err)
+	}
+
+	if err := s.unmarshal(content, target); err != nil {
+		return fmt.Errorf("parsing state file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeFile serializes given object to YAML and writes it to a given file. If object
+// serializes to an empty YAML object, empty file is written.
+func (s *stateCodec) writeFile(path string, o interface{}) error {
+	stateRaw, err := s.marshal(o)
+	if err != nil {
+		return fmt.Errorf("serializing state: %w", err)
+	}
+
+	if string(stateRaw) == "{}\n" {
+		stateRaw = []byte{}
+	}
+
+	// #nosec G115 // Constant conversion.
+	if err := os.WriteFile(path, stateRaw, fs.FileMode(stateFilePermissions)); err != nil {
+		return fmt.Errorf("writing state to file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// lockFile creates given lock file with given lock information. If the file already exists,
+// *StateLockedError is returned.
+func lockFile(path string, lock *StateLock) error {
+	lockRaw, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("serializing lock: %w", err)
+	}
+
+	// #nosec G115 // Constant conversion.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fs.FileMode(stateFilePermissions)) // #nosec G304
+	if errors.Is(err, fs.ErrExist) {
+		currentLock, err := lockFromFile(path)
+		if err != nil {
+			return err
+		}
+
+		return &StateLockedError{
+			Lock: currentLock,
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("creating lock file %q: %w", path, err)
+	}
+
+	if _, err := f.Write(lockRaw); err != nil {
+		_ = f.Close() //nolint:errcheck // Write error is more important.
+
+		return fmt.Errorf("writing lock file %q: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing lock file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// lockFromFile reads lock information from given lock file. If the file does not exist,
+// nil is returned.
+func lockFromFile(path string) (*StateLock, error) {
+	lockRaw, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil // Missing lock file means state is not locked.
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading lock file %q: %w", path, err)
+	}
+
+	lock := &StateLock{}
+
+	if err := yaml.Unmarshal(lockRaw, lock); err != nil {
+		return nil, fmt.Errorf("parsing lock file %q: %w", path, err)
+	}
+
+	return lock, nil
+}
+
+// unlockFile removes given lock file.
+func unlockFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing lock file %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package flexkube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testFileStateBackend(t *testing.T) (StateBackend, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+
+	backend, err := (&FileStateBackendConfig{Path: path}).New()
+	if err != nil {
+		t.Fatalf("Creating file state backend should succeed, got: %v", err)
+	}
+
+	return backend, path
+}
+
+// New() tests.
+func TestFileStateBackendNewEmptyPath(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (&FileStateBackendConfig{}).New(); err == nil {
+		t.Fatalf("Creating file state backend without path should fail")
+	}
+}
+
+// Load() and Save() tests.
+func TestFileStateBackend(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := testFileStateBackend(t)
+
+	testStateBackendRoundTrip(t, backend)
+}
+
+func TestFileStateBackendSaveEmpty(t *testing.T) {
+	t.Parallel()
+
+	backend, path := testFileStateBackend(t)
+
+	if err := backend.Save(nil); err != nil {
+		t.Fatalf("Saving empty state should succeed, got: %v", err)
+	}
+
+	content, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		t.Fatalf("Reading state file: %v", err)
+	}
+
+	if len(content) != 0 {
+		t.Fatalf("Empty state should be saved as empty file, got: %q", content)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Checking state file: %v", err)
+	}
+
+	if info.Mode().Perm() != stateFilePermissions {
+		t.Fatalf("State file should have %o permissions, got %o", stateFilePermissions, info.Mode().Perm())
+	}
+}
+
+func TestFileStateBackendLoadMalformed(t *testing.T) {
+	t.Parallel()
+
+	backend, path := testFileStateBackend(t)
+
+	if err := os.WriteFile(path, []byte("state: foo"), 0o600); err != nil {
+		t.Fatalf("Writing state file: %v", err)
+	}
+
+	if _, err := backend.Load(); err == nil {
+		t.Fatalf("Loading malformed state should fail")
+	}
+}
+
+// Lock(), Unlock() and CurrentLock() tests.
+func TestFileStateBackendLock(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := testFileStateBackend(t)
+
+	testStateBackendLock(t, backend)
+}
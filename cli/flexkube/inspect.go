@@ -0,0 +1,117 @@
+package flexkube
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+const (
+	// InspectOutputTable is a value of --output flag for PKI inspection report in table format.
+	InspectOutputTable = "table"
+
+	// InspectOutputJSON is a value of --output flag for PKI inspection report in JSON format.
+	InspectOutputJSON = "json"
+
+	// ExpiryThresholdFlag is const for --expiry-threshold flag.
+	ExpiryThresholdFlag = "expiry-threshold"
+
+	// defaultExpiryThreshold is a default value of --expiry-threshold flag.
+	defaultExpiryThreshold = 30 * 24 * time.Hour
+
+	// inspectTablePadding is a number of spaces between columns of PKI inspection table.
+	inspectTablePadding = 2
+)
+
+// PKIInspection holds information about all certificates stored in the state.
+type PKIInspection struct {
+	// Certificates holds information about each certificate.
+	Certificates []pki.CertificateInfo `json:"certificates"`
+
+	// Expiring is a list of names of certificates, which expire within the threshold.
+	Expiring []string `json:"expiring"`
+}
+
+// InspectPKI prints information about all certificates in PKI stored in the state in a given
+// output format.
+//
+// If any of the certificates expires within given threshold, error is returned, so the command
+// can be used for monitoring.
+func (r *Resource) InspectPKI(output string, threshold time.Duration) error {
+	if r.State == nil || r.State.PKI == nil {
+		return fmt.Errorf("PKI not found in the state")
+	}
+
+	now := time.Now()
+
+	infos, err := r.State.PKI.Inspect(now)
+	if err != nil {
+		return fmt.Errorf("inspecting PKI: %w", err)
+	}
+
+	inspection := &PKIInspection{
+		Certificates: infos,
+		Expiring:     []string{},
+	}
+
+	for _, info := range infos {
+		if info.NotAfter.Before(now.Add(threshold)) {
+			inspection.Expiring = append(inspection.Expiring, info.Name)
+		}
+	}
+
+	switch output {
+	case InspectOutputTable:
+		fmt.Print(inspection.Table())
+	case InspectOutputJSON:
+		o, err := inspection.JSON()
+		if err != nil {
+			return fmt.Errorf("formatting PKI inspection: %w", err)
+		}
+
+		fmt.Println(o)
+	default:
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	if len(inspection.Expiring) > 0 {
+		return fmt.Errorf("%d certificates expire within %s: %s", len(inspection.Expiring), threshold,
+			strings.Join(inspection.Expiring, ", "))
+	}
+
+	return nil
+}
+
+// Table returns human-readable form of the PKI inspection in table format.
+func (i *PKIInspection) Table() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, inspectTablePadding, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tCOMMON NAME\tSANS\tISSUER\tSERIAL\tNOT AFTER\tDAYS REMAINING\tKEY TYPE")
+
+	for _, c := range i.Certificates {
+		sans := append(append([]string{}, c.DNSNames...), c.IPAddresses...)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", c.Name, c.CommonName, strings.Join(sans, ","),
+			c.Issuer, c.SerialNumber, c.NotAfter.UTC().Format(time.RFC3339), c.DaysRemaining, c.KeyType)
+	}
+
+	_ = w.Flush() //nolint:errcheck // Writing to strings.Builder never fails.
+
+	return sb.String()
+}
+
+// JSON returns PKI inspection in JSON format.
+func (i *PKIInspection) JSON() (string, error) {
+	output, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("serializing PKI inspection: %w", err)
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,348 @@
+package flexkube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// kubernetesStateKey is a key in Secret or ConfigMap data, where state is stored.
+	kubernetesStateKey = "state.yaml"
+
+	// kubernetesLockAnnotation is an annotation set on Secret or ConfigMap, when state is locked.
+	kubernetesLockAnnotation = "flexkube.io/lock"
+)
+
+// errConcurrentModification is returned, when the object storing the state has been modified
+// since it has been fetched.
+var errConcurrentModification = errors.New("object has been modified concurrently")
+
+// KubernetesStateBackendConfig configures state backend, which stores the state in a Secret or
+// ConfigMap in an existing Kubernetes cluster. This allows multiple operators to share the state.
+//
+// Object is created if it does not exist. Namespace must exist.
+type KubernetesStateBackendConfig struct {
+	// KubeconfigPath is a path to kubeconfig file used to access the cluster.
+	//
+	// This field is optional. If empty, kubeconfig from KUBECONFIG environment variable
+	// or from the default location will be used.
+	KubeconfigPath string `json:"kubeconfigPath,omitempty"`
+
+	// Namespace is a namespace, where the object is stored.
+	//
+	// This field is required.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is a name of the object.
+	//
+	// This field is required.
+	Name string `json:"name,omitempty"`
+
+	// ConfigMap controls, if state should be stored in ConfigMap instead of Secret. As state
+	// contains private keys, storing it in a Secret is recommended.
+	ConfigMap bool `json:"configMap,omitempty"`
+}
+
+// kubernetesStateBackend implements StateBackend using Kubernetes Secret or ConfigMap.
+type kubernetesStateBackend struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	configMap bool
+	codec     *stateCodec
+}
+
+// kubernetesStateObject is a common representation of Secret and ConfigMap storing the state.
+type kubernetesStateObject struct {
+	exists bool
+
+	// meta is an object metadata as returned by the API, so labels, owner references and other
+	// fields set by other tools are preserved when updating the object.
+	meta        metav1.ObjectMeta
+	annotations map[string]string
+	state       []byte
+}
+
+// Validate validates Kubernetes state backend configuration.
+func (c *KubernetesStateBackendConfig) Validate() error {
+	var errors util.ValidateErrors
+
+	if c.Namespace == "" {
+		errors = append(errors, fmt.Errorf("namespace must be set"))
+	}
+
+	if c.Name == "" {
+		errors = append(errors, fmt.Errorf("name must be set"))
+	}
+
+	return errors.Return()
+}
+
+// New validates Kubernetes state backend configuration and returns state backend.
+func (c *KubernetesStateBackendConfig) New() (StateBackend, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating configuration: %w", err)
+	}
+
+	codec, err := newStateCodec(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.new(codec)
+}
+
+// new returns state backend using given codec.
+func (c *KubernetesStateBackendConfig) new(codec *stateCodec) (StateBackend, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = c.KubeconfigPath
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes clientset: %w", err)
+	}
+
+	return &kubernetesStateBackend{
+		client:    client,
+		namespace: c.Namespace,
+		name:      c.Name,
+		configMap: c.ConfigMap,
+		codec:     codec,
+	}, nil
+}
+
+// Load is part of StateBackend interface.
+func (k *kubernetesStateBackend) Load() (*ResourceState, error) {
+	o, err := k.get()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resource{}
+
+	if err := k.codec.unmarshal(o.state, r); err != nil {
+		return nil, fmt.Errorf("parsing state: %w", err)
+	}
+
+	return r.State, nil
+}
+
+// Save is part of StateBackend interface.
+func (k *kubernetesStateBackend) Save(state *ResourceState) error {
+	stateRaw, err := k.codec.marshal(&Resource{
+		State: state,
+	})
+	if err != nil {
+		return fmt.Errorf("serializing state: %w", err)
+	}
+
+	o, err := k.get()
+	if err != nil {
+		return err
+	}
+
+	o.state = stateRaw
+
+	return k.put(o)
+}
+
+// Lock is part of StateBackend interface.
+//
+// Lock is stored as an annotation on the object. Object updates use optimistic concurrency,
+// so only one of concurrent Lock() calls can succeed.
+func (k *kubernetesStateBackend) Lock(lock *StateLock) error {
+	o, err := k.get()
+	if err != nil {
+		return err
+	}
+
+	currentLock, err := o.lock()
+	if err != nil {
+		return err
+	}
+
+	if currentLock != nil {
+		return &StateLockedError{
+			Lock: currentLock,
+		}
+	}
+
+	lockRaw, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("serializing lock: %w", err)
+	}
+
+	o.annotations[kubernetesLockAnnotation] = string(lockRaw)
+
+	err = k.put(o)
+	if errors.Is(err, errConcurrentModification) {
+		return &StateLockedError{}
+	}
+
+	if err != nil {
+		return fmt.Errorf("locking state: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock is part of StateBackend interface.
+func (k *kubernetesStateBackend) Unlock() error {
+	o, err := k.get()
+	if err != nil {
+		return err
+	}
+
+	if _, locked := o.annotations[kubernetesLockAnnotation]; !locked {
+		return nil
+	}
+
+	delete(o.annotations, kubernetesLockAnnotation)
+
+	if err := k.put(o); err != nil {
+		return fmt.Errorf("unlocking state: %w", err)
+	}
+
+	return nil
+}
+
+// CurrentLock is part of StateBackend interface.
+func (k *kubernetesStateBackend) CurrentLock() (*StateLock, error) {
+	o, err := k.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.lock()
+}
+
+// lock returns lock information stored in object annotations. If object is not locked,
+// nil is returned.
+func (o *kubernetesStateObject) lock() (*StateLock, error) {
+	lockRaw, locked := o.annotations[kubernetesLockAnnotation]
+	if !locked {
+		return nil, nil //nolint:nilnil // Missing annotation means state is not locked.
+	}
+
+	lock := &StateLock{}
+
+	if err := json.Unmarshal([]byte(lockRaw), lock); err != nil {
+		return nil, fmt.Errorf("parsing %q annotation: %w", kubernetesLockAnnotation, err)
+	}
+
+	return lock, nil
+}
+
+// kind returns kind of the object used for storing the state.
+func (k *kubernetesStateBackend) kind() string {
+	if k.configMap {
+		return "ConfigMap"
+	}
+
+	return "Secret"
+}
+
+// get fetches the object storing the state. If object does not exist, empty object is returned.
+func (k *kubernetesStateBackend) get() (*kubernetesStateObject, error) {
+	o := &kubernetesStateObject{
+		annotations: map[string]string{},
+	}
+
+	var err error
+
+	if k.configMap {
+		var cm *corev1.ConfigMap
+
+		cm, err = k.client.CoreV1().ConfigMaps(k.namespace).Get(context.TODO(), k.name, metav1.GetOptions{})
+		if err == nil {
+			o.meta = cm.ObjectMeta
+			o.state = []byte(cm.Data[kubernetesStateKey])
+		}
+	} else {
+		var secret *corev1.Secret
+
+		secret, err = k.client.CoreV1().Secrets(k.namespace).Get(context.TODO(), k.name, metav1.GetOptions{})
+		if err == nil {
+			o.meta = secret.ObjectMeta
+			o.state = secret.Data[kubernetesStateKey]
+		}
+	}
+
+	if apierrors.IsNotFound(err) {
+		return o, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("getting %s %s/%s: %w", k.kind(), k.namespace, k.name, err)
+	}
+
+	o.exists = true
+
+	for key, value := range o.meta.Annotations {
+		o.annotations[key] = value
+	}
+
+	return o, nil
+}
+
+// put creates or updates the object storing the state. Update fails if the object has been
+// modified since it has been fetched.
+//
+// Metadata fetched by get is preserved, only name, namespace and annotations are modified.
+func (k *kubernetesStateBackend) put(o *kubernetesStateObject) error {
+	meta := *o.meta.DeepCopy()
+	meta.Name = k.name
+	meta.Namespace = k.namespace
+	meta.Annotations = o.annotations
+
+	var err error
+
+	switch {
+	case k.configMap && o.exists:
+		_, err = k.client.CoreV1().ConfigMaps(k.namespace).Update(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: meta,
+			Data:       map[string]string{kubernetesStateKey: string(o.state)},
+		}, metav1.UpdateOptions{})
+	case k.configMap:
+		_, err = k.client.CoreV1().ConfigMaps(k.namespace).Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: meta,
+			Data:       map[string]string{kubernetesStateKey: string(o.state)},
+		}, metav1.CreateOptions{})
+	case o.exists:
+		_, err = k.client.CoreV1().Secrets(k.namespace).Update(context.TODO(), &corev1.Secret{
+			ObjectMeta: meta,
+			Data:       map[string][]byte{kubernetesStateKey: o.state},
+		}, metav1.UpdateOptions{})
+	default:
+		_, err = k.client.CoreV1().Secrets(k.namespace).Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: meta,
+			Data:       map[string][]byte{kubernetesStateKey: o.state},
+		}, metav1.CreateOptions{})
+	}
+
+	if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("writing %s %s/%s: %w: %v", k.kind(), k.namespace, k.name, errConcurrentModification, err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("writing %s %s/%s: %w", k.kind(), k.namespace, k.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package flexkube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testNamespace = "kube-system"
+	testName      = "flexkube-state"
+)
+
+func testKubernetesStateBackend(t *testing.T, configMap bool, objects ...runtime.Object) *kubernetesStateBackend {
+	t.Helper()
+
+	return &kubernetesStateBackend{
+		client:    fake.NewSimpleClientset(objects...),
+		namespace: testNamespace,
+		name:      testName,
+		configMap: configMap,
+		codec:     testCodec(t, &StateEncryptionConfig{}),
+	}
+}
+
+func testObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      testName,
+		Namespace: testNamespace,
+		Labels: map[string]string{
+			"foo": "bar",
+		},
+		Annotations: map[string]string{
+			"baz": "qux",
+		},
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				APIVersion: "v1",
+				Kind:       "Namespace",
+				Name:       testNamespace,
+				UID:        "foo",
+			},
+		},
+	}
+}
+
+// New() tests.
+func TestKubernetesStateBackendNewNoName(t *testing.T) {
+	t.Parallel()
+
+	c := &KubernetesStateBackendConfig{
+		Namespace: testNamespace,
+	}
+
+	if _, err := c.New(); err == nil {
+		t.Fatalf("Creating Kubernetes state backend without name should fail")
+	}
+}
+
+// Load() and Save() tests.
+func TestKubernetesStateBackend(t *testing.T) {
+	t.Parallel()
+
+	for name, configMap := range map[string]bool{"secret": false, "configmap": true} {
+		configMap := configMap
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testStateBackendRoundTrip(t, testKubernetesStateBackend(t, configMap))
+		})
+	}
+}
+
+func TestKubernetesStateBackendSecretPreservesMetadata(t *testing.T) {
+	t.Parallel()
+
+	backend := testKubernetesStateBackend(t, false, &corev1.Secret{
+		ObjectMeta: testObjectMeta(),
+	})
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	secret, err := backend.client.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Getting secret should succeed, got: %v", err)
+	}
+
+	expectedMeta := testObjectMeta()
+
+	if diff := cmp.Diff(expectedMeta.Labels, secret.Labels); diff != "" {
+		t.Fatalf("Labels should be preserved: %s", diff)
+	}
+
+	if diff := cmp.Diff(expectedMeta.Annotations, secret.Annotations); diff != "" {
+		t.Fatalf("Annotations should be preserved: %s", diff)
+	}
+
+	if diff := cmp.Diff(expectedMeta.OwnerReferences, secret.OwnerReferences); diff != "" {
+		t.Fatalf("Owner references should be preserved: %s", diff)
+	}
+
+	if len(secret.Data[kubernetesStateKey]) == 0 {
+		t.Fatalf("State should be saved in the secret")
+	}
+}
+
+func TestKubernetesStateBackendConfigMapPreservesMetadata(t *testing.T) {
+	t.Parallel()
+
+	backend := testKubernetesStateBackend(t, true, &corev1.ConfigMap{
+		ObjectMeta: testObjectMeta(),
+	})
+
+	if err := backend.Lock(newStateLock()); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	if err := backend.Unlock(); err != nil {
+		t.Fatalf("Unlocking state should succeed, got: %v", err)
+	}
+
+	cm, err := backend.client.CoreV1().ConfigMaps(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Getting config map should succeed, got: %v", err)
+	}
+
+	expectedMeta := testObjectMeta()
+
+	if diff := cmp.Diff(expectedMeta.Labels, cm.Labels); diff != "" {
+		t.Fatalf("Labels should be preserved: %s", diff)
+	}
+
+	if diff := cmp.Diff(expectedMeta.Annotations, cm.Annotations); diff != "" {
+		t.Fatalf("Annotations should be preserved and lock annotation removed: %s", diff)
+	}
+
+	if diff := cmp.Diff(expectedMeta.OwnerReferences, cm.OwnerReferences); diff != "" {
+		t.Fatalf("Owner references should be preserved: %s", diff)
+	}
+
+	if cm.Data[kubernetesStateKey] == "" {
+		t.Fatalf("State should be saved in the config map")
+	}
+}
+
+// Lock(), Unlock() and CurrentLock() tests.
+func TestKubernetesStateBackendLock(t *testing.T) {
+	t.Parallel()
+
+	for name, configMap := range map[string]bool{"secret": false, "configmap": true} {
+		configMap := configMap
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testStateBackendLock(t, testKubernetesStateBackend(t, configMap))
+		})
+	}
+}
+
+func TestKubernetesStateBackendLockKeepsState(t *testing.T) {
+	t.Parallel()
+
+	backend := testKubernetesStateBackend(t, false)
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	if err := backend.Unlock(); err != nil {
+		t.Fatalf("Unlocking state should succeed, got: %v", err)
+	}
+
+	state, err := backend.Load()
+	if err != nil {
+		t.Fatalf("Loading state should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(testResourceState(), state); diff != "" {
+		t.Fatalf("Locking should not modify the state: %s", diff)
+	}
+}
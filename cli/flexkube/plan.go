@@ -0,0 +1,142 @@
+package flexkube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/logrusorgru/aurora"
+	"sigs.k8s.io/yaml"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// Plan represents serializable deployment plan of a single resource, which can be reviewed
+// and then applied.
+type Plan struct {
+	// Resource identifies the resource, for which the plan has been created, e.g.
+	// 'kubelet-pool/controllers'.
+	Resource string `json:"resource"`
+
+	// DesiredStateChecksum is a SHA-256 checksum of the desired state of resource containers.
+	// It ensures, that plan won't be applied if configuration changed since the plan has been
+	// created, even if the list of actions remains the same.
+	DesiredStateChecksum string `json:"desiredStateChecksum"`
+
+	// Actions is a list of actions, which will be executed on resource containers.
+	Actions container.Plan `json:"actions"`
+}
+
+// planResource builds deployment plan for a given resource. CheckCurrentState() must be called
+// on the resource before calling this function.
+func planResource(name string, resource types.Resource) (*Plan, error) {
+	actions, err := resource.Containers().Plan()
+	if err != nil {
+		return nil, fmt.Errorf("planning: %w", err)
+	}
+
+	// JSON is used, as it guarantees stable order of map keys.
+	desiredState, err := json.Marshal(resource.Containers().DesiredState())
+	if err != nil {
+		return nil, fmt.Errorf("serializing desired state: %w", err)
+	}
+
+	checksum := sha256.Sum256(desiredState)
+
+	return &Plan{
+		Resource:             name,
+		DesiredStateChecksum: hex.EncodeToString(checksum[:]),
+		Actions:              actions,
+	}, nil
+}
+
+// planToFile saves given plan to a file in YAML format.
+func planToFile(plan *Plan, path string) error {
+	planRaw, err := yaml.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("serializing plan: %w", err)
+	}
+
+	readWriteOwnerOnly := 0o600
+
+	// #nosec G115 // Constant conversion.
+	if err := os.WriteFile(path, planRaw, fs.FileMode(readWriteOwnerOnly)); err != nil {
+		return fmt.Errorf("writing plan to file %q: %w", path, err)
+	}
+
+	fmt.Printf("Plan saved to %q\n", path)
+
+	return nil
+}
+
+// planFromFile reads plan saved by planToFile.
+func planFromFile(path string) (*Plan, error) {
+	planRaw, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("reading plan file %q: %w", path, err)
+	}
+
+	plan := &Plan{}
+
+	if err := yaml.UnmarshalStrict(planRaw, plan); err != nil {
+		return nil, fmt.Errorf("parsing plan file %q: %w", path, err)
+	}
+
+	return plan, nil
+}
+
+// verifyPlan checks, if approved plan is the same as freshly calculated plan.
+func verifyPlan(approved, current *Plan) error {
+	if approved.Resource != current.Resource {
+		return fmt.Errorf("plan has been created for resource %q, not for %q", approved.Resource, current.Resource)
+	}
+
+	if diff := cmp.Diff(approved.Actions, current.Actions); diff != "" {
+		return fmt.Errorf("planned actions changed since the plan has been created:\n%s", diff)
+	}
+
+	if approved.DesiredStateChecksum != current.DesiredStateChecksum {
+		return fmt.Errorf("configuration changed since the plan has been created")
+	}
+
+	return nil
+}
+
+// colorizeAction returns action name colored depending on how disruptive the action is.
+func colorizeAction(action container.ActionType) string {
+	switch action {
+	case container.ActionCreate, container.ActionStart:
+		return aurora.Green(action).String()
+	case container.ActionRemove, container.ActionRecreate:
+		return aurora.Red(action).String()
+	default:
+		return aurora.Yellow(action).String()
+	}
+}
+
+// formatPlan formats planned actions as colored, human-readable text.
+func formatPlan(actions container.Plan) string {
+	var output strings.Builder
+
+	for _, action := range actions {
+		fmt.Fprintf(&output, "  %s %q", colorizeAction(action.Action), action.Container)
+
+		if action.Host != "" {
+			fmt.Fprintf(&output, " on %s", action.Host)
+		}
+
+		output.WriteString("\n")
+
+		for _, reason := range action.Reasons {
+			fmt.Fprintf(&output, "    - %s\n", reason)
+		}
+	}
+
+	return output.String()
+}
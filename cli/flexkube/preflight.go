@@ -0,0 +1,30 @@
+package flexkube
+
+import (
+	"fmt"
+
+	"github.com/flexkube/libflexkube/pkg/preflight"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// preflight runs pre-flight checks on all hosts used by given resource and prints the report.
+// It returns error, if any of the checks failed, unless SkipPreflight is set.
+//
+// Current state of the resource must be checked before.
+func (r *Resource) preflight(name string, resource types.Resource) error {
+	if r.SkipPreflight {
+		return nil
+	}
+
+	fmt.Printf("Running pre-flight checks for %s\n\n", name)
+
+	report := preflight.Check(resource)
+
+	fmt.Printf("%s\n", report.Text())
+
+	if report.Failed() {
+		return fmt.Errorf("pre-flight checks for %s failed, use --%s flag to deploy anyway", name, SkipPreflightFlag)
+	}
+
+	return nil
+}
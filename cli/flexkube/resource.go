@@ -4,8 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"io/fs"
 	"os"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -82,6 +82,41 @@ type Resource struct {
 	// Noop controls, if deployment should actually be executed. If set to 'true', only the difference between
 	// cluster existing state and desired state will be printed, but the State field won't be modified.
 	Noop bool `json:"noop,omitempty"`
+
+	// PlanOut is a path to the file, where deployment plan will be saved. If set, deployment
+	// won't be executed.
+	PlanOut string `json:"planOut,omitempty"`
+
+	// PlanIn is a path to the file with previously saved deployment plan. If set, deployment
+	// will only be executed if freshly calculated plan is the same as the saved one.
+	PlanIn string `json:"planIn,omitempty"`
+
+	// Parallelism controls, how many containers of a single resource can be deployed at the same time.
+	// It is used for all resources, which do not define their own parallelism.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// StateBackend controls, where the state is stored. If not set, state is stored in
+	// the state.yaml file in the current working directory.
+	//
+	// See StateBackendConfig for available backends.
+	StateBackend *StateBackendConfig `json:"stateBackend,omitempty"`
+
+	// ShowSecrets controls, if sensitive values like private keys and tokens should be included in
+	// printed diffs. By default they are replaced with their checksums.
+	ShowSecrets bool `json:"showSecrets,omitempty"`
+
+	// LockTimeout defines, how long to wait for the state lock, if the state is locked by
+	// someone else. By default, execution fails immediately.
+	//
+	// Example value: '5m'.
+	LockTimeout string `json:"lockTimeout,omitempty"`
+
+	// SkipPreflight controls, if pre-flight checks verifying, that hosts meet the requirements
+	// of the resources, should be skipped before deploying them.
+	SkipPreflight bool `json:"skipPreflight,omitempty"`
+
+	// stateBackend is an initialized state backend used for loading and saving the state.
+	stateBackend StateBackend
 }
 
 // ResourceState represents flexkube CLI state format.
@@ -108,6 +143,16 @@ type ResourceState struct {
 
 // getEtcd returns etcd resource, with state and PKI integration enabled.
 func (r *Resource) getEtcd() (types.Resource, error) {
+	etcdConfig, err := r.etcdConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAndNew(etcdConfig)
+}
+
+// etcdConfig returns etcd configuration, with state and PKI integration enabled.
+func (r *Resource) etcdConfig() (*etcd.Cluster, error) {
 	if r.Etcd == nil {
 		if r.State == nil || r.State.Etcd == nil {
 			return nil, fmt.Errorf("etcd management not enabled in the configuration and state not found")
@@ -125,11 +170,23 @@ func (r *Resource) getEtcd() (types.Resource, error) {
 		r.Etcd.PKI = r.State.PKI
 	}
 
-	return validateAndNew(r.Etcd)
+	r.Etcd.Parallelism = r.parallelism(r.Etcd.Parallelism)
+
+	return r.Etcd, nil
 }
 
 // getControlplane returns controlplane resource, with state and PKI integration enabled.
 func (r *Resource) getControlplane() (types.Resource, error) {
+	controlplaneConfig, err := r.controlplaneConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAndNew(controlplaneConfig)
+}
+
+// controlplaneConfig returns controlplane configuration, with state and PKI integration enabled.
+func (r *Resource) controlplaneConfig() (*controlplane.Controlplane, error) {
 	if r.Controlplane == nil {
 		if r.State == nil || r.State.Controlplane == nil {
 			return nil, fmt.Errorf("controlplane not configured and state not found")
@@ -149,11 +206,23 @@ func (r *Resource) getControlplane() (types.Resource, error) {
 		r.Controlplane.PKI = r.State.PKI
 	}
 
-	return validateAndNew(r.Controlplane)
+	r.Controlplane.Parallelism = r.parallelism(r.Controlplane.Parallelism)
+
+	return r.Controlplane, nil
 }
 
 // getKubeletPool returns requested kubelet pool with state and PKI injected.
 func (r *Resource) getKubeletPool(name string) (types.Resource, error) {
+	pool, err := r.kubeletPoolConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAndNew(pool)
+}
+
+// kubeletPoolConfig returns configuration of requested kubelet pool with state and PKI injected.
+func (r *Resource) kubeletPoolConfig(name string) (*kubelet.Pool, error) {
 	stateFound := r.State != nil && r.State.KubeletPools != nil && r.State.KubeletPools[name] != nil
 	configPool, configFound := r.KubeletPools[name]
 
@@ -176,7 +245,9 @@ func (r *Resource) getKubeletPool(name string) (types.Resource, error) {
 		pool.PKI = r.State.PKI
 	}
 
-	return validateAndNew(pool)
+	pool.Parallelism = r.parallelism(pool.Parallelism)
+
+	return pool, nil
 }
 
 // getPKI returns PKI struct with state loaded on top.
@@ -189,8 +260,6 @@ func (r *Resource) getPKI() (*pki.PKI, error) {
 
 	// If state contains PKI, use it as a base for loading.
 	if r.State != nil && r.State.PKI != nil {
-		fmt.Println("Loading existing PKI state from state.yaml file")
-
 		pki = r.State.PKI
 	}
 
@@ -207,8 +276,18 @@ func (r *Resource) getPKI() (*pki.PKI, error) {
 	return pki, nil
 }
 
-// getAPILoadBalancerPool returns requested kubelet pool with state injected.
+// getAPILoadBalancerPool returns requested API load balancer pool with state injected.
 func (r *Resource) getAPILoadBalancerPool(name string) (types.Resource, error) {
+	pool, err := r.apiLoadBalancerPoolConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAndNew(pool)
+}
+
+// apiLoadBalancerPoolConfig returns configuration of requested API load balancer pool with state injected.
+func (r *Resource) apiLoadBalancerPoolConfig(name string) (*apiloadbalancer.APILoadBalancers, error) {
 	stateFound := r.State != nil && r.State.APILoadBalancerPools != nil && r.State.APILoadBalancerPools[name] != nil
 	configPool, configFound := r.APILoadBalancerPools[name]
 
@@ -226,11 +305,23 @@ func (r *Resource) getAPILoadBalancerPool(name string) (types.Resource, error) {
 		pool.State = *r.State.APILoadBalancerPools[name]
 	}
 
-	return validateAndNew(pool)
+	pool.Parallelism = r.parallelism(pool.Parallelism)
+
+	return pool, nil
 }
 
 // getContainers returns requested containers group with state.
 func (r *Resource) getContainers(name string) (types.Resource, error) {
+	containers, err := r.containersConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAndNew(containers)
+}
+
+// containersConfig returns configuration of requested containers group with state.
+func (r *Resource) containersConfig(name string) (*resource.Containers, error) {
 	stateFound := r.State != nil && r.State.Containers != nil && r.State.Containers[name] != nil
 	config, configFound := r.Containers[name]
 
@@ -252,7 +343,99 @@ func (r *Resource) getContainers(name string) (types.Resource, error) {
 		containers.State = *r.State.Containers[name]
 	}
 
-	return validateAndNew(containers)
+	containers.Parallelism = r.parallelism(containers.Parallelism)
+
+	return containers, nil
+}
+
+// poolNames returns sorted names of pools found in the configuration or in the state.
+func poolNames(configNames, stateNames []string) []string {
+	names := map[string]struct{}{}
+
+	for _, name := range append(configNames, stateNames...) {
+		names[name] = struct{}{}
+	}
+
+	sortedNames := []string{}
+
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+
+	sort.Strings(sortedNames)
+
+	return sortedNames
+}
+
+// groupNames returns names of container groups stored in the given map.
+func groupNames(groups map[string]*container.ContainersState) []string {
+	names := []string{}
+
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// kubeletPoolNames returns names of given kubelet pools.
+func kubeletPoolNames(pools map[string]*kubelet.Pool) []string {
+	names := []string{}
+
+	for name := range pools {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// apiLoadBalancerPoolNames returns names of given API Load Balancer pools.
+func apiLoadBalancerPoolNames(pools map[string]*apiloadbalancer.APILoadBalancers) []string {
+	names := []string{}
+
+	for name := range pools {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// allKubeletPoolNames returns names of all kubelet pools found in the configuration or in the state.
+func (r *Resource) allKubeletPoolNames() []string {
+	if r.State == nil {
+		return poolNames(kubeletPoolNames(r.KubeletPools), nil)
+	}
+
+	return poolNames(kubeletPoolNames(r.KubeletPools), groupNames(r.State.KubeletPools))
+}
+
+// allAPILoadBalancerPoolNames returns names of all API Load Balancer pools found in the configuration
+// or in the state.
+func (r *Resource) allAPILoadBalancerPoolNames() []string {
+	if r.State == nil {
+		return poolNames(apiLoadBalancerPoolNames(r.APILoadBalancerPools), nil)
+	}
+
+	return poolNames(apiLoadBalancerPoolNames(r.APILoadBalancerPools), groupNames(r.State.APILoadBalancerPools))
+}
+
+// allContainersNames returns names of all container groups found in the configuration or in the state.
+func (r *Resource) allContainersNames() []string {
+	if r.State == nil {
+		return poolNames(groupNames(r.Containers), nil)
+	}
+
+	return poolNames(groupNames(r.Containers), groupNames(r.State.Containers))
+}
+
+// parallelism returns parallelism, which should be used for the resource. Parallelism
+// configured on the resource level takes precedence over the global one.
+func (r *Resource) parallelism(resourceParallelism int) int {
+	if resourceParallelism != 0 {
+		return resourceParallelism
+	}
+
+	return r.Parallelism
 }
 
 // validateAndNew validates and creates new resource from resource config.
@@ -269,7 +452,9 @@ func validateAndNew(rc types.ResourceConfig) (types.Resource, error) {
 	return r, nil
 }
 
-func checkState(resource types.Resource) (string, error) {
+// checkState checks current state of given resource and prints the difference between current
+// and desired state. Sensitive values are redacted, unless ShowSecrets is set.
+func (r *Resource) checkState(resource types.Resource) (string, error) {
 	// Check current state.
 	fmt.Println("Checking current state")
 
@@ -280,7 +465,10 @@ func checkState(resource types.Resource) (string, error) {
 	// Calculate and print diff.
 	fmt.Printf("Calculating diff...\n\n")
 
-	diff := cmp.Diff(resource.Containers().ToExported().PreviousState, resource.Containers().DesiredState())
+	previousState := resource.Containers().ToExported().PreviousState
+	desiredState := resource.Containers().DesiredState()
+
+	diff := cmp.Diff(previousState, desiredState)
 
 	if diff == "" {
 		fmt.Println("No changes required")
@@ -288,40 +476,126 @@ func checkState(resource types.Resource) (string, error) {
 		return diff, nil
 	}
 
-	fmt.Printf("Following changes required:\n\n%s\n\n", util.ColorizeDiff(diff))
+	printedDiff := diff
+
+	if !r.ShowSecrets {
+		printedDiff = cmp.Diff(previousState, desiredState, util.RedactSensitive())
+	}
+
+	fmt.Printf("Following changes required:\n\n%s\n\n", util.ColorizeDiff(printedDiff))
+
+	actions, err := resource.Containers().Plan()
+	if err != nil {
+		return "", fmt.Errorf("planning: %w", err)
+	}
+
+	if len(actions) > 0 {
+		fmt.Printf("Following actions will be executed:\n\n%s\n", formatPlan(actions))
+	}
 
 	return diff, nil
 }
 
-// execute checks current state of the deployment and triggers the deployment if needed.
-func (r *Resource) execute(resource types.Resource, saveStateF func(types.Resource)) error {
-	diff, err := checkState(resource)
+// execute creates the deployment while holding the state lock, checks current state of the deployment
+// and triggers the deployment if needed.
+//
+// If PlanOut or PlanIn are set, deployment plan is saved or verified against the saved one.
+func (r *Resource) execute(newDeployment func() (*deployment, error)) error {
+	return r.withStateLock(func() error {
+		d, err := newDeployment()
+		if err != nil {
+			return err
+		}
+
+		return r.executeDeployment(d.name, d.resource, d.saveStateF)
+	})
+}
+
+// executeDeployment checks current state of the deployment and triggers the deployment if needed.
+func (r *Resource) executeDeployment(name string, resource types.Resource, saveStateF func(types.Resource)) error {
+	diff, err := r.checkState(resource)
 	if err != nil {
 		return fmt.Errorf("checking current state: %w", err)
 	}
 
-	if r.Noop || diff == "" {
+	if r.PlanOut == "" && r.PlanIn == "" {
+		if r.Noop || diff == "" {
+			return nil
+		}
+
+		if err := r.preflight(name, resource); err != nil {
+			return err
+		}
+
+		return r.deploy(resource, saveStateF)
+	}
+
+	plan, err := planResource(name, resource)
+	if err != nil {
+		return fmt.Errorf("calculating deployment plan: %w", err)
+	}
+
+	if r.PlanOut != "" {
+		return planToFile(plan, r.PlanOut)
+	}
+
+	approvedPlan, err := planFromFile(r.PlanIn)
+	if err != nil {
+		return fmt.Errorf("loading deployment plan: %w", err)
+	}
+
+	if err := verifyPlan(approvedPlan, plan); err != nil {
+		return fmt.Errorf("verifying deployment plan: %w", err)
+	}
+
+	if diff == "" {
 		return nil
 	}
 
+	if err := r.preflight(name, resource); err != nil {
+		return err
+	}
+
+	// Plan has been already approved, so there is no need to ask for confirmation.
+	r.Confirmed = true
+
 	return r.deploy(resource, saveStateF)
 }
 
 // deploy confirms the deployment with the user and persists the state after the deployment.
 func (r *Resource) deploy(resource types.Resource, saveStateF func(types.Resource)) error {
-	if !r.Confirmed {
-		confirmed, err := askForConfirmation()
-		if err != nil {
-			return fmt.Errorf("asking for confirmation: %w", err)
-		}
+	confirmed, err := r.confirm()
+	if err != nil {
+		return err
+	}
 
-		if !confirmed {
-			fmt.Println("Aborted")
+	if !confirmed {
+		return nil
+	}
 
-			return nil
-		}
+	return r.deployAndSave(resource, saveStateF)
+}
+
+// confirm asks user for confirmation, unless the deployment has been already confirmed.
+func (r *Resource) confirm() (bool, error) {
+	if r.Confirmed {
+		return true, nil
 	}
 
+	confirmed, err := askForConfirmation()
+	if err != nil {
+		return false, fmt.Errorf("asking for confirmation: %w", err)
+	}
+
+	if !confirmed {
+		fmt.Println("Aborted")
+	}
+
+	return confirmed, nil
+}
+
+// deployAndSave deploys given resource and persists the state after the deployment.
+func (r *Resource) deployAndSave(resource types.Resource, saveStateF func(types.Resource)) error {
 	deployErr := resource.Deploy()
 
 	if r.State == nil {
@@ -377,8 +651,31 @@ func readYamlFile(file string) ([]byte, error) {
 	return configRaw, nil
 }
 
-// LoadResourceFromFiles loads Resource struct from config.yaml and state.yaml files.
+// LoadResourceFromFiles loads Resource struct from config.yaml file and the state from
+// configured state backend.
 func LoadResourceFromFiles() (*Resource, error) {
+	return LoadResource(nil)
+}
+
+// LoadResource loads Resource struct from config.yaml file and the state using given state
+// backend. If no state backend is given, state backend from the configuration is used and if
+// it is not configured, state is loaded from the state.yaml file.
+func LoadResource(stateBackendConfig *StateBackendConfig) (*Resource, error) {
+	resource, err := loadConfig(stateBackendConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resource.loadState(); err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// loadConfig loads Resource struct from config.yaml file without loading the state. If state
+// backend is given, it overrides state backend from the configuration.
+func loadConfig(stateBackendConfig *StateBackendConfig) (*Resource, error) {
 	resource := &Resource{}
 
 	configRaw, err := readYamlFile("config.yaml")
@@ -386,42 +683,74 @@ func LoadResourceFromFiles() (*Resource, error) {
 		return nil, fmt.Errorf("reading config.yaml file: %w", err)
 	}
 
-	stateRaw, err := readYamlFile("state.yaml")
-	if err != nil {
-		return nil, fmt.Errorf("reading state.yaml file: %w", err)
+	if err := yaml.Unmarshal(configRaw, resource); err != nil {
+		return nil, fmt.Errorf("parsing config.yaml file: %w", err)
 	}
 
-	if err := yaml.Unmarshal([]byte(string(configRaw)+string(stateRaw)), resource); err != nil {
-		return nil, fmt.Errorf("parsing files: %w", err)
+	if stateBackendConfig != nil {
+		if resource.StateBackend != nil && stateBackendConfig.Encryption == nil {
+			stateBackendConfig.Encryption = resource.StateBackend.Encryption
+		}
+
+		resource.StateBackend = stateBackendConfig
 	}
 
 	return resource, nil
 }
 
-// StateToFile saves resource state into state.yaml file.
-func (r *Resource) StateToFile(actionErr error) error {
-	rs := &Resource{
-		State: r.State,
+// loadState loads the state using configured state backend. If the state does not exist,
+// existing state is kept.
+func (r *Resource) loadState() error {
+	backend, err := r.getStateBackend()
+	if err != nil {
+		return fmt.Errorf("initializing state backend: %w", err)
 	}
 
-	stateRaw, err := yaml.Marshal(rs)
+	state, err := backend.Load()
 	if err != nil {
-		return fmt.Errorf("serializing state: %w", err)
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	if state != nil {
+		r.State = state
+	}
+
+	return nil
+}
+
+// getStateBackend returns initialized state backend.
+func (r *Resource) getStateBackend() (StateBackend, error) {
+	if r.stateBackend != nil {
+		return r.stateBackend, nil
 	}
 
-	if string(stateRaw) == "{}\n" {
-		stateRaw = []byte{}
+	config := r.StateBackend
+	if config == nil {
+		config = &StateBackendConfig{}
 	}
 
-	readWriteOwnerOnly := 0o600
+	backend, err := config.New()
+	if err != nil {
+		return nil, err
+	}
+
+	r.stateBackend = backend
+
+	return backend, nil
+}
 
-	// #nosec G115 // Constant conversion.
-	if err := os.WriteFile("state.yaml", stateRaw, fs.FileMode(readWriteOwnerOnly)); err != nil {
+// StateToFile saves resource state using configured state backend. The name is kept for
+// compatibility, as by default state is saved into state.yaml file.
+//
+// If given action error is not nil, it is returned wrapped, after an attempt to save the state.
+func (r *Resource) StateToFile(actionErr error) error {
+	err := r.saveState()
+	if err != nil {
 		if actionErr == nil {
-			return fmt.Errorf("writing new state to file: %w", err)
+			return fmt.Errorf("saving new state: %w", err)
 		}
 
-		fmt.Println("Failed to write state.yaml file: %w", err)
+		fmt.Printf("Failed to save state: %v\n", err)
 	}
 
 	if actionErr != nil {
@@ -433,6 +762,16 @@ func (r *Resource) StateToFile(actionErr error) error {
 	return nil
 }
 
+// saveState saves the state using configured state backend.
+func (r *Resource) saveState() error {
+	backend, err := r.getStateBackend()
+	if err != nil {
+		return fmt.Errorf("initializing state backend: %w", err)
+	}
+
+	return backend.Save(r.State)
+}
+
 // validateKubeconfigPKI validates if required fields are populated in PKI field
 // to generate admin kubeconfig file.
 func (r *Resource) validateKubeconfigPKI() error {
@@ -497,8 +836,8 @@ func (r *Resource) Kubeconfig() (string, error) {
 
 	clientConfig := &client.Config{
 		Server:            fmt.Sprintf("%s:%d", r.Controlplane.APIServerAddress, r.Controlplane.APIServerPort),
-		CACertificate:     r.State.PKI.Kubernetes.CA.X509Certificate,
-		ClientCertificate: r.State.PKI.Kubernetes.AdminCertificate.X509Certificate,
+		CACertificate:     r.State.PKI.Kubernetes.CA.TrustBundle(),
+		ClientCertificate: r.State.PKI.Kubernetes.AdminCertificate.FullChain(),
 		ClientKey:         r.State.PKI.Kubernetes.AdminCertificate.PrivateKey,
 	}
 
@@ -510,11 +849,23 @@ func (r *Resource) Kubeconfig() (string, error) {
 	return k, nil
 }
 
-// RunAPILoadBalancerPool deploys given API Load Balancer pool.
-func (r *Resource) RunAPILoadBalancerPool(name string) error {
+// deployment is a resource, which can be deployed, together with a function persisting its state.
+type deployment struct {
+	// name identifies the resource, e.g. 'kubelet-pool/controllers'.
+	name string
+
+	// resource is a resource to deploy.
+	resource types.Resource
+
+	// saveStateF stores the resource state in the Resource state.
+	saveStateF func(types.Resource)
+}
+
+// apiLoadBalancerPoolDeployment returns deployment of given API Load Balancer pool.
+func (r *Resource) apiLoadBalancerPoolDeployment(name string) (*deployment, error) {
 	pool, err := r.getAPILoadBalancerPool(name)
 	if err != nil {
-		return fmt.Errorf("getting API Load Balancer pool %q from configuration: %w", name, err)
+		return nil, fmt.Errorf("getting API Load Balancer pool %q from configuration: %w", name, err)
 	}
 
 	saveStateF := func(types.Resource) {
@@ -525,42 +876,71 @@ func (r *Resource) RunAPILoadBalancerPool(name string) error {
 		r.State.APILoadBalancerPools[name] = &pool.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(pool, saveStateF)
+	return &deployment{
+		name:       fmt.Sprintf("apiloadbalancer-pool/%s", name),
+		resource:   pool,
+		saveStateF: saveStateF,
+	}, nil
 }
 
-// RunControlplane deploys configured static controlplane.
-func (r *Resource) RunControlplane() error {
+// RunAPILoadBalancerPool deploys given API Load Balancer pool.
+func (r *Resource) RunAPILoadBalancerPool(name string) error {
+	return r.execute(func() (*deployment, error) {
+		return r.apiLoadBalancerPoolDeployment(name)
+	})
+}
+
+// controlplaneDeployment returns deployment of configured static controlplane.
+func (r *Resource) controlplaneDeployment() (*deployment, error) {
 	controlplaneResource, err := r.getControlplane()
 	if err != nil {
-		return fmt.Errorf("getting controlplane from the configuration: %w", err)
+		return nil, fmt.Errorf("getting controlplane from the configuration: %w", err)
 	}
 
 	saveStateF := func(types.Resource) {
 		r.State.Controlplane = &controlplaneResource.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(controlplaneResource, saveStateF)
+	return &deployment{
+		name:       "controlplane",
+		resource:   controlplaneResource,
+		saveStateF: saveStateF,
+	}, nil
 }
 
-// RunEtcd deploys configured etcd cluster.
-func (r *Resource) RunEtcd() error {
+// RunControlplane deploys configured static controlplane.
+func (r *Resource) RunControlplane() error {
+	return r.execute(r.controlplaneDeployment)
+}
+
+// etcdDeployment returns deployment of configured etcd cluster.
+func (r *Resource) etcdDeployment() (*deployment, error) {
 	etcdResource, err := r.getEtcd()
 	if err != nil {
-		return fmt.Errorf("getting etcd from the configuration: %w", err)
+		return nil, fmt.Errorf("getting etcd from the configuration: %w", err)
 	}
 
 	saveStateF := func(types.Resource) {
 		r.State.Etcd = &etcdResource.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(etcdResource, saveStateF)
+	return &deployment{
+		name:       "etcd",
+		resource:   etcdResource,
+		saveStateF: saveStateF,
+	}, nil
 }
 
-// RunKubeletPool deploys given kubelet pool.
-func (r *Resource) RunKubeletPool(name string) error {
+// RunEtcd deploys configured etcd cluster.
+func (r *Resource) RunEtcd() error {
+	return r.execute(r.etcdDeployment)
+}
+
+// kubeletPoolDeployment returns deployment of given kubelet pool.
+func (r *Resource) kubeletPoolDeployment(name string) (*deployment, error) {
 	kubeletPool, err := r.getKubeletPool(name)
 	if err != nil {
-		return fmt.Errorf("getting kubelet pool %q from configuration: %w", name, err)
+		return nil, fmt.Errorf("getting kubelet pool %q from configuration: %w", name, err)
 	}
 
 	saveStateF := func(types.Resource) {
@@ -571,11 +951,31 @@ func (r *Resource) RunKubeletPool(name string) error {
 		r.State.KubeletPools[name] = &kubeletPool.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(kubeletPool, saveStateF)
+	return &deployment{
+		name:       fmt.Sprintf("kubelet-pool/%s", name),
+		resource:   kubeletPool,
+		saveStateF: saveStateF,
+	}, nil
+}
+
+// RunKubeletPool deploys given kubelet pool.
+func (r *Resource) RunKubeletPool(name string) error {
+	return r.execute(func() (*deployment, error) {
+		return r.kubeletPoolDeployment(name)
+	})
 }
 
 // RunPKI generates configured PKI.
 func (r *Resource) RunPKI() error {
+	return r.withStateLock(r.runPKI)
+}
+
+// runPKI generates configured PKI and persists it in the state.
+func (r *Resource) runPKI() error {
+	if r.State != nil && r.State.PKI != nil {
+		fmt.Println("Loading existing PKI state")
+	}
+
 	pki, err := r.getPKI()
 	if err != nil {
 		return fmt.Errorf("loading PKI configuration: %w", err)
@@ -594,11 +994,11 @@ func (r *Resource) RunPKI() error {
 	return r.StateToFile(genErr)
 }
 
-// RunContainers deploys given containers group.
-func (r *Resource) RunContainers(name string) error {
+// containersDeployment returns deployment of given containers group.
+func (r *Resource) containersDeployment(name string) (*deployment, error) {
 	containersResource, err := r.getContainers(name)
 	if err != nil {
-		return fmt.Errorf("getting containers group %q from configuration: %w", name, err)
+		return nil, fmt.Errorf("getting containers group %q from configuration: %w", name, err)
 	}
 
 	saveStateF := func(types.Resource) {
@@ -609,7 +1009,18 @@ func (r *Resource) RunContainers(name string) error {
 		r.State.Containers[name] = &containersResource.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(containersResource, saveStateF)
+	return &deployment{
+		name:       fmt.Sprintf("containers/%s", name),
+		resource:   containersResource,
+		saveStateF: saveStateF,
+	}, nil
+}
+
+// RunContainers deploys given containers group.
+func (r *Resource) RunContainers(name string) error {
+	return r.execute(func() (*deployment, error) {
+		return r.containersDeployment(name)
+	})
 }
 
 // Template executes given Go template using configuration and state.
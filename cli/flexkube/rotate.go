@@ -0,0 +1,243 @@
+package flexkube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// RotatePKI renews PKI certificates, which expire within their renew threshold and rolls out
+// etcd cluster, controlplane and kubelet pools, which configuration files embed them.
+//
+// Containers with updated configuration files are restarted, so they pick up renewed certificates.
+func (r *Resource) RotatePKI() error {
+	if r.PlanOut != "" || r.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are only supported when deploying single resource",
+			PlanOutFlag, PlanInFlag)
+	}
+
+	return r.withStateLock(r.rotatePKI)
+}
+
+// RotateCA advances staged rotation of CA certificates and rolls out etcd cluster, controlplane
+// and kubelet pools, like RotatePKI.
+//
+// If CA rotation is not in progress, it is started for CA certificates with given names, e.g.
+// 'kubernetes/ca'. New CA certificates are generated and trusted together with old ones.
+// When called again, all certificates are re-issued using new CA certificates. When called
+// for the third time, old CA certificates are removed from trust bundles.
+func (r *Resource) RotateCA(names []string) error {
+	if r.PlanOut != "" || r.PlanIn != "" {
+		return fmt.Errorf("--%s and --%s flags are only supported when deploying single resource",
+			PlanOutFlag, PlanInFlag)
+	}
+
+	return r.withStateLock(func() error {
+		return r.rollOutPKI(advanceCARotation(names))
+	})
+}
+
+// advanceCARotation returns function, which moves CA rotation of given PKI to the next phase.
+func advanceCARotation(names []string) func(*pki.PKI) error {
+	return func(p *pki.PKI) error {
+		if err := caRotationStep(p, names); err != nil {
+			return fmt.Errorf("advancing CA rotation: %w", err)
+		}
+
+		if p.CARotation == nil {
+			fmt.Printf("CA rotation will be finished, old CA certificates will no longer be trusted\n\n")
+
+			return nil
+		}
+
+		fmt.Printf("CA rotation of %s will enter phase %q\n\n",
+			strings.Join(p.CARotation.CertificateAuthorities, ", "), p.CARotation.Phase)
+
+		return nil
+	}
+}
+
+// caRotationStep starts CA rotation of given CA certificates or moves CA rotation in progress
+// to the next phase.
+func caRotationStep(p *pki.PKI, names []string) error {
+	if p.CARotation == nil {
+		return p.StartCARotation(names...)
+	}
+
+	if len(names) > 0 {
+		return fmt.Errorf("CA rotation is already in progress, CA certificates to rotate can't be given")
+	}
+
+	switch p.CARotation.Phase {
+	case pki.CARotationPhaseTrustNewCA:
+		return p.ReissueCertificates()
+	case pki.CARotationPhaseReissued:
+		return p.FinishCARotation()
+	default:
+		return fmt.Errorf("unknown CA rotation phase %q", p.CARotation.Phase)
+	}
+}
+
+// rotatePKI renews expiring certificates and rolls out resources using them. It must be called
+// while holding the state lock.
+func (r *Resource) rotatePKI() error {
+	return r.rollOutPKI((*pki.PKI).Generate)
+}
+
+// rollOutPKI generates PKI using given function, prints renewed certificates and rolls out
+// resources using them. It must be called while holding the state lock.
+func (r *Resource) rollOutPKI(generate func(*pki.PKI) error) error {
+	if r.PKI == nil {
+		return fmt.Errorf("PKI management not enabled in the configuration")
+	}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	previousCertificates := x509Certificates(r.State.PKI)
+
+	pkiChanged, err := r.generatePKI(generate)
+	if err != nil {
+		return fmt.Errorf("generating PKI: %w", err)
+	}
+
+	printRenewedCertificates(previousCertificates, x509Certificates(r.State.PKI))
+
+	deployments, err := r.rotationDeployments()
+	if err != nil {
+		return fmt.Errorf("getting resources to roll out: %w", err)
+	}
+
+	changed, err := r.planDeployments(deployments)
+	if err != nil {
+		return fmt.Errorf("planning deployments: %w", err)
+	}
+
+	if err := printReconfiguredContainers(changed); err != nil {
+		return fmt.Errorf("printing containers to reconfigure: %w", err)
+	}
+
+	return r.deployChanges(pkiChanged, changed)
+}
+
+// x509Certificates returns content of all generated certificates in given PKI by their name.
+func x509Certificates(p *pki.PKI) map[string]types.Certificate {
+	certificates := map[string]types.Certificate{}
+
+	if p == nil {
+		return certificates
+	}
+
+	for name, cert := range p.Certificates() {
+		certificates[name] = cert.X509Certificate
+	}
+
+	return certificates
+}
+
+// printRenewedCertificates prints names of certificates, which content differs between
+// previous and current certificates.
+func printRenewedCertificates(previous, current map[string]types.Certificate) {
+	renewed := []string{}
+
+	for name, cert := range current {
+		if previousCert, ok := previous[name]; ok && previousCert != cert {
+			renewed = append(renewed, name)
+		}
+	}
+
+	if len(renewed) == 0 {
+		fmt.Printf("No certificates require renewal\n\n")
+
+		return
+	}
+
+	sort.Strings(renewed)
+
+	fmt.Printf("Following certificates will be renewed:\n\n  %s\n\n", strings.Join(renewed, "\n  "))
+}
+
+// rotationDeployments returns deployments of etcd cluster, controlplane and kubelet pools, which
+// restart their containers after updating configuration files.
+func (r *Resource) rotationDeployments() ([]*deployment, error) {
+	deployments := []*deployment{}
+
+	if r.Etcd != nil {
+		r.Etcd.UpdateStrategy = restartOnConfigChange(r.Etcd.UpdateStrategy)
+
+		d, err := r.etcdDeployment()
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	if r.Controlplane != nil {
+		r.Controlplane.UpdateStrategy = restartOnConfigChange(r.Controlplane.UpdateStrategy)
+
+		d, err := r.controlplaneDeployment()
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	for _, name := range poolNames(kubeletPoolNames(r.KubeletPools), nil) {
+		r.KubeletPools[name].UpdateStrategy = restartOnConfigChange(r.KubeletPools[name].UpdateStrategy)
+
+		d, err := r.kubeletPoolDeployment(name)
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, d)
+	}
+
+	return deployments, nil
+}
+
+// restartOnConfigChange returns copy of given update strategy with restarting containers
+// on configuration change enabled.
+func restartOnConfigChange(updateStrategy *container.UpdateStrategy) *container.UpdateStrategy {
+	u := container.UpdateStrategy{}
+
+	if updateStrategy != nil {
+		u = *updateStrategy
+	}
+
+	u.RestartOnConfigChange = true
+
+	return &u
+}
+
+// printReconfiguredContainers prints containers of given deployments, which will be modified
+// together with planned actions.
+func printReconfiguredContainers(deployments []*deployment) error {
+	lines := []string{}
+
+	for _, d := range deployments {
+		plan, err := d.resource.Containers().Plan()
+		if err != nil {
+			return fmt.Errorf("planning %s: %w", d.name, err)
+		}
+
+		for _, action := range plan {
+			lines = append(lines, fmt.Sprintf("%s: %s (%s)", d.name, action.Container, action.Action))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Following containers must be reconfigured:\n\n  %s\n\n", strings.Join(lines, "\n  "))
+
+	return nil
+}
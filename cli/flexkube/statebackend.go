@@ -0,0 +1,179 @@
+package flexkube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// DefaultStateFile is a path to the file, where state is stored, when no state backend
+	// is configured.
+	DefaultStateFile = "state.yaml"
+
+	// stateFilePermissions are permissions used for files storing the state, as state
+	// contains private keys.
+	stateFilePermissions = 0o600
+
+	// stateDirectoryPermissions are permissions used for directories storing the state.
+	stateDirectoryPermissions = 0o700
+)
+
+// StateBackend persists state of all resources, so it does not change on consecutive runs.
+type StateBackend interface {
+	// Load reads the state. If the state does not exist yet, nil is returned.
+	Load() (*ResourceState, error)
+
+	// Save persists given state.
+	Save(state *ResourceState) error
+
+	// Lock acquires exclusive lock on the state, recording given lock information. If the state
+	// is already locked, *StateLockedError is returned.
+	Lock(lock *StateLock) error
+
+	// Unlock releases the lock on the state, regardless of who holds it.
+	Unlock() error
+
+	// CurrentLock returns information about currently held lock. If the state is not locked,
+	// nil is returned.
+	CurrentLock() (*StateLock, error)
+}
+
+// StateBackendConfig selects and configures the state backend. Only one backend can be configured.
+// If no backend is configured, state is stored in the state.yaml file.
+type StateBackendConfig struct {
+	// File stores the state in a single YAML file.
+	File *FileStateBackendConfig `json:"file,omitempty"`
+
+	// Directory stores the state of each resource in a separate YAML file in a given directory.
+	Directory *DirectoryStateBackendConfig `json:"directory,omitempty"`
+
+	// Kubernetes stores the state in a Secret or ConfigMap in an existing Kubernetes cluster.
+	Kubernetes *KubernetesStateBackendConfig `json:"kubernetes,omitempty"`
+
+	// Encryption configures encryption of sensitive values in the state. It can be used
+	// with any backend.
+	//
+	// See StateEncryptionConfig for available fields.
+	Encryption *StateEncryptionConfig `json:"encryption,omitempty"`
+}
+
+// Validate validates state backend configuration.
+func (c *StateBackendConfig) Validate() error {
+	var errors util.ValidateErrors
+
+	configured := 0
+
+	if c.File != nil {
+		configured++
+
+		if err := c.File.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating file state backend: %w", err))
+		}
+	}
+
+	if c.Directory != nil {
+		configured++
+
+		if err := c.Directory.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating directory state backend: %w", err))
+		}
+	}
+
+	if c.Kubernetes != nil {
+		configured++
+
+		if err := c.Kubernetes.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating Kubernetes state backend: %w", err))
+		}
+	}
+
+	if c.Encryption != nil {
+		if err := c.Encryption.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating state encryption: %w", err))
+		}
+	}
+
+	if configured > 1 {
+		errors = append(errors, fmt.Errorf("only one state backend can be configured, got %d", configured))
+	}
+
+	return errors.Return()
+}
+
+// New validates state backend configuration and returns configured state backend.
+func (c *StateBackendConfig) New() (StateBackend, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating state backend configuration: %w", err)
+	}
+
+	codec, err := newStateCodec(c.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.File != nil:
+		return c.File.new(codec), nil
+	case c.Directory != nil:
+		return c.Directory.new(codec), nil
+	case c.Kubernetes != nil:
+		return c.Kubernetes.new(codec)
+	default:
+		file := &FileStateBackendConfig{
+			Path: DefaultStateFile,
+		}
+
+		return file.new(codec), nil
+	}
+}
+
+// ParseStateBackend parses state backend configuration from a string in one of the following formats:
+//
+// - 'file:<path>' - state is stored in a given file.
+//
+// - 'directory:<path>' - state of each resource is stored in a separate file in a given directory.
+//
+// - 'secret:<namespace>/<name>' - state is stored in a given Kubernetes Secret.
+//
+// - 'configmap:<namespace>/<name>' - state is stored in a given Kubernetes ConfigMap.
+//
+// Kubernetes backends use kubeconfig from KUBECONFIG environment variable or from the default location.
+func ParseStateBackend(spec string) (*StateBackendConfig, error) {
+	backendType, value, found := strings.Cut(spec, ":")
+	if !found || value == "" {
+		return nil, fmt.Errorf("state backend must be in format '<type>:<value>', got %q", spec)
+	}
+
+	switch backendType {
+	case "file":
+		return &StateBackendConfig{
+			File: &FileStateBackendConfig{
+				Path: value,
+			},
+		}, nil
+	case "directory":
+		return &StateBackendConfig{
+			Directory: &DirectoryStateBackendConfig{
+				Path: value,
+			},
+		}, nil
+	case "secret", "configmap":
+		namespace, name, found := strings.Cut(value, "/")
+		if !found {
+			return nil, fmt.Errorf("%s state backend must be in format '%s:<namespace>/<name>', got %q",
+				backendType, backendType, spec)
+		}
+
+		return &StateBackendConfig{
+			Kubernetes: &KubernetesStateBackendConfig{
+				Namespace: namespace,
+				Name:      name,
+				ConfigMap: backendType == "configmap",
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported state backend type %q", backendType)
+	}
+}
@@ -0,0 +1,147 @@
+package flexkube
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+func testContainersState(image string) *container.ContainersState {
+	return &container.ContainersState{
+		"foo": &container.HostConfiguredContainer{
+			Container: container.Container{
+				Config: types.ContainerConfig{
+					Name:  "foo",
+					Image: image,
+				},
+			},
+		},
+	}
+}
+
+func testResourceState() *ResourceState {
+	return &ResourceState{
+		Etcd: testContainersState("etcd"),
+		KubeletPools: map[string]*container.ContainersState{
+			"workers": testContainersState("kubelet"),
+		},
+		Containers: map[string]*container.ContainersState{
+			"foo": testContainersState("foo"),
+			"bar": testContainersState("bar"),
+		},
+	}
+}
+
+// testStateBackendRoundTrip checks, that state saved using given backend is loaded unmodified and
+// that removed groups are removed from the state.
+func testStateBackendRoundTrip(t *testing.T, backend StateBackend) {
+	t.Helper()
+
+	state, err := backend.Load()
+	if err != nil {
+		t.Fatalf("Loading not existing state should succeed, got: %v", err)
+	}
+
+	if state != nil && !cmp.Equal(state, &ResourceState{}) {
+		t.Fatalf("Not existing state should be empty, got: %+v", state)
+	}
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	state, err = backend.Load()
+	if err != nil {
+		t.Fatalf("Loading state should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(testResourceState(), state); diff != "" {
+		t.Fatalf("Loaded state differs from saved state: %s", diff)
+	}
+
+	updatedState := testResourceState()
+	delete(updatedState.Containers, "bar")
+	updatedState.Etcd = nil
+
+	if err := backend.Save(updatedState); err != nil {
+		t.Fatalf("Saving updated state should succeed, got: %v", err)
+	}
+
+	state, err = backend.Load()
+	if err != nil {
+		t.Fatalf("Loading updated state should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(updatedState, state); diff != "" {
+		t.Fatalf("Loaded state differs from updated state: %s", diff)
+	}
+}
+
+// Validate() tests.
+func TestStateBackendConfigValidateMultipleBackends(t *testing.T) {
+	t.Parallel()
+
+	c := &StateBackendConfig{
+		File: &FileStateBackendConfig{
+			Path: "state.yaml",
+		},
+		Directory: &DirectoryStateBackendConfig{
+			Path: "state",
+		},
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Configuring multiple state backends should fail")
+	}
+}
+
+// ParseStateBackend() tests.
+func TestParseStateBackend(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]*StateBackendConfig{
+		"file:foo.yaml": {
+			File: &FileStateBackendConfig{Path: "foo.yaml"},
+		},
+		"directory:foo": {
+			Directory: &DirectoryStateBackendConfig{Path: "foo"},
+		},
+		"secret:foo/bar": {
+			Kubernetes: &KubernetesStateBackendConfig{Namespace: "foo", Name: "bar"},
+		},
+		"configmap:foo/bar": {
+			Kubernetes: &KubernetesStateBackendConfig{Namespace: "foo", Name: "bar", ConfigMap: true},
+		},
+	}
+
+	for spec, expected := range cases {
+		spec := spec
+		expected := expected
+
+		t.Run(spec, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := ParseStateBackend(spec)
+			if err != nil {
+				t.Fatalf("Parsing valid state backend should succeed, got: %v", err)
+			}
+
+			if diff := cmp.Diff(expected, c); diff != "" {
+				t.Fatalf("Unexpected state backend configuration: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseStateBackendInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{"", "file", "file:", "foo:bar", "secret:foo"} {
+		if _, err := ParseStateBackend(spec); err == nil {
+			t.Fatalf("Parsing invalid state backend %q should fail", spec)
+		}
+	}
+}
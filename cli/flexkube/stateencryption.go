@@ -0,0 +1,364 @@
+package flexkube
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"sigs.k8s.io/yaml"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// StatePassphraseEnv is an environment variable, which can be used to provide passphrase
+	// for encrypting the state.
+	StatePassphraseEnv = "FLEXKUBE_STATE_PASSPHRASE"
+
+	// StateKeyEnv is an environment variable, which can be used to provide age X25519 identity
+	// (AGE-SECRET-KEY-1...) for encrypting the state.
+	StateKeyEnv = "FLEXKUBE_STATE_KEY"
+
+	// encryptedValuePrefix is a prefix of encrypted values stored in the state.
+	encryptedValuePrefix = "flexkube-encrypted:v1:"
+
+	// scryptWorkFactor is a base 2 logarithm of scrypt work factor used when encrypting values
+	// using passphrase. As each value is encrypted separately, it is lower than age default.
+	scryptWorkFactor = 15
+
+	// fieldPathSeparator separates field path from the value in encrypted plaintext.
+	fieldPathSeparator = "\x00"
+)
+
+// sensitiveFields are names of state fields, which values are always encrypted.
+//
+//nolint:gochecknoglobals // Used as a constant.
+var sensitiveFields = map[string]bool{
+	"password":             true,
+	"privateKeyPassphrase": true,
+	"token":                true,
+}
+
+// StateEncryptionConfig configures encryption of sensitive values in the state, like private keys,
+// passwords and tokens. With encryption enabled, the state can be safely stored in git repository.
+//
+// Each value is encrypted separately using age (https://age-encryption.org), either with configured
+// passphrase or with X25519 key. Encrypted value is bound to the path of the field it is stored in,
+// so encrypted values cannot be moved between fields.
+//
+// If neither passphrase nor key file is configured, FLEXKUBE_STATE_PASSPHRASE and FLEXKUBE_STATE_KEY
+// environment variables are checked. If none of them is set, the state is not encrypted.
+type StateEncryptionConfig struct {
+	// Passphrase is used for encrypting values using age scrypt recipient.
+	//
+	// As configuration is usually stored next to the state, it is recommended to use
+	// FLEXKUBE_STATE_PASSPHRASE environment variable instead.
+	Passphrase string `json:"passphrase,omitempty"`
+
+	// KeyFile is a path to the file with age X25519 identities, generated e.g. using 'age-keygen'.
+	// Values are encrypted for the first identity and all identities are used for decryption.
+	// Lines starting with '#' are ignored.
+	//
+	// Example value: '~/.config/flexkube/key.txt'.
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+// stateCodec serializes state objects to YAML and back, encrypting and decrypting sensitive
+// values if the key is configured.
+type stateCodec struct {
+	// recipient is used for encrypting values. If nil, values are not encrypted.
+	recipient age.Recipient
+
+	// identities are used for decrypting values.
+	identities []age.Identity
+
+	// encryptedValues stores encrypted values read from the state by their field path and
+	// plaintext value, so unchanged values are saved in the same form, which avoids unnecessary
+	// changes to the state.
+	encryptedValues map[string]string
+}
+
+// Validate validates state encryption configuration.
+func (c *StateEncryptionConfig) Validate() error {
+	if c.Passphrase != "" && c.KeyFile != "" {
+		return fmt.Errorf("passphrase and key file are mutually exclusive")
+	}
+
+	return nil
+}
+
+// newStateCodec returns state codec using key from given configuration or from environment
+// variables. Configuration may be nil.
+func newStateCodec(config *StateEncryptionConfig) (*stateCodec, error) {
+	codec := &stateCodec{
+		encryptedValues: map[string]string{},
+	}
+
+	if config == nil {
+		config = &StateEncryptionConfig{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("validating state encryption configuration: %w", err)
+	}
+
+	if err := codec.configureKey(config); err != nil {
+		return nil, err
+	}
+
+	return codec, nil
+}
+
+// configureKey configures recipient and identities of the codec from given configuration or
+// from environment variables. If state encryption is not configured, codec is left unchanged.
+func (s *stateCodec) configureKey(c *StateEncryptionConfig) error {
+	switch {
+	case c.Passphrase != "":
+		return s.usePassphrase(c.Passphrase)
+	case c.KeyFile != "":
+		f, err := os.Open(c.KeyFile)
+		if err != nil {
+			return fmt.Errorf("opening key file %q: %w", c.KeyFile, err)
+		}
+
+		defer f.Close() //nolint:errcheck // File is only read.
+
+		if err := s.useIdentities(f); err != nil {
+			return fmt.Errorf("reading key file %q: %w", c.KeyFile, err)
+		}
+
+		return nil
+	case os.Getenv(StatePassphraseEnv) != "":
+		return s.usePassphrase(os.Getenv(StatePassphraseEnv))
+	case os.Getenv(StateKeyEnv) != "":
+		if err := s.useIdentities(strings.NewReader(os.Getenv(StateKeyEnv))); err != nil {
+			return fmt.Errorf("parsing %s environment variable: %w", StateKeyEnv, err)
+		}
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+// usePassphrase configures codec to encrypt values using given passphrase.
+func (s *stateCodec) usePassphrase(passphrase string) error {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return fmt.Errorf("creating scrypt recipient: %w", err)
+	}
+
+	recipient.SetWorkFactor(scryptWorkFactor)
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return fmt.Errorf("creating scrypt identity: %w", err)
+	}
+
+	s.recipient = recipient
+	s.identities = []age.Identity{identity}
+
+	return nil
+}
+
+// useIdentities configures codec to encrypt values using age X25519 identities read from
+// given reader. Values are encrypted for the first identity.
+func (s *stateCodec) useIdentities(r io.Reader) error {
+	identities, err := age.ParseIdentities(r)
+	if err != nil {
+		return fmt.Errorf("parsing identities: %w", err)
+	}
+
+	identity, ok := identities[0].(*age.X25519Identity)
+	if !ok {
+		return fmt.Errorf("only X25519 identities are supported")
+	}
+
+	s.recipient = identity.Recipient()
+	s.identities = identities
+
+	return nil
+}
+
+// marshal serializes given object to YAML, encrypting sensitive values if the key is configured.
+func (s *stateCodec) marshal(o interface{}) ([]byte, error) {
+	raw, err := yaml.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("serializing: %w", err)
+	}
+
+	if s.recipient == nil {
+		return raw, nil
+	}
+
+	return s.transform(raw, s.encryptValue)
+}
+
+// unmarshal decrypts encrypted values in given YAML, if there are any, and deserializes it
+// into given object.
+func (s *stateCodec) unmarshal(raw []byte, o interface{}) error {
+	if bytes.Contains(raw, []byte(encryptedValuePrefix)) {
+		decrypted, err := s.transform(raw, s.decryptValue)
+		if err != nil {
+			return err
+		}
+
+		raw = decrypted
+	}
+
+	if err := yaml.Unmarshal(raw, o); err != nil {
+		return fmt.Errorf("deserializing: %w", err)
+	}
+
+	return nil
+}
+
+// valueTransformer transforms value stored in a field with given path and name.
+type valueTransformer func(fieldPath, field, value string) (string, error)
+
+// transform applies given function to all string values in given YAML document.
+func (s *stateCodec) transform(raw []byte, f valueTransformer) ([]byte, error) {
+	var document interface{}
+
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	if document == nil {
+		return raw, nil
+	}
+
+	document, err := transformValues("", "", document, f)
+	if err != nil {
+		return nil, err
+	}
+
+	transformed, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("serializing: %w", err)
+	}
+
+	return transformed, nil
+}
+
+// transformValues recursively applies given function to all string values in given document.
+// For values in lists, name of the field holding the list is passed together with the path
+// containing index of the value.
+func transformValues(fieldPath, field string, document interface{}, f valueTransformer) (interface{}, error) {
+	switch v := document.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			keyPath := key
+			if fieldPath != "" {
+				keyPath = fieldPath + "." + key
+			}
+
+			transformed, err := transformValues(keyPath, key, value, f)
+			if err != nil {
+				return nil, err
+			}
+
+			v[key] = transformed
+		}
+
+		return v, nil
+	case []interface{}:
+		for i, value := range v {
+			transformed, err := transformValues(fmt.Sprintf("%s[%d]", fieldPath, i), field, value, f)
+			if err != nil {
+				return nil, err
+			}
+
+			v[i] = transformed
+		}
+
+		return v, nil
+	case string:
+		return f(fieldPath, field, v)
+	default:
+		return v, nil
+	}
+}
+
+// sensitive returns true if given value of given field should be encrypted.
+func sensitive(field, value string) bool {
+	return sensitiveFields[field] || util.IsSensitive(value)
+}
+
+// encryptValue encrypts given value, if it is sensitive.
+//
+// Encrypted value consists of the prefix followed by base64 encoded age file. Encrypted
+// plaintext is the field path and the value, separated by NUL character.
+func (s *stateCodec) encryptValue(fieldPath, field, value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, encryptedValuePrefix) || !sensitive(field, value) {
+		return value, nil
+	}
+
+	plaintext := fieldPath + fieldPathSeparator + value
+
+	if encrypted, ok := s.encryptedValues[plaintext]; ok {
+		return encrypted, nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	w, err := age.Encrypt(buf, s.recipient)
+	if err != nil {
+		return "", fmt.Errorf("encrypting field %q: %w", fieldPath, err)
+	}
+
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", fmt.Errorf("encrypting field %q: %w", fieldPath, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("encrypting field %q: %w", fieldPath, err)
+	}
+
+	encrypted := encryptedValuePrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	s.encryptedValues[plaintext] = encrypted
+
+	return encrypted, nil
+}
+
+// decryptValue decrypts given value, if it is encrypted, verifying that it was encrypted
+// for the field with given path.
+func (s *stateCodec) decryptValue(fieldPath, _, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedValuePrefix) {
+		return value, nil
+	}
+
+	if s.identities == nil {
+		return "", fmt.Errorf("state is encrypted, but no encryption key is configured, set %s or %s "+
+			"environment variable or configure state encryption", StatePassphraseEnv, StateKeyEnv)
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedValuePrefix))
+	if err != nil {
+		return "", fmt.Errorf("decoding field %q: %w", fieldPath, err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(encrypted), s.identities...)
+	if err != nil {
+		return "", fmt.Errorf("decrypting field %q: %w", fieldPath, err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("decrypting field %q: %w", fieldPath, err)
+	}
+
+	encryptedPath, decrypted, ok := strings.Cut(string(plaintext), fieldPathSeparator)
+	if !ok || encryptedPath != fieldPath {
+		return "", fmt.Errorf("value of field %q was encrypted for field %q", fieldPath, encryptedPath)
+	}
+
+	s.encryptedValues[string(plaintext)] = value
+
+	return decrypted, nil
+}
@@ -0,0 +1,165 @@
+package flexkube
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// stateLockRetryInterval defines how long to wait between attempts to acquire the state lock.
+const stateLockRetryInterval = time.Second
+
+// StateLock describes who holds the lock on the state.
+type StateLock struct {
+	// Holder identifies user, host and process, which holds the lock.
+	Holder string `json:"holder,omitempty"`
+
+	// Since is a time, when the lock has been acquired.
+	Since time.Time `json:"since,omitempty"`
+
+	// Command is a command, which is executed while holding the lock.
+	Command string `json:"command,omitempty"`
+}
+
+// StateLockedError is returned by StateBackend, when the state is already locked.
+type StateLockedError struct {
+	// Lock is a currently held lock. It may be nil, if lock holder is not known.
+	Lock *StateLock
+}
+
+// Error implements error interface.
+func (e *StateLockedError) Error() string {
+	if e.Lock == nil {
+		return "state is locked"
+	}
+
+	return fmt.Sprintf("state is locked by %s", e.Lock)
+}
+
+// String returns human-readable description of the lock.
+func (l *StateLock) String() string {
+	return fmt.Sprintf("%s since %s, running command %q", l.Holder, l.Since.Format(time.RFC3339), l.Command)
+}
+
+// newStateLock returns lock information describing the current process.
+func newStateLock() *StateLock {
+	username := os.Getenv("USER")
+
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return &StateLock{
+		Holder:  fmt.Sprintf("%s@%s (PID %d)", username, hostname, os.Getpid()),
+		Since:   time.Now().UTC().Truncate(time.Second),
+		Command: strings.Join(os.Args, " "),
+	}
+}
+
+// lockState acquires the lock on the state. If the state is locked by someone else, it retries
+// until configured lock timeout passes.
+func (r *Resource) lockState() error {
+	lockTimeout := time.Duration(0)
+
+	if r.LockTimeout != "" {
+		timeout, err := time.ParseDuration(r.LockTimeout)
+		if err != nil {
+			return fmt.Errorf("parsing lock timeout: %w", err)
+		}
+
+		lockTimeout = timeout
+	}
+
+	backend, err := r.getStateBackend()
+	if err != nil {
+		return fmt.Errorf("initializing state backend: %w", err)
+	}
+
+	start := time.Now()
+
+	for {
+		err := backend.Lock(newStateLock())
+
+		var lockedErr *StateLockedError
+
+		if !errors.As(err, &lockedErr) || time.Since(start) >= lockTimeout {
+			return err
+		}
+
+		fmt.Printf("Waiting for the state lock: %v\n", err)
+
+		time.Sleep(stateLockRetryInterval)
+	}
+}
+
+// withStateLock executes given function while holding the lock on the state. After acquiring
+// the lock, the state is re-loaded, so changes made by previous lock holder are not lost.
+//
+// Lock is also acquired for no-op runs, so they don't read the state while it is being modified.
+func (r *Resource) withStateLock(f func() error) error {
+	if err := r.lockState(); err != nil {
+		return fmt.Errorf("locking state: %w", err)
+	}
+
+	err := r.loadState()
+	if err == nil {
+		err = f()
+	}
+
+	if unlockErr := r.stateBackend.Unlock(); unlockErr != nil {
+		if err == nil {
+			return fmt.Errorf("unlocking state: %w", unlockErr)
+		}
+
+		fmt.Printf("Failed to unlock state: %v\n", unlockErr)
+	}
+
+	return err
+}
+
+// ForceUnlock removes the lock from the state, regardless of who holds it. It should only be
+// used, when the lock holder has been interrupted and was not able to remove the lock.
+func (r *Resource) ForceUnlock() error {
+	backend, err := r.getStateBackend()
+	if err != nil {
+		return fmt.Errorf("initializing state backend: %w", err)
+	}
+
+	lock, err := backend.CurrentLock()
+	if err != nil {
+		return fmt.Errorf("reading current lock: %w", err)
+	}
+
+	if lock == nil {
+		fmt.Println("State is not locked")
+
+		return nil
+	}
+
+	fmt.Printf("State is locked by %s\n\n", lock)
+
+	if r.Noop {
+		return nil
+	}
+
+	confirmed, err := r.confirm()
+	if err != nil || !confirmed {
+		return err
+	}
+
+	if err := backend.Unlock(); err != nil {
+		return fmt.Errorf("removing lock: %w", err)
+	}
+
+	fmt.Println("State unlocked")
+
+	return nil
+}
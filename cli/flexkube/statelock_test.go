@@ -0,0 +1,377 @@
+package flexkube
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testStateLock(holder string) *StateLock {
+	return &StateLock{
+		Holder:  holder,
+		Since:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Command: "flexkube apply",
+	}
+}
+
+// testStateBackendLock checks, that given backend allows only one lock holder and that the lock
+// can be released.
+func testStateBackendLock(t *testing.T, backend StateBackend) {
+	t.Helper()
+
+	lock, err := backend.CurrentLock()
+	if err != nil {
+		t.Fatalf("Reading lock of not locked state should succeed, got: %v", err)
+	}
+
+	if lock != nil {
+		t.Fatalf("State should not be locked initially, got: %v", lock)
+	}
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	lock, err = backend.CurrentLock()
+	if err != nil {
+		t.Fatalf("Reading current lock should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(testStateLock("foo"), lock); diff != "" {
+		t.Fatalf("Unexpected lock: %s", diff)
+	}
+
+	err = backend.Lock(testStateLock("bar"))
+
+	var lockedErr *StateLockedError
+
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("Locking already locked state should return StateLockedError, got: %v", err)
+	}
+
+	if diff := cmp.Diff(testStateLock("foo"), lockedErr.Lock); diff != "" {
+		t.Fatalf("StateLockedError should contain current lock holder: %s", diff)
+	}
+
+	if err := backend.Unlock(); err != nil {
+		t.Fatalf("Unlocking state should succeed, got: %v", err)
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock != nil {
+		t.Fatalf("State should not be locked after unlocking, got lock %v and error %v", lock, err)
+	}
+
+	if err := backend.Unlock(); err != nil {
+		t.Fatalf("Unlocking not locked state should succeed, got: %v", err)
+	}
+
+	if err := backend.Lock(testStateLock("bar")); err != nil {
+		t.Fatalf("Locking released state should succeed, got: %v", err)
+	}
+}
+
+func testLockResource(t *testing.T) (*Resource, StateBackend) {
+	t.Helper()
+
+	backend, _ := testFileStateBackend(t)
+
+	return &Resource{
+		stateBackend: backend,
+	}, backend
+}
+
+// StateLockedError.Error() tests.
+func TestStateLockedErrorUnknownHolder(t *testing.T) {
+	t.Parallel()
+
+	if err := (&StateLockedError{}).Error(); err != "state is locked" {
+		t.Fatalf("Unexpected error message: %q", err)
+	}
+}
+
+func TestStateLockedErrorHolder(t *testing.T) {
+	t.Parallel()
+
+	err := (&StateLockedError{Lock: testStateLock("foo")}).Error()
+
+	for _, expected := range []string{"foo", "2023-01-01T00:00:00Z", "flexkube apply"} {
+		if !strings.Contains(err, expected) {
+			t.Fatalf("Error message should contain %q, got: %q", expected, err)
+		}
+	}
+}
+
+// lockState() tests.
+func TestLockState(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+
+	if err := r.lockState(); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	lock, err := backend.CurrentLock()
+	if err != nil {
+		t.Fatalf("Reading current lock should succeed, got: %v", err)
+	}
+
+	if lock == nil || !strings.Contains(lock.Holder, fmt.Sprintf("PID %d", os.Getpid())) {
+		t.Fatalf("Lock should be held by current process, got: %v", lock)
+	}
+}
+
+func TestLockStateLockedTimeout(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+	r.LockTimeout = "1s"
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	start := time.Now()
+
+	err := r.lockState()
+
+	var lockedErr *StateLockedError
+
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("Locking state held by someone else should fail with StateLockedError, got: %v", err)
+	}
+
+	if time.Since(start) < time.Second {
+		t.Fatalf("Locking should be retried until lock timeout passes")
+	}
+}
+
+func TestLockStateLockedNoTimeout(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	var lockedErr *StateLockedError
+
+	if err := r.lockState(); !errors.As(err, &lockedErr) {
+		t.Fatalf("Locking state held by someone else should fail immediately, got: %v", err)
+	}
+}
+
+func TestLockStateWaitForRelease(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+	r.LockTimeout = "10s"
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	unlockErr := make(chan error, 1)
+
+	go func() {
+		time.Sleep(stateLockRetryInterval / 2)
+
+		unlockErr <- backend.Unlock()
+	}()
+
+	if err := r.lockState(); err != nil {
+		t.Fatalf("Locking state should succeed, once previous holder releases the lock, got: %v", err)
+	}
+
+	if err := <-unlockErr; err != nil {
+		t.Fatalf("Unlocking state should succeed, got: %v", err)
+	}
+}
+
+func TestLockStateBadTimeout(t *testing.T) {
+	t.Parallel()
+
+	r, _ := testLockResource(t)
+	r.LockTimeout = "doh"
+
+	if err := r.lockState(); err == nil {
+		t.Fatalf("Locking state with invalid lock timeout should fail")
+	}
+}
+
+// withStateLock() tests.
+func TestWithStateLock(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+
+	if err := backend.Save(testResourceState()); err != nil {
+		t.Fatalf("Saving state should succeed, got: %v", err)
+	}
+
+	called := false
+
+	err := r.withStateLock(func() error {
+		called = true
+
+		if lock, err := backend.CurrentLock(); err != nil || lock == nil {
+			t.Errorf("State should be locked while executing function, got lock %v and error %v", lock, err)
+		}
+
+		if diff := cmp.Diff(testResourceState(), r.State); diff != "" {
+			t.Errorf("State should be loaded after acquiring the lock: %s", diff)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Executing function with state lock should succeed, got: %v", err)
+	}
+
+	if !called {
+		t.Fatalf("Function should be called")
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock != nil {
+		t.Fatalf("State should be unlocked after function finishes, got lock %v and error %v", lock, err)
+	}
+}
+
+func TestWithStateLockReleaseOnError(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+
+	expectedErr := fmt.Errorf("foo")
+
+	if err := r.withStateLock(func() error { return expectedErr }); !errors.Is(err, expectedErr) {
+		t.Fatalf("Error returned by function should be returned, got: %v", err)
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock != nil {
+		t.Fatalf("State should be unlocked after function fails, got lock %v and error %v", lock, err)
+	}
+}
+
+func TestWithStateLockReleaseOnLoadError(t *testing.T) {
+	t.Parallel()
+
+	backend, path := testFileStateBackend(t)
+
+	if err := os.WriteFile(path, []byte("state: foo"), 0o600); err != nil {
+		t.Fatalf("Writing state file: %v", err)
+	}
+
+	r := &Resource{
+		stateBackend: backend,
+	}
+
+	err := r.withStateLock(func() error {
+		t.Errorf("Function should not be called, when loading state fails")
+
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("Executing function with malformed state should fail")
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock != nil {
+		t.Fatalf("State should be unlocked after loading state fails, got lock %v and error %v", lock, err)
+	}
+}
+
+func TestWithStateLockLocked(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	err := r.withStateLock(func() error {
+		t.Errorf("Function should not be called, when state is locked")
+
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("Executing function with locked state should fail")
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock == nil || lock.Holder != "foo" {
+		t.Fatalf("Lock held by someone else should not be released, got lock %v and error %v", lock, err)
+	}
+}
+
+// ForceUnlock() tests.
+func TestForceUnlock(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+	r.Confirmed = true
+
+	// Lock left behind by interrupted process.
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	if err := r.ForceUnlock(); err != nil {
+		t.Fatalf("Force unlocking state should succeed, got: %v", err)
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock != nil {
+		t.Fatalf("State should be unlocked, got lock %v and error %v", lock, err)
+	}
+
+	if err := r.ForceUnlock(); err != nil {
+		t.Fatalf("Force unlocking not locked state should succeed, got: %v", err)
+	}
+}
+
+func TestForceUnlockNoop(t *testing.T) {
+	t.Parallel()
+
+	r, backend := testLockResource(t)
+	r.Noop = true
+
+	if err := backend.Lock(testStateLock("foo")); err != nil {
+		t.Fatalf("Locking state should succeed, got: %v", err)
+	}
+
+	if err := r.ForceUnlock(); err != nil {
+		t.Fatalf("Force unlocking state in no-op mode should succeed, got: %v", err)
+	}
+
+	if lock, err := backend.CurrentLock(); err != nil || lock == nil {
+		t.Fatalf("State should stay locked in no-op mode, got lock %v and error %v", lock, err)
+	}
+}
+
+func TestForceUnlockMalformedLock(t *testing.T) {
+	t.Parallel()
+
+	backend, path := testFileStateBackend(t)
+
+	if err := os.WriteFile(path+".lock", []byte("foo"), 0o600); err != nil {
+		t.Fatalf("Writing lock file: %v", err)
+	}
+
+	r := &Resource{
+		stateBackend: backend,
+		Confirmed:    true,
+	}
+
+	if err := r.ForceUnlock(); err == nil {
+		t.Fatalf("Force unlocking state with malformed lock should fail")
+	}
+
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("Malformed lock should not be removed, got: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package flexkube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// ValidateOutputText is a value of --output flag for human-readable validation report.
+	ValidateOutputText = "text"
+
+	// ValidateOutputJSON is a value of --output flag for validation report in JSON format.
+	ValidateOutputJSON = "json"
+)
+
+// ResourceValidation holds validation result of a single resource.
+type ResourceValidation struct {
+	// Resource is a name of validated resource, e.g. 'etcd' or 'kubelet-pool/workers'.
+	Resource string `json:"resource"`
+
+	// Valid is true, if resource configuration has no errors.
+	Valid bool `json:"valid"`
+
+	// Errors is a list of all validation errors found in resource configuration.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// validator is implemented by all resource configurations, which can be validated.
+type validator interface {
+	Validate() error
+}
+
+// ValidationReport holds validation results of all configured resources.
+type ValidationReport struct {
+	// Valid is true, if none of the resources has validation errors.
+	Valid bool `json:"valid"`
+
+	// Resources holds validation results for each configured resource.
+	Resources []ResourceValidation `json:"resources"`
+}
+
+// ValidateAll validates configuration of all resources defined in configuration or in the state.
+//
+// Validation does not connect to any of the configured hosts. Only resources present in the
+// loaded state are validated, so if the state has not been loaded, only the configuration is
+// validated.
+func (r *Resource) ValidateAll() *ValidationReport {
+	report := &ValidationReport{
+		Valid:     true,
+		Resources: []ResourceValidation{},
+	}
+
+	if r.PKI != nil {
+		report.add("pki", func() (validator, error) {
+			return r.getPKI()
+		})
+	}
+
+	if r.Etcd != nil || (r.State != nil && r.State.Etcd != nil) {
+		report.add("etcd", func() (validator, error) {
+			return r.etcdConfig()
+		})
+	}
+
+	if r.Controlplane != nil || (r.State != nil && r.State.Controlplane != nil) {
+		report.add("controlplane", func() (validator, error) {
+			return r.controlplaneConfig()
+		})
+	}
+
+	for _, name := range r.allAPILoadBalancerPoolNames() {
+		name := name
+
+		report.add(fmt.Sprintf("apiloadbalancer-pool/%s", name), func() (validator, error) {
+			return r.apiLoadBalancerPoolConfig(name)
+		})
+	}
+
+	for _, name := range r.allKubeletPoolNames() {
+		name := name
+
+		report.add(fmt.Sprintf("kubelet-pool/%s", name), func() (validator, error) {
+			return r.kubeletPoolConfig(name)
+		})
+	}
+
+	for _, name := range r.allContainersNames() {
+		name := name
+
+		report.add(fmt.Sprintf("containers/%s", name), func() (validator, error) {
+			return r.containersConfig(name)
+		})
+	}
+
+	return report
+}
+
+// add validates the resource configuration returned by the given function and adds the result
+// to the report.
+func (v *ValidationReport) add(name string, configF func() (validator, error)) {
+	result := ResourceValidation{
+		Resource: name,
+		Valid:    true,
+	}
+
+	config, err := configF()
+	if err == nil {
+		err = config.Validate()
+	}
+
+	if err != nil {
+		result.Valid = false
+		result.Errors = validationErrors(err)
+		v.Valid = false
+	}
+
+	v.Resources = append(v.Resources, result)
+}
+
+// validationErrors flattens given error into a list of error messages.
+func validationErrors(err error) []string {
+	var validateErrors util.ValidateErrors
+
+	if !errors.As(err, &validateErrors) {
+		return []string{err.Error()}
+	}
+
+	messages := []string{}
+
+	for _, e := range validateErrors {
+		messages = append(messages, validationErrors(e)...)
+	}
+
+	return messages
+}
+
+// Text returns human-readable form of the validation report.
+func (v *ValidationReport) Text() string {
+	var sb strings.Builder
+
+	invalid := 0
+
+	for _, r := range v.Resources {
+		if r.Valid {
+			fmt.Fprintf(&sb, "%s: OK\n", r.Resource)
+
+			continue
+		}
+
+		invalid++
+
+		fmt.Fprintf(&sb, "%s: FAILED\n", r.Resource)
+
+		for _, e := range r.Errors {
+			fmt.Fprintf(&sb, "  - %s\n", e)
+		}
+	}
+
+	if invalid > 0 {
+		fmt.Fprintf(&sb, "\n%d of %d resources have invalid configuration\n", invalid, len(v.Resources))
+	} else {
+		fmt.Fprintf(&sb, "\nConfiguration of all %d resources is valid\n", len(v.Resources))
+	}
+
+	return sb.String()
+}
+
+// JSON returns validation report in JSON format.
+func (v *ValidationReport) JSON() (string, error) {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("serializing validation report: %w", err)
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,53 @@
+package flexkube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withTestWorkingDirectory changes working directory to a temporary directory with given
+// config.yaml and state.yaml files for the duration of the test.
+func withTestWorkingDirectory(t *testing.T, config, state string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("Writing config.yaml: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "state.yaml"), []byte(state), 0o600); err != nil {
+		t.Fatalf("Writing state.yaml: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getting working directory: %v", err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Changing working directory: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Restoring working directory: %v", err)
+		}
+	})
+}
+
+// Run() validate tests.
+//
+//nolint:paralleltest // Test changes working directory, which is global for the process.
+func TestValidateSkipState(t *testing.T) {
+	withTestWorkingDirectory(t, "{}\n", "this is not valid state")
+
+	if code := Run([]string{"flexkube", "validate"}); code == 0 {
+		t.Fatalf("Validating with state which can't be loaded should fail")
+	}
+
+	if code := Run([]string{"flexkube", "validate", "--" + SkipStateFlag}); code != 0 {
+		t.Fatalf("Validating without loading the state should succeed, got exit code %d", code)
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// redactedChecksumLength is a number of hex characters of the checksum included in redacted value.
+const redactedChecksumLength = 16
+
+// sensitiveMarkers are substrings, which if found in the value, mark the value as sensitive. This
+// covers private keys in PEM format, including types.PrivateKey fields, and credentials embedded
+// in kubeconfig files.
+//
+//nolint:gochecknoglobals // Used as a constant.
+var sensitiveMarkers = []string{
+	"PRIVATE KEY-----",
+	"client-key-data:",
+	"token:",
+}
+
+// sensitiveFieldNames are names of struct fields, which values are always sensitive.
+//
+//nolint:gochecknoglobals // Used as a constant.
+var sensitiveFieldNames = map[string]bool{
+	"Password":             true,
+	"PrivateKeyPassphrase": true,
+	"Token":                true,
+}
+
+// IsSensitive returns true, if given value looks like a secret, e.g. PEM private key
+// or kubeconfig with client credentials.
+func IsSensitive(value string) bool {
+	for _, marker := range sensitiveMarkers {
+		if strings.Contains(value, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact replaces given value with the placeholder containing the checksum of the value, so it
+// is still possible to tell if the value has changed, without revealing it. Empty values are
+// not modified.
+func Redact(value string) string {
+	if value == "" {
+		return value
+	}
+
+	checksum := sha256.Sum256([]byte(value))
+
+	return fmt.Sprintf("(sensitive value, sha256:%s)", hex.EncodeToString(checksum[:])[:redactedChecksumLength])
+}
+
+// RedactSensitive returns cmp option, which redacts sensitive values in the diff output
+// using Redact. Values are considered sensitive, if IsSensitive returns true for them or
+// if they are stored in struct fields named 'Password' or 'Token'.
+func RedactSensitive() cmp.Option {
+	return cmp.FilterPath(isSensitivePath, cmp.Transformer("Redact", func(v interface{}) string {
+		return Redact(reflect.ValueOf(v).String())
+	}))
+}
+
+// isSensitivePath returns true, if value at given path is a sensitive string.
+func isSensitivePath(path cmp.Path) bool {
+	step := path.Last()
+
+	if step.Type().Kind() != reflect.String {
+		return false
+	}
+
+	if field, ok := step.(cmp.StructField); ok && sensitiveFieldNames[field.Name()] {
+		return true
+	}
+
+	x, y := step.Values()
+
+	return (x.IsValid() && IsSensitive(x.String())) || (y.IsValid() && IsSensitive(y.String()))
+}
@@ -56,6 +56,22 @@ func Test_GenerateECPrivateKey_returns_PEM_encoded_EC_private_key(t *testing.T)
 	}
 }
 
+// GenerateEd25519PrivateKey tests.
+func Test_GenerateEd25519PrivateKey_returns_PEM_encoded_PKCS8_private_key(t *testing.T) {
+	t.Parallel()
+
+	pemEncodedPrivateKey := GenerateEd25519PrivateKey(t)
+
+	derPrivateKey, _ := pem.Decode([]byte(pemEncodedPrivateKey))
+	if derPrivateKey == nil {
+		t.Fatalf("Returned key is not PEM encoded:\n%s", pemEncodedPrivateKey)
+	}
+
+	if _, err := x509.ParsePKCS8PrivateKey(derPrivateKey.Bytes); err != nil {
+		t.Fatalf("Returned key is not PKCS8 private key")
+	}
+}
+
 // GeneratePKI() tests.
 func TestGeneratePKI(t *testing.T) {
 	t.Parallel()
@@ -11,7 +11,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -72,6 +71,11 @@ type APILoadBalancer struct {
 	//
 	// This field is optional, if used together with APILoadBalancers struct.
 	BindAddress string `json:"bindAddress,omitempty"`
+
+	// Runtime selects container runtime, which will be used to run the container.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
 }
 
 // apiLoadBalancer is validated and executable version of APILoadBalancer.
@@ -82,6 +86,7 @@ type apiLoadBalancer struct {
 	name           string
 	hostConfigPath string
 	bindAddress    string
+	runtime        container.RuntimeConfig
 }
 
 func (a apiLoadBalancer) config() (string, error) {
@@ -151,10 +156,7 @@ func (a *apiLoadBalancer) ToHostConfiguredContainer() (*container.HostConfigured
 	}
 
 	containerConfig := container.Container{
-		// TODO: This is weird. This sets docker as default runtime config.
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: a.runtime,
 		Config: types.ContainerConfig{
 			// TODO: Make it configurable? And don't force user to use HAProxy.
 			Name:        a.name,
@@ -196,6 +198,7 @@ func (a *APILoadBalancer) New() (container.ResourceInstance, error) {
 		name:           util.PickString(a.Name, ContainerName),
 		hostConfigPath: util.PickString(a.HostConfigPath, HostConfigPath),
 		bindAddress:    a.BindAddress,
+		runtime:        container.PickRuntimeConfig(a.Runtime),
 	}
 
 	// Fill empty fields with default values.
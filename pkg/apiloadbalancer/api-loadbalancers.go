@@ -4,6 +4,7 @@ package apiloadbalancer
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 
 	"sigs.k8s.io/yaml"
@@ -12,6 +13,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container"
 	"github.com/flexkube/libflexkube/pkg/host"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -107,14 +109,33 @@ type APILoadBalancers struct {
 	// This field is optional.
 	BindAddress string `json:"bindAddress,omitempty"`
 
+	// Runtime selects container runtime, which will be used by all instances, if instance
+	// itself has no runtime selected.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// State stores state of the created containers. After deployment, it is up to the user to export
 	// the state and restore it on consecutive runs.
 	State container.ContainersState `json:"state,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// This field is optional. If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how many containers can be updated at the same time.
+	//
+	// This field is optional. If not set, containers will be updated one by one.
+	UpdateStrategy *container.UpdateStrategy `json:"updateStrategy,omitempty"`
 }
 
 // apiLoadBalancers is validated and executable version of APILoadBalancers.
 type apiLoadBalancers struct {
 	containers container.ContainersInterface
+
+	// requirements holds pre-flight requirements of load balancer instances.
+	requirements map[string]preflight.Requirements
 }
 
 func (a *APILoadBalancers) propagateInstance(instance *APILoadBalancer) {
@@ -126,6 +147,10 @@ func (a *APILoadBalancers) propagateInstance(instance *APILoadBalancer) {
 	instance.Name = util.PickString(instance.Name, a.Name)
 	instance.HostConfigPath = util.PickString(instance.HostConfigPath, a.HostConfigPath)
 	instance.BindAddress = util.PickString(instance.BindAddress, a.BindAddress)
+
+	if instance.Runtime == nil {
+		instance.Runtime = a.Runtime
+	}
 }
 
 // New validates APILoadBalancers struct and fills all required fields in members with default values
@@ -138,10 +163,14 @@ func (a *APILoadBalancers) New() (types.Resource, error) {
 	}
 
 	containersConfig := &container.Containers{
-		PreviousState: a.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  a.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    a.Parallelism,
+		UpdateStrategy: a.UpdateStrategy,
 	}
 
+	requirements := map[string]preflight.Requirements{}
+
 	for instanceName, lb := range a.APILoadBalancers {
 		lb := lb
 		a.propagateInstance(&lb)
@@ -150,22 +179,44 @@ func (a *APILoadBalancers) New() (types.Resource, error) {
 		lbxHcc, _ := lbx.ToHostConfiguredContainer() //nolint:errcheck // Already checked in Validate().
 
 		containersConfig.DesiredState[strconv.Itoa(instanceName)] = lbxHcc
+		requirements[strconv.Itoa(instanceName)] = bindAddressRequirements(lb.BindAddress)
 	}
 
 	c, _ := containersConfig.New() //nolint:errcheck // Already checked in Validate().
 
 	return &apiLoadBalancers{
-		containers: c,
+		containers:   c,
+		requirements: requirements,
 	}, nil
 }
 
+// bindAddressRequirements returns pre-flight requirements for load balancer listening on
+// given bind address. If bind address has no port, no ports are required.
+func bindAddressRequirements(bindAddress string) preflight.Requirements {
+	_, portString, err := net.SplitHostPort(bindAddress)
+	if err != nil {
+		return preflight.Requirements{}
+	}
+
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return preflight.Requirements{}
+	}
+
+	return preflight.Requirements{
+		Ports: []int{port},
+	}
+}
+
 // Validate validates APILoadBalancers struct.
 func (a *APILoadBalancers) Validate() error {
 	var errors util.ValidateErrors
 
 	containersConfig := &container.Containers{
-		PreviousState: a.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  a.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    a.Parallelism,
+		UpdateStrategy: a.UpdateStrategy,
 	}
 
 	for instanceName, lb := range a.APILoadBalancers {
@@ -226,3 +277,9 @@ func (a *apiLoadBalancers) Deploy() error {
 func (a *apiLoadBalancers) Containers() container.ContainersInterface {
 	return a.containers
 }
+
+// PreflightRequirements implements preflight.Requirer interface. Instances require port from their
+// bind address.
+func (a *apiLoadBalancers) PreflightRequirements() map[string]preflight.Requirements {
+	return a.requirements
+}
@@ -1,8 +1,10 @@
 package apiloadbalancer
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -111,3 +113,21 @@ func TestLoadBalancersContainers(t *testing.T) {
 		t.Fatalf("Containers() should return non-nil value")
 	}
 }
+
+// PreflightRequirements() tests.
+func TestLoadBalancersPreflightRequirements(t *testing.T) {
+	t.Parallel()
+
+	p := GetLoadBalancers(t)
+
+	requirer, ok := p.(preflight.Requirer)
+	if !ok {
+		t.Fatalf("Load balancers should implement preflight.Requirer interface")
+	}
+
+	for name, requirements := range requirer.PreflightRequirements() {
+		if expected := []int{6443}; !reflect.DeepEqual(requirements.Ports, expected) {
+			t.Fatalf("Instance %q should require ports %v, got %v", name, expected, requirements.Ports)
+		}
+	}
+}
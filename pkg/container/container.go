@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/containerd"
 	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 )
@@ -48,6 +49,9 @@ type Interface interface {
 
 	// SetStatus allows overriding container status.
 	SetStatus(newStatus types.ContainerStatus)
+
+	// SetConfig allows overriding container configuration.
+	SetConfig(newConfig types.ContainerConfig)
 }
 
 // InstanceInterface represents operations, which can be executed on existing
@@ -90,9 +94,64 @@ type Container struct {
 
 // RuntimeConfig is a collection of various runtime configurations which can be defined
 // by user.
+//
+// Built-in resources like etcd cluster, controlplane, kubelet pool and API load balancers
+// use Docker, unless other runtime is selected in their configuration.
 type RuntimeConfig struct {
 	// Docker stores Docker runtime configuration.
 	Docker *docker.Config `json:"docker,omitempty"`
+
+	// Containerd stores containerd runtime configuration.
+	Containerd *containerd.Config `json:"containerd,omitempty"`
+}
+
+// config returns configuration of selected container runtime. If none or more than one
+// runtime is configured, nil is returned.
+func (r RuntimeConfig) config() runtime.Config {
+	switch {
+	case r.Docker != nil && r.Containerd == nil:
+		return r.Docker
+	case r.Containerd != nil && r.Docker == nil:
+		return r.Containerd
+	default:
+		return nil
+	}
+}
+
+// DefaultRuntimeConfig returns runtime configuration used by built-in resources, when
+// runtime is not selected.
+func DefaultRuntimeConfig() RuntimeConfig {
+	return RuntimeConfig{
+		Docker: docker.DefaultConfig(),
+	}
+}
+
+// PickRuntimeConfig returns first non-nil runtime configuration from given ones. If all
+// of them are nil, DefaultRuntimeConfig is returned.
+func PickRuntimeConfig(configs ...*RuntimeConfig) RuntimeConfig {
+	for _, c := range configs {
+		if c != nil {
+			return *c
+		}
+	}
+
+	return DefaultRuntimeConfig()
+}
+
+// runtimeConfigFrom converts given runtime configuration back to RuntimeConfig.
+func runtimeConfigFrom(config runtime.Config) RuntimeConfig {
+	switch c := config.(type) {
+	case *docker.Config:
+		return RuntimeConfig{
+			Docker: c,
+		}
+	case *containerd.Config:
+		return RuntimeConfig{
+			Containerd: c,
+		}
+	default:
+		return RuntimeConfig{}
+	}
 }
 
 // container represents validated version of Container object, which contains all requires
@@ -129,7 +188,7 @@ func (c *Container) New() (Interface, error) {
 	newContainer := &container{
 		base{
 			config:        c.Config,
-			runtimeConfig: c.Runtime.Docker,
+			runtimeConfig: c.Runtime.config(),
 		},
 	}
 
@@ -154,8 +213,12 @@ func (c *Container) Validate() error {
 		return fmt.Errorf("image must be set")
 	}
 
-	if c.Runtime.Docker == nil {
-		return fmt.Errorf("docker runtime must be set")
+	if c.Runtime.Docker == nil && c.Runtime.Containerd == nil {
+		return fmt.Errorf("docker or containerd runtime must be set")
+	}
+
+	if c.Runtime.config() == nil {
+		return fmt.Errorf("only one container runtime can be set")
 	}
 
 	// TODO check runtime configurations here
@@ -166,7 +229,6 @@ func (c *Container) Validate() error {
 //
 // It returns error if container runtime configuration is invalid.
 func (c *container) selectRuntime() error {
-	// TODO once we add more runtimes, if there is more than one defined, return an error here.
 	r, err := c.runtimeConfig.New()
 	if err != nil {
 		return fmt.Errorf("selecting container runtime: %w", err)
@@ -244,6 +306,10 @@ func (c *container) SetStatus(s types.ContainerStatus) {
 	c.status = s
 }
 
+func (c *container) SetConfig(config types.ContainerConfig) {
+	c.config = config
+}
+
 func (c *container) Runtime() runtime.Runtime {
 	return c.runtime
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/containerd"
 	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 )
@@ -80,6 +81,24 @@ func TestValidateUnsupportedRuntime(t *testing.T) {
 	}
 }
 
+func TestValidateMultipleRuntimes(t *testing.T) {
+	t.Parallel()
+
+	testContainer := &Container{
+		Runtime: RuntimeConfig{
+			Docker:     &docker.Config{},
+			Containerd: &containerd.Config{},
+		},
+		Config: types.ContainerConfig{
+			Name:  "foo",
+			Image: "nonexistent",
+		},
+	}
+	if err := testContainer.Validate(); err == nil {
+		t.Errorf("Validating container with multiple container runtimes should fail")
+	}
+}
+
 func TestValidateRequireImage(t *testing.T) {
 	t.Parallel()
 
@@ -112,6 +131,50 @@ func TestSelectDockerRuntime(t *testing.T) {
 	}
 }
 
+func TestSelectContainerdRuntime(t *testing.T) {
+	t.Parallel()
+
+	testContainer := &Container{
+		Runtime: RuntimeConfig{
+			Containerd: &containerd.Config{},
+		},
+		Config: types.ContainerConfig{
+			Name:  "foo",
+			Image: "nonexistent",
+		},
+	}
+
+	c, err := testContainer.New()
+	if err != nil {
+		t.Fatalf("Creating container with containerd runtime should succeed, got: %v", err)
+	}
+
+	if _, ok := c.(*container).runtimeConfig.(*containerd.Config); !ok {
+		t.Fatalf("Containerd runtime configuration should be selected")
+	}
+}
+
+// PickRuntimeConfig() tests.
+func TestPickRuntimeConfigDefault(t *testing.T) {
+	t.Parallel()
+
+	if r := PickRuntimeConfig(nil, nil); r.Docker == nil || r.Containerd != nil {
+		t.Fatalf("Docker runtime should be picked by default, got: %+v", r)
+	}
+}
+
+func TestPickRuntimeConfig(t *testing.T) {
+	t.Parallel()
+
+	expected := &RuntimeConfig{
+		Containerd: &containerd.Config{},
+	}
+
+	if r := PickRuntimeConfig(nil, expected, &RuntimeConfig{}); r != *expected {
+		t.Fatalf("First non-nil runtime configuration should be picked, got: %+v", r)
+	}
+}
+
 // FromStatus() tests.
 func TestFromStatusValid(t *testing.T) {
 	t.Parallel()
@@ -5,6 +5,8 @@ package container
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/google/go-cmp/cmp"
 	"sigs.k8s.io/yaml"
@@ -49,6 +51,11 @@ type ContainersInterface interface {
 	// Having those fields modified allows to minimize the difference when comparing previous state
 	// and desired state.
 	DesiredState() ContainersState
+
+	// Plan returns list of actions, which will be executed by Deploy().
+	//
+	// CheckCurrentState() must be called before calling Plan(), otherwise error will be returned.
+	Plan() (Plan, error)
 }
 
 // Containers allow to orchestrate and update multiple containers spread
@@ -60,6 +67,22 @@ type Containers struct {
 
 	// DesiredState is a user-defined desired containers configuration.
 	DesiredState ContainersState `json:"desiredState,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how existing containers with pending changes are updated.
+	//
+	// If not set, containers are updated in batches of the size equal to the parallelism.
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// ReadinessCheck is executed for each updated container, before the next batch of containers
+	// is updated.
+	//
+	// Due to it's nature, it can only be set programmatically.
+	ReadinessCheck ReadinessCheck `json:"-"`
 }
 
 // containers is a validated version of the Containers, which allows user to perform operations on them
@@ -73,6 +96,15 @@ type containers struct {
 
 	// resiredState is a user-defined desired containers configuration after validation.
 	desiredState containersState
+
+	// parallelism is a maximum number of containers, which are deployed at the same time.
+	parallelism int
+
+	// updateStrategy controls, how existing containers are updated.
+	updateStrategy *UpdateStrategy
+
+	// readinessCheck is executed for updated containers.
+	readinessCheck ReadinessCheck
 }
 
 // New validates Containers configuration and returns container object, which can be
@@ -86,10 +118,28 @@ func (c *Containers) New() (ContainersInterface, error) {
 	previousState, _ := c.PreviousState.New() //nolint:errcheck // Checked in Validate().
 	desiredState, _ := c.DesiredState.New()   //nolint:errcheck // Checked in Validate().
 
-	return &containers{
-		previousState: previousState.(containersState), //nolint:forcetypeassert // This should be avoided.
-		desiredState:  desiredState.(containersState),  //nolint:forcetypeassert // This should be avoided.
-	}, nil
+	cs := &containers{
+		previousState:  previousState.(containersState), //nolint:forcetypeassert // This should be avoided.
+		desiredState:   desiredState.(containersState),  //nolint:forcetypeassert // This should be avoided.
+		parallelism:    c.Parallelism,
+		updateStrategy: c.UpdateStrategy,
+		readinessCheck: c.ReadinessCheck,
+	}
+
+	inheritHostKeyFingerprints(cs.previousState, cs.desiredState)
+
+	return cs, nil
+}
+
+// inheritHostKeyFingerprints copies SSH host key fingerprints recorded on first use from previous
+// state to desired state, so they are verified on following connections and they don't show up
+// as a configuration change.
+func inheritHostKeyFingerprints(previousState, desiredState containersState) {
+	for name, desired := range desiredState {
+		if previous, ok := previousState[name]; ok {
+			desired.host.InheritHostKeyFingerprint(previous.host)
+		}
+	}
 }
 
 // Validate validates Containers struct and all structs used underneath.
@@ -114,6 +164,16 @@ func (c *Containers) Validate() error {
 		errors = append(errors, fmt.Errorf("validating desired state failed: %w", err))
 	}
 
+	if c.Parallelism < 0 {
+		errors = append(errors, fmt.Errorf("parallelism must not be negative"))
+	}
+
+	if c.UpdateStrategy != nil {
+		if err := c.UpdateStrategy.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating update strategy: %w", err))
+		}
+	}
+
 	return errors.Return()
 }
 
@@ -155,8 +215,6 @@ func (c *Containers) Deploy() error {
 	// differ.
 	// This is similar to what Terraform is doing and may cause planning to run several times, so it may require
 	// some optimization.
-	// Alternatively we can have serializable plan and a knob in execute command to control whether we should
-	// make additional validation or not.
 	if err := containers.CheckCurrentState(); err != nil {
 		return fmt.Errorf("checking current state: %w", err)
 	}
@@ -171,7 +229,8 @@ func (c *Containers) Deploy() error {
 }
 
 // CheckCurrentState copies previous state to current state, to mark, that it has been called at least once
-// and then updates state of all containers.
+// and then updates state of all containers. If live configuration of existing containers differs from
+// the recorded one, the current state is updated, so Deploy() can restore desired configuration.
 func (c *containers) CheckCurrentState() error {
 	if c.currentState == nil {
 		// We just assign the pointer, but it's fine, since we don't need previous
@@ -180,7 +239,13 @@ func (c *containers) CheckCurrentState() error {
 		c.currentState = c.previousState
 	}
 
-	return c.currentState.CheckState()
+	if err := c.currentState.CheckState(); err != nil {
+		return fmt.Errorf("checking state: %w", err)
+	}
+
+	c.detectDrift()
+
+	return nil
 }
 
 // filesToUpdate returns list of files, which needs to be updated, based on the current state of the container.
@@ -196,11 +261,6 @@ func filesToUpdate(targetHCC hostConfiguredContainer, stateHCC *hostConfiguredCo
 	// Loop over desired config files and check if they exist.
 	for path, content := range targetHCC.configFiles {
 		if currentContent, exists := stateHCC.configFiles[path]; !exists || content != currentContent {
-			// TODO convert all prints to logging, so we can add more verbose information too
-			fmt.Printf("Detected configuration drift for file %q\n", path)
-			fmt.Printf("  current: \n%+v\n", currentContent)
-			fmt.Printf("  desired: \n%+v\n", content)
-
 			files = append(files, path)
 		}
 	}
@@ -208,6 +268,29 @@ func filesToUpdate(targetHCC hostConfiguredContainer, stateHCC *hostConfiguredCo
 	return files
 }
 
+// configurationDrift returns current and desired content of given configuration files
+// in human-readable format. Sensitive content like private keys or kubeconfigs with
+// credentials is redacted, as it is printed during the deployment.
+func configurationDrift(files []string, targetHCC, stateHCC hostConfiguredContainer) string {
+	content := func(value string) string {
+		if util.IsSensitive(value) {
+			return util.Redact(value)
+		}
+
+		return value
+	}
+
+	drift := ""
+
+	for _, path := range files {
+		drift += fmt.Sprintf("Detected configuration drift for file %q\n", path)
+		drift += fmt.Sprintf("  current: \n%+v\n", content(stateHCC.configFiles[path]))
+		drift += fmt.Sprintf("  desired: \n%+v\n", content(targetHCC.configFiles[path]))
+	}
+
+	return drift
+}
+
 // ensureConfigured makes sure that all desired configuration files are correct.
 func (c *containers) ensureConfigured(containerName string) error {
 	targetHCC := c.desiredState[containerName]
@@ -221,6 +304,11 @@ func (c *containers) ensureConfigured(containerName string) error {
 
 	f := filesToUpdate(*targetHCC, stateHCC)
 
+	if stateHCC != nil {
+		// TODO convert all prints to logging, so we can add more verbose information too
+		fmt.Print(configurationDrift(f, *targetHCC, *stateHCC))
+	}
+
 	err := targetHCC.Configure(f)
 	if err != nil && reflect.DeepEqual(f, filesToUpdate(*targetHCC, stateHCC)) {
 		return fmt.Errorf("no files has been updated: %w", err)
@@ -315,7 +403,7 @@ func (c *containers) diffHost(containerName string) (string, error) {
 		return "", fmt.Errorf("can't diff container: %w", err)
 	}
 
-	return cmp.Diff(c.currentState[containerName].host, c.desiredState[containerName].host), nil
+	return cmp.Diff(c.currentState[containerName].host, c.desiredState[containerName].host, util.RedactSensitive()), nil
 }
 
 // recreate is a helper, which removes container from current state and creates new one from
@@ -367,9 +455,10 @@ func (c *containers) diffContainer(containerName string) (string, error) {
 		return "", fmt.Errorf("can't diff container: %w", err)
 	}
 
-	cd := cmp.Diff(c.currentState[containerName].container.Config(), c.desiredState[containerName].container.Config())
+	cd := cmp.Diff(c.currentState[containerName].container.Config(), c.desiredState[containerName].container.Config(),
+		util.RedactSensitive())
 	rcd := cmp.Diff(c.currentState[containerName].container.RuntimeConfig(),
-		c.desiredState[containerName].container.RuntimeConfig())
+		c.desiredState[containerName].container.RuntimeConfig(), util.RedactSensitive())
 
 	return cd + rcd, nil
 }
@@ -395,19 +484,12 @@ func (c *containers) ensureContainer(containerName string) error {
 
 // hasUpdates return bool if there are any pending configuration changes to the container.
 func (c *containers) hasUpdates(containerName string) (bool, error) {
-	diffHost, err := c.diffHost(containerName)
+	action, _, err := c.planUpdate(containerName)
 	if err != nil {
-		return false, fmt.Errorf("checking host diff: %w", err)
+		return false, fmt.Errorf("checking for pending updates: %w", err)
 	}
 
-	updatableFiles := filesToUpdate(*c.desiredState[containerName], c.currentState[containerName])
-
-	diffContainer, err := c.diffContainer(containerName)
-	if err != nil {
-		return false, fmt.Errorf("checking container diff: %w", err)
-	}
-
-	return diffHost != "" || len(updatableFiles) != 0 || diffContainer != "", nil
+	return action != "", nil
 }
 
 func (c *containers) ensureCurrentContainer(containerName string, stateHCC hostConfiguredContainer) (*hostConfiguredContainer, error) { //nolint:lll // Just long types.
@@ -460,6 +542,12 @@ func (c *containers) ensureNewContainer(containerName string) error {
 }
 
 func (c *containers) ensureUpToDate(containerName string) error {
+	// Planned action must be checked before the update, as it changes the current state.
+	action, _, err := c.planUpdate(containerName)
+	if err != nil {
+		return fmt.Errorf("planning update of container %q: %w", containerName, err)
+	}
+
 	// Update containers on hosts.
 	// This can move containers between hosts, but NOT the data.
 	if err := c.ensureHost(containerName); err != nil {
@@ -474,33 +562,148 @@ func (c *containers) ensureUpToDate(containerName string) error {
 		return fmt.Errorf("updating container %q: %w", containerName, err)
 	}
 
+	if action == ActionRestart {
+		return c.restart(containerName)
+	}
+
 	return nil
 }
 
-// updateExistingContainer handles updating existing containers. It either removes them
-// if they are not needed anymore or makes sure that their configuration is up to date.
-func (c *containers) updateExistingContainers() error {
-	for containerName := range c.currentState {
-		if _, exists := c.desiredState[containerName]; !exists {
-			if err := c.currentState.RemoveContainer(containerName); err != nil {
-				return fmt.Errorf("removing old container: %w", err)
-			}
+// restart stops and starts given container, so it picks up updated configuration files.
+func (c *containers) restart(containerName string) error {
+	hcc := c.currentState[containerName]
 
-			continue
-		}
+	fmt.Printf("Restarting container %q to apply configuration changes\n", containerName)
+
+	if err := hcc.Stop(); err != nil {
+		return fmt.Errorf("stopping container %q: %w", containerName, err)
+	}
 
-		if err := c.ensureUpToDate(containerName); err != nil {
-			return fmt.Errorf("ensuring, that container %q is up to date: %w", containerName, err)
+	if err := hcc.Start(); err != nil {
+		return fmt.Errorf("starting container %q: %w", containerName, err)
+	}
+
+	return nil
+}
+
+// updateExistingContainer handles updating existing container. It either removes it
+// if it is not needed anymore or makes sure that it's configuration is up to date.
+func (c *containers) updateExistingContainer(containerName string) error {
+	if _, exists := c.desiredState[containerName]; !exists {
+		if err := c.currentState.RemoveContainer(containerName); err != nil {
+			return fmt.Errorf("removing old container: %w", err)
 		}
+
+		return nil
+	}
+
+	if err := c.ensureUpToDate(containerName); err != nil {
+		return fmt.Errorf("ensuring, that container %q is up to date: %w", containerName, err)
 	}
 
 	return nil
 }
 
+// singleContainer returns copy of containers, which only holds given container in
+// current and desired state. This allows to modify the states of different containers
+// concurrently.
+func (c *containers) singleContainer(containerName string) *containers {
+	sc := &containers{
+		currentState:   containersState{},
+		desiredState:   containersState{},
+		parallelism:    1,
+		updateStrategy: c.updateStrategy,
+		readinessCheck: c.readinessCheck,
+	}
+
+	if hcc, ok := c.currentState[containerName]; ok {
+		sc.currentState[containerName] = hcc
+	}
+
+	if hcc, ok := c.desiredState[containerName]; ok {
+		sc.desiredState[containerName] = hcc
+	}
+
+	return sc
+}
+
+// mergeCurrentState copies current state of given container from single container copy
+// created by singleContainer.
+func (c *containers) mergeCurrentState(containerName string, sc *containers) {
+	if hcc, ok := sc.currentState[containerName]; ok {
+		c.currentState[containerName] = hcc
+
+		return
+	}
+
+	delete(c.currentState, containerName)
+}
+
+// forEachContainer executes given action for every container present in given state, running
+// at most given number of actions at the same time. Each action operates on a copy of containers
+// holding only single container, which is merged back to the current state once the action finishes,
+// even if it fails, so the state of successfully deployed containers can be persisted.
+//
+// Errors returned by the actions are collected and returned together.
+func (c *containers) forEachContainer(
+	state containersState,
+	parallelism int,
+	action func(*containers, string) error,
+) error {
+	containerNames := []string{}
+
+	for containerName := range state {
+		containerNames = append(containerNames, containerName)
+	}
+
+	sort.Strings(containerNames)
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		errors util.ValidateErrors
+	)
+
+	workers := make(chan struct{}, parallelism)
+
+	for _, containerName := range containerNames {
+		workers <- struct{}{}
+
+		mutex.Lock()
+		sc := c.singleContainer(containerName)
+		mutex.Unlock()
+
+		wg.Add(1)
+
+		go func(containerName string, sc *containers) {
+			defer wg.Done()
+
+			err := action(sc, containerName)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			c.mergeCurrentState(containerName, sc)
+
+			if err != nil {
+				errors = append(errors, fmt.Errorf("container %q: %w", containerName, err))
+			}
+
+			<-workers
+		}(containerName, sc)
+	}
+
+	wg.Wait()
+
+	return errors.Return()
+}
+
 // Deploy checks for containers configuration drifts and tries to reach desired state.
 //
-// TODO we should break down this function into smaller functions
-// TODO add planning, so it is possible to inspect what will be done
 // TODO currently we only compare previous configuration with new configuration.
 // We should also read runtime parameters and confirm that everything is according
 // to the spec.
@@ -511,24 +714,23 @@ func (c *containers) Deploy() error {
 
 	fmt.Println("Checking for stopped and missing containers")
 
-	for containerName, stateHCC := range c.currentState {
-		d, err := c.ensureCurrentContainer(containerName, *stateHCC)
+	err := c.forEachContainer(c.currentState, c.parallelism, func(sc *containers, containerName string) error {
+		d, err := sc.ensureCurrentContainer(containerName, *sc.currentState[containerName])
 
 		if d != nil {
-			c.currentState[containerName] = d
+			sc.currentState[containerName] = d
 		}
 
-		if err != nil {
-			return fmt.Errorf("handling existing container %q: %w", containerName, err)
-		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("handling existing containers: %w", err)
 	}
 
 	fmt.Println("Configuring and creating new containers")
 
-	for containerName := range c.desiredState {
-		if err := c.ensureNewContainer(containerName); err != nil {
-			return fmt.Errorf("creating new container %q: %w", containerName, err)
-		}
+	if err := c.forEachContainer(c.desiredState, c.parallelism, (*containers).ensureNewContainer); err != nil {
+		return fmt.Errorf("creating new containers: %w", err)
 	}
 
 	fmt.Println("Updating existing containers")
@@ -564,8 +766,11 @@ func (c *containers) StateToYaml() ([]byte, error) {
 // ToExported converts containers struct to exported Containers.
 func (c *containers) ToExported() *Containers {
 	return &Containers{
-		PreviousState: c.previousState.Export(),
-		DesiredState:  c.desiredState.Export(),
+		PreviousState:  c.previousState.Export(),
+		DesiredState:   c.desiredState.Export(),
+		Parallelism:    c.parallelism,
+		UpdateStrategy: c.updateStrategy,
+		ReadinessCheck: c.readinessCheck,
 	}
 }
 
@@ -3,7 +3,6 @@ package container
 import (
 	"fmt"
 
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 )
 
@@ -133,19 +132,22 @@ func (s containersState) Export() ContainersState {
 	exportedState := ContainersState{}
 
 	for containerName, hcc := range s {
-		exportedHCC := &HostConfiguredContainer{ //nolint:forcetypeassert // False positive?.
+		exportedHCC := &HostConfiguredContainer{
 			Container: Container{
-				Config: hcc.container.Config(),
-				Runtime: RuntimeConfig{
-					Docker: hcc.container.RuntimeConfig().(*docker.Config),
-				},
+				Config:  hcc.container.Config(),
+				Runtime: runtimeConfigFrom(hcc.container.RuntimeConfig()),
 			},
 			Host:        hcc.host,
 			ConfigFiles: hcc.configFiles,
 		}
 
 		if s := hcc.container.Status(); s.ID != "" || s.Status != "" {
-			exportedHCC.Container.Status = s
+			status := *s
+
+			// Live configuration is always read from the runtime, so don't export it.
+			status.Config = nil
+
+			exportedHCC.Container.Status = &status
 		}
 
 		if exportedHCC.ConfigFiles == nil {
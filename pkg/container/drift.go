@@ -0,0 +1,208 @@
+package container
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+// configDrift compares recorded container configuration with the live configuration
+// reported by the runtime. It returns recorded configuration with drifted fields replaced
+// by their live values and human-readable list of drifted fields.
+//
+// Runtimes fill fields which were not set when creating the container with their defaults
+// (e.g. environment variables or arguments from the image), so optional fields are only
+// compared if they are set in the recorded configuration.
+func configDrift(recorded, live types.ContainerConfig) (types.ContainerConfig, []string) {
+	drifted := []string{}
+	config := recorded
+
+	if live.Image != "" && live.Image != recorded.Image {
+		drifted = append(drifted, "image")
+		config.Image = live.Image
+	}
+
+	if len(recorded.Args) > 0 && !reflect.DeepEqual(recorded.Args, live.Args) {
+		drifted = append(drifted, "args")
+		config.Args = live.Args
+	}
+
+	if len(recorded.Entrypoint) > 0 && !reflect.DeepEqual(recorded.Entrypoint, live.Entrypoint) {
+		drifted = append(drifted, "entrypoint")
+		config.Entrypoint = live.Entrypoint
+	}
+
+	if recorded.Privileged != live.Privileged {
+		drifted = append(drifted, "privileged")
+		config.Privileged = live.Privileged
+	}
+
+	for _, field := range []struct {
+		name     string
+		recorded *string
+		live     string
+	}{
+		{"networkMode", &config.NetworkMode, live.NetworkMode},
+		{"pidMode", &config.PidMode, live.PidMode},
+		{"ipcMode", &config.IpcMode, live.IpcMode},
+		{"user", &config.User, live.User},
+		{"group", &config.Group, live.Group},
+	} {
+		if *field.recorded != "" && *field.recorded != field.live {
+			drifted = append(drifted, field.name)
+			*field.recorded = field.live
+		}
+	}
+
+	// Published ports are ignored by the runtime when using host network.
+	if recorded.NetworkMode != "host" && !samePorts(recorded.Ports, live.Ports) {
+		drifted = append(drifted, "ports")
+		config.Ports = live.Ports
+	}
+
+	if driftedEnv, env := envDrift(recorded.Env, live.Env); len(driftedEnv) > 0 {
+		drifted = append(drifted, driftedEnv...)
+		config.Env = env
+	}
+
+	if driftedMounts, mounts := mountsDrift(recorded.Mounts, live.Mounts); len(driftedMounts) > 0 {
+		drifted = append(drifted, driftedMounts...)
+		config.Mounts = mounts
+	}
+
+	sort.Strings(drifted)
+
+	return config, drifted
+}
+
+// defaultPortProtocol is a protocol used by runtimes for published ports, when protocol
+// is not specified.
+const defaultPortProtocol = "tcp"
+
+// samePorts checks, if both lists contain the same ports, ignoring the order.
+func samePorts(recorded, live []types.PortMap) bool {
+	if len(recorded) != len(live) {
+		return false
+	}
+
+	key := func(p types.PortMap) string {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = defaultPortProtocol
+		}
+
+		return fmt.Sprintf("%s:%d/%s", p.IP, p.Port, protocol)
+	}
+
+	ports := map[string]int{}
+
+	for _, p := range recorded {
+		ports[key(p)]++
+	}
+
+	for _, p := range live {
+		ports[key(p)]--
+	}
+
+	for _, count := range ports {
+		if count != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// envDrift returns list of recorded environment variables, which have different
+// value in the live configuration, together with updated environment variables.
+func envDrift(recorded, live map[string]string) ([]string, map[string]string) {
+	drifted := []string{}
+	env := map[string]string{}
+
+	for k, v := range recorded {
+		liveValue, ok := live[k]
+
+		if !ok || liveValue != v {
+			drifted = append(drifted, fmt.Sprintf("env %q", k))
+		}
+
+		if ok {
+			env[k] = liveValue
+		}
+	}
+
+	return drifted, env
+}
+
+// mountsDrift returns list of recorded mounts, which are missing or differ in the
+// live configuration, together with updated mounts.
+func mountsDrift(recorded, live []types.Mount) ([]string, []types.Mount) {
+	drifted := []string{}
+	mounts := []types.Mount{}
+
+	liveMounts := map[string]types.Mount{}
+
+	for _, m := range live {
+		liveMounts[strings.TrimSuffix(m.Target, "/")] = m
+	}
+
+	for _, m := range recorded {
+		liveMount, ok := liveMounts[strings.TrimSuffix(m.Target, "/")]
+		if !ok {
+			drifted = append(drifted, fmt.Sprintf("mount %q", m.Target))
+
+			continue
+		}
+
+		updatedMount := m
+
+		if strings.TrimSuffix(liveMount.Source, "/") != strings.TrimSuffix(m.Source, "/") {
+			updatedMount.Source = liveMount.Source
+		}
+
+		if m.Propagation != "" && liveMount.Propagation != m.Propagation {
+			updatedMount.Propagation = liveMount.Propagation
+		}
+
+		if updatedMount != m {
+			drifted = append(drifted, fmt.Sprintf("mount %q", m.Target))
+		}
+
+		mounts = append(mounts, updatedMount)
+	}
+
+	return drifted, mounts
+}
+
+// detectDrift compares configuration of existing containers with their live configuration
+// reported by the runtime. If they differ, the drift is reported and the current state is
+// updated with live configuration, so Deploy() will recreate drifted containers.
+func (c *containers) detectDrift() {
+	containerNames := []string{}
+
+	for containerName := range c.currentState {
+		containerNames = append(containerNames, containerName)
+	}
+
+	sort.Strings(containerNames)
+
+	for _, containerName := range containerNames {
+		hcc := c.currentState[containerName]
+		if hcc == nil || !hcc.container.Status().Exists() || hcc.container.Status().Config == nil {
+			continue
+		}
+
+		config, drifted := configDrift(hcc.container.Config(), *hcc.container.Status().Config)
+		if len(drifted) == 0 {
+			continue
+		}
+
+		fmt.Printf("Detected out-of-band configuration drift for container %q: %s\n",
+			containerName, strings.Join(drifted, ", "))
+
+		hcc.container.SetConfig(config)
+	}
+}
@@ -0,0 +1,216 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+func driftTestConfig() types.ContainerConfig {
+	return types.ContainerConfig{
+		Name:        testContainerName,
+		Image:       testImage,
+		Args:        []string{"--foo"},
+		NetworkMode: "host",
+		Env: map[string]string{
+			"FOO": "bar",
+		},
+		Mounts: []types.Mount{
+			{
+				Source: "/foo/",
+				Target: "/bar",
+			},
+		},
+	}
+}
+
+// configDrift() tests.
+func TestConfigDriftNoDrift(t *testing.T) {
+	t.Parallel()
+
+	live := driftTestConfig()
+
+	// Runtime defaults should not be reported as drift.
+	live.Env["PATH"] = "/usr/bin"
+	live.IpcMode = "private"
+	live.Mounts = append(live.Mounts, types.Mount{
+		Source:      "/baz",
+		Target:      "/baz",
+		Propagation: "rprivate",
+	})
+	live.Mounts[0].Source = "/foo"
+	live.Ports = []types.PortMap{
+		{
+			Port:     80,
+			Protocol: "tcp",
+		},
+	}
+
+	if _, drifted := configDrift(driftTestConfig(), live); len(drifted) != 0 {
+		t.Fatalf("No drift should be detected, got: %v", drifted)
+	}
+}
+
+func TestConfigDrift(t *testing.T) {
+	t.Parallel()
+
+	live := driftTestConfig()
+	live.Image = testAnotherImage
+	live.Args = []string{"--bar"}
+	live.Privileged = true
+	live.NetworkMode = "bridge"
+	live.Env = map[string]string{}
+	live.Mounts = []types.Mount{}
+
+	config, drifted := configDrift(driftTestConfig(), live)
+
+	expectedDrift := []string{
+		"args",
+		`env "FOO"`,
+		"image",
+		`mount "/bar"`,
+		"networkMode",
+		"privileged",
+	}
+
+	if diff := cmp.Diff(expectedDrift, drifted); diff != "" {
+		t.Fatalf("Unexpected drift: %s", diff)
+	}
+
+	expectedConfig := live
+	expectedConfig.Name = testContainerName
+
+	if diff := cmp.Diff(expectedConfig, config); diff != "" {
+		t.Fatalf("Drifted fields should be updated with live values: %s", diff)
+	}
+}
+
+func TestConfigDriftPorts(t *testing.T) {
+	t.Parallel()
+
+	recorded := types.ContainerConfig{
+		Image: testImage,
+		Ports: []types.PortMap{
+			{
+				Port:     80,
+				Protocol: "tcp",
+			},
+			{
+				Port:     443,
+				Protocol: "tcp",
+			},
+		},
+	}
+
+	live := recorded
+	live.Ports = []types.PortMap{recorded.Ports[1], recorded.Ports[0]}
+
+	if _, drifted := configDrift(recorded, live); len(drifted) != 0 {
+		t.Fatalf("Order of ports should be ignored, got: %v", drifted)
+	}
+
+	live.Ports = live.Ports[:1]
+
+	if _, drifted := configDrift(recorded, live); len(drifted) != 1 {
+		t.Fatalf("Removed port should be reported as drift, got: %v", drifted)
+	}
+}
+
+func TestConfigDriftPortsDefaultProtocol(t *testing.T) {
+	t.Parallel()
+
+	recorded := types.ContainerConfig{
+		Image: testImage,
+		Ports: []types.PortMap{
+			{
+				IP:   "127.0.0.1",
+				Port: 80,
+			},
+		},
+	}
+
+	live := recorded
+	live.Ports = []types.PortMap{
+		{
+			IP:       "127.0.0.1",
+			Port:     80,
+			Protocol: "tcp",
+		},
+	}
+
+	if _, drifted := configDrift(recorded, live); len(drifted) != 0 {
+		t.Fatalf("Empty protocol should be treated as TCP, got: %v", drifted)
+	}
+
+	live.Ports[0].Protocol = "udp"
+
+	if _, drifted := configDrift(recorded, live); len(drifted) != 1 {
+		t.Fatalf("Changed protocol should be reported as drift, got: %v", drifted)
+	}
+}
+
+// detectDrift() tests.
+func TestDetectDrift(t *testing.T) {
+	t.Parallel()
+
+	live := driftTestConfig()
+	live.Image = testAnotherImage
+
+	stateHCC := planTestHCC(testContainerID, driftTestConfig(), nil)
+	stateHCC.container.Status().Config = &live
+
+	testContainers := &containers{
+		currentState: containersState{
+			testContainerName: stateHCC,
+			"missing":         planTestHCC("", driftTestConfig(), nil),
+		},
+		desiredState: containersState{
+			testContainerName: planTestHCC("", driftTestConfig(), nil),
+		},
+	}
+
+	testContainers.detectDrift()
+
+	if image := stateHCC.container.Config().Image; image != testAnotherImage {
+		t.Fatalf("Current state should be updated with live image, got %q", image)
+	}
+
+	plan, err := testContainers.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	expectedPlan := Plan{
+		{
+			Container: testContainerName,
+			Action:    ActionRecreate,
+			Reasons:   []string{"container configuration changed"},
+		},
+	}
+
+	if diff := cmp.Diff(expectedPlan, plan); diff != "" {
+		t.Fatalf("Drifted container should be recreated: %s", diff)
+	}
+}
+
+// Export() tests.
+func TestExportSkipsLiveConfig(t *testing.T) {
+	t.Parallel()
+
+	live := driftTestConfig()
+
+	stateHCC := planTestHCC(testContainerID, driftTestConfig(), nil)
+	stateHCC.container.Status().Config = &live
+
+	exported := containersState{testContainerName: stateHCC}.Export()
+
+	if exported[testContainerName].Container.Status.Config != nil {
+		t.Fatalf("Live configuration should not be exported")
+	}
+
+	if stateHCC.container.Status().Config == nil {
+		t.Fatalf("Exporting should not modify the state")
+	}
+}
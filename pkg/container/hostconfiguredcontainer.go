@@ -7,6 +7,7 @@ import (
 
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
 
 // ResourceInstance interface represents struct, which can be converted to HostConfiguredContainer.
@@ -136,24 +137,40 @@ func (m *HostConfiguredContainer) Validate() error {
 // connectAndForward instantiates new host object, connects to it and then
 // forwards given UNIX socket using this connection.
 //
-// It returns address of local UNIX socket, where user can connect.
-func (m *hostConfiguredContainer) connectAndForward(targetAddress string) (string, error) {
+// It returns address of local UNIX socket, where user can connect and function, which
+// should be called, when forwarded socket is no longer needed.
+func (m *hostConfiguredContainer) connectAndForward(targetAddress string) (string, func(), error) {
 	h, err := m.host.New()
 	if err != nil {
-		return "", fmt.Errorf("initializing host: %w", err)
+		return "", nil, fmt.Errorf("initializing host: %w", err)
 	}
 
 	hc, err := h.Connect()
+
+	// Transport is only needed for establishing the connection.
+	if closeErr := h.Close(); closeErr != nil {
+		fmt.Printf("Failed closing host transport: %v\n", closeErr)
+	}
+
 	if err != nil {
-		return "", fmt.Errorf("connecting: %w", err)
+		return "", nil, fmt.Errorf("connecting: %w", err)
 	}
 
 	s, err := hc.ForwardUnixSocket(targetAddress)
 	if err != nil {
-		return "", fmt.Errorf("forwarding unix socket: %w", err)
+		closeConnected(hc)
+
+		return "", nil, fmt.Errorf("forwarding unix socket: %w", err)
 	}
 
-	return s, nil
+	return s, func() { closeConnected(hc) }, nil
+}
+
+// closeConnected closes given host connection, printing the error, as it is not critical.
+func closeConnected(hc transport.Connected) {
+	if err := hc.Close(); err != nil {
+		fmt.Printf("Failed closing host connection: %v\n", err)
+	}
 }
 
 // withForwardedRuntime takes action function as an argument and before executing it, it configures the runtime
@@ -164,11 +181,13 @@ func (m *hostConfiguredContainer) withForwardedRuntime(action func() error) erro
 	// Store originally configured address so we can restore it later.
 	oldAddress := oldRuntimeConfig.GetAddress()
 
-	newAddress, err := m.connectAndForward(oldAddress)
+	newAddress, release, err := m.connectAndForward(oldAddress)
 	if err != nil {
 		return fmt.Errorf("forwarding host: %w", err)
 	}
 
+	defer release()
+
 	// Override configuration with forwarded address and create Runtime from it.
 	oldRuntimeConfig.SetAddress(newAddress)
 
@@ -97,11 +97,13 @@ func TestConnectAndForward(t *testing.T) {
 		},
 	}
 
-	s, err := testHCC.connectAndForward(fmt.Sprintf("unix://%s", addr.String()))
+	s, release, err := testHCC.connectAndForward(fmt.Sprintf("unix://%s", addr.String()))
 	if err != nil {
 		t.Fatalf("Direct forwarding to open listener should work, got: %v", err)
 	}
 
+	defer release()
+
 	if s == "" {
 		t.Fatalf("Returned forwarded address shouldn't be empty")
 	}
@@ -0,0 +1,200 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/flexkube/libflexkube/pkg/host"
+)
+
+// ActionType describes, what kind of change will be made to the container during deployment.
+type ActionType string
+
+const (
+	// ActionCreate means, that container does not exist and will be created.
+	ActionCreate ActionType = "create"
+
+	// ActionRecreate means, that container or host configuration changed, so existing container
+	// will be removed and new one will be created.
+	ActionRecreate ActionType = "recreate"
+
+	// ActionUpdateConfigFiles means, that configuration files of the container will be updated.
+	ActionUpdateConfigFiles ActionType = "update-config-files"
+
+	// ActionRestart means, that configuration files of the container will be updated and then
+	// the container will be restarted, so it picks up the changes.
+	ActionRestart ActionType = "restart"
+
+	// ActionStart means, that container exists and is up to date, but it is not running, so it
+	// will be started.
+	ActionStart ActionType = "start"
+
+	// ActionRemove means, that container is no longer desired and will be removed.
+	ActionRemove ActionType = "remove"
+)
+
+// PlannedAction represents single change, which will be made to the container during deployment.
+type PlannedAction struct {
+	// Container is a name of the container, which will be modified.
+	Container string `json:"container"`
+
+	// Host describes the host, on which the container is or will be running.
+	Host string `json:"host,omitempty"`
+
+	// Action describes what will be done with the container.
+	Action ActionType `json:"action"`
+
+	// Reasons is a list of human-readable explanations, why given action will be executed.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Plan is a list of actions, which will be executed by Deploy() to reach the desired state.
+//
+// Plan is sorted by container name, so it can be serialized and compared with other plans.
+type Plan []PlannedAction
+
+// Plan returns list of actions, which will be executed by Deploy().
+//
+// CheckCurrentState() must be called before calling Plan(), otherwise error will be returned.
+func (c *containers) Plan() (Plan, error) {
+	if c.currentState == nil {
+		return nil, fmt.Errorf("can't plan without knowing current state of the containers")
+	}
+
+	plan := Plan{}
+
+	for containerName, stateHCC := range c.currentState {
+		action, err := c.planExisting(containerName, stateHCC)
+		if err != nil {
+			return nil, fmt.Errorf("planning container %q: %w", containerName, err)
+		}
+
+		if action != nil {
+			plan = append(plan, *action)
+		}
+	}
+
+	for containerName, targetHCC := range c.desiredState {
+		if stateHCC, ok := c.currentState[containerName]; ok && stateHCC != nil && stateHCC.container.Status().Exists() {
+			continue
+		}
+
+		reason := "container does not exist yet"
+
+		if _, ok := c.currentState[containerName]; ok {
+			reason = "container is missing on the host"
+		}
+
+		plan = append(plan, PlannedAction{
+			Container: containerName,
+			Host:      describeHost(targetHCC.host),
+			Action:    ActionCreate,
+			Reasons:   []string{reason},
+		})
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Container < plan[j].Container
+	})
+
+	return plan, nil
+}
+
+// planExisting returns action, which will be executed for container present in the current state.
+// If no action is needed, nil is returned.
+func (c *containers) planExisting(containerName string, stateHCC *hostConfiguredContainer) (*PlannedAction, error) {
+	// Containers which are gone will be created from scratch or simply dropped from the state.
+	if stateHCC == nil || !stateHCC.container.Status().Exists() {
+		return nil, nil //nolint:nilnil // Nil action means, that nothing will be done.
+	}
+
+	targetHCC, isDesired := c.desiredState[containerName]
+	if !isDesired {
+		return &PlannedAction{
+			Container: containerName,
+			Host:      describeHost(stateHCC.host),
+			Action:    ActionRemove,
+			Reasons:   []string{"container is no longer desired"},
+		}, nil
+	}
+
+	action, reasons, err := c.planUpdate(containerName)
+	if err != nil {
+		return nil, fmt.Errorf("planning update: %w", err)
+	}
+
+	if action == "" && !stateHCC.container.Status().Running() {
+		action = ActionStart
+		reasons = []string{fmt.Sprintf("container is not running, current status is %q", stateHCC.container.Status().Status)}
+	}
+
+	if action == "" {
+		return nil, nil //nolint:nilnil // Nil action means, that nothing will be done.
+	}
+
+	return &PlannedAction{
+		Container: containerName,
+		Host:      describeHost(targetHCC.host),
+		Action:    action,
+		Reasons:   reasons,
+	}, nil
+}
+
+// planUpdate returns action, which will be executed on existing and desired container together
+// with the reasons for it. If container is up to date, empty action is returned.
+func (c *containers) planUpdate(containerName string) (ActionType, []string, error) {
+	reasons := []string{}
+
+	diffHost, err := c.diffHost(containerName)
+	if err != nil {
+		return "", nil, fmt.Errorf("checking host diff: %w", err)
+	}
+
+	if diffHost != "" {
+		reasons = append(reasons, "host configuration changed")
+	}
+
+	diffContainer, err := c.diffContainer(containerName)
+	if err != nil {
+		return "", nil, fmt.Errorf("checking container diff: %w", err)
+	}
+
+	if diffContainer != "" {
+		reasons = append(reasons, "container configuration changed")
+	}
+
+	files := filesToUpdate(*c.desiredState[containerName], c.currentState[containerName])
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		reasons = append(reasons, fmt.Sprintf("configuration file %q changed", file))
+	}
+
+	switch {
+	case diffHost != "" || diffContainer != "":
+		return ActionRecreate, reasons, nil
+	case len(files) != 0 && c.restartOnConfigChange():
+		return ActionRestart, reasons, nil
+	case len(files) != 0:
+		return ActionUpdateConfigFiles, reasons, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// describeHost returns human-readable description of the host, which does not contain
+// any credentials.
+func describeHost(h host.Host) string {
+	if h.SSHConfig != nil {
+		return fmt.Sprintf("%s@%s", h.SSHConfig.User, net.JoinHostPort(h.SSHConfig.Address, strconv.Itoa(h.SSHConfig.Port)))
+	}
+
+	if h.DirectConfig != nil {
+		return "localhost"
+	}
+
+	return ""
+}
@@ -0,0 +1,191 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+func planTestHCC(id string, config types.ContainerConfig, configFiles map[string]string) *hostConfiguredContainer {
+	return &hostConfiguredContainer{
+		container: &container{
+			base: base{
+				config: config,
+				status: types.ContainerStatus{
+					ID:     id,
+					Status: "running",
+				},
+			},
+		},
+		configFiles: configFiles,
+	}
+}
+
+// Plan() tests.
+func TestPlanNoCurrentState(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (&containers{}).Plan(); err == nil {
+		t.Fatalf("Planning without current state should fail")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	movedHCC := planTestHCC("", types.ContainerConfig{}, nil)
+	movedHCC.host = host.Host{
+		DirectConfig: &direct.Config{},
+	}
+
+	stoppedHCC := planTestHCC(testContainerID, types.ContainerConfig{}, nil)
+	stoppedHCC.container.Status().Status = "exited"
+
+	testContainers := &containers{
+		currentState: containersState{
+			"up-to-date":   planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+			"removed":      planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+			"gone":         planTestHCC("", types.ContainerConfig{}, nil),
+			"gone-removed": planTestHCC("", types.ContainerConfig{}, nil),
+			"config-files": planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+			"changed":      planTestHCC(testContainerID, types.ContainerConfig{Image: "foo"}, nil),
+			"moved":        planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+			"stopped":      stoppedHCC,
+		},
+		desiredState: containersState{
+			"up-to-date":   planTestHCC("", types.ContainerConfig{}, nil),
+			"gone":         planTestHCC("", types.ContainerConfig{}, nil),
+			"config-files": planTestHCC("", types.ContainerConfig{}, map[string]string{testConfigPath: testConfigContent}),
+			"changed":      planTestHCC("", types.ContainerConfig{Image: "bar"}, nil),
+			"moved":        movedHCC,
+			"new":          planTestHCC("", types.ContainerConfig{}, nil),
+			"stopped":      planTestHCC("", types.ContainerConfig{}, nil),
+		},
+	}
+
+	plan, err := testContainers.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	expectedPlan := Plan{
+		{
+			Container: "changed",
+			Action:    ActionRecreate,
+			Reasons:   []string{"container configuration changed"},
+		},
+		{
+			Container: "config-files",
+			Action:    ActionUpdateConfigFiles,
+			Reasons:   []string{`configuration file "/tmp/foo" changed`},
+		},
+		{
+			Container: "gone",
+			Action:    ActionCreate,
+			Reasons:   []string{"container is missing on the host"},
+		},
+		{
+			Container: "moved",
+			Host:      "localhost",
+			Action:    ActionRecreate,
+			Reasons:   []string{"host configuration changed"},
+		},
+		{
+			Container: "new",
+			Action:    ActionCreate,
+			Reasons:   []string{"container does not exist yet"},
+		},
+		{
+			Container: "removed",
+			Action:    ActionRemove,
+			Reasons:   []string{"container is no longer desired"},
+		},
+		{
+			Container: "stopped",
+			Action:    ActionStart,
+			Reasons:   []string{`container is not running, current status is "exited"`},
+		},
+	}
+
+	if diff := cmp.Diff(expectedPlan, plan); diff != "" {
+		t.Fatalf("Unexpected plan: %s", diff)
+	}
+}
+
+func TestPlanRestartOnConfigChange(t *testing.T) {
+	t.Parallel()
+
+	testContainers := &containers{
+		currentState: containersState{
+			testContainerName: planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+		},
+		desiredState: containersState{
+			testContainerName: planTestHCC("", types.ContainerConfig{}, map[string]string{testConfigPath: testConfigContent}),
+		},
+		updateStrategy: &UpdateStrategy{
+			RestartOnConfigChange: true,
+		},
+	}
+
+	plan, err := testContainers.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	expectedPlan := Plan{
+		{
+			Container: testContainerName,
+			Action:    ActionRestart,
+			Reasons:   []string{`configuration file "/tmp/foo" changed`},
+		},
+	}
+
+	if diff := cmp.Diff(expectedPlan, plan); diff != "" {
+		t.Fatalf("Unexpected plan: %s", diff)
+	}
+}
+
+func TestPlanNoChanges(t *testing.T) {
+	t.Parallel()
+
+	testContainers := &containers{
+		currentState: containersState{
+			testContainerName: planTestHCC(testContainerID, types.ContainerConfig{}, nil),
+		},
+		desiredState: containersState{
+			testContainerName: planTestHCC("", types.ContainerConfig{}, nil),
+		},
+	}
+
+	plan, err := testContainers.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("Plan should be empty when there are no changes, got: %v", plan)
+	}
+}
+
+// describeHost() tests.
+func TestDescribeHostSSH(t *testing.T) {
+	t.Parallel()
+
+	h := host.Host{
+		SSHConfig: &ssh.Config{
+			Address:  "10.0.0.1",
+			Port:     22,
+			User:     "core",
+			Password: "secret",
+		},
+	}
+
+	if d := describeHost(h); d != "core@10.0.0.1:22" {
+		t.Fatalf("Unexpected host description: %q", d)
+	}
+}
@@ -22,6 +22,16 @@ type Containers struct {
 
 	// Containers stores user-provider containers to create.
 	Containers container.ContainersState `json:"containers,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// This field is optional. If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how many containers can be updated at the same time.
+	//
+	// This field is optional. If not set, containers will be updated one by one.
+	UpdateStrategy *container.UpdateStrategy `json:"updateStrategy,omitempty"`
 }
 
 // containers implements both container.ContainersInterface and types.Resource.
@@ -34,8 +44,10 @@ type containers struct {
 // This method will validate all the configuration provided.
 func (c *Containers) New() (types.Resource, error) {
 	containersConfig := container.Containers{
-		PreviousState: c.State,
-		DesiredState:  c.Containers,
+		PreviousState:  c.State,
+		DesiredState:   c.Containers,
+		Parallelism:    c.Parallelism,
+		UpdateStrategy: c.UpdateStrategy,
 	}
 
 	newContainers, err := containersConfig.New()
@@ -53,8 +65,10 @@ func (c *Containers) New() (types.Resource, error) {
 // Validate is also part of types.ResourceConfig interface.
 func (c *Containers) Validate() error {
 	co := container.Containers{
-		PreviousState: c.State,
-		DesiredState:  c.Containers,
+		PreviousState:  c.State,
+		DesiredState:   c.Containers,
+		Parallelism:    c.Parallelism,
+		UpdateStrategy: c.UpdateStrategy,
 	}
 
 	return co.Validate()
@@ -108,6 +122,13 @@ func (c *containers) DesiredState() container.ContainersState {
 	return c.containers.DesiredState()
 }
 
+// Plan returns list of actions, which will be executed by Deploy().
+//
+// Plan is part of container.ContainersInterface.
+func (c *containers) Plan() (container.Plan, error) {
+	return c.containers.Plan()
+}
+
 // Containers is part of container.ContainersInterface.
 func (c *containers) Containers() container.ContainersInterface {
 	return c.containers
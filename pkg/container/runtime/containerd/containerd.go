@@ -0,0 +1,1254 @@
+// Package containerd implements runtime.Interface and runtime.Config interfaces
+// by talking to containerd API.
+package containerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	containerdclient "github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/leases"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/pkg/dialer"
+	"github.com/containerd/containerd/runtime/restart"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+const (
+	// DefaultAddress is a default containerd socket address.
+	DefaultAddress = "unix:///run/containerd/containerd.sock"
+
+	// DefaultNamespace is a default containerd namespace, in which containers will be created.
+	DefaultNamespace = "flexkube"
+
+	// How long we wait when gracefully stopping the container before force-killing it.
+	stopTimeoutSeconds = 30
+
+	// How long leases for temporary content used for copying files are valid.
+	leaseExpiration = time.Hour
+
+	// statusCreated is reported when container exists, but it has no task created.
+	statusCreated = "created"
+
+	// argsCountLabel is a container label storing number of arguments passed to the container,
+	// so process arguments from the container spec can be split back into entrypoint and arguments.
+	argsCountLabel = "io.flexkube.args-count"
+
+	// execOutputPrefix is a prefix of the file in container root filesystem, where output
+	// of commands executed by exec is stored.
+	execOutputPrefix = ".flexkube-exec-"
+)
+
+// Config struct represents containerd container runtime configuration.
+type Config struct {
+	// Address is a containerd socket URL. If empty, DefaultAddress will be used.
+	Address string `json:"address,omitempty"`
+
+	// Namespace is a containerd namespace, in which containers will be managed. If empty,
+	// DefaultNamespace will be used.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ClientGetter allows to use custom containerd client.
+	ClientGetter func(address string, opts ...containerdclient.ClientOpt) (Client, error) `json:"-"`
+}
+
+// Client is a wrapper interface over
+// https://pkg.go.dev/github.com/containerd/containerd#Client
+// with the functions we use.
+type Client interface {
+	GetImage(ctx context.Context, ref string) (containerdclient.Image, error)
+	Pull(ctx context.Context, ref string, opts ...containerdclient.RemoteOpt) (containerdclient.Image, error)
+	NewContainer(
+		ctx context.Context,
+		id string,
+		opts ...containerdclient.NewContainerOpts,
+	) (containerdclient.Container, error)
+	LoadContainer(ctx context.Context, id string) (containerdclient.Container, error)
+	ContentStore() content.Store
+	SnapshotService(snapshotterName string) snapshots.Snapshotter
+	DiffService() containerdclient.DiffService
+	WithLease(ctx context.Context, opts ...leases.Opt) (context.Context, func(context.Context) error, error)
+}
+
+// containerd struct is a struct, which can be used to manage containerd containers.
+type containerd struct {
+	ctx context.Context //nolint:containedctx // Ignore until runtime interface supports context.
+	cli Client
+}
+
+// SetAddress sets runtime config address where it should connect.
+func (c *Config) SetAddress(s string) {
+	c.Address = s
+}
+
+// GetAddress returns configured container runtime address.
+func (c *Config) GetAddress() string {
+	if c != nil && c.Address != "" {
+		return c.Address
+	}
+
+	return DefaultAddress
+}
+
+// New validates containerd runtime configuration and returns configured
+// runtime client.
+func (c *Config) New() (runtime.Runtime, error) {
+	cli, err := c.getContainerdClient()
+	if err != nil {
+		return nil, fmt.Errorf("creating containerd client: %w", err)
+	}
+
+	namespace := DefaultNamespace
+	if c != nil && c.Namespace != "" {
+		namespace = c.Namespace
+	}
+
+	return &containerd{
+		ctx: namespaces.WithNamespace(context.Background(), namespace),
+		cli: cli,
+	}, nil
+}
+
+// getContainerdClient returns containerd client.
+func (c *Config) getContainerdClient() (Client, error) {
+	// containerd client expects socket path without the scheme.
+	address := strings.TrimPrefix(c.GetAddress(), "unix://")
+
+	// Default dial options block until connection is established, which is not desired,
+	// as runtime is usually created before the socket gets forwarded.
+	opts := []containerdclient.ClientOpt{
+		containerdclient.WithDialOpts([]grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(dialer.ContextDialer),
+		}),
+	}
+
+	if c != nil && c.ClientGetter != nil {
+		return c.ClientGetter(address, opts...)
+	}
+
+	return containerdclient.New(address, opts...)
+}
+
+// pullImageIfNotPresent pulls image if it's not already present on the host and returns it.
+func (d *containerd) pullImageIfNotPresent(image string) (containerdclient.Image, error) {
+	i, err := d.cli.GetImage(d.ctx, image)
+	if err == nil {
+		return i, nil
+	}
+
+	if !errdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("checking for image presence: %w", err)
+	}
+
+	i, err = d.cli.Pull(d.ctx, image, containerdclient.WithPullUnpack)
+	if err != nil {
+		return nil, fmt.Errorf("pulling image: %w", err)
+	}
+
+	return i, nil
+}
+
+// privilegedCapabilities is a list of capabilities added to privileged containers.
+//
+// Capabilities of current process cannot be used, as containerd is usually running on the remote host.
+func privilegedCapabilities() []string {
+	return []string{
+		"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_FSETID", "CAP_KILL",
+		"CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP", "CAP_LINUX_IMMUTABLE", "CAP_NET_BIND_SERVICE",
+		"CAP_NET_BROADCAST", "CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK", "CAP_IPC_OWNER", "CAP_SYS_MODULE",
+		"CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE", "CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT",
+		"CAP_SYS_NICE", "CAP_SYS_RESOURCE", "CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD", "CAP_LEASE",
+		"CAP_AUDIT_WRITE", "CAP_AUDIT_CONTROL", "CAP_SETFCAP", "CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG",
+		"CAP_WAKE_ALARM", "CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF", "CAP_CHECKPOINT_RESTORE",
+	}
+}
+
+// mounts converts container Mount to OCI mount type.
+func mounts(containerMounts []types.Mount) []specs.Mount {
+	ociMounts := []specs.Mount{}
+
+	for _, containerMount := range containerMounts {
+		options := []string{"rbind", "rw"}
+
+		if containerMount.Propagation != "" {
+			options = append(options, containerMount.Propagation)
+		}
+
+		ociMounts = append(ociMounts, specs.Mount{
+			Type:        "bind",
+			Source:      containerMount.Source,
+			Destination: containerMount.Target,
+			Options:     options,
+		})
+	}
+
+	return ociMounts
+}
+
+// withUser sets user and group of the container process.
+//
+// Only numeric values are supported, as resolving names requires access to container
+// root filesystem, which is not possible when talking to remote containerd.
+func withUser(user, group string) (oci.SpecOpts, error) {
+	uid, err := strconv.ParseUint(user, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("only numeric user is supported, got %q: %w", user, err)
+	}
+
+	gid := uint64(0)
+
+	if group != "" {
+		if gid, err = strconv.ParseUint(group, 10, 32); err != nil {
+			return nil, fmt.Errorf("only numeric group is supported, got %q: %w", group, err)
+		}
+	}
+
+	return oci.WithUIDGID(uint32(uid), uint32(gid)), nil
+}
+
+// hostNamespaces returns spec options for namespaces, which should be shared with the host.
+func hostNamespaces(config *types.ContainerConfig) ([]oci.SpecOpts, error) {
+	opts := []oci.SpecOpts{}
+
+	modes := map[specs.LinuxNamespaceType]string{
+		specs.NetworkNamespace: config.NetworkMode,
+		specs.PIDNamespace:     config.PidMode,
+		specs.IPCNamespace:     config.IpcMode,
+	}
+
+	for namespace, mode := range modes {
+		switch mode {
+		case "":
+		case "host":
+			opts = append(opts, oci.WithHostNamespace(namespace))
+		default:
+			return nil, fmt.Errorf("unsupported %s namespace mode %q, only 'host' is supported", namespace, mode)
+		}
+	}
+
+	if config.NetworkMode == "host" {
+		opts = append(opts, oci.WithHostHostsFile, oci.WithHostResolvconf)
+	}
+
+	return opts, nil
+}
+
+// specOpts converts container configuration to OCI spec options. Options derived from the image
+// are not included.
+func specOpts(config *types.ContainerConfig) ([]oci.SpecOpts, error) {
+	if len(config.Ports) > 0 && config.NetworkMode != "host" {
+		return nil, fmt.Errorf("port mappings are not supported, use 'host' network mode instead")
+	}
+
+	opts := []oci.SpecOpts{
+		oci.WithMounts(mounts(config.Mounts)),
+	}
+
+	// If only arguments are specified, they are combined with image entrypoint by imageSpecOpts.
+	if len(config.Entrypoint) > 0 {
+		opts = append(opts, oci.WithProcessArgs(append(append([]string{}, config.Entrypoint...), config.Args...)...))
+	}
+
+	env := []string{}
+	for k, v := range config.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(env)
+
+	opts = append(opts, oci.WithEnv(env))
+
+	if config.User != "" {
+		userOpt, err := withUser(config.User, config.Group)
+		if err != nil {
+			return nil, fmt.Errorf("setting user: %w", err)
+		}
+
+		opts = append(opts, userOpt)
+	}
+
+	namespaceOpts, err := hostNamespaces(config)
+	if err != nil {
+		return nil, fmt.Errorf("configuring namespaces: %w", err)
+	}
+
+	opts = append(opts, namespaceOpts...)
+
+	if config.Privileged {
+		opts = append(opts,
+			oci.WithCapabilities(privilegedCapabilities()),
+			oci.WithMaskedPaths(nil),
+			oci.WithReadonlyPaths(nil),
+			oci.WithWriteableSysfs,
+			oci.WithWriteableCgroupfs,
+			oci.WithApparmorProfile(""),
+			oci.WithSeccompUnconfined,
+			oci.WithAllDevicesAllowed,
+		)
+	}
+
+	return opts, nil
+}
+
+// imageSpecOpts returns spec options, which apply image defaults, like entrypoint and
+// environment variables.
+func imageSpecOpts(config *types.ContainerConfig, image containerdclient.Image) []oci.SpecOpts {
+	// If only arguments are specified, combine them with entrypoint from the image.
+	if len(config.Entrypoint) == 0 && len(config.Args) > 0 {
+		return []oci.SpecOpts{oci.WithImageConfigArgs(image, config.Args)}
+	}
+
+	return []oci.SpecOpts{oci.WithImageConfig(image)}
+}
+
+// Create creates containerd container.
+func (d *containerd) Create(config *types.ContainerConfig) (string, error) {
+	image, err := d.pullImageIfNotPresent(config.Image)
+	if err != nil {
+		return "", fmt.Errorf("pulling image: %w", err)
+	}
+
+	opts, err := specOpts(config)
+	if err != nil {
+		return "", fmt.Errorf("converting container config to OCI specification: %w", err)
+	}
+
+	// Options from the image must be applied first, so they can be overridden by the
+	// container configuration.
+	imageOpts := imageSpecOpts(config, image)
+
+	c, err := d.cli.NewContainer(d.ctx, config.Name,
+		containerdclient.WithImage(image),
+		containerdclient.WithNewSnapshot(fmt.Sprintf("%s-snapshot", config.Name), image),
+		containerdclient.WithNewSpec(append(imageOpts, opts...)...),
+		containerdclient.WithAdditionalContainerLabels(map[string]string{
+			argsCountLabel: strconv.Itoa(len(config.Args)),
+		}),
+		// Equivalent of Docker 'unless-stopped' restart policy.
+		containerdclient.NewContainerOpts(restart.WithStatus(containerdclient.Stopped)),
+	)
+	if err != nil {
+		return "", fmt.Errorf("creating container: %w", err)
+	}
+
+	return c.ID(), nil
+}
+
+// task returns task of given container. If task does not exist, nil is returned.
+func (d *containerd) task(c containerdclient.Container) (containerdclient.Task, error) {
+	t, err := c.Task(d.ctx, nil)
+	if err == nil {
+		return t, nil
+	}
+
+	if errdefs.IsNotFound(err) {
+		return nil, nil //nolint:nilnil // Nil task means, that task does not exist.
+	}
+
+	return nil, fmt.Errorf("getting container task: %w", err)
+}
+
+// deleteTask removes given task, killing it if it's still running.
+func (d *containerd) deleteTask(t containerdclient.Task) error {
+	if _, err := t.Delete(d.ctx, containerdclient.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("deleting task: %w", err)
+	}
+
+	return nil
+}
+
+// Start starts containerd container.
+func (d *containerd) Start(id string) error {
+	c, err := d.cli.LoadContainer(d.ctx, id)
+	if err != nil {
+		return fmt.Errorf("loading container: %w", err)
+	}
+
+	// Remove old, stopped task, as new one cannot be created otherwise.
+	t, err := d.task(c)
+	if err != nil {
+		return fmt.Errorf("checking for existing task: %w", err)
+	}
+
+	if t != nil {
+		if err := d.deleteTask(t); err != nil {
+			return fmt.Errorf("removing old task: %w", err)
+		}
+	}
+
+	t, err = c.NewTask(d.ctx, cio.NullIO)
+	if err != nil {
+		return fmt.Errorf("creating task: %w", err)
+	}
+
+	if err := t.Start(d.ctx); err != nil {
+		return fmt.Errorf("starting task: %w", err)
+	}
+
+	// Mark container as running, so restart monitor will keep it running.
+	err = c.Update(d.ctx, containerdclient.UpdateContainerOpts(restart.WithStatus(containerdclient.Running)))
+	if err != nil {
+		return fmt.Errorf("enabling restart monitor: %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops containerd container.
+func (d *containerd) Stop(id string) error {
+	c, err := d.cli.LoadContainer(d.ctx, id)
+	if err != nil {
+		return fmt.Errorf("loading container: %w", err)
+	}
+
+	// Mark container as stopped first, so restart monitor won't start it again.
+	err = c.Update(d.ctx, containerdclient.UpdateContainerOpts(restart.WithStatus(containerdclient.Stopped)))
+	if err != nil {
+		return fmt.Errorf("disabling restart monitor: %w", err)
+	}
+
+	t, err := d.task(c)
+	if err != nil || t == nil {
+		return err
+	}
+
+	exitCh, err := t.Wait(d.ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for task: %w", err)
+	}
+
+	if err := t.Kill(d.ctx, syscall.SIGTERM); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("sending SIGTERM to the task: %w", err)
+	}
+
+	select {
+	case <-exitCh:
+	case <-time.After(stopTimeoutSeconds * time.Second):
+		if err := t.Kill(d.ctx, syscall.SIGKILL); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("sending SIGKILL to the task: %w", err)
+		}
+
+		<-exitCh
+	}
+
+	return d.deleteTask(t)
+}
+
+// Status returns container status.
+func (d *containerd) Status(id string) (types.ContainerStatus, error) {
+	containerStatus := types.ContainerStatus{
+		ID: id,
+	}
+
+	c, err := d.cli.LoadContainer(d.ctx, id)
+	if err != nil {
+		// If container is missing, return status with empty ID.
+		if errdefs.IsNotFound(err) {
+			containerStatus.ID = ""
+
+			return containerStatus, nil
+		}
+
+		return containerStatus, fmt.Errorf("loading container: %w", err)
+	}
+
+	if containerStatus.Config, err = d.containerConfig(c); err != nil {
+		return containerStatus, fmt.Errorf("getting container configuration: %w", err)
+	}
+
+	t, err := d.task(c)
+	if err != nil {
+		return containerStatus, fmt.Errorf("getting task: %w", err)
+	}
+
+	if t == nil {
+		containerStatus.Status = statusCreated
+
+		return containerStatus, nil
+	}
+
+	status, err := t.Status(d.ctx)
+	if err != nil {
+		return containerStatus, fmt.Errorf("getting task status: %w", err)
+	}
+
+	containerStatus.Status = string(status.Status)
+
+	return containerStatus, nil
+}
+
+// containerConfig returns configuration of given container, read from its OCI spec.
+func (d *containerd) containerConfig(c containerdclient.Container) (*types.ContainerConfig, error) {
+	info, err := c.Info(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting container info: %w", err)
+	}
+
+	spec, err := c.Spec(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting container spec: %w", err)
+	}
+
+	return containerConfigFromSpec(info, spec), nil
+}
+
+// containerConfigFromSpec converts container OCI spec back to ContainerConfig, so it can be
+// compared with configuration used for creating the container.
+func containerConfigFromSpec(info containers.Container, spec *oci.Spec) *types.ContainerConfig {
+	if spec == nil || spec.Process == nil {
+		return nil
+	}
+
+	config := &types.ContainerConfig{
+		Name:       info.ID,
+		Image:      info.Image,
+		Privileged: privileged(spec),
+		User:       strconv.FormatUint(uint64(spec.Process.User.UID), 10),
+		Group:      strconv.FormatUint(uint64(spec.Process.User.GID), 10),
+		Env:        map[string]string{},
+	}
+
+	config.Entrypoint, config.Args = splitArgs(spec.Process.Args, info.Labels[argsCountLabel])
+
+	for _, e := range spec.Process.Env {
+		k, v, _ := strings.Cut(e, "=")
+		config.Env[k] = v
+	}
+
+	config.NetworkMode = namespaceMode(spec, specs.NetworkNamespace)
+	config.PidMode = namespaceMode(spec, specs.PIDNamespace)
+	config.IpcMode = namespaceMode(spec, specs.IPCNamespace)
+
+	for _, m := range spec.Mounts {
+		// Mounts from the configuration are always read-write bind mounts. Other mounts are
+		// added by default spec or by host network mode, like /etc/hosts.
+		if m.Type != "bind" || !hasOption(m.Options, "rw") {
+			continue
+		}
+
+		config.Mounts = append(config.Mounts, types.Mount{
+			Source:      m.Source,
+			Target:      m.Destination,
+			Propagation: mountPropagation(m.Options),
+		})
+	}
+
+	return config
+}
+
+// splitArgs splits process arguments into entrypoint and given number of arguments. If number
+// of arguments is unknown, all process arguments are returned as entrypoint.
+func splitArgs(processArgs []string, argsCount string) ([]string, []string) {
+	count, err := strconv.Atoi(argsCount)
+	if err != nil || count < 0 || count > len(processArgs) {
+		return processArgs, nil
+	}
+
+	split := len(processArgs) - count
+
+	return processArgs[:split], processArgs[split:]
+}
+
+// privileged checks, if given spec has all capabilities added to privileged containers.
+func privileged(spec *oci.Spec) bool {
+	if spec.Process.Capabilities == nil {
+		return false
+	}
+
+	capabilities := map[string]struct{}{}
+
+	for _, capability := range spec.Process.Capabilities.Bounding {
+		capabilities[capability] = struct{}{}
+	}
+
+	for _, capability := range privilegedCapabilities() {
+		if _, ok := capabilities[capability]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// namespaceMode returns 'host', if container shares given namespace with the host.
+func namespaceMode(spec *oci.Spec, namespace specs.LinuxNamespaceType) string {
+	if spec.Linux == nil {
+		return "host"
+	}
+
+	for _, n := range spec.Linux.Namespaces {
+		if n.Type == namespace {
+			return ""
+		}
+	}
+
+	return "host"
+}
+
+// hasOption checks, if given mount options contain given option.
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mountPropagation returns propagation mode from given mount options.
+func mountPropagation(options []string) string {
+	for _, option := range options {
+		switch option {
+		case "private", "rprivate", "shared", "rshared", "slave", "rslave":
+			return option
+		}
+	}
+
+	return ""
+}
+
+// Delete removes the container.
+func (d *containerd) Delete(id string) error {
+	c, err := d.cli.LoadContainer(d.ctx, id)
+	if err != nil {
+		return fmt.Errorf("loading container: %w", err)
+	}
+
+	t, err := d.task(c)
+	if err != nil {
+		return fmt.Errorf("checking for existing task: %w", err)
+	}
+
+	if t != nil {
+		if err := d.deleteTask(t); err != nil {
+			return fmt.Errorf("removing task: %w", err)
+		}
+	}
+
+	return c.Delete(d.ctx, containerdclient.WithSnapshotCleanup)
+}
+
+// bindMountFor finds the most specific bind mount of the container containing given path.
+// It returns source of the bind mount on the host and the path relative to the bind mount.
+func bindMountFor(spec *oci.Spec, containerPath string) (string, string, bool) {
+	match := ""
+	source := ""
+
+	for _, m := range spec.Mounts {
+		// Mounts from the configuration are always read-write bind mounts. Other mounts are
+		// added by default spec or by host network mode, like /etc/hosts.
+		if m.Type != "bind" || !hasOption(m.Options, "rw") {
+			continue
+		}
+
+		destination := path.Clean(m.Destination)
+
+		if containerPath != destination && !strings.HasPrefix(containerPath, strings.TrimSuffix(destination, "/")+"/") {
+			continue
+		}
+
+		if len(destination) > len(match) {
+			match = destination
+			source = m.Source
+		}
+	}
+
+	if match == "" {
+		return "", "", false
+	}
+
+	return source, strings.TrimPrefix(strings.TrimPrefix(containerPath, match), "/"), true
+}
+
+// loadContainer loads container with given ID together with its OCI spec.
+func (d *containerd) loadContainer(id string) (containerdclient.Container, *oci.Spec, error) {
+	c, err := d.cli.LoadContainer(d.ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading container: %w", err)
+	}
+
+	spec, err := c.Spec(d.ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting container spec: %w", err)
+	}
+
+	return c, spec, nil
+}
+
+// bindMount returns mount, which can be passed to diff service to access given host path.
+func bindMount(hostPath string) []mount.Mount {
+	return []mount.Mount{
+		{
+			Type:    "bind",
+			Source:  hostPath,
+			Options: []string{"rbind", "rw"},
+		},
+	}
+}
+
+// containerMount represents part of container filesystem, which can be accessed by the diff service.
+type containerMount struct {
+	// mounts should be passed to the diff service to access the filesystem.
+	mounts []mount.Mount
+
+	// key identifies the mounts, so files on the same mounts can be grouped.
+	key string
+
+	// path is a path relative to the root of the mounts.
+	path string
+}
+
+// resolveMount returns part of container filesystem, where given container path is located.
+//
+// Paths on bind mounts are resolved to the source of the bind mount. All other paths are
+// resolved to the root filesystem snapshot of the container.
+func (d *containerd) resolveMount(
+	c containerdclient.Container,
+	spec *oci.Spec,
+	containerPath string,
+) (*containerMount, error) {
+	if source, relativePath, ok := bindMountFor(spec, containerPath); ok {
+		// Path is a bind mount itself, which may be a file, so use its parent directory.
+		if relativePath == "" {
+			source, relativePath = path.Dir(source), path.Base(source)
+		}
+
+		return &containerMount{
+			mounts: bindMount(source),
+			key:    "bind:" + source,
+			path:   relativePath,
+		}, nil
+	}
+
+	info, err := c.Info(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting container info: %w", err)
+	}
+
+	if info.SnapshotKey == "" {
+		return nil, fmt.Errorf("path %q is not on bind mount and container has no root filesystem snapshot", containerPath)
+	}
+
+	mounts, err := d.cli.SnapshotService(info.Snapshotter).Mounts(d.ctx, info.SnapshotKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting mounts of snapshot %q: %w", info.SnapshotKey, err)
+	}
+
+	return &containerMount{
+		mounts: mounts,
+		key:    "snapshot:" + info.SnapshotKey,
+		path:   strings.TrimPrefix(containerPath, "/"),
+	}, nil
+}
+
+// Copy takes list of files and copies them to the container using containerd diff service.
+//
+// Files are grouped by the part of container filesystem they belong to. Each group is packed
+// into TAR archive, uploaded to containerd content store and applied either on the source of
+// the bind mount or on the root filesystem snapshot of the container.
+func (d *containerd) Copy(id string, files []*types.File) error {
+	c, spec, err := d.loadContainer(id)
+	if err != nil {
+		return fmt.Errorf("loading container: %w", err)
+	}
+
+	// Keep order of mounts stable, so files are applied in predictable order.
+	mountsOrder := []string{}
+	containerMounts := map[string][]mount.Mount{}
+	mountFiles := map[string][]*types.File{}
+
+	for _, file := range files {
+		m, err := d.resolveMount(c, spec, path.Clean(file.Path))
+		if err != nil {
+			return fmt.Errorf("resolving path %q: %w", file.Path, err)
+		}
+
+		if _, ok := containerMounts[m.key]; !ok {
+			mountsOrder = append(mountsOrder, m.key)
+			containerMounts[m.key] = m.mounts
+		}
+
+		mountFile := *file
+		mountFile.Path = m.path
+
+		if strings.HasSuffix(file.Path, "/") {
+			mountFile.Path += "/"
+		}
+
+		mountFiles[m.key] = append(mountFiles[m.key], &mountFile)
+	}
+
+	ctx, done, err := d.cli.WithLease(d.ctx, leases.WithRandomID(), leases.WithExpiration(leaseExpiration))
+	if err != nil {
+		return fmt.Errorf("creating lease: %w", err)
+	}
+
+	defer done(d.ctx) //nolint:errcheck // Lease will expire anyway.
+
+	for _, key := range mountsOrder {
+		desc, err := d.uploadArchive(ctx, id, mountFiles[key])
+		if err != nil {
+			return fmt.Errorf("uploading files: %w", err)
+		}
+
+		if _, err := d.cli.DiffService().Apply(ctx, desc, containerMounts[key]); err != nil {
+			return fmt.Errorf("applying files: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// uploadArchive packs given files into TAR archive and uploads it to containerd content store.
+func (d *containerd) uploadArchive(ctx context.Context, id string, files []*types.File) (v1.Descriptor, error) {
+	archive, err := filesToTar(files)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("packing files to TAR archive: %w", err)
+	}
+
+	desc := v1.Descriptor{
+		MediaType: v1.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(archive),
+		Size:      int64(len(archive)),
+	}
+
+	ref := fmt.Sprintf("flexkube-copy-%s-%s", id, desc.Digest.Encoded())
+
+	if err := content.WriteBlob(ctx, d.cli.ContentStore(), ref, bytes.NewReader(archive), desc); err != nil {
+		return v1.Descriptor{}, fmt.Errorf("writing blob: %w", err)
+	}
+
+	return desc, nil
+}
+
+// filesToTar converts list of container files to tar archive format.
+func filesToTar(files []*types.File) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buf)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.Path,
+			Mode:     file.Mode,
+			Size:     int64(len(file.Content)),
+			ModTime:  time.Now(),
+			Uname:    file.User,
+			Gname:    file.Group,
+			Typeflag: tar.TypeReg,
+		}
+
+		if strings.HasSuffix(file.Path, "/") {
+			header.Typeflag = tar.TypeDir
+			header.Size = 0
+		}
+
+		if uid, err := strconv.Atoi(file.User); err == nil {
+			header.Uid = uid
+		}
+
+		if gid, err := strconv.Atoi(file.Group); err == nil {
+			header.Gid = gid
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("writing header: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if _, err := tarWriter.Write([]byte(file.Content)); err != nil {
+			return nil, fmt.Errorf("writing content: %w", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, fmt.Errorf("closing writer: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// execTask returns task, in which processes can be executed. If container has no task, new
+// task is created, but not started, and function removing it is returned.
+func (d *containerd) execTask(c containerdclient.Container) (containerdclient.Task, func(), error) {
+	t, err := d.task(c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting task: %w", err)
+	}
+
+	if t == nil {
+		if t, err = c.NewTask(d.ctx, cio.NullIO); err != nil {
+			return nil, nil, fmt.Errorf("creating task: %w", err)
+		}
+
+		cleanup := func() {
+			d.deleteTask(t) //nolint:errcheck // Task is only used for executing commands.
+		}
+
+		return t, cleanup, nil
+	}
+
+	s, err := t.Status(d.ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting task status: %w", err)
+	}
+
+	if s.Status != containerdclient.Running && s.Status != containerdclient.Created {
+		return nil, nil, fmt.Errorf("executing commands in task with status %q is not supported", s.Status)
+	}
+
+	return t, func() {}, nil
+}
+
+// runProcess executes process with given arguments in given task and waits for it to finish.
+func (d *containerd) runProcess(t containerdclient.Task, spec *oci.Spec, args ...string) error {
+	processSpec := *spec.Process
+	processSpec.Args = args
+	processSpec.Terminal = false
+	processSpec.Cwd = "/"
+	processSpec.User = specs.User{}
+
+	p, err := t.Exec(d.ctx, fmt.Sprintf("flexkube-%d", time.Now().UnixNano()), &processSpec, cio.NullIO)
+	if err != nil {
+		return fmt.Errorf("creating process: %w", err)
+	}
+
+	defer p.Delete(d.ctx) //nolint:errcheck // Process has already exited.
+
+	statusC, err := p.Wait(d.ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for process: %w", err)
+	}
+
+	if err := p.Start(d.ctx); err != nil {
+		return fmt.Errorf("starting process: %w", err)
+	}
+
+	status := <-statusC
+
+	code, _, err := status.Result()
+	if err != nil {
+		return fmt.Errorf("getting process exit status: %w", err)
+	}
+
+	if code != 0 {
+		return fmt.Errorf("process %q exited with code %d", args, code)
+	}
+
+	return nil
+}
+
+// exec runs given shell script as root in the container and returns its output.
+//
+// Output of the process cannot be streamed back, as FIFOs used by containerd are created on
+// the host where containerd runs. Instead, script gets path of the output file as a first
+// argument, followed by given arguments. Output file is created in the container root
+// filesystem, from where it's read using containerd diff service.
+//
+// Container image must provide 'sh' for this to work.
+func (d *containerd) exec(c containerdclient.Container, spec *oci.Spec, script string, args ...string) ([]byte, error) {
+	t, cleanup, err := d.execTask(c)
+	if err != nil {
+		return nil, fmt.Errorf("getting task for executing commands: %w", err)
+	}
+
+	defer cleanup()
+
+	outputName := fmt.Sprintf("%s%d", execOutputPrefix, time.Now().UnixNano())
+	outputPath := "/" + outputName
+
+	if err := d.runProcess(t, spec, append([]string{"sh", "-c", script, "sh", outputPath}, args...)...); err != nil {
+		return nil, fmt.Errorf("running script: %w", err)
+	}
+
+	output, err := d.readRootfsFile(c, outputName)
+
+	if err := d.runProcess(t, spec, "rm", "-f", "--", outputPath); err != nil {
+		return nil, fmt.Errorf("removing output file: %w", err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading output file: %w", err)
+	}
+
+	return output, nil
+}
+
+// readRootfsFile reads file with given name from the root filesystem of the container.
+//
+// containerd has no API for reading files from snapshots, so the changes made in the
+// container snapshot to its parent are archived using diff service and the file is
+// extracted from the archive. This means only files created or modified by the container
+// can be read.
+func (d *containerd) readRootfsFile(c containerdclient.Container, name string) ([]byte, error) {
+	info, err := c.Info(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting container info: %w", err)
+	}
+
+	if info.SnapshotKey == "" {
+		return nil, fmt.Errorf("container has no root filesystem snapshot")
+	}
+
+	ctx, done, err := d.cli.WithLease(d.ctx, leases.WithRandomID(), leases.WithExpiration(leaseExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("creating lease: %w", err)
+	}
+
+	defer done(d.ctx) //nolint:errcheck // Lease will expire anyway.
+
+	snapshotter := d.cli.SnapshotService(info.Snapshotter)
+
+	snapshotInfo, err := snapshotter.Stat(ctx, info.SnapshotKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshot %q info: %w", info.SnapshotKey, err)
+	}
+
+	upper, err := snapshotter.Mounts(ctx, info.SnapshotKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting mounts of snapshot %q: %w", info.SnapshotKey, err)
+	}
+
+	viewKey := fmt.Sprintf("%s-view-%d", info.SnapshotKey, time.Now().UnixNano())
+
+	lower, err := snapshotter.View(ctx, viewKey, snapshotInfo.Parent)
+	if err != nil {
+		return nil, fmt.Errorf("creating view of snapshot %q: %w", snapshotInfo.Parent, err)
+	}
+
+	defer snapshotter.Remove(ctx, viewKey) //nolint:errcheck // View is also removed with the lease.
+
+	desc, err := d.cli.DiffService().Compare(ctx, lower, upper, diff.WithMediaType(v1.MediaTypeImageLayer))
+	if err != nil {
+		return nil, fmt.Errorf("archiving changes in snapshot %q: %w", info.SnapshotKey, err)
+	}
+
+	archive, err := content.ReadBlob(ctx, d.cli.ContentStore(), desc)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	file, err := fileFromTar(archive, name)
+	if err != nil {
+		return nil, fmt.Errorf("extracting file from archive: %w", err)
+	}
+
+	if file == nil {
+		return nil, fmt.Errorf("file %q not found in root filesystem", name)
+	}
+
+	return []byte(file.Content), nil
+}
+
+// readScript archives regular files from given paths, which exist, into TAR archive. Symbolic
+// links are followed. If none of the files exist, empty output is written.
+const readScript = `out="$1"; shift
+for p; do shift; if [ -f "$p" ]; then set -- "$@" "${p#/}"; fi; done
+if [ $# -eq 0 ]; then : > "$out"; exit 0; fi
+exec tar -chf "$out" -C / -- "$@"`
+
+// Read reads files from container.
+//
+// Files are archived by 'tar' executed in the container, so container image must provide
+// 'sh' and 'tar'. Files, which do not exist, are skipped.
+func (d *containerd) Read(id string, srcPaths []string) ([]*types.File, error) {
+	files := []*types.File{}
+
+	if len(srcPaths) == 0 {
+		return files, nil
+	}
+
+	c, spec, err := d.loadContainer(id)
+	if err != nil {
+		return nil, fmt.Errorf("loading container: %w", err)
+	}
+
+	paths := []string{}
+
+	for _, srcPath := range srcPaths {
+		paths = append(paths, path.Clean(srcPath))
+	}
+
+	archive, err := d.exec(c, spec, readScript, paths...)
+	if err != nil {
+		return nil, fmt.Errorf("archiving files: %w", err)
+	}
+
+	for i, p := range paths {
+		file, err := fileFromTar(archive, strings.TrimPrefix(p, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("extracting file %q from archive: %w", srcPaths[i], err)
+		}
+
+		// File does not exist.
+		if file == nil {
+			continue
+		}
+
+		file.Path = srcPaths[i]
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// fileFromTar extracts file with a given name from tar archive. If file is not found, nil
+// is returned. Hard links are resolved to the file they point to.
+func fileFromTar(archive []byte, name string) (*types.File, error) {
+	tarReader := tar.NewReader(bytes.NewReader(archive))
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, nil //nolint:nilnil // Nil file means, that file does not exist.
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unpacking tar header: %w", err)
+		}
+
+		if path.Clean(header.Name) != name {
+			continue
+		}
+
+		// When archiving, files with the same inode are stored as hard links to the first one.
+		if header.Typeflag == tar.TypeLink && path.Clean(header.Linkname) != name {
+			return fileFromTar(archive, path.Clean(header.Linkname))
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+
+		if _, err := buf.ReadFrom(tarReader); err != nil {
+			return nil, fmt.Errorf("reading from tar archive: %w", err)
+		}
+
+		return &types.File{
+			User:    strconv.Itoa(header.Uid),
+			Group:   strconv.Itoa(header.Gid),
+			Content: buf.String(),
+			Mode:    header.Mode,
+		}, nil
+	}
+}
+
+// statScript prints raw mode in hex of each given path or '-', if path does not exist.
+const statScript = `out="$1"; shift
+for p; do if [ -e "$p" ] || [ -L "$p" ]; then stat -c %f -- "$p" || exit 1; else echo -; fi; done > "$out"`
+
+// Stat check if given paths exist on the container.
+//
+// Paths are checked by 'stat' executed in the container, so container image must provide
+// 'sh' and 'stat'. Paths, which do not exist, are not included in the result.
+func (d *containerd) Stat(id string, paths []string) (map[string]os.FileMode, error) {
+	result := map[string]os.FileMode{}
+
+	if len(paths) == 0 {
+		return result, nil
+	}
+
+	c, spec, err := d.loadContainer(id)
+	if err != nil {
+		return nil, fmt.Errorf("loading container: %w", err)
+	}
+
+	output, err := d.exec(c, spec, statScript, paths...)
+	if err != nil {
+		return nil, fmt.Errorf("checking paths: %w", err)
+	}
+
+	modes := strings.Fields(string(output))
+	if len(modes) != len(paths) {
+		return nil, fmt.Errorf("expected %d file modes, got %d: %q", len(paths), len(modes), output)
+	}
+
+	for i, p := range paths {
+		if modes[i] == "-" {
+			continue
+		}
+
+		mode, err := strconv.ParseUint(modes[i], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing mode %q of path %q: %w", modes[i], p, err)
+		}
+
+		result[p] = fileMode(uint32(mode))
+	}
+
+	return result, nil
+}
+
+// fileMode converts raw Unix file mode to os.FileMode.
+func fileMode(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode & 0o777)
+
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		fileMode |= os.ModeDir
+	case syscall.S_IFLNK:
+		fileMode |= os.ModeSymlink
+	case syscall.S_IFIFO:
+		fileMode |= os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		fileMode |= os.ModeSocket
+	case syscall.S_IFBLK:
+		fileMode |= os.ModeDevice
+	case syscall.S_IFCHR:
+		fileMode |= os.ModeDevice | os.ModeCharDevice
+	}
+
+	if mode&syscall.S_ISUID != 0 {
+		fileMode |= os.ModeSetuid
+	}
+
+	if mode&syscall.S_ISGID != 0 {
+		fileMode |= os.ModeSetgid
+	}
+
+	if mode&syscall.S_ISVTX != 0 {
+		fileMode |= os.ModeSticky
+	}
+
+	return fileMode
+}
+
+// DefaultConfig returns containerd's runtime default configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Address:   DefaultAddress,
+		Namespace: DefaultNamespace,
+	}
+}
@@ -0,0 +1,195 @@
+package containerd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/google/go-cmp/cmp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+// containerConfigFromSpec() tests.
+func TestContainerConfigFromSpecRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	config := &types.ContainerConfig{
+		Name:       "foo",
+		Image:      "busybox:latest",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Args:       []string{"sleep infinity"},
+		User:       "1000",
+		Group:      "1001",
+		Env: map[string]string{
+			"FOO": "bar",
+		},
+		Privileged:  true,
+		NetworkMode: "host",
+		PidMode:     "host",
+		Mounts: []types.Mount{
+			{
+				Source:      "/",
+				Target:      "/mnt/host",
+				Propagation: "rshared",
+			},
+			{
+				Source: "/etc/kubernetes",
+				Target: "/etc/kubernetes",
+			},
+		},
+	}
+
+	opts, err := specOpts(config)
+	if err != nil {
+		t.Fatalf("Converting config to spec options should succeed, got: %v", err)
+	}
+
+	info := containers.Container{
+		ID:    config.Name,
+		Image: config.Image,
+		Labels: map[string]string{
+			argsCountLabel: strconv.Itoa(len(config.Args)),
+		},
+	}
+
+	spec, err := oci.GenerateSpec(namespaces.WithNamespace(context.Background(), "default"), nil, &info, opts...)
+	if err != nil {
+		t.Fatalf("Generating spec should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(config, containerConfigFromSpec(info, spec)); diff != "" {
+		t.Fatalf("Configuration read from spec should be the same as created one: %s", diff)
+	}
+}
+
+func TestContainerConfigFromSpecNoArgsCount(t *testing.T) {
+	t.Parallel()
+
+	spec := &oci.Spec{
+		Process: &specs.Process{
+			Args: []string{"/bin/sh", "-c", "sleep infinity"},
+		},
+	}
+
+	config := containerConfigFromSpec(containers.Container{}, spec)
+
+	if diff := cmp.Diff(spec.Process.Args, config.Entrypoint); diff != "" {
+		t.Fatalf("Without args count, all arguments should be reported as entrypoint: %s", diff)
+	}
+
+	if len(config.Args) != 0 {
+		t.Fatalf("Without args count, no arguments should be reported, got: %v", config.Args)
+	}
+}
+
+// runScript runs given script locally the same way as it's executed in the container
+// and returns its output.
+func runScript(t *testing.T, script string, args ...string) []byte {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "out")
+
+	cmd := exec.Command("sh", append([]string{"-c", script, "sh", out}, args...)...) //nolint:gosec // Test input.
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Running script should succeed, got: %v, output: %s", err, output)
+	}
+
+	output, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Reading output: %v", err)
+	}
+
+	return output
+}
+
+// testFiles creates directory with a file, a directory and a symbolic link and returns
+// their paths.
+func testFiles(t *testing.T) (string, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+
+	if err := os.WriteFile(file, []byte("foo"), 0o600); err != nil {
+		t.Fatalf("Writing file: %v", err)
+	}
+
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatalf("Creating symbolic link: %v", err)
+	}
+
+	return dir, file, link
+}
+
+// statScript tests.
+func TestStatScript(t *testing.T) {
+	t.Parallel()
+
+	dir, file, link := testFiles(t)
+	missing := filepath.Join(dir, "missing")
+
+	modes := strings.Fields(string(runScript(t, statScript, dir, file, missing, link)))
+
+	if len(modes) != 4 || modes[2] != "-" {
+		t.Fatalf("Expected 4 modes with missing file reported as '-', got: %v", modes)
+	}
+
+	for i, p := range []string{dir, file, link} {
+		if i == 2 {
+			i = 3
+		}
+
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatalf("Statting %q: %v", p, err)
+		}
+
+		mode, err := strconv.ParseUint(modes[i], 16, 32)
+		if err != nil {
+			t.Fatalf("Parsing mode %q should succeed, got: %v", modes[i], err)
+		}
+
+		if fileMode(uint32(mode)) != info.Mode() {
+			t.Errorf("Expected mode %v for %q, got %v", info.Mode(), p, fileMode(uint32(mode)))
+		}
+	}
+}
+
+// readScript tests.
+func TestReadScript(t *testing.T) {
+	t.Parallel()
+
+	dir, file, link := testFiles(t)
+
+	archive := runScript(t, readScript, dir, file, filepath.Join(dir, "missing"), link)
+
+	for _, p := range []string{file, link} {
+		f, err := fileFromTar(archive, strings.TrimPrefix(p, "/"))
+		if err != nil {
+			t.Fatalf("Extracting file should succeed, got: %v", err)
+		}
+
+		if f == nil || f.Content != "foo" || f.Mode != 0o600 {
+			t.Fatalf("Expected file %q with content 'foo' and mode 0600, got: %+v", p, f)
+		}
+	}
+}
+
+func TestReadScriptNoFiles(t *testing.T) {
+	t.Parallel()
+
+	if output := runScript(t, readScript, filepath.Join(t.TempDir(), "missing")); len(output) != 0 {
+		t.Fatalf("Output should be empty when no files exist, got: %q", output)
+	}
+}
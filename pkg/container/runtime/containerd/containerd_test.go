@@ -0,0 +1,948 @@
+package containerd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	containerdclient "github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/containerd"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+const testContainerID = "foo"
+
+func newRuntime(t *testing.T, client *containerd.FakeClient) runtime.Runtime {
+	t.Helper()
+
+	config := &containerd.Config{
+		ClientGetter: func(string, ...containerdclient.ClientOpt) (containerd.Client, error) {
+			return client, nil
+		},
+	}
+
+	r, err := config.New()
+	if err != nil {
+		t.Fatalf("Creating runtime should succeed, got: %v", err)
+	}
+
+	return r
+}
+
+func fakeContainerWithTask(task containerdclient.Task) *containerd.FakeContainer {
+	return &containerd.FakeContainer{
+		IDF: func() string { return testContainerID },
+		TaskF: func(context.Context, cio.Attach) (containerdclient.Task, error) {
+			if task == nil {
+				return nil, errdefs.ErrNotFound
+			}
+
+			return task, nil
+		},
+		DeleteF: func(context.Context, ...containerdclient.DeleteOpts) error {
+			return nil
+		},
+		InfoF: func(context.Context, ...containerdclient.InfoOpts) (containers.Container, error) {
+			return containers.Container{
+				ID:    testContainerID,
+				Image: "busybox:latest",
+				Labels: map[string]string{
+					"io.flexkube.args-count": "1",
+				},
+			}, nil
+		},
+		SpecF: func(context.Context) (*oci.Spec, error) {
+			return &oci.Spec{
+				Process: &specs.Process{
+					Args: []string{"/bin/sh", "-c", "sleep infinity"},
+					Env:  []string{"PATH=/bin", "FOO=bar=baz"},
+					User: specs.User{
+						UID: 1000,
+						GID: 1001,
+					},
+				},
+				Linux: &specs.Linux{
+					Namespaces: []specs.LinuxNamespace{
+						{
+							Type: specs.PIDNamespace,
+						},
+					},
+				},
+				Mounts: []specs.Mount{
+					{
+						Type:        "proc",
+						Source:      "proc",
+						Destination: "/proc",
+					},
+					{
+						Type:        "bind",
+						Source:      "/",
+						Destination: "/mnt/host",
+						Options:     []string{"rbind", "rw", "rshared"},
+					},
+					{
+						Type:        "bind",
+						Source:      "/etc/kubernetes",
+						Destination: "/etc/kubernetes",
+						Options:     []string{"rbind", "rw"},
+					},
+					{
+						Type:        "bind",
+						Source:      "/etc/hosts",
+						Destination: "/etc/hosts",
+						Options:     []string{"rbind", "ro"},
+					},
+				},
+			}, nil
+		},
+	}
+}
+
+func clientWithContainer(c containerdclient.Container) *containerd.FakeClient {
+	return &containerd.FakeClient{
+		LoadContainerF: func(context.Context, string) (containerdclient.Container, error) {
+			return c, nil
+		},
+	}
+}
+
+// New() tests.
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	expectedAddress := "/run/foo.sock"
+
+	config := &containerd.Config{
+		Address: "unix://" + expectedAddress,
+		ClientGetter: func(address string, _ ...containerdclient.ClientOpt) (containerd.Client, error) {
+			if address != expectedAddress {
+				t.Errorf("Expected address %q, got %q", expectedAddress, address)
+			}
+
+			return &containerd.FakeClient{}, nil
+		},
+	}
+
+	if _, err := config.New(); err != nil {
+		t.Fatalf("Creating new containerd client should work, got: %v", err)
+	}
+}
+
+func TestNewClientFail(t *testing.T) {
+	t.Parallel()
+
+	config := &containerd.Config{
+		ClientGetter: func(string, ...containerdclient.ClientOpt) (containerd.Client, error) {
+			return nil, fmt.Errorf("expected")
+		},
+	}
+
+	if _, err := config.New(); err == nil {
+		t.Fatalf("Creating new containerd client should propagate client creation errors")
+	}
+}
+
+// GetAddress() tests.
+func TestGetAddressNilConfig(t *testing.T) {
+	t.Parallel()
+
+	var c *containerd.Config
+
+	if a := c.GetAddress(); a != containerd.DefaultAddress {
+		t.Fatalf("Expected %q, got %q", containerd.DefaultAddress, a)
+	}
+}
+
+func TestGetAddress(t *testing.T) {
+	t.Parallel()
+
+	c := &containerd.Config{}
+
+	expectedAddress := "unix:///foo.sock"
+
+	c.SetAddress(expectedAddress)
+
+	if a := c.GetAddress(); a != expectedAddress {
+		t.Fatalf("Expected %q, got %q", expectedAddress, a)
+	}
+}
+
+// DefaultConfig() tests.
+func TestDefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	c := containerd.DefaultConfig()
+
+	if c.Address != containerd.DefaultAddress || c.Namespace != containerd.DefaultNamespace {
+		t.Fatalf("Unexpected default config: %+v", c)
+	}
+}
+
+// Create() tests.
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	pulled := false
+
+	client := &containerd.FakeClient{
+		GetImageF: func(context.Context, string) (containerdclient.Image, error) {
+			return nil, errdefs.ErrNotFound
+		},
+		PullF: func(context.Context, string, ...containerdclient.RemoteOpt) (containerdclient.Image, error) {
+			pulled = true
+
+			return nil, nil
+		},
+		NewContainerF: func(
+			_ context.Context,
+			id string,
+			_ ...containerdclient.NewContainerOpts,
+		) (containerdclient.Container, error) {
+			return &containerd.FakeContainer{
+				IDF: func() string { return id },
+			}, nil
+		},
+	}
+
+	id, err := newRuntime(t, client).Create(&types.ContainerConfig{
+		Name:        testContainerID,
+		Image:       "busybox:latest",
+		User:        "1000",
+		Group:       "1000",
+		NetworkMode: "host",
+		Privileged:  true,
+		Mounts: []types.Mount{
+			{
+				Source:      "/",
+				Target:      "/mnt/host",
+				Propagation: "rshared",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Creating container should succeed, got: %v", err)
+	}
+
+	if id != testContainerID {
+		t.Fatalf("Expected container ID %q, got %q", testContainerID, id)
+	}
+
+	if !pulled {
+		t.Fatalf("Image should be pulled when it's not present")
+	}
+}
+
+func TestCreatePullImageFail(t *testing.T) {
+	t.Parallel()
+
+	client := &containerd.FakeClient{
+		GetImageF: func(context.Context, string) (containerdclient.Image, error) {
+			return nil, fmt.Errorf("expected")
+		},
+	}
+
+	if _, err := newRuntime(t, client).Create(&types.ContainerConfig{}); err == nil {
+		t.Fatalf("Creating container should fail when checking image fails")
+	}
+}
+
+func TestCreateBadConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]*types.ContainerConfig{
+		"non-numeric user": {
+			User: "nobody",
+		},
+		"non-numeric group": {
+			User:  "0",
+			Group: "nogroup",
+		},
+		"ports without host network": {
+			Ports: []types.PortMap{
+				{
+					Protocol: "tcp",
+					Port:     80,
+				},
+			},
+		},
+		"unsupported network mode": {
+			NetworkMode: "bridge",
+		},
+	}
+
+	for name, config := range cases {
+		config := config
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &containerd.FakeClient{
+				NewContainerF: func(
+					context.Context,
+					string,
+					...containerdclient.NewContainerOpts,
+				) (containerdclient.Container, error) {
+					t.Fatalf("Container should not be created with invalid configuration")
+
+					return nil, nil
+				},
+			}
+
+			if _, err := newRuntime(t, client).Create(config); err == nil {
+				t.Fatalf("Creating container should fail")
+			}
+		})
+	}
+}
+
+// Start() tests.
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	started := false
+	oldTaskDeleted := false
+
+	c := fakeContainerWithTask(&containerd.FakeTask{
+		DeleteF: func(context.Context, ...containerdclient.ProcessDeleteOpts) (*containerdclient.ExitStatus, error) {
+			oldTaskDeleted = true
+
+			return nil, nil
+		},
+	})
+
+	c.NewTaskF = func(context.Context, cio.Creator, ...containerdclient.NewTaskOpts) (containerdclient.Task, error) {
+		return &containerd.FakeTask{
+			StartF: func(context.Context) error {
+				started = true
+
+				return nil
+			},
+		}, nil
+	}
+
+	if err := newRuntime(t, clientWithContainer(c)).Start(testContainerID); err != nil {
+		t.Fatalf("Starting container should succeed, got: %v", err)
+	}
+
+	if !oldTaskDeleted || !started {
+		t.Fatalf("Old task should be removed and new task should be started")
+	}
+}
+
+// Stop() tests.
+func TestStop(t *testing.T) {
+	t.Parallel()
+
+	exitCh := make(chan containerdclient.ExitStatus, 1)
+	deleted := false
+
+	c := fakeContainerWithTask(&containerd.FakeTask{
+		WaitF: func(context.Context) (<-chan containerdclient.ExitStatus, error) {
+			return exitCh, nil
+		},
+		KillF: func(_ context.Context, signal syscall.Signal, _ ...containerdclient.KillOpts) error {
+			if signal != syscall.SIGTERM {
+				t.Errorf("Expected SIGTERM to be sent, got %v", signal)
+			}
+
+			exitCh <- containerdclient.ExitStatus{}
+
+			return nil
+		},
+		DeleteF: func(context.Context, ...containerdclient.ProcessDeleteOpts) (*containerdclient.ExitStatus, error) {
+			deleted = true
+
+			return nil, nil
+		},
+	})
+
+	if err := newRuntime(t, clientWithContainer(c)).Stop(testContainerID); err != nil {
+		t.Fatalf("Stopping container should succeed, got: %v", err)
+	}
+
+	if !deleted {
+		t.Fatalf("Task should be removed after stopping")
+	}
+}
+
+func TestStopNoTask(t *testing.T) {
+	t.Parallel()
+
+	if err := newRuntime(t, clientWithContainer(fakeContainerWithTask(nil))).Stop(testContainerID); err != nil {
+		t.Fatalf("Stopping container without task should succeed, got: %v", err)
+	}
+}
+
+// Status() tests.
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	c := fakeContainerWithTask(&containerd.FakeTask{
+		StatusF: func(context.Context) (containerdclient.Status, error) {
+			return containerdclient.Status{Status: containerdclient.Running}, nil
+		},
+	})
+
+	status, err := newRuntime(t, clientWithContainer(c)).Status(testContainerID)
+	if err != nil {
+		t.Fatalf("Getting status should succeed, got: %v", err)
+	}
+
+	expectedStatus := types.ContainerStatus{
+		ID:     testContainerID,
+		Status: string(containerdclient.Running),
+		Config: &types.ContainerConfig{
+			Name:       testContainerID,
+			Image:      "busybox:latest",
+			Entrypoint: []string{"/bin/sh", "-c"},
+			Args:       []string{"sleep infinity"},
+			User:       "1000",
+			Group:      "1001",
+			Env: map[string]string{
+				"PATH": "/bin",
+				"FOO":  "bar=baz",
+			},
+			NetworkMode: "host",
+			IpcMode:     "host",
+			Mounts: []types.Mount{
+				{
+					Source:      "/",
+					Target:      "/mnt/host",
+					Propagation: "rshared",
+				},
+				{
+					Source: "/etc/kubernetes",
+					Target: "/etc/kubernetes",
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expectedStatus, status); diff != "" {
+		t.Fatalf("Unexpected status: %s", diff)
+	}
+}
+
+func TestStatusNoTask(t *testing.T) {
+	t.Parallel()
+
+	status, err := newRuntime(t, clientWithContainer(fakeContainerWithTask(nil))).Status(testContainerID)
+	if err != nil {
+		t.Fatalf("Getting status should succeed, got: %v", err)
+	}
+
+	if status.Status != "created" {
+		t.Fatalf("Container without task should be reported as created, got: %q", status.Status)
+	}
+
+	if status.Config == nil {
+		t.Fatalf("Container without task should have configuration reported")
+	}
+}
+
+func TestStatusSpecError(t *testing.T) {
+	t.Parallel()
+
+	c := fakeContainerWithTask(nil)
+	c.SpecF = func(context.Context) (*oci.Spec, error) {
+		return nil, fmt.Errorf("expected")
+	}
+
+	if _, err := newRuntime(t, clientWithContainer(c)).Status(testContainerID); err == nil {
+		t.Fatalf("Getting status should fail when getting container spec fails")
+	}
+}
+
+func TestStatusNotFound(t *testing.T) {
+	t.Parallel()
+
+	client := &containerd.FakeClient{
+		LoadContainerF: func(context.Context, string) (containerdclient.Container, error) {
+			return nil, errdefs.ErrNotFound
+		},
+	}
+
+	status, err := newRuntime(t, client).Status(testContainerID)
+	if err != nil {
+		t.Fatalf("Getting status of missing container should succeed, got: %v", err)
+	}
+
+	if status.ID != "" {
+		t.Fatalf("Missing container should have empty ID, got: %q", status.ID)
+	}
+}
+
+func TestStatusRuntimeError(t *testing.T) {
+	t.Parallel()
+
+	client := &containerd.FakeClient{
+		LoadContainerF: func(context.Context, string) (containerdclient.Container, error) {
+			return nil, fmt.Errorf("expected")
+		},
+	}
+
+	if _, err := newRuntime(t, client).Status(testContainerID); err == nil {
+		t.Fatalf("Getting status should propagate runtime errors")
+	}
+}
+
+// Delete() tests.
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	deleted := false
+
+	c := fakeContainerWithTask(nil)
+	c.DeleteF = func(context.Context, ...containerdclient.DeleteOpts) error {
+		deleted = true
+
+		return nil
+	}
+
+	if err := newRuntime(t, clientWithContainer(c)).Delete(testContainerID); err != nil {
+		t.Fatalf("Deleting container should succeed, got: %v", err)
+	}
+
+	if !deleted {
+		t.Fatalf("Container should be deleted")
+	}
+}
+
+func tarHeaders(t *testing.T, archive []byte) map[string]*tar.Header {
+	t.Helper()
+
+	headers := map[string]*tar.Header{}
+	tarReader := tar.NewReader(bytes.NewReader(archive))
+
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			return headers
+		}
+
+		headers[header.Name] = header
+	}
+}
+
+// Copy() tests.
+func TestCopy(t *testing.T) {
+	t.Parallel()
+
+	store, err := local.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Creating content store: %v", err)
+	}
+
+	snapshotMounts := []mount.Mount{
+		{
+			Type:   "overlay",
+			Source: "overlay",
+		},
+	}
+
+	applied := map[string]map[string]*tar.Header{}
+
+	container := fakeContainerWithTask(nil)
+	container.InfoF = func(context.Context, ...containerdclient.InfoOpts) (containers.Container, error) {
+		return containers.Container{
+			Snapshotter: "overlayfs",
+			SnapshotKey: testContainerID,
+		}, nil
+	}
+
+	client := clientWithContainer(container)
+	client.ContentStoreF = func() content.Store { return store }
+	client.SnapshotServiceF = func(snapshotterName string) snapshots.Snapshotter {
+		return &containerd.FakeSnapshotter{
+			MountsF: func(_ context.Context, key string) ([]mount.Mount, error) {
+				if snapshotterName != "overlayfs" || key != testContainerID {
+					t.Errorf("Unexpected snapshot %q of snapshotter %q", key, snapshotterName)
+				}
+
+				return snapshotMounts, nil
+			},
+		}
+	}
+	client.DiffServiceF = func() containerdclient.DiffService {
+		return &containerd.FakeDiffService{
+			ApplyF: func(
+				ctx context.Context,
+				desc v1.Descriptor,
+				mounts []mount.Mount,
+				_ ...diff.ApplyOpt,
+			) (v1.Descriptor, error) {
+				if len(mounts) != 1 {
+					t.Errorf("Expected exactly one mount, got: %+v", mounts)
+				}
+
+				archive, err := content.ReadBlob(ctx, store, desc)
+				if err != nil {
+					return v1.Descriptor{}, err
+				}
+
+				applied[mounts[0].Source] = tarHeaders(t, archive)
+
+				return v1.Descriptor{}, nil
+			},
+		}
+	}
+
+	files := []*types.File{
+		{
+			Path:    "/mnt/host/etc/foo",
+			Content: "foo",
+			Mode:    0o600,
+		},
+		{
+			Path:    "/etc/kubernetes/pki/ca.crt",
+			Content: "bar",
+			Mode:    0o644,
+		},
+		{
+			Path:    "/var/lib/foo",
+			Content: "baz",
+			Mode:    0o644,
+		},
+	}
+
+	if err := newRuntime(t, client).Copy(testContainerID, files); err != nil {
+		t.Fatalf("Copying files should succeed, got: %v", err)
+	}
+
+	expectedFiles := map[string][]string{
+		// Bind mount of host root filesystem.
+		"/": {"etc/foo"},
+		// Files must be applied on the most specific bind mount.
+		"/etc/kubernetes": {"pki/ca.crt"},
+		// Files outside bind mounts must be applied on the container snapshot.
+		"overlay": {"var/lib/foo"},
+	}
+
+	for source, expectedPaths := range expectedFiles {
+		paths := []string{}
+
+		for p := range applied[source] {
+			paths = append(paths, p)
+		}
+
+		if diff := cmp.Diff(expectedPaths, paths); diff != "" {
+			t.Errorf("Unexpected files applied on mount %q: %s", source, diff)
+		}
+	}
+
+	if len(applied) != len(expectedFiles) {
+		t.Errorf("Expected files to be applied on %d mounts, got: %v", len(expectedFiles), applied)
+	}
+}
+
+func TestCopyNoSnapshot(t *testing.T) {
+	t.Parallel()
+
+	container := fakeContainerWithTask(nil)
+	container.InfoF = func(context.Context, ...containerdclient.InfoOpts) (containers.Container, error) {
+		return containers.Container{}, nil
+	}
+
+	files := []*types.File{
+		{
+			Path: "/var/lib/foo",
+		},
+	}
+
+	if err := newRuntime(t, clientWithContainer(container)).Copy(testContainerID, files); err == nil {
+		t.Fatalf("Copying files outside of bind mounts to container without snapshot should fail")
+	}
+}
+
+// fakeExecClient returns client with container without task, which executes processes
+// using given run function. Output returned by run function is stored in the output file
+// passed to the script, so it can be read back using diff service.
+func fakeExecClient(t *testing.T, run func(args []string) (string, uint32)) (*containerd.FakeClient, *[][]string) {
+	t.Helper()
+
+	store, err := local.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Creating content store: %v", err)
+	}
+
+	executed := [][]string{}
+	rootfs := map[string]string{}
+
+	process := func(spec *specs.Process) containerdclient.Process {
+		statusC := make(chan containerdclient.ExitStatus, 1)
+
+		return &containerd.FakeProcess{
+			WaitF: func(context.Context) (<-chan containerdclient.ExitStatus, error) {
+				return statusC, nil
+			},
+			StartF: func(context.Context) error {
+				executed = append(executed, spec.Args)
+
+				code := uint32(0)
+
+				switch spec.Args[0] {
+				case "sh":
+					rootfs[strings.TrimPrefix(spec.Args[4], "/")], code = run(spec.Args[5:])
+				case "rm":
+					delete(rootfs, strings.TrimPrefix(spec.Args[3], "/"))
+				}
+
+				statusC <- *containerdclient.NewExitStatus(code, time.Now(), nil)
+
+				return nil
+			},
+		}
+	}
+
+	task := &containerd.FakeTask{
+		ExecF: func(_ context.Context, _ string, spec *specs.Process, _ cio.Creator) (containerdclient.Process, error) {
+			if spec.User.UID != 0 || spec.Terminal {
+				t.Errorf("Process should be executed as root without terminal, got: %+v", spec)
+			}
+
+			return process(spec), nil
+		},
+		DeleteF: func(context.Context, ...containerdclient.ProcessDeleteOpts) (*containerdclient.ExitStatus, error) {
+			executed = append(executed, []string{"delete task"})
+
+			return nil, nil
+		},
+	}
+
+	container := fakeContainerWithTask(nil)
+	container.NewTaskF = func(
+		context.Context,
+		cio.Creator,
+		...containerdclient.NewTaskOpts,
+	) (containerdclient.Task, error) {
+		return task, nil
+	}
+	container.InfoF = func(context.Context, ...containerdclient.InfoOpts) (containers.Container, error) {
+		return containers.Container{
+			Snapshotter: "overlayfs",
+			SnapshotKey: testContainerID,
+		}, nil
+	}
+
+	client := clientWithContainer(container)
+	client.ContentStoreF = func() content.Store { return store }
+	client.SnapshotServiceF = func(string) snapshots.Snapshotter {
+		return &containerd.FakeSnapshotter{
+			StatF: func(context.Context, string) (snapshots.Info, error) {
+				return snapshots.Info{Parent: "parent"}, nil
+			},
+			MountsF: func(context.Context, string) ([]mount.Mount, error) {
+				return []mount.Mount{{Type: "overlay", Source: "upper"}}, nil
+			},
+			ViewF: func(_ context.Context, _, parent string, _ ...snapshots.Opt) ([]mount.Mount, error) {
+				return []mount.Mount{{Type: "overlay", Source: parent}}, nil
+			},
+		}
+	}
+	client.DiffServiceF = func() containerdclient.DiffService {
+		return &containerd.FakeDiffService{
+			CompareF: func(ctx context.Context, lower, upper []mount.Mount, _ ...diff.Opt) (v1.Descriptor, error) {
+				if lower[0].Source != "parent" || upper[0].Source != "upper" {
+					t.Errorf("Container snapshot should be compared with its parent, got: %v, %v", lower, upper)
+				}
+
+				files := []*types.File{}
+				for name, content := range rootfs {
+					files = append(files, &types.File{Path: name, Content: content})
+				}
+
+				return writeBlob(ctx, t, store, files), nil
+			},
+		}
+	}
+
+	return client, &executed
+}
+
+// writeBlob packs given files into TAR archive and writes it to given content store.
+func writeBlob(ctx context.Context, t *testing.T, store content.Store, files []*types.File) v1.Descriptor {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buf)
+
+	for _, file := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:     file.Path,
+			Mode:     file.Mode,
+			Size:     int64(len(file.Content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatalf("Writing header: %v", err)
+		}
+
+		if _, err := tarWriter.Write([]byte(file.Content)); err != nil {
+			t.Fatalf("Writing content: %v", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Closing writer: %v", err)
+	}
+
+	desc := v1.Descriptor{
+		MediaType: v1.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(buf.Bytes()),
+		Size:      int64(buf.Len()),
+	}
+
+	if err := content.WriteBlob(ctx, store, desc.Digest.String(), bytes.NewReader(buf.Bytes()), desc); err != nil {
+		t.Fatalf("Writing blob: %v", err)
+	}
+
+	return desc
+}
+
+// Read() tests.
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	archive := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(archive)
+
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:     "mnt/host/etc/foo",
+		Mode:     0o600,
+		Size:     3,
+		Uid:      1000,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		t.Fatalf("Writing header: %v", err)
+	}
+
+	if _, err := tarWriter.Write([]byte("bar")); err != nil {
+		t.Fatalf("Writing content: %v", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Closing writer: %v", err)
+	}
+
+	client, executed := fakeExecClient(t, func(args []string) (string, uint32) {
+		if diff := cmp.Diff([]string{"/mnt/host/etc/foo", "/mnt/host/opt/foo"}, args); diff != "" {
+			t.Errorf("Unexpected paths passed to the script: %s", diff)
+		}
+
+		return archive.String(), 0
+	})
+
+	files, err := newRuntime(t, client).Read(testContainerID, []string{"/mnt/host/etc/foo/", "/mnt/host/opt/foo"})
+	if err != nil {
+		t.Fatalf("Reading files should succeed, got: %v", err)
+	}
+
+	expectedFiles := []*types.File{
+		{
+			Path:    "/mnt/host/etc/foo/",
+			Content: "bar",
+			Mode:    0o600,
+			User:    "1000",
+			Group:   "0",
+		},
+	}
+
+	if diff := cmp.Diff(expectedFiles, files); diff != "" {
+		t.Fatalf("Unexpected files: %s", diff)
+	}
+
+	if len(*executed) != 3 || (*executed)[1][0] != "rm" || (*executed)[2][0] != "delete task" {
+		t.Fatalf("Output file and temporary task should be removed, got: %v", *executed)
+	}
+}
+
+func TestReadFail(t *testing.T) {
+	t.Parallel()
+
+	client, executed := fakeExecClient(t, func([]string) (string, uint32) {
+		return "", 1
+	})
+
+	if _, err := newRuntime(t, client).Read(testContainerID, []string{"/etc/foo"}); err == nil {
+		t.Fatalf("Reading files should fail when archiving files fails")
+	}
+
+	if (*executed)[len(*executed)-1][0] != "delete task" {
+		t.Fatalf("Temporary task should be removed, got: %v", *executed)
+	}
+}
+
+// Stat() tests.
+func TestStat(t *testing.T) {
+	t.Parallel()
+
+	client, _ := fakeExecClient(t, func([]string) (string, uint32) {
+		return "41ed\n8180\n-\na1ff\n", 0
+	})
+
+	result, err := newRuntime(t, client).Stat(testContainerID, []string{
+		"/mnt/host/etc",
+		"/mnt/host/etc/foo",
+		"/mnt/host/etc/bar",
+		"/var/run",
+	})
+	if err != nil {
+		t.Fatalf("Stat should succeed, got: %v", err)
+	}
+
+	expectedResult := map[string]os.FileMode{
+		"/mnt/host/etc":     os.ModeDir | 0o755,
+		"/mnt/host/etc/foo": 0o600,
+		"/var/run":          os.ModeSymlink | 0o777,
+	}
+
+	if diff := cmp.Diff(expectedResult, result); diff != "" {
+		t.Fatalf("Unexpected result: %s", diff)
+	}
+}
+
+func TestStatBadOutput(t *testing.T) {
+	t.Parallel()
+
+	client, _ := fakeExecClient(t, func([]string) (string, uint32) {
+		return "41ed\n", 0
+	})
+
+	if _, err := newRuntime(t, client).Stat(testContainerID, []string{"/etc", "/etc/foo"}); err == nil {
+		t.Fatalf("Stat should fail when number of returned modes does not match number of paths")
+	}
+}
+
+func TestStatStoppedTask(t *testing.T) {
+	t.Parallel()
+
+	c := fakeContainerWithTask(&containerd.FakeTask{
+		StatusF: func(context.Context) (containerdclient.Status, error) {
+			return containerdclient.Status{Status: containerdclient.Stopped}, nil
+		},
+	})
+
+	if _, err := newRuntime(t, clientWithContainer(c)).Stat(testContainerID, []string{"/etc"}); err == nil {
+		t.Fatalf("Stat should fail for containers with stopped task")
+	}
+}
@@ -0,0 +1,350 @@
+package containerd
+
+import (
+	"context"
+	"syscall"
+
+	containerdclient "github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/leases"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/snapshots"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// FakeClient is a mock of containerd client, which should be used only for testing.
+type FakeClient struct {
+	// GetImageF will be called by GetImage.
+	GetImageF func(ctx context.Context, ref string) (containerdclient.Image, error)
+
+	// PullF will be called by Pull.
+	PullF func(ctx context.Context, ref string, opts ...containerdclient.RemoteOpt) (containerdclient.Image, error)
+
+	// NewContainerF will be called by NewContainer.
+	NewContainerF func(
+		ctx context.Context,
+		id string,
+		opts ...containerdclient.NewContainerOpts,
+	) (containerdclient.Container, error)
+
+	// LoadContainerF will be called by LoadContainer.
+	LoadContainerF func(ctx context.Context, id string) (containerdclient.Container, error)
+
+	// ContentStoreF will be called by ContentStore.
+	ContentStoreF func() content.Store
+
+	// DiffServiceF will be called by DiffService.
+	DiffServiceF func() containerdclient.DiffService
+
+	// SnapshotServiceF will be called by SnapshotService.
+	SnapshotServiceF func(snapshotterName string) snapshots.Snapshotter
+}
+
+// GetImage mocks containerd client GetImage().
+func (f *FakeClient) GetImage(ctx context.Context, ref string) (containerdclient.Image, error) {
+	if f.GetImageF == nil {
+		return nil, nil //nolint:nilnil // Image is only passed back to the client, so it may be nil.
+	}
+
+	return f.GetImageF(ctx, ref)
+}
+
+// Pull mocks containerd client Pull().
+func (f *FakeClient) Pull(
+	ctx context.Context,
+	ref string,
+	opts ...containerdclient.RemoteOpt,
+) (containerdclient.Image, error) {
+	return f.PullF(ctx, ref, opts...)
+}
+
+// NewContainer mocks containerd client NewContainer().
+func (f *FakeClient) NewContainer(
+	ctx context.Context,
+	id string,
+	opts ...containerdclient.NewContainerOpts,
+) (containerdclient.Container, error) {
+	return f.NewContainerF(ctx, id, opts...)
+}
+
+// LoadContainer mocks containerd client LoadContainer().
+func (f *FakeClient) LoadContainer(ctx context.Context, id string) (containerdclient.Container, error) {
+	return f.LoadContainerF(ctx, id)
+}
+
+// ContentStore mocks containerd client ContentStore().
+func (f *FakeClient) ContentStore() content.Store {
+	return f.ContentStoreF()
+}
+
+// DiffService mocks containerd client DiffService().
+func (f *FakeClient) DiffService() containerdclient.DiffService {
+	return f.DiffServiceF()
+}
+
+// SnapshotService mocks containerd client SnapshotService().
+func (f *FakeClient) SnapshotService(snapshotterName string) snapshots.Snapshotter {
+	return f.SnapshotServiceF(snapshotterName)
+}
+
+// WithLease mocks containerd client WithLease(). It returns given context and
+// no-op release function.
+func (f *FakeClient) WithLease(
+	ctx context.Context,
+	_ ...leases.Opt,
+) (context.Context, func(context.Context) error, error) {
+	return ctx, func(context.Context) error { return nil }, nil
+}
+
+// FakeContainer is a mock of containerd container, which should be used only for testing.
+//
+// Methods not mocked by this struct will panic when called.
+type FakeContainer struct {
+	containerdclient.Container
+
+	// IDF will be called by ID.
+	IDF func() string
+
+	// TaskF will be called by Task.
+	TaskF func(ctx context.Context, attach cio.Attach) (containerdclient.Task, error)
+
+	// NewTaskF will be called by NewTask.
+	NewTaskF func(
+		ctx context.Context,
+		ioCreate cio.Creator,
+		opts ...containerdclient.NewTaskOpts,
+	) (containerdclient.Task, error)
+
+	// DeleteF will be called by Delete.
+	DeleteF func(ctx context.Context, opts ...containerdclient.DeleteOpts) error
+
+	// SpecF will be called by Spec.
+	SpecF func(ctx context.Context) (*oci.Spec, error)
+
+	// InfoF will be called by Info.
+	InfoF func(ctx context.Context, opts ...containerdclient.InfoOpts) (containers.Container, error)
+
+	// UpdateF will be called by Update.
+	UpdateF func(ctx context.Context, opts ...containerdclient.UpdateContainerOpts) error
+}
+
+// ID mocks containerd container ID().
+func (f *FakeContainer) ID() string {
+	return f.IDF()
+}
+
+// Task mocks containerd container Task().
+func (f *FakeContainer) Task(ctx context.Context, attach cio.Attach) (containerdclient.Task, error) {
+	return f.TaskF(ctx, attach)
+}
+
+// NewTask mocks containerd container NewTask().
+func (f *FakeContainer) NewTask(
+	ctx context.Context,
+	ioCreate cio.Creator,
+	opts ...containerdclient.NewTaskOpts,
+) (containerdclient.Task, error) {
+	return f.NewTaskF(ctx, ioCreate, opts...)
+}
+
+// Delete mocks containerd container Delete().
+func (f *FakeContainer) Delete(ctx context.Context, opts ...containerdclient.DeleteOpts) error {
+	return f.DeleteF(ctx, opts...)
+}
+
+// Spec mocks containerd container Spec().
+func (f *FakeContainer) Spec(ctx context.Context) (*oci.Spec, error) {
+	return f.SpecF(ctx)
+}
+
+// Update mocks containerd container Update().
+func (f *FakeContainer) Update(ctx context.Context, opts ...containerdclient.UpdateContainerOpts) error {
+	if f.UpdateF == nil {
+		return nil
+	}
+
+	return f.UpdateF(ctx, opts...)
+}
+
+// Info mocks containerd container Info().
+func (f *FakeContainer) Info(ctx context.Context, opts ...containerdclient.InfoOpts) (containers.Container, error) {
+	return f.InfoF(ctx, opts...)
+}
+
+// FakeTask is a mock of containerd task, which should be used only for testing.
+//
+// Methods not mocked by this struct will panic when called.
+type FakeTask struct {
+	containerdclient.Task
+
+	// StartF will be called by Start.
+	StartF func(ctx context.Context) error
+
+	// DeleteF will be called by Delete.
+	DeleteF func(ctx context.Context, opts ...containerdclient.ProcessDeleteOpts) (*containerdclient.ExitStatus, error)
+
+	// KillF will be called by Kill.
+	KillF func(ctx context.Context, signal syscall.Signal, opts ...containerdclient.KillOpts) error
+
+	// WaitF will be called by Wait.
+	WaitF func(ctx context.Context) (<-chan containerdclient.ExitStatus, error)
+
+	// StatusF will be called by Status.
+	StatusF func(ctx context.Context) (containerdclient.Status, error)
+
+	// ExecF will be called by Exec.
+	ExecF func(ctx context.Context, id string, spec *specs.Process, ioCreate cio.Creator) (containerdclient.Process, error)
+}
+
+// Start mocks containerd task Start().
+func (f *FakeTask) Start(ctx context.Context) error {
+	return f.StartF(ctx)
+}
+
+// Delete mocks containerd task Delete().
+func (f *FakeTask) Delete(
+	ctx context.Context,
+	opts ...containerdclient.ProcessDeleteOpts,
+) (*containerdclient.ExitStatus, error) {
+	return f.DeleteF(ctx, opts...)
+}
+
+// Kill mocks containerd task Kill().
+func (f *FakeTask) Kill(ctx context.Context, signal syscall.Signal, opts ...containerdclient.KillOpts) error {
+	return f.KillF(ctx, signal, opts...)
+}
+
+// Wait mocks containerd task Wait().
+func (f *FakeTask) Wait(ctx context.Context) (<-chan containerdclient.ExitStatus, error) {
+	return f.WaitF(ctx)
+}
+
+// Status mocks containerd task Status().
+func (f *FakeTask) Status(ctx context.Context) (containerdclient.Status, error) {
+	return f.StatusF(ctx)
+}
+
+// Exec mocks containerd task Exec().
+func (f *FakeTask) Exec(
+	ctx context.Context,
+	id string,
+	spec *specs.Process,
+	ioCreate cio.Creator,
+) (containerdclient.Process, error) {
+	return f.ExecF(ctx, id, spec, ioCreate)
+}
+
+// FakeProcess is a mock of containerd process, which should be used only for testing.
+//
+// Methods not mocked by this struct will panic when called.
+type FakeProcess struct {
+	containerdclient.Process
+
+	// StartF will be called by Start.
+	StartF func(ctx context.Context) error
+
+	// WaitF will be called by Wait.
+	WaitF func(ctx context.Context) (<-chan containerdclient.ExitStatus, error)
+
+	// DeleteF will be called by Delete.
+	DeleteF func(ctx context.Context, opts ...containerdclient.ProcessDeleteOpts) (*containerdclient.ExitStatus, error)
+}
+
+// Start mocks containerd process Start().
+func (f *FakeProcess) Start(ctx context.Context) error {
+	return f.StartF(ctx)
+}
+
+// Wait mocks containerd process Wait().
+func (f *FakeProcess) Wait(ctx context.Context) (<-chan containerdclient.ExitStatus, error) {
+	return f.WaitF(ctx)
+}
+
+// Delete mocks containerd process Delete().
+func (f *FakeProcess) Delete(
+	ctx context.Context,
+	opts ...containerdclient.ProcessDeleteOpts,
+) (*containerdclient.ExitStatus, error) {
+	if f.DeleteF == nil {
+		return nil, nil
+	}
+
+	return f.DeleteF(ctx, opts...)
+}
+
+// FakeDiffService is a mock of containerd diff service, which should be used only for testing.
+type FakeDiffService struct {
+	// CompareF will be called by Compare.
+	CompareF func(ctx context.Context, lower, upper []mount.Mount, opts ...diff.Opt) (v1.Descriptor, error)
+
+	// ApplyF will be called by Apply.
+	ApplyF func(ctx context.Context, desc v1.Descriptor, mount []mount.Mount, opts ...diff.ApplyOpt) (v1.Descriptor, error)
+}
+
+// Compare mocks containerd diff service Compare().
+func (f *FakeDiffService) Compare(
+	ctx context.Context,
+	lower,
+	upper []mount.Mount,
+	opts ...diff.Opt,
+) (v1.Descriptor, error) {
+	return f.CompareF(ctx, lower, upper, opts...)
+}
+
+// Apply mocks containerd diff service Apply().
+func (f *FakeDiffService) Apply(
+	ctx context.Context,
+	desc v1.Descriptor,
+	mounts []mount.Mount,
+	opts ...diff.ApplyOpt,
+) (v1.Descriptor, error) {
+	return f.ApplyF(ctx, desc, mounts, opts...)
+}
+
+// FakeSnapshotter is a mock of containerd snapshotter, which should be used only for testing.
+//
+// Methods not mocked by this struct will panic when called.
+type FakeSnapshotter struct {
+	snapshots.Snapshotter
+
+	// MountsF will be called by Mounts.
+	MountsF func(ctx context.Context, key string) ([]mount.Mount, error)
+
+	// StatF will be called by Stat.
+	StatF func(ctx context.Context, key string) (snapshots.Info, error)
+
+	// ViewF will be called by View.
+	ViewF func(ctx context.Context, key, parent string, opts ...snapshots.Opt) ([]mount.Mount, error)
+
+	// RemoveF will be called by Remove.
+	RemoveF func(ctx context.Context, key string) error
+}
+
+// Mounts mocks containerd snapshotter Mounts().
+func (f *FakeSnapshotter) Mounts(ctx context.Context, key string) ([]mount.Mount, error) {
+	return f.MountsF(ctx, key)
+}
+
+// Stat mocks containerd snapshotter Stat().
+func (f *FakeSnapshotter) Stat(ctx context.Context, key string) (snapshots.Info, error) {
+	return f.StatF(ctx, key)
+}
+
+// View mocks containerd snapshotter View().
+func (f *FakeSnapshotter) View(ctx context.Context, key, parent string, opts ...snapshots.Opt) ([]mount.Mount, error) {
+	return f.ViewF(ctx, key, parent, opts...)
+}
+
+// Remove mocks containerd snapshotter Remove().
+func (f *FakeSnapshotter) Remove(ctx context.Context, key string) error {
+	if f.RemoveF == nil {
+		return nil
+	}
+
+	return f.RemoveF(ctx, key)
+}
@@ -73,6 +73,9 @@ type Client interface {
 	ContainerStatPath(ctx context.Context, container, path string) (dockertypes.ContainerPathStat, error)
 	ImageList(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error)
 	ImagePull(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error)
+	Info(ctx context.Context) (dockertypes.Info, error)
+	ServerVersion(ctx context.Context) (dockertypes.Version, error)
+	ContainerList(ctx context.Context, options dockertypes.ContainerListOptions) ([]dockertypes.Container, error)
 }
 
 // docker struct is a struct, which can be used to manage Docker containers.
@@ -281,10 +284,64 @@ func (d *docker) Status(id string) (types.ContainerStatus, error) {
 	}
 
 	containerStatus.Status = status.State.Status
+	containerStatus.Config = containerConfigFromInspect(status)
 
 	return containerStatus, nil
 }
 
+// containerConfigFromInspect converts Docker container inspect response back to
+// ContainerConfig, so it can be compared with configuration used for creating the container.
+func containerConfigFromInspect(status dockertypes.ContainerJSON) *types.ContainerConfig {
+	if status.ContainerJSONBase == nil || status.Config == nil || status.HostConfig == nil {
+		return nil
+	}
+
+	user, group, _ := strings.Cut(status.Config.User, ":")
+
+	config := &types.ContainerConfig{
+		Name:        strings.TrimPrefix(status.Name, "/"),
+		Image:       status.Config.Image,
+		Args:        status.Config.Cmd,
+		Entrypoint:  status.Config.Entrypoint,
+		Privileged:  status.HostConfig.Privileged,
+		NetworkMode: string(status.HostConfig.NetworkMode),
+		PidMode:     string(status.HostConfig.PidMode),
+		IpcMode:     string(status.HostConfig.IpcMode),
+		User:        user,
+		Group:       group,
+		Env:         map[string]string{},
+	}
+
+	for _, e := range status.Config.Env {
+		k, v, _ := strings.Cut(e, "=")
+		config.Env[k] = v
+	}
+
+	for _, m := range status.Mounts {
+		if m.Type != mount.TypeBind {
+			continue
+		}
+
+		config.Mounts = append(config.Mounts, types.Mount{
+			Source:      m.Source,
+			Target:      m.Destination,
+			Propagation: string(m.Propagation),
+		})
+	}
+
+	for port, bindings := range status.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			config.Ports = append(config.Ports, types.PortMap{
+				IP:       binding.HostIP,
+				Port:     port.Int(),
+				Protocol: port.Proto(),
+			})
+		}
+	}
+
+	return config
+}
+
 // Delete removes the container.
 func (d *docker) Delete(id string) error {
 	return d.cli.ContainerRemove(d.ctx, id, dockertypes.ContainerRemoveOptions{})
@@ -477,6 +534,61 @@ func (d *docker) pullImage(image string) error {
 	return out.Close()
 }
 
+// Info returns information about Docker daemon, including host ports published by running containers.
+func (d *docker) Info() (*runtime.Info, error) {
+	info, err := d.cli.Info(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting Docker info: %w", err)
+	}
+
+	version, err := d.cli.ServerVersion(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting Docker version: %w", err)
+	}
+
+	containers, err := d.cli.ContainerList(d.ctx, dockertypes.ContainerListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	return &runtime.Info{
+		Name:             "docker",
+		Version:          version.Version,
+		APIVersion:       version.APIVersion,
+		MinAPIVersion:    version.MinAPIVersion,
+		ClientAPIVersion: strings.TrimPrefix(defaults.DockerAPIVersion, "v"),
+		CgroupDriver:     info.CgroupDriver,
+		RootDir:          info.DockerRootDir,
+		PortBindings:     portBindings(containers),
+	}, nil
+}
+
+// portBindings returns host ports published by given containers.
+func portBindings(containers []dockertypes.Container) []runtime.PortBinding {
+	bindings := []runtime.PortBinding{}
+
+	for _, c := range containers {
+		name := strings.TrimPrefix(strings.Join(c.Names, ","), "/")
+
+		for _, p := range c.Ports {
+			if p.PublicPort == 0 {
+				continue
+			}
+
+			bindings = append(bindings, runtime.PortBinding{
+				Container: name,
+				PortMap: types.PortMap{
+					IP:       p.IP,
+					Port:     int(p.PublicPort),
+					Protocol: p.Type,
+				},
+			})
+		}
+	}
+
+	return bindings
+}
+
 // DefaultConfig returns Docker's runtime default configuration.
 func DefaultConfig() *Config {
 	return &Config{
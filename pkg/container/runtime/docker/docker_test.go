@@ -14,14 +14,18 @@ import (
 
 	dockertypes "github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
 	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/defaults"
 )
 
 // New() tests.
@@ -196,6 +200,92 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+func TestStatusConfig(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &docker.Config{
+		ClientGetter: func(...client.Opt) (docker.Client, error) {
+			return &docker.FakeClient{
+				ContainerInspectF: func(context.Context, string) (dockertypes.ContainerJSON, error) {
+					return dockertypes.ContainerJSON{
+						ContainerJSONBase: &dockertypes.ContainerJSONBase{
+							Name: "/foo",
+							State: &dockertypes.ContainerState{
+								Status: "running",
+							},
+							HostConfig: &containertypes.HostConfig{
+								NetworkMode: "host",
+								PortBindings: nat.PortMap{
+									"80/tcp": []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "80"}},
+								},
+							},
+						},
+						Config: &containertypes.Config{
+							Image: "foo:v0.1.0",
+							Cmd:   []string{"--bar"},
+							User:  "1000:1001",
+							Env:   []string{"FOO=bar=baz"},
+						},
+						Mounts: []dockertypes.MountPoint{
+							{
+								Type:        mount.TypeBind,
+								Source:      "/foo",
+								Destination: "/bar",
+								Propagation: mount.PropagationRShared,
+							},
+							{
+								Type:        mount.TypeVolume,
+								Source:      "/var/lib/docker/volumes/foo",
+								Destination: "/baz",
+							},
+						},
+					}, nil
+				},
+			}, nil
+		},
+	}
+
+	testClient, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating test client: %v", err)
+	}
+
+	status, err := testClient.Status("foo")
+	if err != nil {
+		t.Fatalf("Checking for status should succeed, got: %v", err)
+	}
+
+	expectedConfig := &types.ContainerConfig{
+		Name:        "foo",
+		Image:       "foo:v0.1.0",
+		Args:        []string{"--bar"},
+		NetworkMode: "host",
+		User:        "1000",
+		Group:       "1001",
+		Env: map[string]string{
+			"FOO": "bar=baz",
+		},
+		Mounts: []types.Mount{
+			{
+				Source:      "/foo",
+				Target:      "/bar",
+				Propagation: "rshared",
+			},
+		},
+		Ports: []types.PortMap{
+			{
+				IP:       "127.0.0.1",
+				Port:     80,
+				Protocol: "tcp",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expectedConfig, status.Config); diff != "" {
+		t.Fatalf("Unexpected live configuration: %s", diff)
+	}
+}
+
 func TestStatusNotFound(t *testing.T) {
 	t.Parallel()
 
@@ -806,3 +896,98 @@ func TestConvertContainerConfigEnvVariables(t *testing.T) {
 		t.Fatalf("Unexpected error creating test container: %v", err)
 	}
 }
+
+// Info() tests.
+func TestInfo(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &docker.Config{
+		ClientGetter: func(...client.Opt) (docker.Client, error) {
+			return &docker.FakeClient{
+				InfoF: func(context.Context) (dockertypes.Info, error) {
+					return dockertypes.Info{
+						CgroupDriver:  "systemd",
+						DockerRootDir: "/var/lib/docker",
+					}, nil
+				},
+				ServerVersionF: func(context.Context) (dockertypes.Version, error) {
+					return dockertypes.Version{
+						Version:    "24.0.5",
+						APIVersion: "1.43",
+					}, nil
+				},
+				ContainerListF: func(context.Context, dockertypes.ContainerListOptions) ([]dockertypes.Container, error) {
+					return []dockertypes.Container{
+						{
+							Names: []string{"/foo"},
+							Ports: []dockertypes.Port{
+								{PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+								{PrivatePort: 443, Type: "tcp"},
+							},
+						},
+					}, nil
+				},
+			}, nil
+		},
+	}
+
+	testClient, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating test client: %v", err)
+	}
+
+	informer, ok := testClient.(runtime.Informer)
+	if !ok {
+		t.Fatalf("Docker runtime should implement runtime.Informer interface")
+	}
+
+	info, err := informer.Info()
+	if err != nil {
+		t.Fatalf("Getting info should succeed, got: %v", err)
+	}
+
+	expected := &runtime.Info{
+		Name:             "docker",
+		Version:          "24.0.5",
+		APIVersion:       "1.43",
+		ClientAPIVersion: strings.TrimPrefix(defaults.DockerAPIVersion, "v"),
+		CgroupDriver:     "systemd",
+		RootDir:          "/var/lib/docker",
+		PortBindings: []runtime.PortBinding{
+			{
+				Container: "foo",
+				PortMap: types.PortMap{
+					Port:     8080,
+					Protocol: "tcp",
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expected, info); diff != "" {
+		t.Fatalf("Unexpected info: %s", diff)
+	}
+}
+
+func TestInfoRuntimeError(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &docker.Config{
+		ClientGetter: func(...client.Opt) (docker.Client, error) {
+			return &docker.FakeClient{
+				InfoF: func(context.Context) (dockertypes.Info, error) {
+					return dockertypes.Info{}, fmt.Errorf("daemon not running")
+				},
+			}, nil
+		},
+	}
+
+	testClient, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating test client: %v", err)
+	}
+
+	if _, err := testClient.(runtime.Informer).Info(); err == nil { //nolint:forcetypeassert // Checked in TestInfo.
+		t.Fatalf("Getting info should fail")
+	}
+}
@@ -59,6 +59,15 @@ type FakeClient struct {
 
 	// ImagePullF will be called by ImagePull.
 	ImagePullF func(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error)
+
+	// InfoF will be called by Info.
+	InfoF func(ctx context.Context) (dockertypes.Info, error)
+
+	// ServerVersionF will be called by ServerVersion.
+	ServerVersionF func(ctx context.Context) (dockertypes.Version, error)
+
+	// ContainerListF will be called by ContainerList.
+	ContainerListF func(ctx context.Context, options dockertypes.ContainerListOptions) ([]dockertypes.Container, error)
 }
 
 // ContainerCreate mocks Docker client ContainerCreate().
@@ -154,3 +163,33 @@ func (f *FakeClient) ImagePull(
 
 	return f.ImagePullF(ctx, ref, options)
 }
+
+// Info mocks Docker client Info().
+func (f *FakeClient) Info(ctx context.Context) (dockertypes.Info, error) {
+	if f.InfoF == nil {
+		return dockertypes.Info{}, nil
+	}
+
+	return f.InfoF(ctx)
+}
+
+// ServerVersion mocks Docker client ServerVersion().
+func (f *FakeClient) ServerVersion(ctx context.Context) (dockertypes.Version, error) {
+	if f.ServerVersionF == nil {
+		return dockertypes.Version{}, nil
+	}
+
+	return f.ServerVersionF(ctx)
+}
+
+// ContainerList mocks Docker client ContainerList().
+func (f *FakeClient) ContainerList(
+	ctx context.Context,
+	options dockertypes.ContainerListOptions,
+) ([]dockertypes.Container, error) {
+	if f.ContainerListF == nil {
+		return []dockertypes.Container{}, nil
+	}
+
+	return f.ContainerListF(ctx, options)
+}
@@ -41,6 +41,49 @@ type Runtime interface {
 	Stat(ID string, paths []string) (map[string]os.FileMode, error)
 }
 
+// Info describes container runtime and the host it runs on.
+type Info struct {
+	// Name is a name of the container runtime, e.g. 'docker'.
+	Name string `json:"name"`
+
+	// Version is a version of the container runtime.
+	Version string `json:"version"`
+
+	// APIVersion is the highest API version supported by the container runtime.
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// MinAPIVersion is the lowest API version supported by the container runtime.
+	MinAPIVersion string `json:"minAPIVersion,omitempty"`
+
+	// ClientAPIVersion is the API version used to talk to the container runtime.
+	ClientAPIVersion string `json:"clientAPIVersion,omitempty"`
+
+	// CgroupDriver is a cgroup driver used by the container runtime, e.g. 'systemd' or 'cgroupfs'.
+	CgroupDriver string `json:"cgroupDriver,omitempty"`
+
+	// RootDir is a directory, where container runtime stores images and containers.
+	RootDir string `json:"rootDir,omitempty"`
+
+	// PortBindings is a list of host ports published by running containers.
+	PortBindings []PortBinding `json:"portBindings,omitempty"`
+}
+
+// PortBinding describes host port published by the container.
+type PortBinding struct {
+	// Container is a name of the container publishing the port.
+	Container string `json:"container"`
+
+	// PortMap describes published port.
+	types.PortMap
+}
+
+// Informer is implemented by container runtimes, which are able to describe themselves and the host
+// they run on. It is used for running pre-flight checks before deploying containers.
+type Informer interface {
+	// Info returns information about the container runtime.
+	Info() (*Info, error)
+}
+
 // Config defines interface for runtime configuration. Since some feature are generic to runtime,
 // this interface make sure that other parts of the system are compatible with it.
 type Config interface {
@@ -52,15 +52,21 @@ type ContainerConfig struct {
 }
 
 // ContainerStatus stores status information received from the runtime.
-//
-// TODO: This should cover all fields which are defined in ContainerConfig,
-// so we can read and compare if actual configuration matches our expectations.
 type ContainerStatus struct {
 	// ID is a runtime specific container ID.
 	ID string `json:"id,omitempty"`
 
 	// Status is a runtime specific status string.
 	Status string `json:"status,omitempty"`
+
+	// Config is a live configuration of the container as reported by the runtime. It allows
+	// to detect changes made to the container outside of flexkube.
+	//
+	// Runtimes may fill fields, which were not set when creating the container, with their
+	// default values. If runtime is not able to report the configuration, it will be nil.
+	//
+	// It is not persisted, as it is always read from the runtime.
+	Config *ContainerConfig `json:"-"`
 }
 
 // PortMap is basically a github.com/docker/go-connections/nat.PortMap.
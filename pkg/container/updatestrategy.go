@@ -0,0 +1,212 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+// UpdateStrategy controls, how existing containers with pending changes are updated.
+//
+// Containers are updated in batches. Once all containers in a batch are updated, readiness check
+// is executed for each of them and only when all of them are ready, the next batch is started.
+type UpdateStrategy struct {
+	// BatchSize controls, how many containers with pending changes can be updated at the same time.
+	//
+	// This field is optional. If not set, value of parallelism will be used.
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// MaxUnavailable controls, how many containers in a single batch can be recreated or removed,
+	// which makes them unavailable until the new container is ready.
+	//
+	// This field is optional. If not set, it is equal to the batch size.
+	MaxUnavailable int `json:"maxUnavailable,omitempty"`
+
+	// RestartOnConfigChange controls, if containers should be restarted after their configuration
+	// files are updated. This is required for processes, which only read their configuration files
+	// on startup, e.g. to pick up renewed certificates.
+	//
+	// Restarted containers count towards MaxUnavailable.
+	RestartOnConfigChange bool `json:"restartOnConfigChange,omitempty"`
+}
+
+// ReadinessCheck is a function, which blocks until container with given name becomes ready.
+// If container does not become ready, error should be returned, which aborts the deployment.
+type ReadinessCheck func(containerName string) error
+
+// Validate validates UpdateStrategy struct.
+func (u *UpdateStrategy) Validate() error {
+	var errors util.ValidateErrors
+
+	if u.BatchSize < 0 {
+		errors = append(errors, fmt.Errorf("batch size must not be negative"))
+	}
+
+	if u.MaxUnavailable < 0 {
+		errors = append(errors, fmt.Errorf("max unavailable must not be negative"))
+	}
+
+	return errors.Return()
+}
+
+// updateBatch is a group of containers, which are updated at the same time.
+type updateBatch struct {
+	// containers is a list of all containers in the batch, including ones without pending
+	// changes.
+	containers []string
+
+	// updated is a list of containers, which will be running after the update and for which
+	// readiness check should be executed.
+	updated []string
+}
+
+// restartOnConfigChange returns true, if containers should be restarted after updating
+// their configuration files.
+func (c *containers) restartOnConfigChange() bool {
+	return c.updateStrategy != nil && c.updateStrategy.RestartOnConfigChange
+}
+
+// batchLimits returns batch size and number of containers, which can be unavailable
+// at the same time.
+func (c *containers) batchLimits() (int, int) {
+	batchSize := c.parallelism
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	if c.updateStrategy == nil {
+		return batchSize, batchSize
+	}
+
+	if c.updateStrategy.BatchSize > 0 {
+		batchSize = c.updateStrategy.BatchSize
+	}
+
+	maxUnavailable := batchSize
+
+	if c.updateStrategy.MaxUnavailable > 0 {
+		maxUnavailable = c.updateStrategy.MaxUnavailable
+	}
+
+	return batchSize, maxUnavailable
+}
+
+// updateBatches splits containers from the current state into batches according to the update
+// strategy. Containers without pending changes are not included in any batch.
+func (c *containers) updateBatches() ([]updateBatch, error) {
+	batchSize, maxUnavailable := c.batchLimits()
+
+	containerNames := []string{}
+
+	for containerName := range c.currentState {
+		containerNames = append(containerNames, containerName)
+	}
+
+	sort.Strings(containerNames)
+
+	batches := []updateBatch{}
+	batch := updateBatch{}
+	updates := 0
+	unavailable := 0
+
+	for _, containerName := range containerNames {
+		action, err := c.planExisting(containerName, c.currentState[containerName])
+		if err != nil {
+			return nil, fmt.Errorf("planning container %q: %w", containerName, err)
+		}
+
+		if action == nil {
+			continue
+		}
+
+		disruptive := action.Action == ActionRecreate || action.Action == ActionRemove || action.Action == ActionRestart
+
+		if updates == batchSize || (disruptive && unavailable == maxUnavailable) {
+			batches = append(batches, batch)
+			batch = updateBatch{}
+			updates = 0
+			unavailable = 0
+		}
+
+		batch.containers = append(batch.containers, containerName)
+		updates++
+
+		if disruptive {
+			unavailable++
+		}
+
+		if action.Action != ActionRemove {
+			batch.updated = append(batch.updated, containerName)
+		}
+	}
+
+	if len(batch.containers) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// waitForReady executes readiness check for given containers one by one.
+func (c *containers) waitForReady(containerNames []string) error {
+	if c.readinessCheck == nil {
+		return nil
+	}
+
+	for _, containerName := range containerNames {
+		fmt.Printf("Waiting for container %q to become ready\n", containerName)
+
+		if err := c.readinessCheck(containerName); err != nil {
+			return fmt.Errorf("container %q did not become ready: %w", containerName, err)
+		}
+	}
+
+	return nil
+}
+
+// updateExistingContainers handles updating all existing containers in batches defined by
+// the update strategy. Next batch is only started, when all updated containers from the previous
+// batch are ready.
+func (c *containers) updateExistingContainers() error {
+	batches, err := c.updateBatches()
+	if err != nil {
+		return fmt.Errorf("splitting containers into batches: %w", err)
+	}
+
+	// Containers without pending changes may still need their state refreshed, so they are handled
+	// first, without waiting for readiness.
+	unchangedState := containersState{}
+
+	for containerName, hcc := range c.currentState {
+		unchangedState[containerName] = hcc
+	}
+
+	for _, batch := range batches {
+		for _, containerName := range batch.containers {
+			delete(unchangedState, containerName)
+		}
+	}
+
+	if err := c.forEachContainer(unchangedState, c.parallelism, (*containers).updateExistingContainer); err != nil {
+		return fmt.Errorf("updating containers: %w", err)
+	}
+
+	for _, batch := range batches {
+		batchState := containersState{}
+
+		for _, containerName := range batch.containers {
+			batchState[containerName] = c.currentState[containerName]
+		}
+
+		if err := c.forEachContainer(batchState, c.parallelism, (*containers).updateExistingContainer); err != nil {
+			return fmt.Errorf("updating containers: %w", err)
+		}
+
+		if err := c.waitForReady(batch.updated); err != nil {
+			return fmt.Errorf("waiting for updated containers: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+)
+
+func updateTestHCC(image string, status types.ContainerStatus) *hostConfiguredContainer {
+	return &hostConfiguredContainer{
+		hooks: &Hooks{},
+		host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		container: &container{
+			base: base{
+				config: types.ContainerConfig{
+					Image: image,
+				},
+				status:        status,
+				runtimeConfig: asRuntime(fakeRuntime()),
+			},
+		},
+	}
+}
+
+// updateTestContainers returns containers, where all given containers must be recreated
+// because of changed image.
+func updateTestContainers(containerNames ...string) *containers {
+	testContainers := &containers{
+		currentState: containersState{},
+		desiredState: containersState{},
+	}
+
+	for _, containerName := range containerNames {
+		testContainers.currentState[containerName] = updateTestHCC(testAnotherImage, types.ContainerStatus{
+			ID:     testContainerID,
+			Status: "running",
+		})
+		testContainers.desiredState[containerName] = updateTestHCC(testImage, types.ContainerStatus{})
+	}
+
+	return testContainers
+}
+
+// Validate() tests.
+func TestUpdateStrategyValidateNegative(t *testing.T) {
+	t.Parallel()
+
+	u := &UpdateStrategy{
+		BatchSize:      -1,
+		MaxUnavailable: -1,
+	}
+
+	if err := u.Validate(); err == nil {
+		t.Fatalf("Validating update strategy with negative values should fail")
+	}
+}
+
+func TestContainersValidateBadUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	c := &Containers{
+		DesiredState: ContainersState{
+			testContainerName: &HostConfiguredContainer{
+				Host: host.Host{
+					DirectConfig: &direct.Config{},
+				},
+				Container: Container{
+					Config: types.ContainerConfig{
+						Name:  testContainerName,
+						Image: testImage,
+					},
+					Runtime: RuntimeConfig{
+						Docker: docker.DefaultConfig(),
+					},
+				},
+			},
+		},
+		UpdateStrategy: &UpdateStrategy{
+			BatchSize: -1,
+		},
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validating containers with invalid update strategy should fail")
+	}
+}
+
+// updateBatches() tests.
+func TestUpdateBatchesDefault(t *testing.T) {
+	t.Parallel()
+
+	testContainers := updateTestContainers("a", "b", "c")
+	testContainers.currentState["d"] = planTestHCC(testContainerID, types.ContainerConfig{}, nil)
+	testContainers.desiredState["d"] = planTestHCC("", types.ContainerConfig{}, nil)
+
+	batches, err := testContainers.updateBatches()
+	if err != nil {
+		t.Fatalf("Splitting containers into batches should succeed, got: %v", err)
+	}
+
+	expectedBatches := []updateBatch{
+		{containers: []string{"a"}, updated: []string{"a"}},
+		{containers: []string{"b"}, updated: []string{"b"}},
+		{containers: []string{"c"}, updated: []string{"c"}},
+	}
+
+	if diff := cmp.Diff(expectedBatches, batches, cmp.AllowUnexported(updateBatch{})); diff != "" {
+		t.Fatalf("Unexpected batches: %s", diff)
+	}
+}
+
+func TestUpdateBatchesMaxUnavailable(t *testing.T) {
+	t.Parallel()
+
+	testContainers := updateTestContainers("a", "b", "c")
+	testContainers.updateStrategy = &UpdateStrategy{
+		BatchSize:      3,
+		MaxUnavailable: 2,
+	}
+
+	// Container with only configuration files changed does not become unavailable during update.
+	testContainers.currentState["b"] = planTestHCC(testContainerID, types.ContainerConfig{}, nil)
+	testContainers.desiredState["b"] = planTestHCC("", types.ContainerConfig{}, map[string]string{
+		testConfigPath: testConfigContent,
+	})
+
+	// Removed container becomes unavailable, but it should not be checked for readiness.
+	testContainers.currentState["d"] = planTestHCC(testContainerID, types.ContainerConfig{}, nil)
+
+	batches, err := testContainers.updateBatches()
+	if err != nil {
+		t.Fatalf("Splitting containers into batches should succeed, got: %v", err)
+	}
+
+	expectedBatches := []updateBatch{
+		{containers: []string{"a", "b", "c"}, updated: []string{"a", "b", "c"}},
+		{containers: []string{"d"}},
+	}
+
+	if diff := cmp.Diff(expectedBatches, batches, cmp.AllowUnexported(updateBatch{})); diff != "" {
+		t.Fatalf("Unexpected batches: %s", diff)
+	}
+}
+
+func TestUpdateBatchesRestartOnConfigChange(t *testing.T) {
+	t.Parallel()
+
+	testContainers := &containers{
+		currentState: containersState{},
+		desiredState: containersState{},
+		updateStrategy: &UpdateStrategy{
+			BatchSize:             2,
+			MaxUnavailable:        1,
+			RestartOnConfigChange: true,
+		},
+	}
+
+	for _, containerName := range []string{"a", "b"} {
+		testContainers.currentState[containerName] = planTestHCC(testContainerID, types.ContainerConfig{}, nil)
+		testContainers.desiredState[containerName] = planTestHCC("", types.ContainerConfig{}, map[string]string{
+			testConfigPath: testConfigContent,
+		})
+	}
+
+	batches, err := testContainers.updateBatches()
+	if err != nil {
+		t.Fatalf("Splitting containers into batches should succeed, got: %v", err)
+	}
+
+	// Restarted containers become unavailable, so they must not be restarted at the same time.
+	expectedBatches := []updateBatch{
+		{containers: []string{"a"}, updated: []string{"a"}},
+		{containers: []string{"b"}, updated: []string{"b"}},
+	}
+
+	if diff := cmp.Diff(expectedBatches, batches, cmp.AllowUnexported(updateBatch{})); diff != "" {
+		t.Fatalf("Unexpected batches: %s", diff)
+	}
+}
+
+// restart() tests.
+func TestRestart(t *testing.T) {
+	t.Parallel()
+
+	stopped := false
+	started := false
+
+	r := fakeRuntime()
+	r.StopF = func(string) error {
+		stopped = true
+
+		return nil
+	}
+	r.StartF = func(string) error {
+		if !stopped {
+			t.Errorf("Container should be stopped before starting")
+		}
+
+		started = true
+
+		return nil
+	}
+
+	hcc := updateTestHCC(testImage, types.ContainerStatus{
+		ID:     testContainerID,
+		Status: "running",
+	})
+	hcc.container = &container{
+		base: base{
+			status:        *hcc.container.Status(),
+			runtimeConfig: asRuntime(r),
+		},
+	}
+
+	testContainers := &containers{
+		currentState: containersState{
+			testContainerName: hcc,
+		},
+	}
+
+	if err := testContainers.restart(testContainerName); err != nil {
+		t.Fatalf("Restarting container should succeed, got: %v", err)
+	}
+
+	if !started {
+		t.Fatalf("Container should be started")
+	}
+}
+
+// updateExistingContainers() tests.
+func TestUpdateExistingContainersReadinessCheck(t *testing.T) {
+	t.Parallel()
+
+	testContainers := updateTestContainers("a", "b")
+
+	ready := []string{}
+
+	testContainers.readinessCheck = func(containerName string) error {
+		// Next container must not be touched, until previous one is ready.
+		if containerName == "a" && testContainers.currentState["b"].container.Status().ID != testContainerID {
+			t.Errorf("Container %q should not be updated before container %q is ready", "b", "a")
+		}
+
+		ready = append(ready, containerName)
+
+		return nil
+	}
+
+	if err := testContainers.updateExistingContainers(); err != nil {
+		t.Fatalf("Updating existing containers should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"a", "b"}, ready); diff != "" {
+		t.Fatalf("Unexpected readiness checks: %s", diff)
+	}
+}
+
+func TestUpdateExistingContainersReadinessCheckFail(t *testing.T) {
+	t.Parallel()
+
+	testContainers := updateTestContainers("a", "b")
+	testContainers.readinessCheck = func(string) error {
+		return fmt.Errorf("not ready")
+	}
+
+	if err := testContainers.updateExistingContainers(); err == nil {
+		t.Fatalf("Updating existing containers should fail when container does not become ready")
+	}
+
+	if testContainers.currentState["a"].container.Status().ID != testAnotherContainerID {
+		t.Fatalf("Container %q should be updated", "a")
+	}
+
+	if testContainers.currentState["b"].container.Status().ID != testContainerID {
+		t.Fatalf("Container %q should not be updated, when previous container is not ready", "b")
+	}
+}
+
+func TestUpdateExistingContainersParallelism(t *testing.T) {
+	t.Parallel()
+
+	testContainers := updateTestContainers("a", "b", "c")
+	testContainers.parallelism = 1
+	testContainers.updateStrategy = &UpdateStrategy{
+		BatchSize:      3,
+		MaxUnavailable: 3,
+	}
+
+	var running, maxRunning int32
+
+	for containerName := range testContainers.desiredState {
+		testRuntime := fakeRuntime()
+		testRuntime.CreateF = func(*types.ContainerConfig) (string, error) {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				previous := atomic.LoadInt32(&maxRunning)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+
+			return testAnotherContainerID, nil
+		}
+
+		testContainers.desiredState[containerName].container.(*container).base.runtimeConfig = asRuntime(testRuntime)
+	}
+
+	if err := testContainers.updateExistingContainers(); err != nil {
+		t.Fatalf("Updating existing containers should succeed, got: %v", err)
+	}
+
+	if maxRunning != 1 {
+		t.Fatalf("Expected at most 1 container to be updated at the same time, got %d", maxRunning)
+	}
+}
+
+// recordingRuntime is a container runtime, which records start and stop calls for running
+// container. Unlike runtime.Fake, it has no function fields, so two containers using it are
+// not reported as having different runtime configuration.
+type recordingRuntime struct {
+	Calls *[]string
+}
+
+func (r recordingRuntime) Create(*types.ContainerConfig) (string, error) {
+	return testAnotherContainerID, nil
+}
+
+func (r recordingRuntime) Delete(string) error {
+	return nil
+}
+
+func (r recordingRuntime) Start(id string) error {
+	if id == testContainerID {
+		*r.Calls = append(*r.Calls, "start")
+	}
+
+	return nil
+}
+
+func (r recordingRuntime) Status(id string) (types.ContainerStatus, error) {
+	return types.ContainerStatus{
+		ID:     id,
+		Status: "running",
+	}, nil
+}
+
+func (r recordingRuntime) Stop(id string) error {
+	if id == testContainerID {
+		*r.Calls = append(*r.Calls, "stop")
+	}
+
+	return nil
+}
+
+func (r recordingRuntime) Copy(string, []*types.File) error {
+	return nil
+}
+
+func (r recordingRuntime) Read(string, []string) ([]*types.File, error) {
+	return nil, nil
+}
+
+func (r recordingRuntime) Stat(string, []string) (map[string]os.FileMode, error) {
+	return map[string]os.FileMode{}, nil
+}
+
+// Deploy() tests.
+func TestDeployRestartOnConfigChange(t *testing.T) {
+	t.Parallel()
+
+	calls := []string{}
+
+	runtimeConfig := &runtime.FakeConfig{
+		Runtime: recordingRuntime{
+			Calls: &calls,
+		},
+	}
+
+	testHCC := func(status types.ContainerStatus, content string) *hostConfiguredContainer {
+		return &hostConfiguredContainer{
+			hooks: &Hooks{},
+			host: host.Host{
+				DirectConfig: &direct.Config{},
+			},
+			configFiles: map[string]string{
+				testConfigPath: content,
+			},
+			container: &container{
+				base: base{
+					config: types.ContainerConfig{
+						Image: testImage,
+					},
+					status:        status,
+					runtimeConfig: runtimeConfig,
+				},
+			},
+		}
+	}
+
+	testContainers := &containers{
+		currentState: containersState{
+			testContainerName: testHCC(types.ContainerStatus{
+				ID:     testContainerID,
+				Status: "running",
+			}, "old"),
+		},
+		desiredState: containersState{
+			testContainerName: testHCC(types.ContainerStatus{}, testConfigContent),
+		},
+		updateStrategy: &UpdateStrategy{
+			RestartOnConfigChange: true,
+		},
+	}
+
+	if err := testContainers.Deploy(); err != nil {
+		t.Fatalf("Deploying should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"stop", "start"}, calls); diff != "" {
+		t.Fatalf("Container should be restarted after configuration change: %s", diff)
+	}
+}
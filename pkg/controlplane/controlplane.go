@@ -14,9 +14,18 @@ import (
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
+const (
+	// kubeControllerManagerPort is a default secure port of kube-controller-manager.
+	kubeControllerManagerPort = 10257
+
+	// kubeSchedulerPort is a default secure port of kube-scheduler.
+	kubeSchedulerPort = 10259
+)
+
 // Common struct contains fields, which are common between all controlplane components.
 type Common struct {
 	// Image allows to set Docker image with tag, which will be used by all controlplane containers,
@@ -36,6 +45,12 @@ type Common struct {
 	// FrontProxyCACertificate stores Kubernetes front proxy X.509 CA certificate, PEM
 	// encoded.
 	FrontProxyCACertificate types.Certificate `json:"frontProxyCACertificate,omitempty"`
+
+	// Runtime selects container runtime, which will be used by all controlplane containers,
+	// if they have no runtime selected.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
 }
 
 // Controlplane allows creating static Kubernetes controlplane running as containers.
@@ -77,6 +92,14 @@ type Controlplane struct {
 	// containers will be removed.
 	Destroy bool `json:"destroy,omitempty"`
 
+	// WaitForAPIServerHealthy controls, if deployment should wait for kube-apiserver to report
+	// itself as healthy after it is updated, before updating other components. API server is
+	// reached using kube-controller-manager kubeconfig, so it must be reachable from the machine
+	// running the deployment.
+	//
+	// This field is optional.
+	WaitForAPIServerHealthy bool `json:"waitForAPIServerHealthy,omitempty"`
+
 	// PKI field allows to use PKI resource for managing all Kubernetes certificates. It will be used for
 	// components configuration, if they don't have certificates defined.
 	PKI *pki.PKI `json:"pki,omitempty"`
@@ -84,11 +107,27 @@ type Controlplane struct {
 	// State stores state of the created containers. After deployment, it is up to the user to export
 	// the state and restore it on consecutive runs.
 	State *container.ContainersState `json:"state,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// This field is optional. If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how many containers can be updated at the same time.
+	//
+	// This field is optional. If not set, containers will be updated one by one.
+	UpdateStrategy *container.UpdateStrategy `json:"updateStrategy,omitempty"`
 }
 
 // controlplane is executable version of Controlplane, with validated fields and calculated containers.
 type controlplane struct {
 	containers container.ContainersInterface
+
+	// kubeconfig is used to check kube-apiserver health. If empty, health is not checked.
+	kubeconfig string
+
+	// requirements holds pre-flight requirements of controlplane components.
+	requirements map[string]preflight.Requirements
 }
 
 // propagateKubeconfig merges given client config with values stored in Controlplane.
@@ -96,7 +135,7 @@ type controlplane struct {
 func (c *Controlplane) propagateKubeconfig(clientConfig *client.Config) {
 	pkiCA := types.Certificate("")
 	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.CA != nil {
-		pkiCA = c.PKI.Kubernetes.CA.X509Certificate
+		pkiCA = c.PKI.Kubernetes.CA.TrustBundle()
 	}
 
 	clientConfig.CACertificate = clientConfig.CACertificate.Pick(c.Common.KubernetesCACertificate, pkiCA)
@@ -120,9 +159,9 @@ func (c *Controlplane) propagateHost(h *host.Host) *host.Host {
 	return &nh
 }
 
-// propagateCommon merges given common configuration with values stored in Controlplane.
-// Values in given common configuration has priority over ones from the Controlplane.
-func (c *Controlplane) propagateCommon(common *Common) {
+// propagateCommon merges given common configuration with values stored in Controlplane
+// and returns it. Values in given common configuration has priority over ones from the Controlplane.
+func (c *Controlplane) propagateCommon(common *Common) *Common {
 	if common == nil {
 		common = &Common{}
 	}
@@ -133,18 +172,24 @@ func (c *Controlplane) propagateCommon(common *Common) {
 
 	common.Image = util.PickString(common.Image, c.Common.Image)
 
+	if common.Runtime == nil {
+		common.Runtime = c.Common.Runtime
+	}
+
 	var pkiCA types.Certificate
 	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.CA != nil {
-		pkiCA = c.PKI.Kubernetes.CA.X509Certificate
+		pkiCA = c.PKI.Kubernetes.CA.TrustBundle()
 	}
 
 	var frontProxyCA types.Certificate
 	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.FrontProxyCA != nil {
-		frontProxyCA = c.PKI.Kubernetes.FrontProxyCA.X509Certificate
+		frontProxyCA = c.PKI.Kubernetes.FrontProxyCA.TrustBundle()
 	}
 
 	common.KubernetesCACertificate = common.KubernetesCACertificate.Pick(c.Common.KubernetesCACertificate, pkiCA)
 	common.FrontProxyCACertificate = common.FrontProxyCACertificate.Pick(c.Common.FrontProxyCACertificate, frontProxyCA)
+
+	return common
 }
 
 // buildKubeScheduler fills KubeSheduler struct with all default values.
@@ -153,12 +198,12 @@ func (c *Controlplane) buildKubeScheduler() {
 
 	c.propagateKubeconfig(&ksc.Kubeconfig)
 
-	c.propagateCommon(ksc.Common)
+	ksc.Common = c.propagateCommon(ksc.Common)
 
 	// TODO: can be moved to function, which takes Kubeconfig and *pki.Certificate as an input
 	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.KubeSchedulerCertificate != nil {
 		ksc.Kubeconfig.ClientCertificate = ksc.Kubeconfig.ClientCertificate.Pick(
-			c.PKI.Kubernetes.KubeSchedulerCertificate.X509Certificate)
+			c.PKI.Kubernetes.KubeSchedulerCertificate.FullChain())
 
 		ksc.Kubeconfig.ClientKey = ksc.Kubeconfig.ClientKey.Pick(c.PKI.Kubernetes.KubeSchedulerCertificate.PrivateKey)
 	}
@@ -172,12 +217,12 @@ func (c *Controlplane) buildKubeControllerManager() {
 
 	c.propagateKubeconfig(&kcmc.Kubeconfig)
 
-	c.propagateCommon(kcmc.Common)
+	kcmc.Common = c.propagateCommon(kcmc.Common)
 
 	if c.PKI != nil && c.PKI.Kubernetes != nil {
 		if c.PKI.Kubernetes.KubeControllerManagerCertificate != nil {
 			kcmc.Kubeconfig.ClientCertificate = kcmc.Kubeconfig.ClientCertificate.Pick(
-				c.PKI.Kubernetes.KubeControllerManagerCertificate.X509Certificate)
+				c.PKI.Kubernetes.KubeControllerManagerCertificate.FullChain())
 
 			kcmc.Kubeconfig.ClientKey = kcmc.Kubeconfig.ClientKey.Pick(
 				c.PKI.Kubernetes.KubeControllerManagerCertificate.PrivateKey)
@@ -188,7 +233,7 @@ func (c *Controlplane) buildKubeControllerManager() {
 		}
 
 		if c.PKI.RootCA != nil {
-			kcmc.RootCACertificate = kcmc.RootCACertificate.Pick(c.PKI.RootCA.X509Certificate)
+			kcmc.RootCACertificate = kcmc.RootCACertificate.Pick(c.PKI.RootCA.TrustBundle())
 		}
 
 		if c.PKI.Kubernetes.ServiceAccountCertificate != nil {
@@ -241,13 +286,13 @@ func (c *Controlplane) mergeEtcdCertificatesFromPKI(etcdPKI pki.Etcd) {
 	apiConfig := &c.KubeAPIServer
 
 	if etcdPKI.CA != nil {
-		apiConfig.EtcdCACertificate = apiConfig.EtcdCACertificate.Pick(etcdPKI.CA.X509Certificate)
+		apiConfig.EtcdCACertificate = apiConfig.EtcdCACertificate.Pick(etcdPKI.CA.TrustBundle())
 	}
 
 	// "root" and "kube-apiserver" are common CNs for etcd client certificate for kube-apiserver.
 	for _, cn := range []string{"root", "kube-apiserver"} {
 		if c, ok := etcdPKI.ClientCertificates[cn]; ok {
-			apiConfig.EtcdClientCertificate = apiConfig.EtcdClientCertificate.Pick(c.X509Certificate)
+			apiConfig.EtcdClientCertificate = apiConfig.EtcdClientCertificate.Pick(c.FullChain())
 			apiConfig.EtcdClientKey = apiConfig.EtcdClientKey.Pick(c.PrivateKey)
 		}
 	}
@@ -259,17 +304,17 @@ func (c *Controlplane) mergeKubeAPIServerCertificatesFromPKI(apiPKI pki.KubeAPIS
 	apiConfig := &c.KubeAPIServer
 
 	if c := apiPKI.ServerCertificate; c != nil {
-		apiConfig.APIServerCertificate = apiConfig.APIServerCertificate.Pick(c.X509Certificate)
+		apiConfig.APIServerCertificate = apiConfig.APIServerCertificate.Pick(c.FullChain())
 		apiConfig.APIServerKey = apiConfig.APIServerKey.Pick(c.PrivateKey)
 	}
 
 	if c := apiPKI.FrontProxyClientCertificate; c != nil {
-		apiConfig.FrontProxyCertificate = apiConfig.FrontProxyCertificate.Pick(c.X509Certificate)
+		apiConfig.FrontProxyCertificate = apiConfig.FrontProxyCertificate.Pick(c.FullChain())
 		apiConfig.FrontProxyKey = apiConfig.FrontProxyKey.Pick(c.PrivateKey)
 	}
 
 	if c := apiPKI.KubeletCertificate; c != nil {
-		apiConfig.KubeletClientCertificate = apiConfig.KubeletClientCertificate.Pick(c.X509Certificate)
+		apiConfig.KubeletClientCertificate = apiConfig.KubeletClientCertificate.Pick(c.FullChain())
 		apiConfig.KubeletClientKey = apiConfig.KubeletClientKey.Pick(c.PrivateKey)
 	}
 }
@@ -290,7 +335,7 @@ func (c *Controlplane) buildKubeAPIServer() {
 		apiConfig.SecurePort = c.APIServerPort
 	}
 
-	c.propagateCommon(apiConfig.Common)
+	apiConfig.Common = c.propagateCommon(apiConfig.Common)
 
 	c.kubeAPIServerPKIIntegration()
 
@@ -329,6 +374,19 @@ func (c *Controlplane) New() (types.Resource, error) {
 		"kube-scheduler":          ksHcc,
 	}
 
+	controlplane.requirements = map[string]preflight.Requirements{
+		"kube-apiserver":          {Ports: []int{c.KubeAPIServer.SecurePort}},
+		"kube-controller-manager": {Ports: []int{kubeControllerManagerPort}},
+		"kube-scheduler":          {Ports: []int{kubeSchedulerPort}},
+	}
+
+	if c.WaitForAPIServerHealthy {
+		kubeconfig, _ := c.KubeControllerManager.Kubeconfig.ToYAMLString() //nolint:errcheck // We check it in Validate().
+
+		controlplane.kubeconfig = kubeconfig
+		containersConfig.ReadinessCheck = controlplane.componentReady
+	}
+
 	co, _ := containersConfig.New() //nolint:errcheck // We check it in Validate().
 
 	controlplane.containers = co
@@ -346,7 +404,10 @@ func (c *Controlplane) buildComponents() {
 
 func (c *Controlplane) containersWithState() (*controlplane, *container.Containers, error) {
 	newControlplane := &controlplane{}
-	containersConfig := &container.Containers{}
+	containersConfig := &container.Containers{
+		Parallelism:    c.Parallelism,
+		UpdateStrategy: c.UpdateStrategy,
+	}
 
 	// If state is empty, just return initialized containers config and controlplane.
 	if c.State == nil || len(*c.State) == 0 {
@@ -472,7 +533,27 @@ func (c *controlplane) Deploy() error {
 	return c.containers.Deploy()
 }
 
+// componentReady waits until kube-apiserver reports itself as healthy. Other components are
+// considered ready once they are running.
+func (c *controlplane) componentReady(name string) error {
+	if name != "kube-apiserver" || c.kubeconfig == "" {
+		return nil
+	}
+
+	kc, err := client.NewClient([]byte(c.kubeconfig))
+	if err != nil {
+		return fmt.Errorf("creating kubernetes client: %w", err)
+	}
+
+	return kc.WaitForHealthy(client.PollInterval, client.RetryTimeout)
+}
+
 // Containers implement types.Resource interface.
 func (c *controlplane) Containers() container.ContainersInterface {
 	return c.containers
 }
+
+// PreflightRequirements implements preflight.Requirer interface. Components require their secure ports.
+func (c *controlplane) PreflightRequirements() map[string]preflight.Requirements {
+	return c.requirements
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/internal/utiltest"
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/containerd"
 	"github.com/flexkube/libflexkube/pkg/pki"
 )
 
@@ -214,3 +216,91 @@ func TestControlplaneNewPKIIntegration(t *testing.T) {
 		t.Fatalf("Creating new controlplane with valid PKI should succeed, got: %v", err)
 	}
 }
+
+func TestControlplaneNewPropagateRuntime(t *testing.T) {
+	t.Parallel()
+
+	pki := &pki.PKI{
+		Etcd: &pki.Etcd{
+			ClientCNs: []string{"kube-apiserver", "root"},
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := pki.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	testConfig := &Controlplane{
+		Common: &Common{
+			Runtime: &container.RuntimeConfig{
+				Containerd: containerd.DefaultConfig(),
+			},
+		},
+		PKI:              pki,
+		APIServerAddress: "127.0.0.1",
+		APIServerPort:    6443,
+		KubeAPIServer: KubeAPIServer{
+			EtcdServers: []string{"https://127.0.0.1:2379"},
+		},
+	}
+
+	if _, err := testConfig.New(); err != nil {
+		t.Fatalf("Creating new controlplane should succeed, got: %v", err)
+	}
+
+	for name, common := range map[string]*Common{
+		"kube-apiserver":          testConfig.KubeAPIServer.Common,
+		"kube-controller-manager": testConfig.KubeControllerManager.Common,
+		"kube-scheduler":          testConfig.KubeScheduler.Common,
+	} {
+		if common == nil || common.Runtime == nil || common.Runtime.Containerd == nil {
+			t.Errorf("Runtime should be propagated to %s, got: %+v", name, common)
+		}
+	}
+}
+
+// componentReady() tests.
+func TestComponentReadyNotAPIServer(t *testing.T) {
+	t.Parallel()
+
+	testControlplane := &controlplane{
+		kubeconfig: "foo",
+	}
+
+	if err := testControlplane.componentReady("kube-scheduler"); err != nil {
+		t.Fatalf("Only kube-apiserver health should be checked, got: %v", err)
+	}
+}
+
+func TestComponentReadyBadKubeconfig(t *testing.T) {
+	t.Parallel()
+
+	testControlplane := &controlplane{
+		kubeconfig: "foo",
+	}
+
+	if err := testControlplane.componentReady("kube-apiserver"); err == nil {
+		t.Fatalf("Checking kube-apiserver health with bad kubeconfig should fail")
+	}
+}
+
+func TestControlplaneNewWaitForAPIServerHealthy(t *testing.T) {
+	t.Parallel()
+
+	testConfigRaw := controlplaneYAML(t) + "waitForAPIServerHealthy: true\n"
+
+	c, err := FromYaml([]byte(testConfigRaw))
+	if err != nil {
+		t.Fatalf("Creating controlplane should succeed, got: %v", err)
+	}
+
+	cp, ok := c.(*controlplane)
+	if !ok {
+		t.Fatalf("FromYaml should return controlplane, got: %T", c)
+	}
+
+	if cp.kubeconfig == "" {
+		t.Fatalf("Kubeconfig for checking kube-apiserver health should be set")
+	}
+}
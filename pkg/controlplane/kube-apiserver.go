@@ -7,7 +7,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -221,10 +220,7 @@ func (k *kubeAPIServer) ToHostConfiguredContainer() (*container.HostConfiguredCo
 		Host:        k.host,
 		ConfigFiles: k.configFiles(),
 		Container: container.Container{
-			// TODO: This is weird. This sets docker as default runtime config.
-			Runtime: container.RuntimeConfig{
-				Docker: docker.DefaultConfig(),
-			},
+			Runtime: container.PickRuntimeConfig(k.common.Runtime),
 			Config: containertypes.ContainerConfig{
 				Name:        containerName,
 				Image:       util.PickString(k.common.Image, defaults.KubeAPIServerImage),
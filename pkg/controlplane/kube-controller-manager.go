@@ -1,11 +1,11 @@
 package controlplane
 
 import (
+	"encoding/pem"
 	"fmt"
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -69,7 +69,10 @@ func (k *kubeControllerManager) args() []string {
 		"--use-service-account-credentials",
 		// signing-cert and signing-key flags are required for issuing certificates
 		// inside cluster. This is for example required for kubelet TLS bootstrapping.
-		"--cluster-signing-cert-file=/etc/kubernetes/pki/ca.crt",
+		//
+		// Signing certificate file must contain only the certificate matching the signing key,
+		// while ca.crt may contain also previous CA certificate during CA rotation.
+		"--cluster-signing-cert-file=/etc/kubernetes/pki/cluster-signing-ca.crt",
 		"--cluster-signing-key-file=/etc/kubernetes/pki/ca.key",
 		// Specifies private RSA key which will be used for signing service account tokens,
 		// as one of kube-controller-manager roles is to create tokens for each service account.
@@ -107,6 +110,9 @@ func (k *kubeControllerManager) ToHostConfiguredContainer() (*container.HostConf
 	configFiles["/etc/kubernetes/kube-controller-manager/pki/ca.crt"] = string(k.common.KubernetesCACertificate)
 	configFiles["/etc/kubernetes/kube-controller-manager/pki/ca.key"] = k.kubernetesCAKey
 
+	signingCA := firstPEMBlock(string(k.common.KubernetesCACertificate))
+	configFiles["/etc/kubernetes/kube-controller-manager/pki/cluster-signing-ca.crt"] = signingCA
+
 	caBundle := fmt.Sprintf("%s%s", k.rootCACertificate, string(k.common.KubernetesCACertificate))
 	configFiles["/etc/kubernetes/kube-controller-manager/pki/root.crt"] = caBundle
 
@@ -115,10 +121,7 @@ func (k *kubeControllerManager) ToHostConfiguredContainer() (*container.HostConf
 	configFiles["/etc/kubernetes/kube-controller-manager/pki/front-proxy-ca.crt"] = frontProxyCA
 
 	containerConfig := container.Container{
-		// TODO this is weird. This sets docker as default runtime config
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: container.PickRuntimeConfig(k.common.Runtime),
 		Config: containertypes.ContainerConfig{
 			Name:  "kube-controller-manager",
 			Image: util.PickString(k.common.Image, defaults.KubeControllerManagerImage),
@@ -139,6 +142,17 @@ func (k *kubeControllerManager) ToHostConfiguredContainer() (*container.HostConf
 	}, nil
 }
 
+// firstPEMBlock returns first PEM block from given PEM encoded data. If there is no
+// PEM block in the data, empty string is returned.
+func firstPEMBlock(data string) string {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return ""
+	}
+
+	return string(pem.EncodeToMemory(block))
+}
+
 // New validates KubeControllerManager and returns usable kubeControllerManager.
 func (k *KubeControllerManager) New() (container.ResourceInstance, error) {
 	if k.Common == nil {
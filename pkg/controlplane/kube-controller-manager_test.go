@@ -166,6 +166,33 @@ func TestKubeControllerManagerToHostConfiguredContainer(t *testing.T) {
 	}
 }
 
+func TestKubeControllerManagerClusterSigningCertificate(t *testing.T) {
+	t.Parallel()
+
+	newCA := utiltest.GeneratePKI(t).Certificate
+	oldCA := utiltest.GeneratePKI(t).Certificate
+
+	kcm := &kubeControllerManager{
+		common: Common{
+			KubernetesCACertificate: types.Certificate(newCA + oldCA),
+		},
+	}
+
+	hcc, err := kcm.ToHostConfiguredContainer()
+	if err != nil {
+		t.Fatalf("Generating HostConfiguredContainer should work, got: %v", err)
+	}
+
+	signingCA := hcc.ConfigFiles["/etc/kubernetes/kube-controller-manager/pki/cluster-signing-ca.crt"]
+	if signingCA != newCA {
+		t.Fatalf("Cluster signing certificate should contain only first CA certificate, got:\n%s", signingCA)
+	}
+
+	if ca := hcc.ConfigFiles["/etc/kubernetes/kube-controller-manager/pki/ca.crt"]; ca != newCA+oldCA {
+		t.Fatalf("CA certificate file should contain whole CA bundle, got:\n%s", ca)
+	}
+}
+
 // New() tests.
 func TestKubeControllerManagerNewEmptyHost(t *testing.T) {
 	t.Parallel()
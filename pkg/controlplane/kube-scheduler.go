@@ -10,7 +10,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -63,10 +62,7 @@ func (k *kubeScheduler) ToHostConfiguredContainer() (*container.HostConfiguredCo
 	configFiles["/etc/kubernetes/kube-scheduler/kube-scheduler.yaml"] = string(configRaw)
 
 	containerConfig := container.Container{
-		// TODO: This is weird. This sets docker as default runtime config.
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: container.PickRuntimeConfig(k.common.Runtime),
 		Config: containertypes.ContainerConfig{
 			Name:  "kube-scheduler",
 			Image: util.PickString(k.common.Image, defaults.KubeSchedulerImage),
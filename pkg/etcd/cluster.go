@@ -18,6 +18,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/host"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -88,6 +89,33 @@ type Cluster struct {
 	// ExtraMounts defines extra mounts from host filesystem, which should be added to member
 	// containers. It will be used unless member define it's own extra mounts.
 	ExtraMounts []containertypes.Mount `json:"extraMounts,omitempty"`
+
+	// Runtime selects container runtime, which will be used by all members, if member has no
+	// runtime selected.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// This field is optional. If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how many members can be updated at the same time. Each updated
+	// member must become healthy, before the next member is updated.
+	//
+	// This field is optional. If not set, members will be updated one by one.
+	UpdateStrategy *container.UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// DestroyMembers is a list of members from the state, which should be removed from the cluster
+	// and then have their containers removed. Members are removed using etcd member remove API, using
+	// configuration stored in the state, so Members field must be empty when this field is set.
+	//
+	// Remaining members are kept as they are stored in the state. If all members are destroyed,
+	// containers are simply removed.
+	//
+	// This field is optional.
+	DestroyMembers []string `json:"destroyMembers,omitempty"`
 }
 
 // cluster is executable version of Cluster, with validated fields and calculated containers.
@@ -122,20 +150,24 @@ func (c *Cluster) propagateMember(memberName string, memberConfig *MemberConfig)
 		memberConfig.ExtraMounts = c.ExtraMounts
 	}
 
+	if memberConfig.Runtime == nil {
+		memberConfig.Runtime = c.Runtime
+	}
+
 	// PKI integration.
 	if c.PKI != nil && c.PKI.Etcd != nil {
 		etcdPKI := c.PKI.Etcd
 
 		memberConfig.CACertificate = util.PickString(memberConfig.CACertificate, c.CACertificate,
-			string(etcdPKI.CA.X509Certificate))
+			string(etcdPKI.CA.TrustBundle()))
 
 		if c, ok := etcdPKI.PeerCertificates[memberConfig.Name]; ok {
-			memberConfig.PeerCertificate = util.PickString(memberConfig.PeerCertificate, string(c.X509Certificate))
+			memberConfig.PeerCertificate = util.PickString(memberConfig.PeerCertificate, string(c.FullChain()))
 			memberConfig.PeerKey = util.PickString(memberConfig.PeerKey, string(c.PrivateKey))
 		}
 
 		if c, ok := etcdPKI.ServerCertificates[memberConfig.Name]; ok {
-			memberConfig.ServerCertificate = util.PickString(memberConfig.ServerCertificate, string(c.X509Certificate))
+			memberConfig.ServerCertificate = util.PickString(memberConfig.ServerCertificate, string(c.FullChain()))
 			memberConfig.ServerKey = util.PickString(memberConfig.ServerKey, string(c.PrivateKey))
 		}
 	}
@@ -158,14 +190,23 @@ func (c *Cluster) New() (types.Resource, error) {
 	}
 
 	containersConfig := container.Containers{
-		PreviousState: c.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  c.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    c.Parallelism,
+		UpdateStrategy: c.UpdateStrategy,
 	}
 
 	cluster := &cluster{
 		members: map[string]Member{},
 	}
 
+	containersConfig.ReadinessCheck = cluster.memberReady
+
+	if len(c.DestroyMembers) > 0 {
+		//nolint:errcheck // We check it in Validate().
+		containersConfig.DesiredState, cluster.members, _ = c.remainingMembers()
+	}
+
 	for name, m := range c.Members {
 		m := m
 		c.propagateMember(name, &m)
@@ -204,8 +245,16 @@ func (c *Cluster) Validate() error {
 	}
 
 	containersConfig := container.Containers{
-		PreviousState: c.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  c.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    c.Parallelism,
+		UpdateStrategy: c.UpdateStrategy,
+	}
+
+	if len(c.DestroyMembers) > 0 {
+		if err := c.validateDestroyMembers(); err != nil {
+			errors = append(errors, fmt.Errorf("validating members to destroy: %w", err))
+		}
 	}
 
 	for name, m := range c.Members {
@@ -236,6 +285,63 @@ func (c *Cluster) Validate() error {
 	return errors.Return()
 }
 
+// validateDestroyMembers validates, that members to destroy can be removed using information
+// stored in the state.
+func (c *Cluster) validateDestroyMembers() error {
+	var errors util.ValidateErrors
+
+	if len(c.Members) > 0 {
+		errors = append(errors, fmt.Errorf("members can't be defined when destroying members"))
+	}
+
+	for _, name := range c.DestroyMembers {
+		if _, ok := c.State[name]; !ok {
+			errors = append(errors, fmt.Errorf("member %q not found in the state", name))
+		}
+	}
+
+	if _, _, err := c.remainingMembers(); err != nil {
+		errors = append(errors, err)
+	}
+
+	return errors.Return()
+}
+
+// remainingMembers builds desired state and members, which are not destroyed, from the state.
+func (c *Cluster) remainingMembers() (container.ContainersState, map[string]Member, error) {
+	var errors util.ValidateErrors
+
+	desiredState := container.ContainersState{}
+	members := map[string]Member{}
+
+	destroyed := map[string]struct{}{}
+
+	for _, name := range c.DestroyMembers {
+		destroyed[name] = struct{}{}
+	}
+
+	for name, hcc := range c.State {
+		if _, ok := destroyed[name]; ok || hcc == nil {
+			continue
+		}
+
+		m, err := memberConfigFromState(name, hcc).New()
+		if err != nil {
+			errors = append(errors, fmt.Errorf("building member %q from the state: %w", name, err))
+
+			continue
+		}
+
+		desiredHCC := *hcc
+		desiredHCC.Container.Status = nil
+
+		desiredState[name] = &desiredHCC
+		members[name] = m
+	}
+
+	return desiredState, members, errors.Return()
+}
+
 // FromYaml allows to create and validate resource from YAML format.
 func FromYaml(c []byte) (types.Resource, error) {
 	return types.ResourceFromYaml(c, &Cluster{})
@@ -284,18 +390,16 @@ func (c *cluster) getClient() (etcdClient, error) {
 		return nil, fmt.Errorf("getting member object: %w", err)
 	}
 
-	endpoints, err := firstMember.forwardEndpoints(c.getExistingEndpoints())
-	if err != nil {
-		return nil, fmt.Errorf("forwarding endpoints: %w", err)
-	}
+	cli, _, err := newForwardedEtcdClient(firstMember, c.getExistingEndpoints())
 
-	return firstMember.getEtcdClient(endpoints)
+	return cli, err
 }
 
 type etcdClient interface {
 	MemberList(context context.Context) (*clientv3.MemberListResponse, error)
 	MemberAdd(context context.Context, peerURLs []string) (*clientv3.MemberAddResponse, error)
 	MemberRemove(context context.Context, id uint64) (*clientv3.MemberRemoveResponse, error)
+	Status(context context.Context, endpoint string) (*clientv3.StatusResponse, error)
 	Close() error
 }
 
@@ -329,11 +433,12 @@ func (c *cluster) membersToAdd() []string {
 
 // updateMembers adds and remove members from the cluster according to the configuration.
 func (c *cluster) updateMembers(cli etcdClient) error {
+	previousState := c.containers.ToExported().PreviousState
+
 	for _, name := range c.membersToRemove() {
+		// Use member configuration from the state, so member can also be matched by the peer URL.
 		member := &member{
-			config: &MemberConfig{
-				Name: name,
-			},
+			config: memberConfigFromState(name, previousState[name]),
 		}
 
 		if err := member.remove(cli); err != nil {
@@ -363,6 +468,10 @@ func (c *cluster) Deploy() error {
 		}
 
 		if err := c.updateMembers(cli); err != nil {
+			if closeErr := cli.Close(); closeErr != nil {
+				fmt.Printf("Failed closing etcd client: %v\n", closeErr)
+			}
+
 			return fmt.Errorf("updating members before deploying: %w", err)
 		}
 
@@ -374,7 +483,31 @@ func (c *cluster) Deploy() error {
 	return c.containers.Deploy()
 }
 
+// memberReady waits until member with given name becomes healthy. Members which are
+// not part of the configuration are skipped.
+func (c *cluster) memberReady(name string) error {
+	m, ok := c.members[name]
+	if !ok {
+		return nil
+	}
+
+	return m.waitReady()
+}
+
 // Containers implement types.Resource interface.
 func (c *cluster) Containers() container.ContainersInterface {
 	return c.containers
 }
+
+// PreflightRequirements implements preflight.Requirer interface. Members require client and peer ports.
+func (c *cluster) PreflightRequirements() map[string]preflight.Requirements {
+	requirements := map[string]preflight.Requirements{}
+
+	for name := range c.members {
+		requirements[name] = preflight.Requirements{
+			Ports: []int{clientPort, peerPort},
+		}
+	}
+
+	return requirements
+}
@@ -725,3 +725,69 @@ func Test_Building_members_config_from_cluster_config(t *testing.T) {
 		})
 	})
 }
+
+// memberReady() tests.
+func TestMemberReadyNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	testCluster := &cluster{
+		members: map[string]Member{},
+	}
+
+	if err := testCluster.memberReady("foo"); err != nil {
+		t.Fatalf("Checking readiness of member, which is not configured should be skipped, got: %v", err)
+	}
+}
+
+// DestroyMembers tests.
+func TestDestroyMembers(t *testing.T) {
+	t.Parallel()
+
+	c := &Cluster{
+		State: container.ContainersState{
+			"foo": testStateMember(t, "foo", "10.0.0.1"),
+			"bar": testStateMember(t, "bar", "10.0.0.2"),
+		},
+		DestroyMembers: []string{"foo"},
+	}
+
+	r, err := c.New()
+	if err != nil {
+		t.Fatalf("Creating cluster with members to destroy should succeed, got: %v", err)
+	}
+
+	testCluster, ok := r.(*cluster)
+	if !ok {
+		t.Fatalf("New() should return cluster object")
+	}
+
+	if _, ok := testCluster.members["bar"]; !ok || len(testCluster.members) != 1 {
+		t.Fatalf("Only remaining members should be built from the state, got: %v", testCluster.members)
+	}
+
+	if r := testCluster.membersToRemove(); !reflect.DeepEqual(r, []string{"foo"}) {
+		t.Fatalf("Destroyed member should be removed, got: %v", r)
+	}
+
+	if r := testCluster.membersToAdd(); len(r) != 0 {
+		t.Fatalf("No members should be added, got: %v", r)
+	}
+}
+
+func TestDestroyMembersValidate(t *testing.T) {
+	t.Parallel()
+
+	c := &Cluster{
+		Members: map[string]MemberConfig{
+			"bar": {},
+		},
+		State: container.ContainersState{
+			"foo": testStateMember(t, "foo", "10.0.0.1"),
+		},
+		DestroyMembers: []string{"baz"},
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validating cluster with unknown member to destroy and members defined should fail")
+	}
+}
@@ -4,22 +4,54 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"net"
+	"net/url"
 	"strings"
+	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
+const (
+	// caCertificatePath is a path on the host, where etcd CA certificate is stored.
+	caCertificatePath = "/etc/kubernetes/etcd/ca.crt"
+
+	// peerCertificatePath is a path on the host, where member peer certificate is stored.
+	peerCertificatePath = "/etc/kubernetes/etcd/peer.crt"
+
+	// peerKeyPath is a path on the host, where member peer private key is stored.
+	peerKeyPath = "/etc/kubernetes/etcd/peer.key"
+
+	// serverCertificatePath is a path on the host, where member server certificate is stored.
+	serverCertificatePath = "/etc/kubernetes/etcd/server.crt"
+
+	// serverKeyPath is a path on the host, where member server private key is stored.
+	serverKeyPath = "/etc/kubernetes/etcd/server.key"
+
+	// readinessPollInterval defines how often member health is checked while waiting for it
+	// to become ready.
+	readinessPollInterval = 2 * time.Second
+
+	// readinessTimeout defines how long we wait for member to become ready.
+	readinessTimeout = 5 * time.Minute
+
+	// clientPort is a port, where member listens for client connections.
+	clientPort = 2379
+
+	// peerPort is a port, where member listens for connections from other members.
+	peerPort = 2380
+)
+
 // MemberConfig represents single etcd member.
 type MemberConfig struct {
 	// Name defines the name of the etcd member. It is used for --name flag.
@@ -127,6 +159,11 @@ type MemberConfig struct {
 	// ExtraMounts defines extra mounts from host filesystem, which should be added to kubelet
 	// containers. It will be used unless kubelet instance define it's own extra mounts.
 	ExtraMounts []containertypes.Mount `json:"extraMounts,omitempty"`
+
+	// Runtime selects container runtime, which will be used to run member container.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
 }
 
 // Member represents functionality provided by validated MemberConfig.
@@ -135,8 +172,9 @@ type Member interface {
 
 	peerAddress() string
 	add(cli etcdClient) error
-	forwardEndpoints(endpoints []string) ([]string, error)
+	forwardEndpoints(endpoints []string) ([]string, transport.Connected, error)
 	getEtcdClient(endpoints []string) (etcdClient, error)
+	waitReady() error
 }
 
 // member is a validated, executable version of MemberConfig.
@@ -146,11 +184,11 @@ type member struct {
 
 func (m *member) configFiles() map[string]string {
 	return map[string]string{
-		"/etc/kubernetes/etcd/ca.crt":     m.config.CACertificate,
-		"/etc/kubernetes/etcd/peer.crt":   m.config.PeerCertificate,
-		"/etc/kubernetes/etcd/peer.key":   m.config.PeerKey,
-		"/etc/kubernetes/etcd/server.crt": m.config.ServerCertificate,
-		"/etc/kubernetes/etcd/server.key": m.config.ServerKey,
+		caCertificatePath:     m.config.CACertificate,
+		peerCertificatePath:   m.config.PeerCertificate,
+		peerKeyPath:           m.config.PeerKey,
+		serverCertificatePath: m.config.ServerCertificate,
+		serverKeyPath:         m.config.ServerKey,
 	}
 }
 
@@ -205,10 +243,7 @@ func (m *member) args() []string {
 // ToHostConfiguredContainer takes configured member and converts it to generic HostConfiguredContainer.
 func (m *member) ToHostConfiguredContainer() (*container.HostConfiguredContainer, error) {
 	memberContainer := container.Container{
-		// TODO: This is weird. This sets docker as default runtime config.
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: container.PickRuntimeConfig(m.config.Runtime),
 		Config: containertypes.ContainerConfig{
 			Name:       fmt.Sprintf("etcd-%s", m.config.Name),
 			Image:      m.config.Image,
@@ -249,6 +284,53 @@ func (m *member) ToHostConfiguredContainer() (*container.HostConfiguredContainer
 	}, nil
 }
 
+// memberConfigFromState recreates member configuration from the member container stored in the state,
+// so the cluster can be reached without members configuration.
+func memberConfigFromState(name string, hcc *container.HostConfiguredContainer) *MemberConfig {
+	m := &MemberConfig{
+		Name: name,
+	}
+
+	if hcc == nil {
+		return m
+	}
+
+	m.Host = hcc.Host
+	m.CACertificate = hcc.ConfigFiles[caCertificatePath]
+	m.PeerCertificate = hcc.ConfigFiles[peerCertificatePath]
+	m.PeerKey = hcc.ConfigFiles[peerKeyPath]
+	m.ServerCertificate = hcc.ConfigFiles[serverCertificatePath]
+	m.ServerKey = hcc.ConfigFiles[serverKeyPath]
+
+	for _, arg := range hcc.Container.Config.Args {
+		flag, value, found := strings.Cut(arg, "=")
+		if !found {
+			continue
+		}
+
+		switch flag {
+		case "--name":
+			m.Name = value
+		case "--initial-advertise-peer-urls":
+			m.PeerAddress = urlHostname(value)
+		case "--advertise-client-urls":
+			m.ServerAddress = urlHostname(value)
+		}
+	}
+
+	return m
+}
+
+// urlHostname returns hostname from given URL. If URL can't be parsed, empty string is returned.
+func urlHostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
+}
+
 func (m *member) peerAddress() string {
 	return m.config.PeerAddress
 }
@@ -324,27 +406,89 @@ func (m *member) peerURLs() []string {
 }
 
 // forwardEndpoints opens forwarding connection for each endpoint
-// and then returns new list of endpoints. If forwarding fails, error is returned.
-func (m *member) forwardEndpoints(endpoints []string) ([]string, error) {
+// and then returns new list of endpoints and the connection used for forwarding,
+// which should be closed, when endpoints are no longer needed. If forwarding fails,
+// error is returned.
+func (m *member) forwardEndpoints(endpoints []string) ([]string, transport.Connected, error) {
 	newEndpoints := []string{}
 
 	h, _ := m.config.Host.New() //nolint:errcheck // We check it in Validate().
 
 	connectedHost, err := h.Connect()
+
+	// Transport is only needed for establishing the connection.
+	if closeErr := h.Close(); closeErr != nil {
+		fmt.Printf("Failed closing host transport: %v\n", closeErr)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("opening forwarding connection to host: %w", err)
+		return nil, nil, fmt.Errorf("opening forwarding connection to host: %w", err)
 	}
 
 	for _, endpoint := range endpoints {
 		forwardedEndpoint, err := connectedHost.ForwardTCP(endpoint)
 		if err != nil {
-			return nil, fmt.Errorf("opening forwarding to member: %w", err)
+			if closeErr := connectedHost.Close(); closeErr != nil {
+				fmt.Printf("Failed closing forwarding connection: %v\n", closeErr)
+			}
+
+			return nil, nil, fmt.Errorf("opening forwarding to member: %w", err)
 		}
 
 		newEndpoints = append(newEndpoints, fmt.Sprintf("https://%s", forwardedEndpoint))
 	}
 
-	return newEndpoints, nil
+	return newEndpoints, connectedHost, nil
+}
+
+// forwardedEtcdClient is an etcd client using forwarded endpoints. Closing the client
+// also closes the forwards.
+type forwardedEtcdClient struct {
+	etcdClient
+
+	connected transport.Connected
+}
+
+// newForwardedEtcdClient forwards given endpoints using given member host and creates
+// etcd client using them. It returns created client and forwarded endpoints.
+func newForwardedEtcdClient(m Member, endpoints []string) (etcdClient, []string, error) {
+	forwardedEndpoints, connected, err := m.forwardEndpoints(endpoints)
+	if err != nil {
+		return nil, nil, fmt.Errorf("forwarding endpoints: %w", err)
+	}
+
+	cli, err := m.getEtcdClient(forwardedEndpoints)
+	if err != nil {
+		if closeErr := connected.Close(); closeErr != nil {
+			fmt.Printf("Failed closing forwarding connection: %v\n", closeErr)
+		}
+
+		return nil, nil, fmt.Errorf("getting etcd client: %w", err)
+	}
+
+	return &forwardedEtcdClient{
+		etcdClient: cli,
+		connected:  connected,
+	}, forwardedEndpoints, nil
+}
+
+// Close closes etcd client and forwards used by it.
+func (c *forwardedEtcdClient) Close() error {
+	err := c.etcdClient.Close()
+
+	if closeErr := c.connected.Close(); closeErr != nil {
+		if err == nil {
+			return fmt.Errorf("closing forwarding connection: %w", closeErr)
+		}
+
+		fmt.Printf("Failed closing forwarding connection: %v\n", closeErr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("closing etcd client: %w", err)
+	}
+
+	return nil
 }
 
 // getID returns etcd cluster member ID, based on either member name on the cluster or matching
@@ -379,11 +523,9 @@ func (m *member) getEtcdClient(endpoints []string) (etcdClient, error) {
 	//nolint:errcheck // We check it in Validate().
 	cert, _ := tls.X509KeyPair([]byte(m.config.PeerCertificate), []byte(m.config.PeerKey))
 
-	der, _ := pem.Decode([]byte(m.config.CACertificate))
-	ca, _ := x509.ParseCertificate(der.Bytes) //nolint:errcheck // We check it in Validate().
-
+	// CA certificate may be a bundle with both old and new CA certificates during CA rotation.
 	certPool := x509.NewCertPool()
-	certPool.AddCert(ca)
+	certPool.AppendCertsFromPEM([]byte(m.config.CACertificate))
 
 	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:            endpoints,
@@ -443,3 +585,42 @@ func (m *member) remove(cli etcdClient) error {
 
 	return nil
 }
+
+// waitReady waits until member becomes healthy, as reported by it's client endpoint.
+func (m *member) waitReady() error {
+	cli, endpoints, err := newForwardedEtcdClient(m, []string{net.JoinHostPort(m.config.ServerAddress, "2379")})
+	if err != nil {
+		return fmt.Errorf("creating etcd client for member: %w", err)
+	}
+
+	if err := waitHealthy(cli, endpoints[0], readinessPollInterval, readinessTimeout); err != nil {
+		if closeErr := cli.Close(); closeErr != nil {
+			fmt.Printf("Failed closing etcd client: %v\n", closeErr)
+		}
+
+		return fmt.Errorf("waiting for member to become healthy: %w", err)
+	}
+
+	if err := cli.Close(); err != nil {
+		return fmt.Errorf("closing etcd client: %w", err)
+	}
+
+	return nil
+}
+
+// waitHealthy waits until given endpoint reports, that it knows the cluster leader and
+// has no errors, e.g. alarms raised, using given client.
+func waitHealthy(cli etcdClient, endpoint string, pollInterval, timeout time.Duration) error {
+	//nolint:staticcheck // Will migrate once https://github.com/kubernetes/kubernetes/issues/119533 is resolved.
+	return wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultDialTimeout)
+		defer cancel()
+
+		resp, err := cli.Status(ctx, endpoint)
+		if err != nil {
+			return false, nil //nolint:nilerr // Ignore all errors, member should eventually become healthy.
+		}
+
+		return resp.Leader != 0 && len(resp.Errors) == 0, nil
+	})
+}
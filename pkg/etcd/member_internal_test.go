@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"github.com/flexkube/libflexkube/internal/utiltest"
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/containerd"
+	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
 )
@@ -72,6 +76,35 @@ func TestExistingCluster(t *testing.T) {
 	}
 }
 
+func TestMemberRuntime(t *testing.T) {
+	t.Parallel()
+
+	testMember := &member{
+		config: &MemberConfig{},
+	}
+
+	hcc, err := testMember.ToHostConfiguredContainer()
+	if err != nil {
+		t.Fatalf("Creating host configured container should succeed, got: %v", err)
+	}
+
+	if hcc.Container.Runtime.Docker == nil || hcc.Container.Runtime.Containerd != nil {
+		t.Fatalf("Docker runtime should be used by default, got: %+v", hcc.Container.Runtime)
+	}
+
+	testMember.config.Runtime = &container.RuntimeConfig{
+		Containerd: containerd.DefaultConfig(),
+	}
+
+	if hcc, err = testMember.ToHostConfiguredContainer(); err != nil {
+		t.Fatalf("Creating host configured container should succeed, got: %v", err)
+	}
+
+	if hcc.Container.Runtime.Containerd == nil || hcc.Container.Runtime.Docker != nil {
+		t.Fatalf("Selected runtime should be used, got: %+v", hcc.Container.Runtime)
+	}
+}
+
 // peerURLs() tests.
 func TestPeerURLs(t *testing.T) {
 	t.Parallel()
@@ -102,11 +135,15 @@ func TestForwardEndpoints(t *testing.T) {
 		},
 	}
 
-	fe, err := testMember.forwardEndpoints([]string{"127.0.0.1:2379"})
+	fe, connected, err := testMember.forwardEndpoints([]string{"127.0.0.1:2379"})
 	if err != nil {
 		t.Fatalf("Forwarding should succeed, got: %v", err)
 	}
 
+	if err := connected.Close(); err != nil {
+		t.Fatalf("Closing forwarding connection should succeed, got: %v", err)
+	}
+
 	if l := len(fe); l != testID {
 		t.Fatalf("Should get exactly one forwarded endpoint, got %d", l)
 	}
@@ -124,7 +161,7 @@ func TestForwardEndpointsFail(t *testing.T) {
 		},
 	}
 
-	if _, err := testMember.forwardEndpoints([]string{"127.0.0.1"}); err == nil {
+	if _, _, err := testMember.forwardEndpoints([]string{"127.0.0.1"}); err == nil {
 		t.Fatalf("Forwarding bad address should fail")
 	}
 }
@@ -477,3 +514,137 @@ func TestAddGetIDFail(t *testing.T) {
 		t.Fatalf("Adding member should fail, when getting member id fails")
 	}
 }
+
+// waitHealthy() tests.
+func TestWaitHealthy(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+
+	testClient := &fakeClient{
+		statusF: func(context.Context, string) (*clientv3.StatusResponse, error) {
+			attempts++
+
+			if attempts < 2 {
+				return nil, fmt.Errorf("not ready yet")
+			}
+
+			return &clientv3.StatusResponse{
+				Leader: 1,
+			}, nil
+		},
+	}
+
+	if err := waitHealthy(testClient, "foo", time.Millisecond, time.Second); err != nil {
+		t.Fatalf("Waiting for healthy member should succeed, got: %v", err)
+	}
+}
+
+func TestWaitHealthyTimeout(t *testing.T) {
+	t.Parallel()
+
+	testClient := &fakeClient{
+		statusF: func(context.Context, string) (*clientv3.StatusResponse, error) {
+			return nil, fmt.Errorf("not ready")
+		},
+	}
+
+	if err := waitHealthy(testClient, "foo", time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatalf("Waiting for unhealthy member should time out")
+	}
+}
+
+func TestWaitHealthyNoLeader(t *testing.T) {
+	t.Parallel()
+
+	testClient := &fakeClient{
+		statusF: func(context.Context, string) (*clientv3.StatusResponse, error) {
+			return &clientv3.StatusResponse{}, nil
+		},
+	}
+
+	if err := waitHealthy(testClient, "foo", time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatalf("Waiting for member without leader should time out")
+	}
+}
+
+func TestWaitHealthyErrors(t *testing.T) {
+	t.Parallel()
+
+	testClient := &fakeClient{
+		statusF: func(context.Context, string) (*clientv3.StatusResponse, error) {
+			return &clientv3.StatusResponse{
+				Leader: 1,
+				Errors: []string{"NOSPACE"},
+			}, nil
+		},
+	}
+
+	if err := waitHealthy(testClient, "foo", time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatalf("Waiting for member reporting errors should time out")
+	}
+}
+
+func testStateMember(t *testing.T, name, address string) *container.HostConfiguredContainer {
+	t.Helper()
+
+	cert := utiltest.GenerateX509Certificate(t)
+	privateKey := utiltest.GenerateRSAPrivateKey(t)
+
+	testMember := &member{
+		config: &MemberConfig{
+			Name:              name,
+			Image:             defaults.EtcdImage,
+			PeerAddress:       address,
+			ServerAddress:     address,
+			CACertificate:     cert,
+			PeerCertificate:   cert,
+			PeerKey:           privateKey,
+			ServerCertificate: cert,
+			ServerKey:         privateKey,
+			Host: host.Host{
+				DirectConfig: &direct.Config{},
+			},
+		},
+	}
+
+	hcc, err := testMember.ToHostConfiguredContainer()
+	if err != nil {
+		t.Fatalf("Creating host configured container should succeed, got: %v", err)
+	}
+
+	return hcc
+}
+
+// memberConfigFromState() tests.
+func TestMemberConfigFromState(t *testing.T) {
+	t.Parallel()
+
+	hcc := testStateMember(t, "foo", "10.0.0.1")
+
+	m := memberConfigFromState("bar", hcc)
+
+	if m.Name != "foo" {
+		t.Errorf("Member name should be taken from container arguments, got %q", m.Name)
+	}
+
+	if m.PeerAddress != "10.0.0.1" || m.ServerAddress != "10.0.0.1" {
+		t.Errorf("Member addresses should be taken from container arguments, got %q and %q", m.PeerAddress, m.ServerAddress)
+	}
+
+	if m.PeerKey != hcc.ConfigFiles[peerKeyPath] {
+		t.Errorf("Member peer key should be taken from configuration files")
+	}
+
+	if _, err := m.New(); err != nil {
+		t.Fatalf("Member configuration built from state should be valid, got: %v", err)
+	}
+}
+
+func TestMemberConfigFromStateNoContainer(t *testing.T) {
+	t.Parallel()
+
+	if m := memberConfigFromState("foo", nil); m.Name != "foo" {
+		t.Fatalf("Member name should be set from given name, got %q", m.Name)
+	}
+}
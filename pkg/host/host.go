@@ -4,6 +4,8 @@ package host
 
 import (
 	"fmt"
+	"io"
+	"sync"
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/host/transport"
@@ -25,11 +27,19 @@ type Host struct {
 }
 
 type host struct {
+	config    *Host
 	transport transport.Interface
 }
 
 type hostConnected struct {
-	transport transport.Connected
+	connection *pooledConnection
+
+	// forwards holds number of references to forwards acquired using this connection,
+	// indexed by local address, so they can be released on Close().
+	forwards map[string]int
+
+	// mutex protects forwards map.
+	mutex sync.Mutex
 }
 
 // New validates Host configuration and sets configured transport method.
@@ -50,6 +60,7 @@ func (h *Host) New() (transport.Interface, error) {
 	}
 
 	return &host{
+		config:    h,
 		transport: configuredTransport,
 	}, nil
 }
@@ -79,30 +90,114 @@ func (h *Host) Validate() error {
 	return errors.Return()
 }
 
-// selectTransport returns transport protocol configured for container.
+// Connect connects to the host using configured transport method.
 //
-// It returns error if transport protocol configuration is invalid.
+// Connections are shared by all hosts with the same configuration, so if the connection
+// to the host is already established, it is reused.
 func (h *host) Connect() (transport.Connected, error) {
-	connected, err := h.transport.Connect()
+	connection, err := connections.connect(h.config, h.transport)
 	if err != nil {
-		return nil, fmt.Errorf("connecting: %w", err)
+		return nil, err
 	}
 
 	return &hostConnected{
-		transport: connected,
+		connection: connection,
+		forwards:   map[string]int{},
 	}, nil
 }
 
+// Close closes configured transport method. Established connections remain open, so they can
+// be reused. Use CloseConnections to close them.
+func (h *host) Close() error {
+	if err := h.transport.Close(); err != nil {
+		return fmt.Errorf("closing transport: %w", err)
+	}
+
+	return nil
+}
+
 // ForwardUnixSocket forwards given unix socket path using configured transport method and returns
-// local unix socket address.
+// local unix socket address. If given path is already forwarded, existing forward is reused.
 func (h *hostConnected) ForwardUnixSocket(path string) (string, error) {
-	return h.transport.ForwardUnixSocket(path)
+	return h.forward(path, func() (string, error) {
+		return h.connection.connected.ForwardUnixSocket(path)
+	})
 }
 
 // ForwardTCP forwards given TCP address using configured transport method and returns local
-// address with port.
+// address with port. If given address is already forwarded, existing forward is reused.
 func (h *hostConnected) ForwardTCP(address string) (string, error) {
-	return h.transport.ForwardTCP(address)
+	return h.forward(address, func() (string, error) {
+		return h.connection.connected.ForwardTCP(address)
+	})
+}
+
+// forward acquires forward of given remote address from the shared connection.
+func (h *hostConnected) forward(remoteAddress string, f func() (string, error)) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	localAddress, err := h.connection.forward(remoteAddress, f)
+	if err != nil {
+		return "", err
+	}
+
+	h.forwards[localAddress]++
+
+	return localAddress, nil
+}
+
+// CloseForward releases the forward with given local address. Forward is closed, once it is
+// released by all its users.
+func (h *hostConnected) CloseForward(localAddress string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.forwards[localAddress] == 0 {
+		return fmt.Errorf("address %q is not forwarded", localAddress)
+	}
+
+	h.forwards[localAddress]--
+
+	if h.forwards[localAddress] == 0 {
+		delete(h.forwards, localAddress)
+	}
+
+	return h.connection.release(localAddress)
+}
+
+// Exec runs given command on the host using the shared connection.
+func (h *hostConnected) Exec(command string, stdin io.Reader) ([]byte, []byte, int, error) {
+	return h.connection.connected.Exec(command, stdin)
+}
+
+// Close releases all forwards acquired using this connection. The connection itself remains
+// open, so it can be reused. Use CloseConnections to close it.
+func (h *hostConnected) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var errors util.ValidateErrors
+
+	for localAddress, references := range h.forwards {
+		for i := 0; i < references; i++ {
+			if err := h.connection.release(localAddress); err != nil {
+				errors = append(errors, fmt.Errorf("releasing forward %q: %w", localAddress, err))
+			}
+		}
+	}
+
+	h.forwards = map[string]int{}
+
+	return errors.Return()
+}
+
+// InheritHostKeyFingerprint copies SSH host key fingerprint recorded on first use from given previous
+// configuration of the same host. See ssh.Config.InheritHostKeyFingerprint for more details.
+func (h *Host) InheritHostKeyFingerprint(previous Host) {
+	if h.SSHConfig != nil {
+		h.SSHConfig.InheritHostKeyFingerprint(previous.SSHConfig)
+	}
 }
 
 // BuildConfig merges values from both host objects. This is a helper method used for building hierarchical
@@ -147,6 +147,39 @@ func TestForwardTCP(t *testing.T) {
 	}
 }
 
+// InheritHostKeyFingerprint() tests.
+func TestInheritHostKeyFingerprint(t *testing.T) {
+	t.Parallel()
+
+	h := &Host{
+		SSHConfig: &ssh.Config{
+			Address:         "localhost",
+			TrustOnFirstUse: true,
+		},
+	}
+
+	h.InheritHostKeyFingerprint(Host{
+		SSHConfig: &ssh.Config{
+			Address:            "localhost",
+			HostKeyFingerprint: "SHA256:foo",
+		},
+	})
+
+	if h.SSHConfig.HostKeyFingerprint != "SHA256:foo" {
+		t.Fatalf("Host key fingerprint should be inherited, got %q", h.SSHConfig.HostKeyFingerprint)
+	}
+}
+
+func TestInheritHostKeyFingerprintDirect(t *testing.T) {
+	t.Parallel()
+
+	h := &Host{
+		DirectConfig: &direct.Config{},
+	}
+
+	h.InheritHostKeyFingerprint(Host{})
+}
+
 // BuildConfig() tests.
 func TestBuildConfigDirectByDefault(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,288 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+// connections is a connection pool shared by all hosts, so all operations on the same host
+// reuse the same connection.
+//
+//nolint:gochecknoglobals // Connections are shared by all hosts.
+var connections = newConnectionPool()
+
+// connectionPool caches established connections, indexed by resolved transport configuration.
+type connectionPool struct {
+	// connections holds established connections.
+	connections map[string]*pooledConnection
+
+	// locks holds mutexes for each connection key, so connecting to the same host happens only
+	// once, while still allowing to connect to different hosts in parallel.
+	locks map[string]*sync.Mutex
+
+	// mutex protects maps above.
+	mutex sync.Mutex
+}
+
+// pooledConnection is a connection shared by multiple hosts, with reference counted forwards,
+// so the same address is only forwarded once.
+type pooledConnection struct {
+	// config is a configuration of the host, which established the connection.
+	config *Host
+
+	// connected is established connection.
+	connected transport.Connected
+
+	// forwards holds active forwards, indexed by forwarded remote address.
+	forwards map[string]*forward
+
+	// closed is set, when the connection is closed using CloseConnections.
+	closed bool
+
+	// mutex protects forwards map and closed flag.
+	mutex sync.Mutex
+}
+
+// forward represents forwarded address shared by multiple users.
+type forward struct {
+	// localAddress is an address, where remote address is available.
+	localAddress string
+
+	// references is a number of users of the forward.
+	references int
+}
+
+func newConnectionPool() *connectionPool {
+	return &connectionPool{
+		connections: map[string]*pooledConnection{},
+		locks:       map[string]*sync.Mutex{},
+	}
+}
+
+// CloseConnections closes all connections established by hosts, including all their forwards.
+// It should be called, when no more operations on hosts are expected, e.g. by long-running processes
+// after finishing the deployment. Closed connections are established again, when needed.
+func CloseConnections() error {
+	return connections.close()
+}
+
+// connectionKey returns key identifying connections to the host with given configuration.
+//
+// Host key fingerprints recorded on first use are not included, as they are filled in while
+// connecting, so configurations with and without them share the same connection.
+func connectionKey(config *Host) string {
+	c := *config
+
+	if c.SSHConfig != nil {
+		sshConfig := withoutRecordedFingerprints(*c.SSHConfig)
+		c.SSHConfig = &sshConfig
+	}
+
+	// Host configuration always consists of serializable types.
+	key, _ := json.Marshal(c) //nolint:errchkjson // Serialization can't fail.
+
+	return string(key)
+}
+
+// withoutRecordedFingerprints returns copy of given SSH configuration without host key
+// fingerprints recorded on first use, including jump hosts.
+func withoutRecordedFingerprints(config ssh.Config) ssh.Config {
+	if config.TrustOnFirstUse {
+		config.HostKeyFingerprint = ""
+	}
+
+	jumpHosts := []ssh.Config{}
+
+	for _, jumpHost := range config.JumpHosts {
+		jumpHosts = append(jumpHosts, withoutRecordedFingerprints(jumpHost))
+	}
+
+	config.JumpHosts = jumpHosts
+
+	return config
+}
+
+// lock locks the mutex for given connection key and returns function unlocking it.
+func (p *connectionPool) lock(key string) func() {
+	p.mutex.Lock()
+
+	keyLock, ok := p.locks[key]
+	if !ok {
+		keyLock = &sync.Mutex{}
+		p.locks[key] = keyLock
+	}
+
+	p.mutex.Unlock()
+
+	keyLock.Lock()
+
+	return keyLock.Unlock
+}
+
+// connect returns established connection for given host configuration. If there is no connection
+// cached or cached connection is no longer alive, new connection is established using given transport.
+func (p *connectionPool) connect(config *Host, t transport.Interface) (*pooledConnection, error) {
+	key := connectionKey(config)
+
+	unlock := p.lock(key)
+	defer unlock()
+
+	p.mutex.Lock()
+	pooled, ok := p.connections[key]
+	p.mutex.Unlock()
+
+	if ok && pooled.alive() {
+		// Make sure host key fingerprints recorded while establishing the connection end up
+		// in the configuration of all hosts using it.
+		config.InheritHostKeyFingerprint(*pooled.config)
+
+		return pooled, nil
+	}
+
+	// Connection is broken, e.g. because the host has been rebooted, so establish it again.
+	if ok {
+		if err := pooled.close(); err != nil {
+			fmt.Printf("Failed closing broken connection: %v\n", err)
+		}
+	}
+
+	connected, err := t.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	pooled = &pooledConnection{
+		config:    config,
+		connected: connected,
+		forwards:  map[string]*forward{},
+	}
+
+	p.mutex.Lock()
+	p.connections[key] = pooled
+	p.mutex.Unlock()
+
+	return pooled, nil
+}
+
+// alive checks if the connection can still be used. Connections, which can't be checked,
+// are assumed to be alive.
+func (c *pooledConnection) alive() bool {
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+
+	if closed {
+		return false
+	}
+
+	pinger, ok := c.connected.(transport.Pinger)
+	if !ok {
+		return true
+	}
+
+	return pinger.Ping() == nil
+}
+
+// forward returns local address of forwarded remote address. If the address is not forwarded
+// yet, it gets forwarded using given function.
+func (c *pooledConnection) forward(remoteAddress string, f func() (string, error)) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return "", fmt.Errorf("connection is closed")
+	}
+
+	if existing, ok := c.forwards[remoteAddress]; ok {
+		existing.references++
+
+		return existing.localAddress, nil
+	}
+
+	localAddress, err := f()
+	if err != nil {
+		return "", err
+	}
+
+	c.forwards[remoteAddress] = &forward{
+		localAddress: localAddress,
+		references:   1,
+	}
+
+	return localAddress, nil
+}
+
+// release releases the forward with given local address. When forward is no longer used, it
+// gets closed.
+func (c *pooledConnection) release(localAddress string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	for remoteAddress, f := range c.forwards {
+		if f.localAddress != localAddress {
+			continue
+		}
+
+		f.references--
+
+		if f.references > 0 {
+			return nil
+		}
+
+		delete(c.forwards, remoteAddress)
+
+		if err := c.connected.CloseForward(localAddress); err != nil {
+			return fmt.Errorf("closing forward: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("address %q is not forwarded", localAddress)
+}
+
+// close closes all cached connections and removes them from the pool.
+func (p *connectionPool) close() error {
+	p.mutex.Lock()
+	pooled := p.connections
+	p.connections = map[string]*pooledConnection{}
+	p.mutex.Unlock()
+
+	var errors util.ValidateErrors
+
+	for _, c := range pooled {
+		if err := c.close(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	return errors.Return()
+}
+
+// close closes the connection with all its forwards.
+func (c *pooledConnection) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	c.forwards = map[string]*forward{}
+
+	if err := c.connected.Close(); err != nil {
+		return fmt.Errorf("closing connection: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package host
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+// testTransport is a transport.Interface implementation counting established
+// connections and forwards.
+type testTransport struct {
+	connects       int
+	forwards       int
+	closedForwards []string
+	closes         int
+	pingErr        error
+}
+
+func (t *testTransport) Connect() (transport.Connected, error) {
+	t.connects++
+
+	return t, nil
+}
+
+func (t *testTransport) ForwardUnixSocket(path string) (string, error) {
+	t.forwards++
+
+	return fmt.Sprintf("%s-%d", path, t.forwards), nil
+}
+
+func (t *testTransport) ForwardTCP(address string) (string, error) {
+	t.forwards++
+
+	return fmt.Sprintf("%s-%d", address, t.forwards), nil
+}
+
+func (t *testTransport) CloseForward(localAddress string) error {
+	t.closedForwards = append(t.closedForwards, localAddress)
+
+	return nil
+}
+
+func (t *testTransport) Exec(command string, _ io.Reader) ([]byte, []byte, int, error) {
+	return []byte(command), nil, 0, nil
+}
+
+func (t *testTransport) Ping() error {
+	return t.pingErr
+}
+
+func (t *testTransport) Close() error {
+	t.closes++
+
+	return nil
+}
+
+func testSSHHost() *Host {
+	return &Host{
+		SSHConfig: &ssh.Config{
+			Address:         "localhost",
+			Port:            ssh.Port,
+			TrustOnFirstUse: true,
+		},
+	}
+}
+
+func TestConnectionPoolReusesConnection(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	for i := 0; i < 3; i++ {
+		if _, err := pool.connect(testSSHHost(), testTransport); err != nil {
+			t.Fatalf("Connecting should succeed, got: %v", err)
+		}
+	}
+
+	if testTransport.connects != 1 {
+		t.Fatalf("Connection should be established once, got %d connections", testTransport.connects)
+	}
+}
+
+func TestConnectionPoolReconnectsBrokenConnection(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	first, err := pool.connect(testSSHHost(), testTransport)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	testTransport.pingErr = fmt.Errorf("connection lost")
+
+	second, err := pool.connect(testSSHHost(), testTransport)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	if testTransport.connects != 2 {
+		t.Fatalf("Broken connection should be established again, got %d connections", testTransport.connects)
+	}
+
+	if first == second {
+		t.Fatalf("Broken connection should not be handed out")
+	}
+
+	if testTransport.closes != 1 {
+		t.Fatalf("Broken connection should be closed, got %d closes", testTransport.closes)
+	}
+
+	if _, err := first.forward("unix:///run/docker.sock", func() (string, error) { return "", nil }); err == nil {
+		t.Fatalf("Forwarding over replaced connection should fail")
+	}
+}
+
+func TestConnectionPoolDifferentHosts(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	otherHost := testSSHHost()
+	otherHost.SSHConfig.Address = "127.0.0.1"
+
+	for _, h := range []*Host{testSSHHost(), otherHost} {
+		if _, err := pool.connect(h, testTransport); err != nil {
+			t.Fatalf("Connecting should succeed, got: %v", err)
+		}
+	}
+
+	if testTransport.connects != 2 {
+		t.Fatalf("Connection should be established for each host, got %d connections", testTransport.connects)
+	}
+}
+
+func TestConnectionPoolInheritsHostKeyFingerprint(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	first := testSSHHost()
+
+	if _, err := pool.connect(first, testTransport); err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	// Simulate recording host key fingerprint while connecting.
+	first.SSHConfig.HostKeyFingerprint = "SHA256:foo"
+
+	second := testSSHHost()
+
+	if _, err := pool.connect(second, testTransport); err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	if testTransport.connects != 1 {
+		t.Fatalf("Connection should be reused, got %d connections", testTransport.connects)
+	}
+
+	if fingerprint := second.SSHConfig.HostKeyFingerprint; fingerprint != "SHA256:foo" {
+		t.Fatalf("Host key fingerprint should be inherited from pooled connection, got %q", fingerprint)
+	}
+}
+
+func TestConnectionKeyPinnedFingerprint(t *testing.T) {
+	t.Parallel()
+
+	first := testSSHHost()
+	first.SSHConfig.TrustOnFirstUse = false
+	first.SSHConfig.HostKeyFingerprint = "SHA256:foo"
+
+	second := testSSHHost()
+	second.SSHConfig.TrustOnFirstUse = false
+	second.SSHConfig.HostKeyFingerprint = "SHA256:bar"
+
+	if connectionKey(first) == connectionKey(second) {
+		t.Fatalf("Hosts with different pinned host key fingerprints should not share connection")
+	}
+}
+
+func TestPooledConnectionForwardReferences(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	pooled, err := pool.connect(testSSHHost(), testTransport)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	hc := &hostConnected{
+		connection: pooled,
+		forwards:   map[string]int{},
+	}
+
+	first, err := hc.ForwardUnixSocket("unix:///run/docker.sock")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	second, err := hc.ForwardUnixSocket("unix:///run/docker.sock")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	if first != second || testTransport.forwards != 1 {
+		t.Fatalf("Forward should be reused, got %q and %q with %d forwards", first, second, testTransport.forwards)
+	}
+
+	if err := hc.CloseForward(first); err != nil {
+		t.Fatalf("Releasing forward should succeed, got: %v", err)
+	}
+
+	if len(testTransport.closedForwards) != 0 {
+		t.Fatalf("Forward should not be closed while still in use")
+	}
+
+	if err := hc.CloseForward(second); err != nil {
+		t.Fatalf("Releasing forward should succeed, got: %v", err)
+	}
+
+	if len(testTransport.closedForwards) != 1 {
+		t.Fatalf("Forward should be closed, when no longer in use")
+	}
+
+	if err := hc.CloseForward(second); err == nil {
+		t.Fatalf("Releasing closed forward should fail")
+	}
+}
+
+func TestHostConnectedClose(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	pooled, err := pool.connect(testSSHHost(), testTransport)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	first := &hostConnected{
+		connection: pooled,
+		forwards:   map[string]int{},
+	}
+
+	second := &hostConnected{
+		connection: pooled,
+		forwards:   map[string]int{},
+	}
+
+	for _, hc := range []*hostConnected{first, first, second} {
+		if _, err := hc.ForwardTCP("localhost:2379"); err != nil {
+			t.Fatalf("Forwarding should succeed, got: %v", err)
+		}
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Closing connection should succeed, got: %v", err)
+	}
+
+	if len(testTransport.closedForwards) != 0 {
+		t.Fatalf("Forward should not be closed while still in use by other connection")
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("Closing connection should succeed, got: %v", err)
+	}
+
+	if len(testTransport.closedForwards) != 1 {
+		t.Fatalf("Forward should be closed, when all connections using it are closed")
+	}
+
+	if testTransport.closes != 0 {
+		t.Fatalf("Pooled connection should remain open for reuse")
+	}
+}
+
+func TestConnectionPoolClose(t *testing.T) {
+	t.Parallel()
+
+	pool := newConnectionPool()
+	testTransport := &testTransport{}
+
+	pooled, err := pool.connect(testSSHHost(), testTransport)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	if err := pool.close(); err != nil {
+		t.Fatalf("Closing pool should succeed, got: %v", err)
+	}
+
+	if testTransport.closes != 1 {
+		t.Fatalf("Pooled connection should be closed, got %d closes", testTransport.closes)
+	}
+
+	if _, err := pooled.forward("localhost:2379", func() (string, error) { return "", nil }); err == nil {
+		t.Fatalf("Forwarding using closed connection should fail")
+	}
+
+	if _, err := pool.connect(testSSHHost(), testTransport); err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	if testTransport.connects != 2 {
+		t.Fatalf("Connection should be established again after closing the pool, got %d connections", testTransport.connects)
+	}
+}
@@ -3,8 +3,12 @@
 package direct
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os/exec"
 
 	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
@@ -55,3 +59,42 @@ func (d *direct) ForwardTCP(address string) (string, error) {
 
 	return address, nil
 }
+
+// Close implements transport.Interface and transport.Connected interfaces.
+//
+// Given that direct does not hold any resources, it does nothing.
+func (d *direct) Close() error {
+	return nil
+}
+
+// CloseForward implements transport.Connected interface.
+//
+// Given that direct does not forward anything, it does nothing.
+func (d *direct) CloseForward(_ string) error {
+	return nil
+}
+
+// Exec implements transport.Connected interface.
+//
+// Given that direct operates on local machine, command is executed locally using 'sh'.
+func (d *direct) Exec(command string, stdin io.Reader) ([]byte, []byte, int, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+
+	switch {
+	case errors.As(err, &exitErr):
+		return stdout.Bytes(), stderr.Bytes(), exitErr.ExitCode(), nil
+	case err != nil:
+		return nil, nil, 0, fmt.Errorf("running command: %w", err)
+	}
+
+	return stdout.Bytes(), stderr.Bytes(), 0, nil
+}
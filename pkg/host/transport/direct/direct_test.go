@@ -1,6 +1,7 @@
 package direct_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/flexkube/libflexkube/pkg/host/transport"
@@ -98,3 +99,36 @@ func TestForwardTCPBadAddress(t *testing.T) {
 		t.Fatalf("TCP forwarding should fail when forwarding bad address")
 	}
 }
+
+func TestCloseForward(t *testing.T) {
+	t.Parallel()
+
+	d := newDirect(t)
+
+	directConnected, err := d.Connect()
+	if err != nil {
+		t.Fatalf("Connecting: %v", err)
+	}
+
+	if err := directConnected.CloseForward("localhost:80"); err != nil {
+		t.Fatalf("Closing forward should always succeed, got: %v", err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	dc, err := newDirect(t).Connect()
+	if err != nil {
+		t.Fatalf("Connecting: %v", err)
+	}
+
+	stdout, stderr, exitCode, err := dc.Exec("cat; echo bar >&2; exit 3", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatalf("Running command should succeed, got: %v", err)
+	}
+
+	if string(stdout) != "foo" || string(stderr) != "bar\n" || exitCode != 3 {
+		t.Fatalf("Unexpected result, stdout: %q, stderr: %q, exit code: %d", stdout, stderr, exitCode)
+	}
+}
@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// signer returns signer for configured private key. Encrypted private key is decrypted using
+// configured passphrase. If certificate is configured, it is used together with the private key.
+func (d *Config) signer() (gossh.Signer, error) {
+	signer, err := gossh.ParsePrivateKey([]byte(d.PrivateKey))
+
+	var passphraseMissingErr *gossh.PassphraseMissingError
+
+	if errors.As(err, &passphraseMissingErr) {
+		signer, err = d.decryptPrivateKey()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	if d.Certificate == "" {
+		return signer, nil
+	}
+
+	cert, err := parseUserCertificate(d.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	certSigner, err := gossh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("using certificate with private key: %w", err)
+	}
+
+	return certSigner, nil
+}
+
+// decryptPrivateKey parses encrypted private key using passphrase from the configuration or
+// from the environment variable.
+func (d *Config) decryptPrivateKey() (gossh.Signer, error) {
+	passphrase := d.PrivateKeyPassphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(PrivateKeyPassphraseEnv)
+	}
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("private key is encrypted, but no passphrase is configured, "+
+			"set 'privateKeyPassphrase' field or %s environment variable", PrivateKeyPassphraseEnv)
+	}
+
+	signer, err := gossh.ParsePrivateKeyWithPassphrase([]byte(d.PrivateKey), []byte(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting private key: %w", err)
+	}
+
+	return signer, nil
+}
+
+// parseUserCertificate parses OpenSSH user certificate in authorized_keys format.
+func parseUserCertificate(certificate string) (*gossh.Certificate, error) {
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	cert, ok := publicKey.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("expected certificate, got %s public key", publicKey.Type())
+	}
+
+	if cert.CertType != gossh.UserCert {
+		return nil, fmt.Errorf("expected user certificate, got host certificate")
+	}
+
+	return cert, nil
+}
+
+// keyboardInteractive returns keyboard-interactive challenge handler, which answers all prompts
+// with hidden input using given password. This allows password authentication with servers,
+// which only allow keyboard-interactive authentication.
+func keyboardInteractive(password string) gossh.KeyboardInteractiveChallenge {
+	return func(_, _ string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+
+		for i := range questions {
+			if !echos[i] {
+				answers[i] = password
+			}
+		}
+
+		return answers, nil
+	}
+}
@@ -0,0 +1,214 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	testPassphrase = "secret"
+)
+
+func generateEncryptedPrivateKey(t *testing.T) (string, gossh.Signer) {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating key: %v", err)
+	}
+
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(privateKey, "", []byte(testPassphrase))
+	if err != nil {
+		t.Fatalf("Encrypting private key: %v", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("Creating signer: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(block)), signer
+}
+
+func signUserCertificate(t *testing.T, key gossh.PublicKey, certType uint32) string {
+	t.Helper()
+
+	cert := &gossh.Certificate{
+		Key:             key,
+		CertType:        certType,
+		ValidPrincipals: []string{"root"},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+
+	if err := cert.SignCert(rand.Reader, generateHostKey(t)); err != nil {
+		t.Fatalf("Signing certificate: %v", err)
+	}
+
+	return authorizedKey(cert)
+}
+
+func TestSignerEncryptedPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	privateKey, expectedSigner := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: testPassphrase,
+	}
+
+	signer, err := c.signer()
+	if err != nil {
+		t.Fatalf("Decrypting private key should succeed, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(signer.PublicKey().Marshal(), expectedSigner.PublicKey().Marshal()) {
+		t.Fatalf("Decrypted private key should match original key")
+	}
+}
+
+//nolint:paralleltest // This test modifies PrivateKeyPassphraseEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestSignerEncryptedPrivateKeyPassphraseFromEnvironment(t *testing.T) {
+	t.Setenv(PrivateKeyPassphraseEnv, testPassphrase)
+
+	privateKey, _ := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey: privateKey,
+	}
+
+	if _, err := c.signer(); err != nil {
+		t.Fatalf("Decrypting private key with passphrase from environment should succeed, got: %v", err)
+	}
+}
+
+//nolint:paralleltest // This test modifies PrivateKeyPassphraseEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestSignerEncryptedPrivateKeyNoPassphrase(t *testing.T) {
+	t.Setenv(PrivateKeyPassphraseEnv, "")
+
+	privateKey, _ := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey: privateKey,
+	}
+
+	if _, err := c.signer(); err == nil {
+		t.Fatalf("Using encrypted private key without passphrase should fail")
+	}
+}
+
+func TestSignerEncryptedPrivateKeyBadPassphrase(t *testing.T) {
+	t.Parallel()
+
+	privateKey, _ := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: "bad",
+	}
+
+	if _, err := c.signer(); err == nil {
+		t.Fatalf("Decrypting private key with bad passphrase should fail")
+	}
+}
+
+func TestSignerCertificate(t *testing.T) {
+	t.Parallel()
+
+	privateKey, signer := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: testPassphrase,
+		Certificate:          signUserCertificate(t, signer.PublicKey(), gossh.UserCert),
+	}
+
+	certSigner, err := c.signer()
+	if err != nil {
+		t.Fatalf("Using certificate should succeed, got: %v", err)
+	}
+
+	if _, ok := certSigner.PublicKey().(*gossh.Certificate); !ok {
+		t.Fatalf("Signer should use certificate as public key, got %s", certSigner.PublicKey().Type())
+	}
+}
+
+func TestSignerHostCertificate(t *testing.T) {
+	t.Parallel()
+
+	privateKey, signer := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: testPassphrase,
+		Certificate:          signUserCertificate(t, signer.PublicKey(), gossh.HostCert),
+	}
+
+	if _, err := c.signer(); err == nil {
+		t.Fatalf("Using host certificate for authentication should fail")
+	}
+}
+
+func TestSignerCertificateOtherKey(t *testing.T) {
+	t.Parallel()
+
+	privateKey, _ := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: testPassphrase,
+		Certificate:          signUserCertificate(t, generateHostKey(t).PublicKey(), gossh.UserCert),
+	}
+
+	if _, err := c.signer(); err == nil {
+		t.Fatalf("Using certificate issued for other key should fail")
+	}
+}
+
+func TestSignerCertificateNotCertificate(t *testing.T) {
+	t.Parallel()
+
+	privateKey, signer := generateEncryptedPrivateKey(t)
+
+	c := &Config{
+		PrivateKey:           privateKey,
+		PrivateKeyPassphrase: testPassphrase,
+		Certificate:          authorizedKey(signer.PublicKey()),
+	}
+
+	if _, err := c.signer(); err == nil {
+		t.Fatalf("Using plain public key as certificate should fail")
+	}
+}
+
+func TestValidateCertificateWithoutPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	c := newTestConfig(t)
+	c.PrivateKey = ""
+	c.Certificate = signUserCertificate(t, generateHostKey(t).PublicKey(), gossh.UserCert)
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Certificate without private key should be rejected")
+	}
+}
+
+func TestKeyboardInteractive(t *testing.T) {
+	t.Parallel()
+
+	answers, err := keyboardInteractive("foo")("", "", []string{"Login:", "Password:"}, []bool{true, false})
+	if err != nil {
+		t.Fatalf("Answering challenge should succeed, got: %v", err)
+	}
+
+	if expected := []string{"", "foo"}; !reflect.DeepEqual(answers, expected) {
+		t.Fatalf("Expected answers %v, got %v", expected, answers)
+	}
+}
@@ -32,7 +32,12 @@ func BuildConfig(sshConfig, defaults *Config) *Config {
 		defaults = &Config{}
 	}
 
-	sshConfig.PrivateKey = util.PickString(sshConfig.PrivateKey, defaults.PrivateKey)
+	// Passphrase and certificate belong to the private key, so only inherit them together with it.
+	if sshConfig.PrivateKey == "" {
+		sshConfig.PrivateKey = defaults.PrivateKey
+		sshConfig.PrivateKeyPassphrase = util.PickString(sshConfig.PrivateKeyPassphrase, defaults.PrivateKeyPassphrase)
+		sshConfig.Certificate = util.PickString(sshConfig.Certificate, defaults.Certificate)
+	}
 
 	sshConfig.User = util.PickString(sshConfig.User, defaults.User, User)
 
@@ -52,5 +57,19 @@ func BuildConfig(sshConfig, defaults *Config) *Config {
 
 	sshConfig.Password = util.PickString(sshConfig.Password, defaults.Password)
 
+	if len(sshConfig.HostKeys) == 0 {
+		sshConfig.HostKeys = defaults.HostKeys
+	}
+
+	if len(sshConfig.HostCAKeys) == 0 {
+		sshConfig.HostCAKeys = defaults.HostCAKeys
+	}
+
+	sshConfig.KnownHostsFile = util.PickString(sshConfig.KnownHostsFile, defaults.KnownHostsFile)
+
+	sshConfig.TrustOnFirstUse = sshConfig.TrustOnFirstUse || defaults.TrustOnFirstUse
+
+	sshConfig.JumpHosts = buildJumpHosts(sshConfig, defaults)
+
 	return sshConfig
 }
@@ -79,6 +79,42 @@ func TestBuildConfig(t *testing.T) { //nolint:funlen,maintidx // Just many test
 				RetryInterval:     ssh.RetryInterval,
 			},
 		},
+		{
+			&ssh.Config{
+				PrivateKey: "foo",
+			},
+			&ssh.Config{
+				PrivateKey:           "bar",
+				PrivateKeyPassphrase: "baz",
+				Certificate:          "doh",
+			},
+			&ssh.Config{
+				PrivateKey:        "foo",
+				Port:              ssh.Port,
+				User:              ssh.User,
+				ConnectionTimeout: ssh.ConnectionTimeout,
+				RetryTimeout:      ssh.RetryTimeout,
+				RetryInterval:     ssh.RetryInterval,
+			},
+		},
+		{
+			nil,
+			&ssh.Config{
+				PrivateKey:           "bar",
+				PrivateKeyPassphrase: "baz",
+				Certificate:          "doh",
+			},
+			&ssh.Config{
+				PrivateKey:           "bar",
+				PrivateKeyPassphrase: "baz",
+				Certificate:          "doh",
+				Port:                 ssh.Port,
+				User:                 ssh.User,
+				ConnectionTimeout:    ssh.ConnectionTimeout,
+				RetryTimeout:         ssh.RetryTimeout,
+				RetryInterval:        ssh.RetryInterval,
+			},
+		},
 
 		// User
 		{
@@ -386,6 +422,30 @@ func TestBuildConfig(t *testing.T) { //nolint:funlen,maintidx // Just many test
 				Password:          "foo",
 			},
 		},
+
+		// Host key verification settings.
+		{
+			&ssh.Config{
+				HostKeys: []string{"foo"},
+			},
+			&ssh.Config{
+				HostKeys:        []string{"bar"},
+				HostCAKeys:      []string{"baz"},
+				KnownHostsFile:  "/etc/ssh/ssh_known_hosts",
+				TrustOnFirstUse: true,
+			},
+			&ssh.Config{
+				ConnectionTimeout: ssh.ConnectionTimeout,
+				Port:              ssh.Port,
+				User:              ssh.User,
+				RetryTimeout:      ssh.RetryTimeout,
+				RetryInterval:     ssh.RetryInterval,
+				HostKeys:          []string{"foo"},
+				HostCAKeys:        []string{"baz"},
+				KnownHostsFile:    "/etc/ssh/ssh_known_hosts",
+				TrustOnFirstUse:   true,
+			},
+		},
 	}
 
 	for i, testCase := range cases {
@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// sessionClient represents SSH client, which is able to open sessions for running commands.
+type sessionClient interface {
+	NewSession() (*gossh.Session, error)
+}
+
+// Exec runs given command on the host using new SSH session. Command is interpreted by
+// the login shell of the user on the host.
+func (d *sshConnected) Exec(command string, stdin io.Reader) ([]byte, []byte, int, error) {
+	client, ok := d.client.(sessionClient)
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("connection does not support running commands")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("opening session: %w", err)
+	}
+
+	defer func() {
+		// Session is closed by the server, once command exits.
+		if err := session.Close(); err != nil && !errors.Is(err, io.EOF) {
+			fmt.Printf("Failed closing session: %v\n", err)
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	err = session.Run(command)
+
+	var exitErr *gossh.ExitError
+
+	switch {
+	case errors.As(err, &exitErr):
+		return stdout.Bytes(), stderr.Bytes(), exitErr.ExitStatus(), nil
+	case err != nil:
+		return nil, nil, 0, fmt.Errorf("running command: %w", err)
+	}
+
+	return stdout.Bytes(), stderr.Bytes(), 0, nil
+}
@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	testFailingCommand = "false"
+)
+
+// serveTestExec accepts SSH connection without authentication and serves exec requests. Executed
+// command prints the command and received stdin to stdout and 'stderr' to stderr. Command
+// 'false' exits with exit code 1.
+func serveTestExec(conn net.Conn, hostKey gossh.Signer) {
+	config := &gossh.ServerConfig{
+		NoClientAuth: true,
+	}
+
+	config.AddHostKey(hostKey)
+
+	_, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go gossh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(gossh.UnknownChannelType, "not supported") //nolint:errcheck // Test server.
+
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go serveTestSession(channel, requests)
+	}
+}
+
+func serveTestSession(channel gossh.Channel, requests <-chan *gossh.Request) {
+	defer channel.Close() //nolint:errcheck // Test server.
+
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil) //nolint:errcheck // Test server.
+
+			continue
+		}
+
+		var payload struct {
+			Command string
+		}
+
+		if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil) //nolint:errcheck // Test server.
+
+			return
+		}
+
+		_ = req.Reply(true, nil) //nolint:errcheck // Test server.
+
+		stdin, _ := io.ReadAll(channel) //nolint:errcheck // Test server.
+
+		fmt.Fprintf(channel, "%s: %s", payload.Command, stdin)
+		fmt.Fprint(channel.Stderr(), "stderr")
+
+		exitStatus := struct {
+			Status uint32
+		}{}
+
+		if payload.Command == testFailingCommand {
+			exitStatus.Status = 1
+		}
+
+		_, _ = channel.SendRequest("exit-status", false, gossh.Marshal(&exitStatus)) //nolint:errcheck // Test server.
+
+		return
+	}
+}
+
+func newTestExecConfig(t *testing.T) *Config {
+	t.Helper()
+
+	hostKey := generateHostKey(t)
+
+	testConfig := newTestConfig(t)
+	testConfig.HostKeys = []string{authorizedKey(hostKey.PublicKey())}
+	testConfig.Dialer = func(_, address string, config *gossh.ClientConfig) (Dialer, error) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("listening: %w", err)
+		}
+
+		go func() {
+			defer listener.Close() //nolint:errcheck // Test server.
+
+			server, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			serveTestExec(server, hostKey)
+		}()
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return nil, fmt.Errorf("dialing: %w", err)
+		}
+
+		c, chans, reqs, err := gossh.NewClientConn(conn, address, config)
+		if err != nil {
+			return nil, fmt.Errorf("establishing SSH connection: %w", err)
+		}
+
+		return gossh.NewClient(c, chans, reqs), nil
+	}
+
+	return testConfig
+}
+
+func connectTestExec(t *testing.T) *sshConnected {
+	t.Helper()
+
+	s, err := newTestExecConfig(t).New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	connected, err := s.Connect()
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := connected.Close(); err != nil {
+			t.Logf("Closing connection: %v", err)
+		}
+	})
+
+	sshConnected, ok := connected.(*sshConnected)
+	if !ok {
+		t.Fatalf("Unexpected connection type %T", connected)
+	}
+
+	return sshConnected
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestExec(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	stdout, stderr, exitCode, err := connectTestExec(t).Exec("cat", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatalf("Running command should succeed, got: %v", err)
+	}
+
+	if expected := "cat: foo"; string(stdout) != expected {
+		t.Fatalf("Expected stdout %q, got %q", expected, stdout)
+	}
+
+	if expected := "stderr"; string(stderr) != expected {
+		t.Fatalf("Expected stderr %q, got %q", expected, stderr)
+	}
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d", exitCode)
+	}
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestExecNonZeroExitCode(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	_, _, exitCode, err := connectTestExec(t).Exec(testFailingCommand, nil)
+	if err != nil {
+		t.Fatalf("Command exiting with non-zero exit code should not return error, got: %v", err)
+	}
+
+	if exitCode != 1 {
+		t.Fatalf("Expected exit code 1, got %d", exitCode)
+	}
+}
+
+func TestExecNoSessionSupport(t *testing.T) {
+	t.Parallel()
+
+	connected := newConnected("localhost:22", &testJumpHost{}, nil)
+
+	if _, _, _, err := connected.Exec("true", nil); err == nil {
+		t.Fatalf("Running command over connection without session support should fail")
+	}
+}
@@ -0,0 +1,188 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+// ErrHostKeyVerification is returned, when host key presented by SSH server is not trusted.
+var ErrHostKeyVerification = errors.New("host key verification failed")
+
+// hostKeyVerifier verifies host keys presented by SSH servers.
+type hostKeyVerifier struct {
+	// hostKeys are public keys, which are accepted as host keys.
+	hostKeys []gossh.PublicKey
+
+	// certChecker verifies host certificates, if CA keys are configured.
+	certChecker *gossh.CertChecker
+
+	// knownHosts verifies host keys using known_hosts file, if configured.
+	knownHosts gossh.HostKeyCallback
+
+	// config is used to read and record host key fingerprint.
+	config *Config
+
+	// mutex protects recording host key fingerprint in the config.
+	mutex sync.Mutex
+}
+
+// parsePublicKeys parses given public keys in authorized_keys format.
+func parsePublicKeys(keys []string) ([]gossh.PublicKey, error) {
+	publicKeys := []gossh.PublicKey{}
+
+	for i, key := range keys {
+		publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %d: %w", i, err)
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	return publicKeys, nil
+}
+
+// validateHostKeys validates host key verification settings.
+func (d *Config) validateHostKeys() util.ValidateErrors {
+	var errors util.ValidateErrors
+
+	if _, err := parsePublicKeys(d.HostKeys); err != nil {
+		errors = append(errors, fmt.Errorf("parsing host keys: %w", err))
+	}
+
+	if _, err := parsePublicKeys(d.HostCAKeys); err != nil {
+		errors = append(errors, fmt.Errorf("parsing host CA keys: %w", err))
+	}
+
+	return errors
+}
+
+// hostKeyVerificationConfigured returns true, if any host key verification method is configured.
+func (d *Config) hostKeyVerificationConfigured() bool {
+	return len(d.HostKeys) > 0 || len(d.HostCAKeys) > 0 || d.KnownHostsFile != "" ||
+		d.HostKeyFingerprint != "" || d.TrustOnFirstUse
+}
+
+// newHostKeyVerifier returns verifier for host keys configured in given config. Host key
+// fingerprint recorded on first use is stored in given config.
+func newHostKeyVerifier(config *Config) (*hostKeyVerifier, error) {
+	// Validate already checks for errors, so we can skip checking here.
+	hostKeys, _ := parsePublicKeys(config.HostKeys) //nolint:errcheck // Checked in Validate().
+	caKeys, _ := parsePublicKeys(config.HostCAKeys) //nolint:errcheck // Checked in Validate().
+
+	verifier := &hostKeyVerifier{
+		hostKeys: hostKeys,
+		config:   config,
+	}
+
+	if len(caKeys) > 0 {
+		verifier.certChecker = &gossh.CertChecker{
+			IsHostAuthority: func(authority gossh.PublicKey, _ string) bool {
+				return containsKey(caKeys, authority)
+			},
+		}
+	}
+
+	if config.KnownHostsFile != "" {
+		knownHosts, err := knownhosts.New(config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading known hosts file %q: %w", config.KnownHostsFile, err)
+		}
+
+		verifier.knownHosts = knownHosts
+	}
+
+	return verifier, nil
+}
+
+// containsKey checks if given key is on the list of keys.
+func containsKey(keys []gossh.PublicKey, key gossh.PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verify implements gossh.HostKeyCallback.
+//
+// Host key is accepted, if it matches any of configured host keys, it's fingerprint matches configured
+// fingerprint, it's signed by one of configured CAs or it's present in configured known_hosts file.
+//
+// If none of the methods above is configured and trust on first use is enabled, host key is accepted and
+// it's fingerprint is recorded in the configuration, so following connections will verify it.
+func (h *hostKeyVerifier) verify(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Compare underlying keys of certificates as well, as servers prefer presenting certificates.
+	keys := []gossh.PublicKey{key}
+
+	if cert, ok := key.(*gossh.Certificate); ok {
+		keys = append(keys, cert.Key)
+	}
+
+	fingerprint := gossh.FingerprintSHA256(keys[len(keys)-1])
+
+	if h.config.HostKeyFingerprint == "" && !h.pinned() && h.config.TrustOnFirstUse {
+		h.config.HostKeyFingerprint = fingerprint
+
+		return nil
+	}
+
+	for _, k := range keys {
+		if containsKey(h.hostKeys, k) || gossh.FingerprintSHA256(k) == h.config.HostKeyFingerprint {
+			return nil
+		}
+	}
+
+	if h.certChecker != nil {
+		if err := h.certChecker.CheckHostKey(hostname, remote, key); err == nil {
+			return nil
+		}
+	}
+
+	if h.knownHosts != nil {
+		if err := h.knownHosts(hostname, remote, key); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w for %q: %s key with fingerprint %s is not trusted, "+
+		"it may indicate a man-in-the-middle attack or that the host has been reinstalled",
+		ErrHostKeyVerification, hostname, key.Type(), fingerprint)
+}
+
+// pinned returns true, if host key verification method other than fingerprint is configured.
+func (h *hostKeyVerifier) pinned() bool {
+	return len(h.hostKeys) > 0 || h.certChecker != nil || h.knownHosts != nil
+}
+
+// InheritHostKeyFingerprint copies host key fingerprints recorded on first use from given
+// previous configuration of the same host, including its jump hosts. This allows to verify the
+// host keys on following connections, even though the fingerprints are not part of user configuration.
+func (d *Config) InheritHostKeyFingerprint(previous *Config) {
+	if d == nil || previous == nil || d.Address != previous.Address || d.Port != previous.Port {
+		return
+	}
+
+	if d.TrustOnFirstUse && d.HostKeyFingerprint == "" {
+		d.HostKeyFingerprint = previous.HostKeyFingerprint
+	}
+
+	for i := range d.JumpHosts {
+		if i < len(previous.JumpHosts) {
+			d.JumpHosts[i].InheritHostKeyFingerprint(&previous.JumpHosts[i])
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	testHostname = "localhost:22"
+)
+
+func testRemoteAddr() net.Addr {
+	return &net.TCPAddr{
+		IP:   net.IPv4(127, 0, 0, 1),
+		Port: Port,
+	}
+}
+
+func generateHostKey(t *testing.T) gossh.Signer {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating key: %v", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("Creating signer: %v", err)
+	}
+
+	return signer
+}
+
+func authorizedKey(key gossh.PublicKey) string {
+	return string(gossh.MarshalAuthorizedKey(key))
+}
+
+func testHostKeyVerifier(t *testing.T, config *Config) *hostKeyVerifier {
+	t.Helper()
+
+	if err := config.validateHostKeys().Return(); err != nil {
+		t.Fatalf("Host key configuration should be valid, got: %v", err)
+	}
+
+	verifier, err := newHostKeyVerifier(config)
+	if err != nil {
+		t.Fatalf("Creating host key verifier should succeed, got: %v", err)
+	}
+
+	return verifier
+}
+
+func TestVerifyHostKey(t *testing.T) {
+	t.Parallel()
+
+	hostKey := generateHostKey(t).PublicKey()
+
+	verifier := testHostKeyVerifier(t, &Config{
+		HostKeys: []string{authorizedKey(hostKey)},
+	})
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), hostKey); err != nil {
+		t.Fatalf("Configured host key should be accepted, got: %v", err)
+	}
+}
+
+func TestVerifyHostKeyMismatch(t *testing.T) {
+	t.Parallel()
+
+	verifier := testHostKeyVerifier(t, &Config{
+		HostKeys: []string{authorizedKey(generateHostKey(t).PublicKey())},
+	})
+
+	err := verifier.verify(testHostname, testRemoteAddr(), generateHostKey(t).PublicKey())
+	if !errors.Is(err, ErrHostKeyVerification) {
+		t.Fatalf("Unknown host key should be rejected with host key verification error, got: %v", err)
+	}
+}
+
+func TestVerifyHostKeyFingerprint(t *testing.T) {
+	t.Parallel()
+
+	hostKey := generateHostKey(t).PublicKey()
+
+	verifier := testHostKeyVerifier(t, &Config{
+		HostKeyFingerprint: gossh.FingerprintSHA256(hostKey),
+	})
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), hostKey); err != nil {
+		t.Fatalf("Host key with configured fingerprint should be accepted, got: %v", err)
+	}
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), generateHostKey(t).PublicKey()); err == nil {
+		t.Fatalf("Host key with different fingerprint should be rejected")
+	}
+}
+
+func TestVerifyHostKeyTrustOnFirstUse(t *testing.T) {
+	t.Parallel()
+
+	hostKey := generateHostKey(t).PublicKey()
+
+	config := &Config{
+		TrustOnFirstUse: true,
+	}
+
+	verifier := testHostKeyVerifier(t, config)
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), hostKey); err != nil {
+		t.Fatalf("Host key should be accepted on first use, got: %v", err)
+	}
+
+	if config.HostKeyFingerprint != gossh.FingerprintSHA256(hostKey) {
+		t.Fatalf("Host key fingerprint should be recorded, got %q", config.HostKeyFingerprint)
+	}
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), hostKey); err != nil {
+		t.Fatalf("Recorded host key should be accepted, got: %v", err)
+	}
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), generateHostKey(t).PublicKey()); err == nil {
+		t.Fatalf("Host key different than recorded one should be rejected")
+	}
+}
+
+func TestVerifyHostKeyTrustOnFirstUseWithHostKeys(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		TrustOnFirstUse: true,
+		HostKeys:        []string{authorizedKey(generateHostKey(t).PublicKey())},
+	}
+
+	verifier := testHostKeyVerifier(t, config)
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), generateHostKey(t).PublicKey()); err == nil {
+		t.Fatalf("Trust on first use should not be used, when host keys are configured")
+	}
+}
+
+func testHostCertificate(t *testing.T, ca gossh.Signer, principal string) *gossh.Certificate {
+	t.Helper()
+
+	cert := &gossh.Certificate{
+		Key:             generateHostKey(t).PublicKey(),
+		CertType:        gossh.HostCert,
+		ValidPrincipals: []string{principal},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("Signing host certificate: %v", err)
+	}
+
+	return cert
+}
+
+func TestVerifyHostCertificate(t *testing.T) {
+	t.Parallel()
+
+	ca := generateHostKey(t)
+
+	verifier := testHostKeyVerifier(t, &Config{
+		HostCAKeys: []string{authorizedKey(ca.PublicKey())},
+	})
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), testHostCertificate(t, ca, "localhost")); err != nil {
+		t.Fatalf("Host certificate signed by configured CA should be accepted, got: %v", err)
+	}
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), testHostCertificate(t, ca, "foo")); err == nil {
+		t.Fatalf("Host certificate issued for different host should be rejected")
+	}
+
+	otherCA := generateHostKey(t)
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), testHostCertificate(t, otherCA, "localhost")); err == nil {
+		t.Fatalf("Host certificate signed by unknown CA should be rejected")
+	}
+}
+
+func TestVerifyHostKeyKnownHostsFile(t *testing.T) {
+	t.Parallel()
+
+	hostKey := generateHostKey(t).PublicKey()
+
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(testHostname)}, hostKey) + "\n"
+
+	if err := os.WriteFile(knownHostsFile, []byte(line), 0o600); err != nil {
+		t.Fatalf("Writing known hosts file: %v", err)
+	}
+
+	verifier := testHostKeyVerifier(t, &Config{
+		KnownHostsFile: knownHostsFile,
+	})
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), hostKey); err != nil {
+		t.Fatalf("Host key from known hosts file should be accepted, got: %v", err)
+	}
+
+	if err := verifier.verify(testHostname, testRemoteAddr(), generateHostKey(t).PublicKey()); err == nil {
+		t.Fatalf("Host key not present in known hosts file should be rejected")
+	}
+}
+
+func TestNewHostKeyVerifierMissingKnownHostsFile(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+	}
+
+	if _, err := newHostKeyVerifier(config); err == nil {
+		t.Fatalf("Creating verifier with missing known hosts file should fail")
+	}
+}
+
+func TestValidateHostKeysBad(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		HostKeys:   []string{"foo"},
+		HostCAKeys: []string{"bar"},
+	}
+
+	if errs := config.validateHostKeys(); len(errs) != 2 {
+		t.Fatalf("Expected 2 validation errors, got: %v", errs)
+	}
+}
+
+func TestInheritHostKeyFingerprint(t *testing.T) {
+	t.Parallel()
+
+	previous := &Config{
+		Address:            "localhost",
+		Port:               Port,
+		TrustOnFirstUse:    true,
+		HostKeyFingerprint: "SHA256:foo",
+	}
+
+	config := &Config{
+		Address:         "localhost",
+		Port:            Port,
+		TrustOnFirstUse: true,
+	}
+
+	config.InheritHostKeyFingerprint(previous)
+
+	if config.HostKeyFingerprint != previous.HostKeyFingerprint {
+		t.Fatalf("Host key fingerprint should be inherited, got %q", config.HostKeyFingerprint)
+	}
+}
+
+func TestInheritHostKeyFingerprintDifferentHost(t *testing.T) {
+	t.Parallel()
+
+	previous := &Config{
+		Address:            "localhost",
+		Port:               Port,
+		HostKeyFingerprint: "SHA256:foo",
+	}
+
+	config := &Config{
+		Address:         "127.0.0.1",
+		Port:            Port,
+		TrustOnFirstUse: true,
+	}
+
+	config.InheritHostKeyFingerprint(previous)
+
+	if config.HostKeyFingerprint != "" {
+		t.Fatalf("Host key fingerprint should not be inherited from different host, got %q", config.HostKeyFingerprint)
+	}
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestConnectHostKeyVerificationFailsImmediately(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	testConfig := newTestConfig(t)
+	testConfig.RetryTimeout = "60s"
+	testConfig.HostKeys = []string{authorizedKey(generateHostKey(t).PublicKey())}
+	testConfig.Dialer = func(_, address string, config *gossh.ClientConfig) (Dialer, error) {
+		return nil, config.HostKeyCallback(address, testRemoteAddr(), generateHostKey(t).PublicKey())
+	}
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(); !errors.Is(err, ErrHostKeyVerification) {
+		t.Fatalf("Connecting should fail with host key verification error, got: %v", err)
+	}
+}
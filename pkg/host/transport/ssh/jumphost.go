@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+// validateJumpHosts validates configuration of all jump hosts.
+func (d *Config) validateJumpHosts() util.ValidateErrors {
+	var errors util.ValidateErrors
+
+	for i, jumpHost := range d.JumpHosts {
+		if len(jumpHost.JumpHosts) > 0 {
+			errors = append(errors, fmt.Errorf("jump host %d: nested jump hosts are not supported, "+
+				"list all jump hosts in order instead", i))
+
+			continue
+		}
+
+		if err := jumpHost.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating jump host %d: %w", i, err))
+		}
+	}
+
+	return errors
+}
+
+// buildJumpHosts returns jump hosts for given configuration with default values filled. If configuration
+// has no jump hosts defined, jump hosts from defaults are used.
+//
+// Jump hosts inherit authentication, timeouts and host key settings, which are not specific to
+// single host, from already built host configuration.
+func buildJumpHosts(sshConfig, defaults *Config) []Config {
+	jumpHosts := sshConfig.JumpHosts
+
+	if len(jumpHosts) == 0 {
+		jumpHosts = defaults.JumpHosts
+	}
+
+	if len(jumpHosts) == 0 {
+		return nil
+	}
+
+	jumpHostDefaults := &Config{
+		User:                 sshConfig.User,
+		Password:             sshConfig.Password,
+		PrivateKey:           sshConfig.PrivateKey,
+		PrivateKeyPassphrase: sshConfig.PrivateKeyPassphrase,
+		Certificate:          sshConfig.Certificate,
+		ConnectionTimeout:    sshConfig.ConnectionTimeout,
+		RetryTimeout:         sshConfig.RetryTimeout,
+		RetryInterval:        sshConfig.RetryInterval,
+		HostCAKeys:           sshConfig.HostCAKeys,
+		KnownHostsFile:       sshConfig.KnownHostsFile,
+		TrustOnFirstUse:      sshConfig.TrustOnFirstUse,
+	}
+
+	builtJumpHosts := make([]Config, 0, len(jumpHosts))
+
+	for _, jumpHost := range jumpHosts {
+		// Copy jump host, as defaults may be shared between multiple hosts.
+		jumpHost := jumpHost
+
+		builtJumpHosts = append(builtJumpHosts, *BuildConfig(&jumpHost, jumpHostDefaults))
+	}
+
+	return builtJumpHosts
+}
+
+// dialVia returns dial function, which opens SSH connection tunneled through given
+// established SSH connection.
+func dialVia(via Dialer) func(network, address string, config *gossh.ClientConfig) (Dialer, error) {
+	return func(network, address string, config *gossh.ClientConfig) (Dialer, error) {
+		conn, err := via.Dial(network, address)
+		if err != nil {
+			return nil, fmt.Errorf("dialing %q through jump host: %w", address, err)
+		}
+
+		return handshake(conn, address, config)
+	}
+}
+
+// handshake establishes SSH connection over given connection.
+//
+// Connections tunneled through SSH do not support deadlines, so to respect the connection timeout,
+// the connection is closed, if the handshake takes too long.
+func handshake(conn net.Conn, address string, config *gossh.ClientConfig) (Dialer, error) {
+	timedOut := func() bool { return false }
+
+	if config.Timeout > 0 {
+		timer := time.AfterFunc(config.Timeout, func() {
+			_ = conn.Close() //nolint:errcheck // Handshake error is more important.
+		})
+
+		timedOut = func() bool { return !timer.Stop() }
+	}
+
+	clientConn, chans, reqs, err := gossh.NewClientConn(conn, address, config)
+
+	if timedOut() {
+		if err == nil {
+			_ = clientConn.Close() //nolint:errcheck // Timeout error is more important.
+		}
+
+		return nil, fmt.Errorf("handshake with %q through jump host timed out after %v", address, config.Timeout)
+	}
+
+	if err != nil {
+		_ = conn.Close() //nolint:errcheck // Handshake error is more important.
+
+		return nil, fmt.Errorf("handshake with %q through jump host: %w", address, err)
+	}
+
+	return gossh.NewClient(clientConn, chans, reqs), nil
+}
+
+// closeDialers closes given SSH connections in reverse order, so connections tunneled through
+// other connections are closed first.
+func closeDialers(dialers []Dialer) error {
+	var errors util.ValidateErrors
+
+	for i := len(dialers) - 1; i >= 0; i-- {
+		closer, ok := dialers[i].(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing connection: %w", err))
+		}
+	}
+
+	return errors.Return()
+}
@@ -0,0 +1,282 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	testJumpHostAddress = "bastion"
+)
+
+// testJumpHost is a Dialer, which serves SSH server with given host key on local random port
+// for every dialed address. If host key is not set, connection is accepted, but not served.
+type testJumpHost struct {
+	hostKey gossh.Signer
+	dialed  []string
+}
+
+func (j *testJumpHost) Dial(_, address string) (net.Conn, error) {
+	j.dialed = append(j.dialed, address)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+
+	go func() {
+		defer listener.Close() //nolint:errcheck // Test server.
+
+		server, err := listener.Accept()
+		if err != nil || j.hostKey == nil {
+			return
+		}
+
+		serveTestSSH(server, j.hostKey)
+	}()
+
+	return net.Dial("tcp", listener.Addr().String())
+}
+
+// serveTestSSH accepts SSH connection authenticated with password 'foo' and rejects all channels.
+func serveTestSSH(conn net.Conn, hostKey gossh.Signer) {
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(_ gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if string(password) != "foo" {
+				return nil, errors.New("bad password")
+			}
+
+			return nil, nil //nolint:nilnil // No special permissions are required.
+		},
+	}
+
+	config.AddHostKey(hostKey)
+
+	_, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go gossh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if err := newChannel.Reject(gossh.Prohibited, "not supported"); err != nil {
+			return
+		}
+	}
+}
+
+func newTestJumpHostConfig(t *testing.T, jumpHost *testJumpHost) *Config {
+	t.Helper()
+
+	testConfig := newTestConfig(t)
+	testConfig.ConnectionTimeout = "1s"
+	testConfig.RetryTimeout = "1s"
+	testConfig.RetryInterval = "100ms"
+
+	jumpHostConfig := *testConfig
+	jumpHostConfig.Address = testJumpHostAddress
+	jumpHostConfig.Dialer = func(_, _ string, _ *gossh.ClientConfig) (Dialer, error) {
+		return jumpHost, nil
+	}
+
+	testConfig.JumpHosts = []Config{jumpHostConfig}
+
+	return testConfig
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestConnectThroughJumpHost(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	hostKey := generateHostKey(t)
+	jumpHost := &testJumpHost{hostKey: hostKey}
+
+	testConfig := newTestJumpHostConfig(t, jumpHost)
+	testConfig.HostKeys = []string{authorizedKey(hostKey.PublicKey())}
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(); err != nil {
+		t.Fatalf("Connecting through jump host should succeed, got: %v", err)
+	}
+
+	if expected := []string{"localhost:22"}; !reflect.DeepEqual(jumpHost.dialed, expected) {
+		t.Fatalf("Expected jump host to dial %v, got %v", expected, jumpHost.dialed)
+	}
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestConnectThroughJumpHostVerifiesHostKey(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	jumpHost := &testJumpHost{hostKey: generateHostKey(t)}
+
+	testConfig := newTestJumpHostConfig(t, jumpHost)
+	testConfig.HostKeys = []string{authorizedKey(generateHostKey(t).PublicKey())}
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(); !errors.Is(err, ErrHostKeyVerification) {
+		t.Fatalf("Connecting should fail with host key verification error, got: %v", err)
+	}
+
+	if len(jumpHost.dialed) != 1 {
+		t.Fatalf("Host key verification failure should not be retried, got %d attempts", len(jumpHost.dialed))
+	}
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestConnectThroughJumpHostTimeout(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	jumpHost := &testJumpHost{}
+
+	testConfig := newTestJumpHostConfig(t, jumpHost)
+	testConfig.ConnectionTimeout = "100ms"
+	testConfig.RetryTimeout = "300ms"
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(); err == nil {
+		t.Fatalf("Connecting to unresponsive host through jump host should fail")
+	}
+
+	if len(jumpHost.dialed) < 2 {
+		t.Fatalf("Connecting should be retried, got %d attempts", len(jumpHost.dialed))
+	}
+}
+
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestConnectJumpHostFail(t *testing.T) {
+	unsetSSHAuthSockEnv(t)
+
+	testConfig := newTestJumpHostConfig(t, &testJumpHost{})
+	testConfig.JumpHosts[0].RetryTimeout = "100ms"
+	testConfig.JumpHosts[0].Dialer = func(_, _ string, _ *gossh.ClientConfig) (Dialer, error) {
+		return nil, errors.New("unreachable")
+	}
+
+	testConfig.Dialer = func(_, _ string, _ *gossh.ClientConfig) (Dialer, error) {
+		t.Fatalf("Host should not be dialed directly, when jump host is configured")
+
+		return nil, nil
+	}
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(); err == nil {
+		t.Fatalf("Connecting through unreachable jump host should fail")
+	}
+}
+
+func TestValidateNestedJumpHosts(t *testing.T) {
+	t.Parallel()
+
+	testConfig := newTestJumpHostConfig(t, &testJumpHost{})
+	testConfig.JumpHosts[0].JumpHosts = []Config{*newTestConfig(t)}
+
+	if err := testConfig.Validate(); err == nil {
+		t.Fatalf("Nested jump hosts should not be allowed")
+	}
+}
+
+func TestValidateBadJumpHost(t *testing.T) {
+	t.Parallel()
+
+	testConfig := newTestConfig(t)
+	testConfig.JumpHosts = []Config{{}}
+
+	if err := testConfig.Validate(); err == nil {
+		t.Fatalf("Invalid jump host configuration should be rejected")
+	}
+}
+
+func TestBuildConfigJumpHosts(t *testing.T) {
+	t.Parallel()
+
+	defaults := &Config{
+		User:     "foo",
+		Password: "bar",
+		HostKeys: []string{"baz"},
+		JumpHosts: []Config{
+			{
+				Address: testJumpHostAddress,
+			},
+		},
+	}
+
+	built := BuildConfig(&Config{Address: "localhost", Port: 2222}, defaults)
+
+	expected := []Config{
+		{
+			Address:           testJumpHostAddress,
+			Port:              Port,
+			User:              "foo",
+			Password:          "bar",
+			ConnectionTimeout: ConnectionTimeout,
+			RetryTimeout:      RetryTimeout,
+			RetryInterval:     RetryInterval,
+		},
+	}
+
+	if !reflect.DeepEqual(built.JumpHosts, expected) {
+		t.Fatalf("Expected jump hosts %+v, got %+v", expected, built.JumpHosts)
+	}
+
+	if defaults.JumpHosts[0].User != "" {
+		t.Fatalf("Building config should not modify jump hosts in defaults")
+	}
+}
+
+func TestInheritHostKeyFingerprintJumpHosts(t *testing.T) {
+	t.Parallel()
+
+	previous := &Config{
+		Address: "localhost",
+		JumpHosts: []Config{
+			{
+				Address:            testJumpHostAddress,
+				TrustOnFirstUse:    true,
+				HostKeyFingerprint: "SHA256:foo",
+			},
+		},
+	}
+
+	config := &Config{
+		Address: "localhost",
+		JumpHosts: []Config{
+			{
+				Address:         testJumpHostAddress,
+				TrustOnFirstUse: true,
+			},
+		},
+	}
+
+	config.InheritHostKeyFingerprint(previous)
+
+	if fingerprint := config.JumpHosts[0].HostKeyFingerprint; fingerprint != "SHA256:foo" {
+		t.Fatalf("Jump host key fingerprint should be inherited, got %q", fingerprint)
+	}
+}
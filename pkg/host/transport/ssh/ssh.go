@@ -3,11 +3,13 @@
 package ssh
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +23,17 @@ import (
 const (
 	// SSHAuthSockEnv is environment variable name used for connecting to ssh-agent.
 	SSHAuthSockEnv = "SSH_AUTH_SOCK"
+
+	// PrivateKeyPassphraseEnv is environment variable name used for reading passphrase of the
+	// encrypted private key, if it is not set in the configuration.
+	PrivateKeyPassphraseEnv = "FLEXKUBE_SSH_PRIVATE_KEY_PASSPHRASE"
+
+	// keepaliveRequest is a global request used for checking if the connection is alive. Servers
+	// reply to unknown requests with failure, which still proves that the connection works.
+	keepaliveRequest = "keepalive@openssh.com"
+
+	// pingTimeout is how long we wait for the reply to keepalive request.
+	pingTimeout = 10 * time.Second
 )
 
 // Config represents SSH transport configuration.
@@ -36,7 +49,8 @@ type Config struct {
 	// User defines as which user the connection should authenticate.
 	User string `json:"user,omitempty"`
 
-	// Password adds password as one of available authentication methods.
+	// Password adds password as one of available authentication methods. It is used for both
+	// password and keyboard-interactive authentication methods.
 	Password string `json:"password,omitempty"`
 
 	// ConnectionTimeout defines time, after which SSH client gives up single attempt for connecting.
@@ -50,9 +64,54 @@ type Config struct {
 	RetryInterval string `json:"retryInterval,omitempty"`
 
 	// PrivateKey adds private key as authentication method.
-	// It must be defined as valid SSH private key in PEM format.
+	// It must be defined as valid SSH private key in PEM format. If private key is
+	// encrypted, PrivateKeyPassphrase must be set.
 	PrivateKey string `json:"privateKey,omitempty"`
 
+	// PrivateKeyPassphrase is a passphrase used to decrypt encrypted PrivateKey.
+	//
+	// If empty, passphrase is read from FLEXKUBE_SSH_PRIVATE_KEY_PASSPHRASE environment variable.
+	PrivateKeyPassphrase string `json:"privateKeyPassphrase,omitempty"`
+
+	// Certificate is an OpenSSH user certificate in authorized_keys format, e.g. content of
+	// 'id_ed25519-cert.pub' file, signed by a CA trusted by the SSH server. If set, it is used
+	// together with PrivateKey for authentication.
+	Certificate string `json:"certificate,omitempty"`
+
+	// HostKeys is a list of public keys in authorized_keys format, which are accepted as host keys.
+	//
+	// If none of HostKeys, HostCAKeys, KnownHostsFile, HostKeyFingerprint and TrustOnFirstUse is set,
+	// host keys are not verified.
+	HostKeys []string `json:"hostKeys,omitempty"`
+
+	// HostCAKeys is a list of CA public keys in authorized_keys format. Host certificates signed by
+	// any of them are accepted, if the certificate is valid for the address of the host.
+	HostCAKeys []string `json:"hostCAKeys,omitempty"`
+
+	// KnownHostsFile is a path to known_hosts file in OpenSSH format, which is used to verify host keys.
+	//
+	// Example value: '~/.ssh/known_hosts'.
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+
+	// TrustOnFirstUse enables accepting the host key on the first connection, if no other verification
+	// method is configured. Fingerprint of the accepted key is recorded in HostKeyFingerprint field,
+	// which is persisted in the state, so the key is verified on following connections.
+	TrustOnFirstUse bool `json:"trustOnFirstUse,omitempty"`
+
+	// HostKeyFingerprint is a SHA256 fingerprint of the accepted host key, e.g. 'SHA256:...', as
+	// printed by 'ssh-keygen -l'. It is filled automatically, when TrustOnFirstUse is enabled.
+	//
+	// As fingerprint is specific to a single host, it is not inherited from default configuration.
+	HostKeyFingerprint string `json:"hostKeyFingerprint,omitempty"`
+
+	// JumpHosts is a list of SSH hosts, through which the connection to the host is tunneled, similar
+	// to ProxyJump option of OpenSSH. Each jump host is connected through the previous one, in given
+	// order, with its own retries and timeouts. Jump hosts cannot have jump hosts on their own.
+	//
+	// Authentication, timeouts and host key settings not specific to a single host, which are not
+	// defined for a jump host, are inherited from the host configuration.
+	JumpHosts []Config `json:"jumpHosts,omitempty"`
+
 	Dialer func(network, address string, config *gossh.ClientConfig) (Dialer, error) `json:"-"`
 }
 
@@ -70,13 +129,23 @@ type ssh struct {
 	retryInterval     time.Duration
 	auth              []gossh.AuthMethod
 	dialer            func(network, address string, config *gossh.ClientConfig) (Dialer, error)
+	hostKeyCallback   gossh.HostKeyCallback
+	jumpHosts         []*ssh
+	agent             io.Closer
 }
 
 type sshConnected struct {
-	client   Dialer
-	address  string
-	uuid     func() (uuid.UUID, error)
-	listener func(string, string) (net.Listener, error)
+	client          Dialer
+	jumpConnections []Dialer
+	address         string
+	uuid            func() (uuid.UUID, error)
+	listener        func(string, string) (net.Listener, error)
+
+	// forwards holds listeners of active forwards, indexed by local address returned to the user.
+	forwards map[string]net.Listener
+
+	// forwardsMutex protects forwards map.
+	forwardsMutex sync.Mutex
 }
 
 // New validates SSH configuration and returns new instance of transport interface.
@@ -85,6 +154,11 @@ func (d *Config) New() (transport.Interface, error) {
 		return nil, fmt.Errorf("validating config: %w", err)
 	}
 
+	return d.newSSH()
+}
+
+// newSSH creates SSH transport from validated configuration.
+func (d *Config) newSSH() (*ssh, error) {
 	connectionTimeout, _ := time.ParseDuration(d.ConnectionTimeout) //nolint:errcheck // This is checked in Validate().
 	retryTimeout, _ := time.ParseDuration(d.RetryTimeout)           //nolint:errcheck // This is checked in Validate().
 	retryInterval, _ := time.ParseDuration(d.RetryInterval)         //nolint:errcheck // This is checked in Validate().
@@ -103,12 +177,28 @@ func (d *Config) New() (transport.Interface, error) {
 		newSSH.dialer = defaultDialF
 	}
 
+	if err := newSSH.setHostKeyCallback(d); err != nil {
+		return nil, err
+	}
+
+	for i := range d.JumpHosts {
+		jumpHost, err := d.JumpHosts[i].newSSH()
+		if err != nil {
+			return nil, fmt.Errorf("creating jump host %d: %w", i, err)
+		}
+
+		newSSH.jumpHosts = append(newSSH.jumpHosts, jumpHost)
+	}
+
 	if d.Password != "" {
-		newSSH.auth = append(newSSH.auth, gossh.Password(d.Password))
+		newSSH.auth = append(newSSH.auth,
+			gossh.Password(d.Password),
+			gossh.KeyboardInteractive(keyboardInteractive(d.Password)),
+		)
 	}
 
 	if d.PrivateKey != "" {
-		signer, _ := gossh.ParsePrivateKey([]byte(d.PrivateKey)) //nolint:errcheck // This is checked in Validate().
+		signer, _ := d.signer() //nolint:errcheck // This is checked in Validate().
 		newSSH.auth = append(newSSH.auth, gossh.PublicKeys(signer))
 	}
 
@@ -120,14 +210,16 @@ func (d *Config) New() (transport.Interface, error) {
 		if err != nil {
 			return nil, fmt.Errorf("dialing SSH agent: %w", err)
 		}
-		// TODO: We should close the authSock with Close() after we finish using it,
-		// but it is not trivial at the moment, so we just let the dying process to
-		// close it.
-		//
-		// defer authConn.Close()
+
+		// Agent connection is used for signing while connecting, so it must be kept open until Close() is called.
+		newSSH.agent = authConn
 
 		signers, err := agent.NewClient(authConn).Signers()
 		if err != nil {
+			if closeErr := newSSH.Close(); closeErr != nil {
+				fmt.Printf("Failed closing SSH agent connection: %v\n", closeErr)
+			}
+
 			return nil, fmt.Errorf("getting public keys from SSH agent: %w", err)
 		}
 
@@ -137,6 +229,26 @@ func (d *Config) New() (transport.Interface, error) {
 	return newSSH, nil
 }
 
+// setHostKeyCallback configures host key verification using given configuration.
+func (d *ssh) setHostKeyCallback(config *Config) error {
+	// Since user may not know the public keys of their server, for convenience,
+	// allow insecure host keys, if no verification method is configured.
+	if !config.hostKeyVerificationConfigured() {
+		d.hostKeyCallback = gossh.InsecureIgnoreHostKey() // #nosec G106
+
+		return nil
+	}
+
+	verifier, err := newHostKeyVerifier(config)
+	if err != nil {
+		return fmt.Errorf("configuring host key verification: %w", err)
+	}
+
+	d.hostKeyCallback = verifier.verify
+
+	return nil
+}
+
 // Validate validates given configuration.
 func (d *Config) Validate() error {
 	var errors util.ValidateErrors
@@ -153,8 +265,14 @@ func (d *Config) Validate() error {
 		errors = append(errors, fmt.Errorf("at least one authentication method must be available"))
 	}
 
-	if _, err := gossh.ParsePrivateKey([]byte(d.PrivateKey)); d.PrivateKey != "" && err != nil {
-		errors = append(errors, fmt.Errorf("parsing private key: %w", err))
+	if d.PrivateKey != "" {
+		if _, err := d.signer(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if d.Certificate != "" && d.PrivateKey == "" {
+		errors = append(errors, fmt.Errorf("certificate requires private key to be set"))
 	}
 
 	if d.Port == 0 {
@@ -162,6 +280,8 @@ func (d *Config) Validate() error {
 	}
 
 	errors = append(errors, d.validateDurations()...)
+	errors = append(errors, d.validateHostKeys()...)
+	errors = append(errors, d.validateJumpHosts()...)
 
 	return errors.Return()
 }
@@ -189,18 +309,72 @@ func (d *Config) validateDurations() util.ValidateErrors {
 	return errors
 }
 
-// Connect opens SSH connection to configured host.
+// Connect opens SSH connection to configured host, tunneled through configured jump hosts.
 func (d *ssh) Connect() (transport.Connected, error) {
+	jumpConnections := []Dialer{}
+
+	var via Dialer
+
+	for _, jumpHost := range d.jumpHosts {
+		connection, err := jumpHost.connect(via)
+		if err != nil {
+			if closeErr := closeDialers(jumpConnections); closeErr != nil {
+				fmt.Printf("Failed closing jump host connections: %v\n", closeErr)
+			}
+
+			return nil, fmt.Errorf("connecting to jump host %q: %w", jumpHost.address, err)
+		}
+
+		jumpConnections = append(jumpConnections, connection)
+		via = connection
+	}
+
+	connection, err := d.connect(via)
+	if err != nil {
+		if closeErr := closeDialers(jumpConnections); closeErr != nil {
+			fmt.Printf("Failed closing jump host connections: %v\n", closeErr)
+		}
+
+		return nil, err
+	}
+
+	return newConnected(d.address, connection, jumpConnections), nil
+}
+
+// Close closes connections to SSH agent used by the host and its jump hosts.
+func (d *ssh) Close() error {
+	var errors util.ValidateErrors
+
+	for _, jumpHost := range d.jumpHosts {
+		if err := jumpHost.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing jump host %q: %w", jumpHost.address, err))
+		}
+	}
+
+	if d.agent != nil {
+		if err := d.agent.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing SSH agent connection: %w", err))
+		}
+
+		d.agent = nil
+	}
+
+	return errors.Return()
+}
+
+// connect opens SSH connection to the host with retries. If via is not nil, connection
+// is tunneled through it.
+func (d *ssh) connect(via Dialer) (Dialer, error) {
 	sshConfig := &gossh.ClientConfig{
-		Auth:    d.auth,
-		Timeout: d.connectionTimeout,
-		User:    d.user,
-		// TODO: Add possibility to specify host keys, which should be accepted.
-		// Since user may not know the public keys of their server, for convenience,
-		// allow insecure host keys.
-		//
-		// #nosec G106
-		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Auth:            d.auth,
+		Timeout:         d.connectionTimeout,
+		User:            d.user,
+		HostKeyCallback: d.hostKeyCallback,
+	}
+
+	dialer := d.dialer
+	if via != nil {
+		dialer = dialVia(via)
 	}
 
 	var connection Dialer
@@ -211,8 +385,13 @@ func (d *ssh) Connect() (transport.Connected, error) {
 
 	// Try until we timeout.
 	for time.Since(start) < d.retryTimeout {
-		if connection, err = d.dialer("tcp", d.address, sshConfig); err == nil {
-			return newConnected(d.address, connection), nil
+		if connection, err = dialer("tcp", d.address, sshConfig); err == nil {
+			return connection, nil
+		}
+
+		// Retrying won't help, if host key is not trusted.
+		if errors.Is(err, ErrHostKeyVerification) {
+			return nil, err
 		}
 
 		time.Sleep(d.retryInterval)
@@ -221,12 +400,70 @@ func (d *ssh) Connect() (transport.Connected, error) {
 	return nil, err
 }
 
-func newConnected(address string, connection Dialer) transport.Connected {
+func newConnected(address string, connection Dialer, jumpConnections []Dialer) transport.Connected {
 	return &sshConnected{
-		client:   connection,
-		address:  address,
-		uuid:     uuid.NewRandom,
-		listener: net.Listen,
+		client:          connection,
+		jumpConnections: jumpConnections,
+		address:         address,
+		uuid:            uuid.NewRandom,
+		listener:        net.Listen,
+		forwards:        map[string]net.Listener{},
+	}
+}
+
+// Close closes all forwards, the connection to the host and connections to jump hosts.
+//
+// Closing the connection also interrupts all connections established through the forwards.
+func (d *sshConnected) Close() error {
+	var errors util.ValidateErrors
+
+	d.forwardsMutex.Lock()
+
+	for localAddress, listener := range d.forwards {
+		if err := listener.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing forward %q: %w", localAddress, err))
+		}
+	}
+
+	d.forwards = map[string]net.Listener{}
+
+	d.forwardsMutex.Unlock()
+
+	if err := closeDialers(append(append([]Dialer{}, d.jumpConnections...), d.client)); err != nil {
+		errors = append(errors, err)
+	}
+
+	return errors.Return()
+}
+
+// requestClient represents SSH client, which is able to send global requests.
+type requestClient interface {
+	SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error)
+}
+
+// Ping checks if the connection to the host is still alive by sending keepalive request to it.
+func (d *sshConnected) Ping() error {
+	client, ok := d.client.(requestClient)
+	if !ok {
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		_, _, err := client.SendRequest(keepaliveRequest, true, nil)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("sending keepalive request: %w", err)
+		}
+
+		return nil
+	case <-time.After(pingTimeout):
+		return fmt.Errorf("timed out waiting for reply to keepalive request after %v", pingTimeout)
 	}
 }
 
@@ -245,13 +482,47 @@ func (d *sshConnected) ForwardUnixSocket(path string) (string, error) {
 
 	path, err = extractPath(path)
 	if err != nil {
+		if closeErr := localSock.Close(); closeErr != nil {
+			fmt.Printf("Failed closing listener: %v\n", closeErr)
+		}
+
 		return "", fmt.Errorf("parsing path %q: %w", path, err)
 	}
 
 	// Schedule accepting connections and return.
 	go forwardConnection(localSock, d.client, path, "unix")
 
-	return fmt.Sprintf("unix://%s", unixAddr.String()), nil
+	return d.trackForward(fmt.Sprintf("unix://%s", unixAddr.String()), localSock), nil
+}
+
+// trackForward stores listener of the forward, so it can be closed later using CloseForward.
+func (d *sshConnected) trackForward(localAddress string, listener net.Listener) string {
+	d.forwardsMutex.Lock()
+	defer d.forwardsMutex.Unlock()
+
+	d.forwards[localAddress] = listener
+
+	return localAddress
+}
+
+// CloseForward closes the listener of the forward with given local address. Already
+// established connections through the forward are not interrupted.
+func (d *sshConnected) CloseForward(localAddress string) error {
+	d.forwardsMutex.Lock()
+	defer d.forwardsMutex.Unlock()
+
+	listener, ok := d.forwards[localAddress]
+	if !ok {
+		return fmt.Errorf("address %q is not forwarded", localAddress)
+	}
+
+	delete(d.forwards, localAddress)
+
+	if err := listener.Close(); err != nil {
+		return fmt.Errorf("closing listener: %w", err)
+	}
+
+	return nil
 }
 
 // handleClient is responsible for copying incoming and outgoing data going
@@ -288,12 +559,14 @@ func handleClient(client, remote io.ReadWriteCloser) {
 	<-chDone
 }
 
-// forwardConnection accepts local connections, and forwards them to remote address.
+// forwardConnection accepts local connections, and forwards them to remote address, until
+// the listener gets closed.
 //
-// TODO: Should we do some error handling here?
+// As forwards may be shared, failing to open the remote connection only closes accepted client
+// connection.
 func forwardConnection(listener net.Listener, connection Dialer, remoteAddress, connectionType string) {
 	defer func() {
-		if err := listener.Close(); err != nil {
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			fmt.Printf("Failed closing listener: %v\n", err)
 		}
 	}()
@@ -302,8 +575,11 @@ func forwardConnection(listener net.Listener, connection Dialer, remoteAddress,
 		// Accept connection from the client.
 		conn, err := listener.Accept()
 		if err != nil {
-			fmt.Printf("Failed to accept connection: %v\n", err)
-			// Handle error (and then for example indicate acceptor is down).
+			// Listener is closed when forward is no longer needed.
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("Failed to accept connection: %v\n", err)
+			}
+
 			return
 		}
 
@@ -312,7 +588,11 @@ func forwardConnection(listener net.Listener, connection Dialer, remoteAddress,
 		if err != nil {
 			fmt.Printf("Failed to open remote connection: %v\n", err)
 
-			return
+			if err := conn.Close(); err != nil {
+				fmt.Printf("Failed closing client connection: %v\n", err)
+			}
+
+			continue
 		}
 
 		// Schedule data transfers.
@@ -338,8 +618,6 @@ func extractPath(path string) (string, error) {
 // randomUnixSocket generates random abstract UNIX socket, including unique UUID,
 // to avoid collisions.
 func (d *sshConnected) randomUnixSocket() (*net.UnixAddr, error) {
-	// TODO: Rather than connecting again every time ForwardUnixSocket is called
-	// we should cache and reuse the connections.
 	socketUUID, err := d.uuid()
 	if err != nil {
 		return nil, fmt.Errorf("generating random UUID for abstract UNIX socket: %w", err)
@@ -366,5 +644,5 @@ func (d *sshConnected) ForwardTCP(address string) (string, error) {
 	// Schedule accepting connections and return.
 	go forwardConnection(localConn, d.client, address, "tcp")
 
-	return localConn.Addr().String(), nil
+	return d.trackForward(localConn.Addr().String(), localConn), nil
 }
@@ -94,6 +94,17 @@ func TestPrivateKeyAuth(t *testing.T) {
 func withPrivateKey(t *testing.T) transport.Interface {
 	t.Helper()
 
+	sshWithPrivateKey, err := privateKeyConfig(t).New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	return sshWithPrivateKey
+}
+
+func privateKeyConfig(t *testing.T) *Config {
+	t.Helper()
+
 	unsetSSHAuthSockEnv(t)
 
 	sshPrivateKeyPath := os.Getenv("TEST_INTEGRATION_SSH_PRIVATE_KEY_PATH")
@@ -108,7 +119,7 @@ func withPrivateKey(t *testing.T) transport.Interface {
 		t.Fatalf("Reading SSH private key from %q shouldn't fail, got: %v", sshPrivateKeyPath, err)
 	}
 
-	withPrivateKeyConfig := &Config{
+	return &Config{
 		Address:           "localhost",
 		User:              "core",
 		ConnectionTimeout: "5s",
@@ -117,13 +128,29 @@ func withPrivateKey(t *testing.T) transport.Interface {
 		Port:              testPort(t),
 		PrivateKey:        string(key),
 	}
+}
 
-	sshWithPrivateKey, err := withPrivateKeyConfig.New()
+//nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
+//nolint:paralleltest // which is a global variable, so to keep things stable, don't run it in parallel.
+func TestJumpHost(t *testing.T) {
+	testConfig := privateKeyConfig(t)
+
+	// Use the same server as a jump host, as it allows TCP forwarding.
+	testConfig.JumpHosts = []Config{*privateKeyConfig(t)}
+
+	s, err := testConfig.New()
 	if err != nil {
 		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
 	}
 
-	return sshWithPrivateKey
+	connected, err := s.Connect()
+	if err != nil {
+		t.Fatalf("Connecting through jump host should succeed, got: %v", err)
+	}
+
+	if _, err := connected.ForwardUnixSocket("unix:///run/docker.sock"); err != nil {
+		t.Fatalf("Forwarding through jump host should succeed, got: %v", err)
+	}
 }
 
 //nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
@@ -24,6 +24,10 @@ import (
 
 const (
 	authMethods = 1
+
+	// passwordAuthMethods is a number of auth methods added for password, which is used for both
+	// password and keyboard-interactive authentication.
+	passwordAuthMethods = 2
 )
 
 func unsetSSHAuthSockEnv(t *testing.T) {
@@ -54,8 +58,8 @@ func TestNewSetPassword(t *testing.T) {
 	testConfig := newTestConfig(t)
 	testConfig.PrivateKey = ""
 	testConfig.Dialer = func(_, _ string, config *gossh.ClientConfig) (Dialer, error) {
-		if len(config.Auth) != authMethods {
-			t.Fatalf("Unexpected auth methods, expected %d, got %v", authMethods, config.Auth)
+		if len(config.Auth) != passwordAuthMethods {
+			t.Fatalf("Unexpected auth methods, expected %d, got %v", passwordAuthMethods, config.Auth)
 		}
 
 		return &gossh.Client{}, nil
@@ -351,7 +355,7 @@ func TestExtractPathTCP(t *testing.T) {
 func testNewConnected(t *testing.T) *sshConnected {
 	t.Helper()
 
-	c, ok := newConnected("localhost:80", nil).(*sshConnected)
+	c, ok := newConnected("localhost:80", nil, nil).(*sshConnected)
 	if !ok {
 		t.Fatalf("Converting connected to internal state")
 	}
@@ -454,15 +458,15 @@ func TestForwardConnectionBadType(t *testing.T) {
 
 	go forwardConnection(forwardListener, &net.Dialer{}, r.Addr().String(), "doh")
 
-	// Try to open connection, so forwarding loop breaks.
-	if _, err := net.Dial("tcp", forwardListener.Addr().String()); err != nil {
-		t.Logf("Opening first connection should succeed, got: %v", err)
-	}
-
-	time.Sleep(time.Second)
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", forwardListener.Addr().String())
+		if err != nil {
+			t.Fatalf("Forward should keep accepting connections, when opening remote connection fails, got: %v", err)
+		}
 
-	if _, err := net.Dial("tcp", forwardListener.Addr().String()); err == nil {
-		t.Fatalf("Opening connection to bad type should fail")
+		if _, err := io.ReadAll(conn); err != nil {
+			t.Fatalf("Client connection should be closed, when opening remote connection fails, got: %v", err)
+		}
 	}
 }
 
@@ -490,6 +494,125 @@ func TestForwardConnectionClosedListener(t *testing.T) {
 	}
 }
 
+// CloseForward() tests.
+func TestCloseForward(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+	connected.client = &net.Dialer{}
+
+	localAddress, err := connected.ForwardTCP("localhost:80")
+	if err != nil {
+		t.Fatalf("Forwarding TCP should succeed, got: %v", err)
+	}
+
+	if err := connected.CloseForward(localAddress); err != nil {
+		t.Fatalf("Closing forward should succeed, got: %v", err)
+	}
+
+	if _, err := net.Dial("tcp", localAddress); err == nil {
+		t.Fatalf("Connecting to closed forward should fail")
+	}
+
+	if err := connected.CloseForward(localAddress); err == nil {
+		t.Fatalf("Closing already closed forward should fail")
+	}
+}
+
+func TestCloseForwardUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+
+	localAddress, err := connected.ForwardUnixSocket("unix:///run/docker.sock")
+	if err != nil {
+		t.Fatalf("Forwarding unix socket should succeed, got: %v", err)
+	}
+
+	if err := connected.CloseForward(localAddress); err != nil {
+		t.Fatalf("Closing forward should succeed, got: %v", err)
+	}
+
+	if len(connected.forwards) != 0 {
+		t.Fatalf("Closed forward should not be tracked anymore, got: %v", connected.forwards)
+	}
+}
+
+func TestConnectedClose(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+	connected.client = &net.Dialer{}
+
+	localAddresses := []string{}
+
+	for i := 0; i < 2; i++ {
+		localAddress, err := connected.ForwardTCP("localhost:80")
+		if err != nil {
+			t.Fatalf("Forwarding TCP should succeed, got: %v", err)
+		}
+
+		localAddresses = append(localAddresses, localAddress)
+	}
+
+	if err := connected.Close(); err != nil {
+		t.Fatalf("Closing connection should succeed, got: %v", err)
+	}
+
+	for _, localAddress := range localAddresses {
+		if _, err := net.Dial("tcp", localAddress); err == nil {
+			t.Fatalf("Connecting to forward %q of closed connection should fail", localAddress)
+		}
+	}
+}
+
+// testRequestClient is a SSH client, which replies to global requests with given error.
+type testRequestClient struct {
+	net.Dialer
+
+	err error
+}
+
+func (c *testRequestClient) SendRequest(string, bool, []byte) (bool, []byte, error) {
+	return false, nil, c.err
+}
+
+// Ping() tests.
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+	connected.client = &testRequestClient{}
+
+	if err := connected.Ping(); err != nil {
+		t.Fatalf("Pinging connection should succeed, got: %v", err)
+	}
+}
+
+func TestPingBrokenConnection(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+	connected.client = &testRequestClient{
+		err: io.EOF,
+	}
+
+	if err := connected.Ping(); err == nil {
+		t.Fatalf("Pinging broken connection should fail")
+	}
+}
+
+func TestPingNoRequestSupport(t *testing.T) {
+	t.Parallel()
+
+	connected := testNewConnected(t)
+	connected.client = &net.Dialer{}
+
+	if err := connected.Ping(); err != nil {
+		t.Fatalf("Connections not supporting requests should be considered alive, got: %v", err)
+	}
+}
+
 // Connect() tests.
 //
 //nolint:paralleltest // This test may access SSHAuthSockEnv environment variable,
@@ -730,6 +853,59 @@ func TestNewSSHAgent(t *testing.T) {
 	}
 }
 
+func TestCloseSSHAgent(t *testing.T) {
+	addr := &net.UnixAddr{
+		Name: "@flexkube-close-agent",
+		Net:  "unix",
+	}
+
+	agentListener, err := net.Listen("unix", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to listen on address %q: %v", addr.String(), err)
+	}
+
+	agentClosed := make(chan struct{})
+
+	go func() {
+		defer close(agentClosed)
+
+		c, err := agentListener.Accept()
+		if err != nil {
+			t.Logf("Accepting connection failed: %v", err)
+
+			return
+		}
+
+		// Serving returns, when client closes the connection.
+		if err := agent.ServeAgent(agent.NewKeyring(), c); err != nil {
+			t.Logf("Serving agent finished: %v", err)
+		}
+
+		if err := agentListener.Close(); err != nil {
+			t.Logf("Closing listener failed: %v", err)
+		}
+	}()
+
+	t.Setenv(SSHAuthSockEnv, addr.String())
+
+	testConfig := newTestConfig(t)
+
+	s, err := testConfig.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object with good ssh-agent should work, got: %v", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Closing SSH object should succeed, got: %v", err)
+	}
+
+	select {
+	case <-agentClosed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Connection to SSH agent should be closed")
+	}
+}
+
 func TestNewSSHAgentWrongSocket(t *testing.T) {
 	addr := &net.UnixAddr{
 		Name: "@bar",
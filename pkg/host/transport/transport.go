@@ -1,12 +1,20 @@
 // Package transport provides interfaces for forwarding connections.
 package transport
 
+import (
+	"io"
+)
+
 // Interface Transport should be a valid object, which is ready to open connection.
 type Interface interface {
 	// Connect initializes the connection with transport method. For example, if transport method
 	// requires initial authentication, it should happen at this point, so further forward errors
 	// are more specific.
 	Connect() (Connected, error)
+
+	// Close releases resources used for establishing connections, e.g. connection to the SSH agent.
+	// Already established connections are not affected.
+	Close() error
 }
 
 // Connected interface describes universal way of communicating with remote hosts
@@ -17,6 +25,25 @@ type Connected interface {
 
 	// ForwardTCP listens on random local port and forwards incoming connections to given remote address.
 	ForwardTCP(remoteAddr string) (localAddr string, err error)
+
+	// CloseForward stops forwarding of given local address, previously returned by ForwardUnixSocket
+	// or ForwardTCP, and releases all resources associated with it.
+	CloseForward(localAddr string) error
+
+	// Exec runs given command using shell on the host, feeding it with data from stdin, if not nil.
+	// Command exiting with non-zero exit code is not considered an error, so callers must check
+	// returned exit code. Error is only returned, if the command could not be run.
+	Exec(command string, stdin io.Reader) (stdout, stderr []byte, exitCode int, err error)
+
+	// Close closes all forwards and the connection itself.
+	Close() error
+}
+
+// Pinger is an optional interface, which may be implemented by Connected, to allow checking
+// if established connection is still usable, e.g. before reusing it.
+type Pinger interface {
+	// Ping returns error, if the connection is no longer usable.
+	Ping() error
 }
 
 // Config describes how Transport interface should be created.
@@ -10,7 +10,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -104,6 +103,12 @@ type Kubelet struct {
 
 	// ExtraArgs defines additional flags which will be added to the kubelet process.
 	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// Runtime selects container runtime, which will be used to run kubelet container. It
+	// does not affect container runtime used by kubelet itself.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
 }
 
 // kubelet is a validated, executable version of Kubelet.
@@ -447,10 +452,7 @@ func (k *kubelet) ToHostConfiguredContainer() (*container.HostConfiguredContaine
 	}
 
 	kubeletContainer := container.Container{
-		// TODO this is weird. This sets docker as default runtime config
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: container.PickRuntimeConfig(k.config.Runtime),
 		Config: containertypes.ContainerConfig{
 			// TODO make it configurable?
 			Name:  "kubelet",
@@ -16,12 +16,19 @@ import (
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
 const (
 	// DefaultHairpinMode is a default HairpinMode configured for kubelets.
 	DefaultHairpinMode = "hairpin-veth"
+
+	// DefaultCgroupDriver is a cgroup driver used by the kubelet, if none is configured.
+	DefaultCgroupDriver = "cgroupfs"
+
+	// Port is a port, where kubelet serves its API.
+	Port = 10250
 )
 
 // Pool represents group of kubelet instances and their configuration.
@@ -124,11 +131,30 @@ type Pool struct {
 
 	// ExtraArgs defines additional flags which will be added to the kubelet process.
 	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// Runtime selects container runtime, which will be used to run kubelet containers. It
+	// will be used unless kubelet instance selects it's own runtime.
+	//
+	// This field is optional. If not set, Docker runtime with default settings will be used.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
+	// Parallelism controls, how many containers can be deployed at the same time.
+	//
+	// This field is optional. If not set, containers will be deployed one by one.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// UpdateStrategy controls, how many containers can be updated at the same time.
+	//
+	// This field is optional. If not set, containers will be updated one by one.
+	UpdateStrategy *container.UpdateStrategy `json:"updateStrategy,omitempty"`
 }
 
 // pool is a validated version of Pool.
 type pool struct {
 	containers container.ContainersInterface
+
+	// kubelets holds validated kubelets, where key is a container name.
+	kubelets map[string]*kubelet
 }
 
 // pkiIntegration merges certificates from PKI into pool configuration.
@@ -138,7 +164,7 @@ func (p *Pool) pkiIntegration() {
 	}
 
 	if p.PKI.Kubernetes.CA != nil && p.KubernetesCACertificate == "" {
-		p.KubernetesCACertificate = p.PKI.Kubernetes.CA.X509Certificate
+		p.KubernetesCACertificate = p.PKI.Kubernetes.CA.TrustBundle()
 	}
 
 	if p.AdminConfig == nil {
@@ -146,7 +172,7 @@ func (p *Pool) pkiIntegration() {
 	}
 
 	if p.AdminConfig.ClientCertificate == "" && p.PKI.Kubernetes.AdminCertificate != nil {
-		p.AdminConfig.ClientCertificate = p.PKI.Kubernetes.AdminCertificate.X509Certificate
+		p.AdminConfig.ClientCertificate = p.PKI.Kubernetes.AdminCertificate.FullChain()
 	}
 
 	if p.AdminConfig.ClientKey == "" && p.PKI.Kubernetes.AdminCertificate != nil {
@@ -199,6 +225,10 @@ func (p *Pool) propagateKubelet(kubelet *Kubelet) {
 		kubelet.ExtraArgs = p.ExtraArgs
 	}
 
+	if kubelet.Runtime == nil {
+		kubelet.Runtime = p.Runtime
+	}
+
 	kubelet.Host = host.BuildConfig(kubelet.Host, host.Host{
 		SSHConfig: p.SSH,
 	})
@@ -219,27 +249,36 @@ func (p *Pool) New() (types.Resource, error) {
 	}
 
 	containers := &container.Containers{
-		PreviousState: p.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  p.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    p.Parallelism,
+		UpdateStrategy: p.UpdateStrategy,
+	}
+
+	newPool := &pool{
+		kubelets: map[string]*kubelet{},
 	}
 
+	containers.ReadinessCheck = newPool.nodeReady
+
 	//nolint:varnamelen // i is fine as iterator.
 	for i := range p.Kubelets {
 		k := &p.Kubelets[i]
 
 		p.propagateKubelet(k)
 
-		kubelet, _ := k.New()                                //nolint:errcheck // This is checked in Validate().
-		kubeletHcc, _ := kubelet.ToHostConfiguredContainer() //nolint:errcheck // This is checked in Validate().
+		newKubelet, _ := k.New()                                //nolint:errcheck // This is checked in Validate().
+		kubeletHcc, _ := newKubelet.ToHostConfiguredContainer() //nolint:errcheck // This is checked in Validate().
 
 		containers.DesiredState[strconv.Itoa(i)] = kubeletHcc
+		newPool.kubelets[strconv.Itoa(i)] = newKubelet.(*kubelet) //nolint:forcetypeassert // New() always returns *kubelet.
 	}
 
 	c, _ := containers.New() //nolint:errcheck // This is checked in Validate().
 
-	return &pool{
-		containers: c,
-	}, nil
+	newPool.containers = c
+
+	return newPool, nil
 }
 
 // Validate validates Pool configuration.
@@ -247,8 +286,10 @@ func (p *Pool) Validate() error {
 	var errors util.ValidateErrors
 
 	containers := &container.Containers{
-		PreviousState: p.State,
-		DesiredState:  container.ContainersState{},
+		PreviousState:  p.State,
+		DesiredState:   container.ContainersState{},
+		Parallelism:    p.Parallelism,
+		UpdateStrategy: p.UpdateStrategy,
 	}
 
 	//nolint:varnamelen // i is fine as iterator.
@@ -307,7 +348,33 @@ func (p *pool) Deploy() error {
 	return p.containers.Deploy()
 }
 
+// nodeReady waits until node of kubelet with given container name becomes ready, if
+// waiting for node readiness is enabled for this kubelet.
+func (p *pool) nodeReady(name string) error {
+	k, ok := p.kubelets[name]
+	if !ok || !k.config.WaitForNodeReady {
+		return nil
+	}
+
+	return k.waitForNodeReady()
+}
+
 // Containers implement types.Resource interface.
 func (p *pool) Containers() container.ContainersInterface {
 	return p.containers
 }
+
+// PreflightRequirements implements preflight.Requirer interface. Kubelets require their API port
+// and container runtime using the same cgroup driver as the kubelet.
+func (p *pool) PreflightRequirements() map[string]preflight.Requirements {
+	requirements := map[string]preflight.Requirements{}
+
+	for name, k := range p.kubelets {
+		requirements[name] = preflight.Requirements{
+			Ports:        []int{Port},
+			CgroupDriver: util.PickString(k.config.CgroupDriver, DefaultCgroupDriver),
+		}
+	}
+
+	return requirements
+}
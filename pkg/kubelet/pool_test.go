@@ -11,6 +11,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/kubelet"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -122,6 +123,29 @@ func TestPoolContainers(t *testing.T) {
 	}
 }
 
+// PreflightRequirements() tests.
+func TestPoolPreflightRequirements(t *testing.T) {
+	t.Parallel()
+
+	p := getPool(t)
+
+	requirer, ok := p.(preflight.Requirer)
+	if !ok {
+		t.Fatalf("Pool should implement preflight.Requirer interface")
+	}
+
+	requirements := requirer.PreflightRequirements()
+	if len(requirements) != 2 {
+		t.Fatalf("Expected requirements for 2 kubelets, got: %+v", requirements)
+	}
+
+	for name, r := range requirements {
+		if r.CgroupDriver != kubelet.DefaultCgroupDriver {
+			t.Fatalf("Kubelet %q should require default cgroup driver, got %q", name, r.CgroupDriver)
+		}
+	}
+}
+
 // Deploy() tests.
 func TestPoolDeploy(t *testing.T) {
 	t.Parallel()
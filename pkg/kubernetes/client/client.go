@@ -43,6 +43,12 @@ type Client interface {
 
 	// PingWait waits until API server becomes available.
 	PingWait(pollInterval, retryTimeout time.Duration) error
+
+	// CheckHealthy returns a function, which checks, if API server reports itself as healthy.
+	CheckHealthy() func() (bool, error)
+
+	// WaitForHealthy waits until API server reports itself as healthy.
+	WaitForHealthy(pollInterval, retryTimeout time.Duration) error
 }
 
 type client struct {
@@ -83,6 +89,25 @@ func (c *client) ping() (bool, error) {
 	return true, nil
 }
 
+// CheckHealthy checks, if API server /healthz endpoint returns 'ok'.
+func (c *client) CheckHealthy() func() (bool, error) {
+	return func() (bool, error) {
+		body, err := c.Discovery().RESTClient().Get().AbsPath("/healthz").DoRaw(context.TODO())
+		if err != nil {
+			return false, nil //nolint:nilerr // Ignore all errors, API server should eventually become healthy.
+		}
+
+		return string(body) == "ok", nil
+	}
+}
+
+// WaitForHealthy waits for API server to become healthy. If it does not become healthy
+// before reaching the timeout, error is returned.
+func (c *client) WaitForHealthy(pollInterval, retryTimeout time.Duration) error {
+	//nolint:staticcheck // Will migrate once https://github.com/kubernetes/kubernetes/issues/119533 is resolved.
+	return wait.PollImmediate(pollInterval, retryTimeout, c.CheckHealthy())
+}
+
 // CheckNodeExists checks if given node object exists.
 func (c *client) CheckNodeExists(name string) func() (bool, error) {
 	return func() (bool, error) {
@@ -82,6 +82,23 @@ func TestPingWaitFakeKubeconfig(t *testing.T) {
 	}
 }
 
+// WaitForHealthy() tests.
+func TestWaitForHealthyFakeKubeconfig(t *testing.T) {
+	t.Parallel()
+
+	kubeconfig := GetKubeconfig(t)
+
+	testClient, err := client.NewClient([]byte(kubeconfig))
+	if err != nil {
+		t.Fatalf("Failed creating client: %v", err)
+	}
+
+	//nolint:staticcheck // Will migrate once https://github.com/kubernetes/kubernetes/issues/119533 is resolved.
+	if err := testClient.WaitForHealthy(1*time.Second, 1*time.Second); !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Fatalf("Waiting for API server with fake config should always timeout, got: %v", err)
+	}
+}
+
 // CheckNodeReady() tests.
 func TestCheckNodeReadyFakeKubeconfig(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,232 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// CARotationPhase describes in which phase the CA rotation is.
+type CARotationPhase string
+
+const (
+	// CARotationPhaseTrustNewCA is a phase of CA rotation, in which new CA certificates are generated
+	// and trusted together with old CA certificates, but all other certificates are still signed by
+	// old CA certificates.
+	CARotationPhaseTrustNewCA CARotationPhase = "trust-new-ca"
+
+	// CARotationPhaseReissued is a phase of CA rotation, in which all certificates are re-issued
+	// using new CA certificates, but old CA certificates are still trusted.
+	CARotationPhaseReissued CARotationPhase = "reissued"
+)
+
+// CARotation stores state of CA certificates rotation.
+type CARotation struct {
+	// Phase is a current phase of the CA rotation.
+	Phase CARotationPhase `json:"phase"`
+
+	// CertificateAuthorities holds names of rotated CA certificates, e.g. 'root-ca', 'etcd/ca'
+	// or 'kubernetes/ca'.
+	CertificateAuthorities []string `json:"certificateAuthorities"`
+}
+
+// Validate validates CA rotation state.
+func (r *CARotation) Validate() error {
+	var errors util.ValidateErrors
+
+	if r.Phase != CARotationPhaseTrustNewCA && r.Phase != CARotationPhaseReissued {
+		errors = append(errors, fmt.Errorf("unknown CA rotation phase %q", r.Phase))
+	}
+
+	if len(r.CertificateAuthorities) == 0 {
+		errors = append(errors, fmt.Errorf("at least one rotated CA certificate must be set"))
+	}
+
+	validNames := (&PKI{Etcd: &Etcd{}, Kubernetes: &Kubernetes{}}).certificateAuthorities()
+
+	for _, name := range r.CertificateAuthorities {
+		if _, ok := validNames[name]; !ok {
+			errors = append(errors, fmt.Errorf("unknown CA certificate %q", name))
+		}
+	}
+
+	return errors.Return()
+}
+
+// TrustBundle returns X.509 certificate, PEM encoded, followed by previous X.509 certificate,
+// if the certificate is a CA certificate, which is being rotated. Consumers, which verify
+// certificates signed by the CA, should use it instead of X509Certificate field.
+//
+// New certificate is always first in the bundle, so the bundle can be used together with the
+// private key of the certificate.
+func (c *Certificate) TrustBundle() types.Certificate {
+	if c.PreviousX509Certificate == "" {
+		return c.X509Certificate
+	}
+
+	return c.X509Certificate + c.PreviousX509Certificate
+}
+
+// certificateAuthorities returns CA certificates of the PKI, which can be rotated, by their name.
+func (p *PKI) certificateAuthorities() map[string]*Certificate {
+	cas := map[string]*Certificate{
+		"root-ca": p.RootCA,
+	}
+
+	if p.Etcd != nil {
+		cas["etcd/ca"] = p.Etcd.CA
+	}
+
+	if p.Kubernetes != nil {
+		cas["kubernetes/ca"] = p.Kubernetes.CA
+		cas["kubernetes/front-proxy-ca"] = p.Kubernetes.FrontProxyCA
+	}
+
+	return cas
+}
+
+// StartCARotation starts rotation of CA certificates with given names, e.g. 'kubernetes/ca'.
+//
+// New private keys and CA certificates are generated, while old CA certificates are kept, so
+// trust bundles returned by TrustBundle contain both old and new CA certificates. All other
+// certificates remain signed by old CA certificates.
+//
+// Once all consumers trust new CA certificates, ReissueCertificates should be called.
+func (p *PKI) StartCARotation(names ...string) error {
+	if p.CARotation != nil {
+		return fmt.Errorf("CA rotation is already in progress, current phase is %q", p.CARotation.Phase)
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("at least one CA certificate to rotate must be given")
+	}
+
+	cas := p.certificateAuthorities()
+
+	for _, name := range names {
+		ca, ok := cas[name]
+		if !ok {
+			return fmt.Errorf("unknown CA certificate %q", name)
+		}
+
+		if ca == nil || ca.X509Certificate == "" {
+			return fmt.Errorf("CA certificate %q is not generated", name)
+		}
+
+		if ca.external() || (name != "root-ca" && p.RootCA != nil && p.RootCA.external()) {
+			return fmt.Errorf("CA certificate %q is signed externally and can't be rotated", name)
+		}
+	}
+
+	for _, name := range names {
+		ca := cas[name]
+
+		ca.PreviousX509Certificate = ca.X509Certificate
+		ca.X509Certificate = ""
+		ca.PrivateKey = ""
+		ca.PublicKey = ""
+	}
+
+	rotated := append([]string{}, names...)
+	sort.Strings(rotated)
+
+	p.CARotation = &CARotation{
+		Phase:                  CARotationPhaseTrustNewCA,
+		CertificateAuthorities: rotated,
+	}
+
+	if err := p.Generate(); err != nil {
+		return fmt.Errorf("generating new CA certificates: %w", err)
+	}
+
+	return nil
+}
+
+// ReissueCertificates re-issues all certificates signed by old CA certificates using new
+// CA certificates. Private keys of re-issued certificates are kept. Old CA certificates
+// remain trusted.
+//
+// Once all consumers use re-issued certificates, FinishCARotation should be called.
+func (p *PKI) ReissueCertificates() error {
+	if err := p.expectCARotationPhase(CARotationPhaseTrustNewCA); err != nil {
+		return err
+	}
+
+	previousCAs := []*x509.Certificate{}
+
+	cas := p.certificateAuthorities()
+
+	for _, name := range p.CARotation.CertificateAuthorities {
+		previous := &Certificate{X509Certificate: cas[name].PreviousX509Certificate}
+
+		previousCA, err := previous.DecodeX509Certificate()
+		if err != nil {
+			return fmt.Errorf("decoding previous %q CA certificate: %w", name, err)
+		}
+
+		previousCAs = append(previousCAs, previousCA)
+	}
+
+	for name, cert := range p.Certificates() {
+		x509Cert, err := cert.DecodeX509Certificate()
+		if err != nil {
+			return fmt.Errorf("decoding %q certificate: %w", name, err)
+		}
+
+		for _, previousCA := range previousCAs {
+			if x509Cert.CheckSignatureFrom(previousCA) == nil {
+				cert.X509Certificate = ""
+
+				break
+			}
+		}
+	}
+
+	if err := p.Generate(); err != nil {
+		return fmt.Errorf("re-issuing certificates: %w", err)
+	}
+
+	p.CARotation.Phase = CARotationPhaseReissued
+
+	return nil
+}
+
+// FinishCARotation removes old CA certificates from trust bundles, which finishes the CA rotation.
+//
+// Certificates issued by old CA certificates outside of the PKI, for example kubelet client
+// certificates issued by kube-controller-manager, must be renewed before calling it, as they
+// won't be trusted anymore.
+func (p *PKI) FinishCARotation() error {
+	if err := p.expectCARotationPhase(CARotationPhaseReissued); err != nil {
+		return err
+	}
+
+	cas := p.certificateAuthorities()
+
+	for _, name := range p.CARotation.CertificateAuthorities {
+		if ca := cas[name]; ca != nil {
+			ca.PreviousX509Certificate = ""
+		}
+	}
+
+	p.CARotation = nil
+
+	return nil
+}
+
+// expectCARotationPhase returns error, if CA rotation is not in progress or if it is in
+// different phase than given one.
+func (p *PKI) expectCARotationPhase(phase CARotationPhase) error {
+	if p.CARotation == nil {
+		return fmt.Errorf("CA rotation is not in progress")
+	}
+
+	if p.CARotation.Phase != phase {
+		return fmt.Errorf("CA rotation must be in phase %q, current phase is %q", phase, p.CARotation.Phase)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package pki_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+func generatedPKI(t *testing.T) *pki.PKI {
+	t.Helper()
+
+	p := &pki.PKI{
+		Etcd: &pki.Etcd{
+			Peers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	return p
+}
+
+func signedBy(t *testing.T, cert, ca *pki.Certificate) bool {
+	t.Helper()
+
+	x509Cert, err := cert.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding certificate should work, got: %v", err)
+	}
+
+	x509CA, err := ca.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding CA certificate should work, got: %v", err)
+	}
+
+	return x509Cert.CheckSignatureFrom(x509CA) == nil
+}
+
+//nolint:funlen,cyclop // Test covers all phases of the rotation.
+func TestCARotation(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	oldCA := *p.Kubernetes.CA
+	oldAdmin := *p.Kubernetes.AdminCertificate
+	oldEtcdPeer := *p.Etcd.PeerCertificates["controller01"]
+
+	if err := p.StartCARotation("kubernetes/ca"); err != nil {
+		t.Fatalf("Starting CA rotation should work, got: %v", err)
+	}
+
+	if p.CARotation == nil || p.CARotation.Phase != pki.CARotationPhaseTrustNewCA {
+		t.Fatalf("CA rotation should be in phase %q, got: %+v", pki.CARotationPhaseTrustNewCA, p.CARotation)
+	}
+
+	if p.Kubernetes.CA.X509Certificate == oldCA.X509Certificate || p.Kubernetes.CA.PrivateKey == oldCA.PrivateKey {
+		t.Fatalf("New Kubernetes CA certificate and private key should be generated")
+	}
+
+	bundle := string(p.Kubernetes.CA.TrustBundle())
+
+	if !strings.HasPrefix(bundle, string(p.Kubernetes.CA.X509Certificate)) {
+		t.Fatalf("Trust bundle should start with new CA certificate, got:\n%s", bundle)
+	}
+
+	if !strings.Contains(bundle, string(oldCA.X509Certificate)) {
+		t.Fatalf("Trust bundle should contain old CA certificate, got:\n%s", bundle)
+	}
+
+	if p.Kubernetes.AdminCertificate.X509Certificate != oldAdmin.X509Certificate {
+		t.Fatalf("Certificates should not be re-issued when starting CA rotation")
+	}
+
+	if err := p.StartCARotation("kubernetes/ca"); err == nil {
+		t.Fatalf("Starting CA rotation when it is in progress should fail")
+	}
+
+	if err := p.FinishCARotation(); err == nil {
+		t.Fatalf("Finishing CA rotation before re-issuing certificates should fail")
+	}
+
+	if err := p.ReissueCertificates(); err != nil {
+		t.Fatalf("Re-issuing certificates should work, got: %v", err)
+	}
+
+	if p.CARotation.Phase != pki.CARotationPhaseReissued {
+		t.Fatalf("CA rotation should be in phase %q, got %q", pki.CARotationPhaseReissued, p.CARotation.Phase)
+	}
+
+	if !signedBy(t, p.Kubernetes.AdminCertificate, p.Kubernetes.CA) {
+		t.Fatalf("Admin certificate should be signed by new Kubernetes CA")
+	}
+
+	if p.Kubernetes.AdminCertificate.PrivateKey != oldAdmin.PrivateKey {
+		t.Fatalf("Private key of re-issued certificate should be kept")
+	}
+
+	if p.Etcd.PeerCertificates["controller01"].X509Certificate != oldEtcdPeer.X509Certificate {
+		t.Fatalf("Certificates signed by not rotated CA should not be re-issued")
+	}
+
+	if !strings.Contains(string(p.Kubernetes.CA.TrustBundle()), string(oldCA.X509Certificate)) {
+		t.Fatalf("Old CA certificate should be trusted until CA rotation is finished")
+	}
+
+	if err := p.FinishCARotation(); err != nil {
+		t.Fatalf("Finishing CA rotation should work, got: %v", err)
+	}
+
+	if p.CARotation != nil {
+		t.Fatalf("CA rotation state should be removed after finishing, got: %+v", p.CARotation)
+	}
+
+	if p.Kubernetes.CA.TrustBundle() != p.Kubernetes.CA.X509Certificate {
+		t.Fatalf("Trust bundle should contain only new CA certificate after finishing CA rotation")
+	}
+}
+
+func TestCARotationRootCAReissuesIntermediateCAs(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	oldKubernetesCA := *p.Kubernetes.CA
+	oldEtcdPeer := *p.Etcd.PeerCertificates["controller01"]
+
+	if err := p.StartCARotation("root-ca"); err != nil {
+		t.Fatalf("Starting CA rotation should work, got: %v", err)
+	}
+
+	if err := p.ReissueCertificates(); err != nil {
+		t.Fatalf("Re-issuing certificates should work, got: %v", err)
+	}
+
+	if !signedBy(t, p.Kubernetes.CA, p.RootCA) {
+		t.Fatalf("Kubernetes CA certificate should be signed by new root CA")
+	}
+
+	if p.Kubernetes.CA.PrivateKey != oldKubernetesCA.PrivateKey {
+		t.Fatalf("Private key of re-issued Kubernetes CA certificate should be kept")
+	}
+
+	if !signedBy(t, p.Etcd.PeerCertificates["controller01"], p.Etcd.CA) {
+		t.Fatalf("Etcd peer certificate should remain valid with re-issued etcd CA certificate")
+	}
+
+	if p.Etcd.PeerCertificates["controller01"].X509Certificate != oldEtcdPeer.X509Certificate {
+		t.Fatalf("Certificates signed by intermediate CA should not be re-issued")
+	}
+}
+
+func TestStartCARotationBad(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"no CA certificates":    {},
+		"unknown CA":            {"kubernetes/admin"},
+		"not generated CA":      {"etcd/ca"},
+		"one of CAs is unknown": {"root-ca", "foo"},
+	}
+
+	for name, names := range cases {
+		names := names
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &pki.PKI{}
+
+			if err := p.Generate(); err != nil {
+				t.Fatalf("Generating valid PKI should work, got: %v", err)
+			}
+
+			rootCA := p.RootCA.X509Certificate
+
+			if err := p.StartCARotation(names...); err == nil {
+				t.Fatalf("Starting CA rotation should fail")
+			}
+
+			if p.CARotation != nil || p.RootCA.X509Certificate != rootCA {
+				t.Fatalf("Failed CA rotation should not modify the PKI")
+			}
+		})
+	}
+}
+
+func TestReissueCertificatesNoRotation(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	if err := p.ReissueCertificates(); err == nil {
+		t.Fatalf("Re-issuing certificates without CA rotation in progress should fail")
+	}
+}
+
+func TestPKIValidateBadCARotation(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		CARotation: &pki.CARotation{
+			Phase:                  "foo",
+			CertificateAuthorities: []string{"bar"},
+		},
+	}
+
+	if err := p.Validate(); err == nil {
+		t.Fatalf("Validating PKI with bad CA rotation state should fail")
+	}
+}
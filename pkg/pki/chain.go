@@ -0,0 +1,186 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// FullChain returns X.509 certificate, PEM encoded, followed by certificates of intermediate CAs,
+// which signed it. It should be used by consumers, which present the certificate to others,
+// so they can verify it, when they trust only the root CA.
+func (c *Certificate) FullChain() types.Certificate {
+	return c.X509Certificate + c.X509CertificateChain
+}
+
+// external returns true, if the certificate is managed outside of the PKI, which means it has
+// X.509 certificate, but it's private key is not available.
+func (c *Certificate) external() bool {
+	return c.X509Certificate != "" && c.PrivateKey == ""
+}
+
+// isSelfSigned checks, if given certificate is signed by itself.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// certificateChain returns chain of intermediate CA certificates for given certificate issued by
+// given CA certificate. Self-signed root CA certificate is not included in the chain.
+//
+// If certificate has not been issued by given CA, for example when it is signed externally or when
+// it is not re-issued yet during CA rotation, already configured chain is returned.
+func certificateChain(cert, caCert *Certificate) (types.Certificate, error) {
+	if caCert == nil {
+		return cert.X509CertificateChain, nil
+	}
+
+	x509Cert, err := cert.DecodeX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("decoding certificate: %w", err)
+	}
+
+	x509CACert, err := caCert.DecodeX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("decoding CA certificate: %w", err)
+	}
+
+	if x509Cert.CheckSignatureFrom(x509CACert) != nil {
+		return cert.X509CertificateChain, nil
+	}
+
+	if isSelfSigned(x509CACert) {
+		return "", nil
+	}
+
+	return caCert.FullChain(), nil
+}
+
+// splitCertificates splits given PEM encoded certificates into the first certificate and the
+// remaining ones.
+func splitCertificates(data string) (types.Certificate, types.Certificate, error) {
+	certificates := []types.Certificate{}
+
+	rest := []byte(data)
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != X509CertificatePEMHeader {
+			return "", "", fmt.Errorf("unexpected PEM block %q, only certificates are allowed", block.Type)
+		}
+
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return "", "", fmt.Errorf("parsing certificate: %w", err)
+		}
+
+		certificates = append(certificates, types.Certificate(pem.EncodeToMemory(block)))
+	}
+
+	if len(certificates) == 0 {
+		return "", "", fmt.Errorf("no PEM encoded certificates found")
+	}
+
+	var chain types.Certificate
+
+	for _, c := range certificates[1:] {
+		chain += c
+	}
+
+	return certificates[0], chain, nil
+}
+
+// validateCAChain validates, that the certificate is a CA certificate, which matches it's
+// private key, if it's available, and that it can be verified using given root CA certificate
+// and configured certificate chain.
+func (c *Certificate) validateCAChain(rootCA *Certificate) error {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return err
+	}
+
+	if !cert.IsCA {
+		return fmt.Errorf("certificate is not a CA certificate")
+	}
+
+	if c.PrivateKey != "" {
+		privateKey, err := c.decodePrivateKey()
+		if err != nil {
+			return err
+		}
+
+		matches, err := c.x509CertificateMatchesKey(privateKey)
+		if err != nil {
+			return err
+		}
+
+		if !matches {
+			return fmt.Errorf("certificate does not match private key")
+		}
+	}
+
+	if rootCA == nil || rootCA.X509Certificate == "" {
+		return nil
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(rootCA.TrustBundle()))
+
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM([]byte(c.X509CertificateChain))
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("verifying certificate chain: %w", err)
+	}
+
+	return nil
+}
+
+// intermediateCACRs returns certificate requests for CA certificates signed by the root CA
+// by their name, e.g. 'etcd/ca'.
+func (p *PKI) intermediateCACRs() map[string]*certificateRequest {
+	crs := map[string]*certificateRequest{}
+
+	if p.Etcd != nil {
+		crs["etcd/ca"] = p.Etcd.caCR(p.RootCA, p.Certificate)
+	}
+
+	if p.Kubernetes != nil {
+		crs["kubernetes/ca"] = p.Kubernetes.kubernetesCACR(p.RootCA, p.Certificate)
+		crs["kubernetes/front-proxy-ca"] = p.Kubernetes.kubernetesFrontProxyCACR(p.RootCA, p.Certificate)
+	}
+
+	return crs
+}
+
+// validateCAChains validates chains of all configured intermediate CA certificates.
+func (p *PKI) validateCAChains() util.ValidateErrors {
+	var errors util.ValidateErrors
+
+	cas := p.certificateAuthorities()
+
+	for _, name := range []string{"etcd/ca", "kubernetes/ca", "kubernetes/front-proxy-ca"} {
+		ca := cas[name]
+		if ca == nil || ca.X509Certificate == "" {
+			continue
+		}
+
+		if err := ca.validateCAChain(p.RootCA); err != nil {
+			errors = append(errors, fmt.Errorf("validating %q CA certificate: %w", name, err))
+		}
+	}
+
+	return errors
+}
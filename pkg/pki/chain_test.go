@@ -0,0 +1,241 @@
+package pki_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+// signCSR signs given PEM encoded certificate signing request as CA certificate using given CA
+// certificate, simulating external CA.
+func signCSR(t *testing.T, csrPEM string, ca *pki.Certificate) string {
+	t.Helper()
+
+	csrBlock, _ := pem.Decode([]byte(csrPEM))
+	if csrBlock == nil || csrBlock.Type != pki.CertificateRequestPEMHeader {
+		t.Fatalf("Certificate signing request should be PEM encoded, got: %s", csrPEM)
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+	if err != nil {
+		t.Fatalf("Parsing certificate signing request should work, got: %v", err)
+	}
+
+	caCert, err := ca.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding CA certificate should work, got: %v", err)
+	}
+
+	keyBlock, _ := pem.Decode([]byte(ca.PrivateKey))
+
+	caKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatalf("Parsing CA private key should work, got: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               csr.Subject,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(8760 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Signing certificate signing request should work, got: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: pki.X509CertificatePEMHeader, Bytes: der}))
+}
+
+// externalPKI returns PKI, which root CA certificate and issuing intermediate CA certificate,
+// with private keys, can be used to simulate external CA.
+func externalPKI(t *testing.T) *pki.PKI {
+	t.Helper()
+
+	external := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := external.Generate(); err != nil {
+		t.Fatalf("Generating external PKI should work, got: %v", err)
+	}
+
+	return external
+}
+
+func TestGenerateCertificateChain(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	if p.RootCA.X509CertificateChain != "" || p.Kubernetes.CA.X509CertificateChain != "" {
+		t.Fatalf("Chain of CA certificates signed by self-signed root CA should be empty")
+	}
+
+	admin := p.Kubernetes.AdminCertificate
+
+	if admin.X509CertificateChain != p.Kubernetes.CA.X509Certificate {
+		t.Fatalf("Chain of admin certificate should contain Kubernetes CA certificate, got:\n%s", admin.X509CertificateChain)
+	}
+
+	if admin.FullChain() != admin.X509Certificate+p.Kubernetes.CA.X509Certificate {
+		t.Fatalf("Full chain should contain certificate followed by it's chain, got:\n%s", admin.FullChain())
+	}
+}
+
+func TestGenerateExternalRootCARequiresSignedIntermediates(t *testing.T) {
+	t.Parallel()
+
+	external := externalPKI(t)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			X509Certificate: external.RootCA.X509Certificate,
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err == nil {
+		t.Fatalf("Generating intermediate CA certificates without root CA private key should fail")
+	}
+
+	if p.RootCA.X509Certificate != external.RootCA.X509Certificate || p.RootCA.PrivateKey != "" {
+		t.Fatalf("External root CA certificate should not be re-generated")
+	}
+}
+
+//nolint:funlen // Test covers whole CSR export and import flow.
+func TestImportSignedIntermediateCAs(t *testing.T) {
+	t.Parallel()
+
+	external := externalPKI(t)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			X509Certificate: external.RootCA.X509Certificate,
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	csrs, err := p.CertificateSigningRequests()
+	if err != nil {
+		t.Fatalf("Creating certificate signing requests should work, got: %v", err)
+	}
+
+	if len(csrs) != 2 {
+		t.Fatalf("Expected certificate signing requests for Kubernetes CA and front proxy CA, got: %v", csrs)
+	}
+
+	// Sign Kubernetes CA using external issuing CA and front proxy CA using external root CA directly.
+	kubernetesCA := signCSR(t, csrs["kubernetes/ca"], external.Kubernetes.CA)
+	issuingCA := external.Kubernetes.CA.X509Certificate
+
+	if err := p.ImportCertificate("kubernetes/ca", kubernetesCA); err == nil {
+		t.Fatalf("Importing certificate without intermediate CA certificates should fail")
+	}
+
+	if err := p.ImportCertificate("kubernetes/ca", kubernetesCA+string(issuingCA)); err != nil {
+		t.Fatalf("Importing signed certificate with it's chain should work, got: %v", err)
+	}
+
+	if err := p.ImportCertificate("kubernetes/front-proxy-ca", signCSR(t, csrs["kubernetes/front-proxy-ca"],
+		external.RootCA)); err != nil {
+		t.Fatalf("Importing signed certificate should work, got: %v", err)
+	}
+
+	if csrs, err := p.CertificateSigningRequests(); err != nil || len(csrs) != 0 {
+		t.Fatalf("No certificate signing requests should be created for imported certificates, got %v: %v", csrs, err)
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with imported intermediate CA certificates should work, got: %v", err)
+	}
+
+	if p.RootCA.PrivateKey != "" {
+		t.Fatalf("External root CA certificate should not be re-generated")
+	}
+
+	if string(p.Kubernetes.CA.X509Certificate) != kubernetesCA {
+		t.Fatalf("Imported Kubernetes CA certificate should not be re-generated")
+	}
+
+	admin := p.Kubernetes.AdminCertificate
+
+	if expected := p.Kubernetes.CA.X509Certificate + issuingCA; admin.X509CertificateChain != expected {
+		t.Fatalf("Admin certificate chain should include Kubernetes CA and external issuing CA, got:\n%s",
+			admin.X509CertificateChain)
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Fatalf("PKI with imported intermediate CA certificates should be valid, got: %v", err)
+	}
+
+	if err := p.StartCARotation("kubernetes/ca"); err == nil {
+		t.Fatalf("Rotating CA certificate signed externally should fail")
+	}
+}
+
+func TestImportCertificateBad(t *testing.T) {
+	t.Parallel()
+
+	external := externalPKI(t)
+	other := externalPKI(t)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			X509Certificate: external.RootCA.X509Certificate,
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.ImportCertificate("kubernetes/ca", string(external.Kubernetes.CA.X509Certificate)); err == nil {
+		t.Fatalf("Importing certificate before creating certificate signing request should fail")
+	}
+
+	csrs, err := p.CertificateSigningRequests()
+	if err != nil {
+		t.Fatalf("Creating certificate signing requests should work, got: %v", err)
+	}
+
+	cases := map[string]struct {
+		name string
+		data string
+	}{
+		"unknown certificate":   {"kubernetes/admin", signCSR(t, csrs["kubernetes/ca"], external.RootCA)},
+		"not PEM encoded":       {"kubernetes/ca", "foo"},
+		"signed by other CA":    {"kubernetes/ca", signCSR(t, csrs["kubernetes/ca"], other.RootCA)},
+		"different private key": {"kubernetes/ca", signCSR(t, csrs["kubernetes/front-proxy-ca"], external.RootCA)},
+	}
+
+	for name, testCase := range cases {
+		if err := p.ImportCertificate(testCase.name, testCase.data); err == nil {
+			t.Fatalf("Importing certificate should fail for case %q", name)
+		}
+	}
+
+	if p.Kubernetes.CA.X509Certificate != "" {
+		t.Fatalf("Failed import should not modify the PKI")
+	}
+}
+
+func TestPKIValidateBadCAChain(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+	other := externalPKI(t)
+
+	p.Kubernetes.CA = other.Kubernetes.CA
+
+	if err := p.Validate(); err == nil {
+		t.Fatalf("Validating PKI with CA certificate not signed by root CA should fail")
+	}
+}
@@ -0,0 +1,108 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+)
+
+// CertificateSigningRequests generates private keys for intermediate CA certificates, which are
+// not issued yet and returns their certificate signing requests, PEM encoded, by their name,
+// e.g. 'etcd/ca'.
+//
+// This allows to sign intermediate CA certificates by the root CA, which private key is not
+// available, for example because it is kept offline. Signed certificates should be imported
+// using ImportCertificate.
+func (p *PKI) CertificateSigningRequests() (map[string]string, error) {
+	csrs := map[string]string{}
+
+	for name, cr := range p.intermediateCACRs() {
+		if cr.Target.X509Certificate != "" {
+			continue
+		}
+
+		cert, err := buildCertificate(cr.Certificates...)
+		if err != nil {
+			return nil, fmt.Errorf("building %q certificate configuration: %w", name, err)
+		}
+
+		if err := cert.Validate(); err != nil {
+			return nil, fmt.Errorf("validating %q certificate: %w", name, err)
+		}
+
+		privateKey, err := cert.getPrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("getting %q private key: %w", name, err)
+		}
+
+		csr, err := cert.certificateSigningRequest(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("creating %q certificate signing request: %w", name, err)
+		}
+
+		cr.Target.PrivateKey = cert.PrivateKey
+		cr.Target.PublicKey = cert.PublicKey
+
+		csrs[name] = csr
+	}
+
+	return csrs, nil
+}
+
+// certificateSigningRequest creates PEM encoded certificate signing request for the certificate
+// using given private key.
+func (c *Certificate) certificateSigningRequest(privateKey crypto.Signer) (string, error) {
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Organization: []string{c.Organization},
+			CommonName:   c.CommonName,
+		},
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, privateKey)
+	if err != nil {
+		return "", fmt.Errorf("creating certificate request: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: CertificateRequestPEMHeader, Bytes: der})), nil
+}
+
+// ImportCertificate imports externally signed X.509 certificate of intermediate CA certificate
+// with given name, e.g. 'etcd/ca'. Given data must contain PEM encoded certificate, optionally
+// followed by certificates of intermediate CAs, which signed it.
+//
+// Certificate must match private key generated by CertificateSigningRequests and it must be
+// possible to verify it using root CA certificate.
+func (p *PKI) ImportCertificate(name, data string) error {
+	cr, ok := p.intermediateCACRs()[name]
+	if !ok {
+		return fmt.Errorf("unknown intermediate CA certificate %q", name)
+	}
+
+	if cr.Target.PrivateKey == "" {
+		return fmt.Errorf("private key of %q is not generated, certificate signing request must be created first", name)
+	}
+
+	x509Certificate, chain, err := splitCertificates(data)
+	if err != nil {
+		return fmt.Errorf("parsing certificates: %w", err)
+	}
+
+	cert := &Certificate{
+		X509Certificate:      x509Certificate,
+		X509CertificateChain: chain,
+		PrivateKey:           cr.Target.PrivateKey,
+	}
+
+	if err := cert.validateCAChain(p.RootCA); err != nil {
+		return fmt.Errorf("validating %q certificate: %w", name, err)
+	}
+
+	cr.Target.X509Certificate = x509Certificate
+	cr.Target.X509CertificateChain = chain
+
+	return nil
+}
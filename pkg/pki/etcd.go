@@ -41,10 +41,6 @@ type Etcd struct {
 
 // Generate generates etcd PKI.
 func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) error {
-	if e.CA == nil {
-		e.CA = &Certificate{}
-	}
-
 	servers := e.Servers
 
 	// If there is no different server certificates defined, assume they are the same as peers.
@@ -54,19 +50,8 @@ func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) err
 
 	e.initializeCertificatesMaps(servers)
 
-	caRequest := &certificateRequest{
-		Target: e.CA,
-		CA:     rootCA,
-		Certificates: []*Certificate{
-			&defaultCertificate,
-			&e.Certificate,
-			caCertificate(EtcdCACN),
-			e.CA,
-		},
-	}
-
 	// etcd CA Certificate
-	if err := buildAndGenerate(caRequest); err != nil {
+	if err := buildAndGenerate(e.caCR(rootCA, defaultCertificate)); err != nil {
 		return fmt.Errorf("generating etcd CA certificate: %w", err)
 	}
 
@@ -85,6 +70,24 @@ func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) err
 	return buildAndGenerate(crs...)
 }
 
+// caCR builds certificate request for etcd CA certificate.
+func (e *Etcd) caCR(rootCA *Certificate, defaultCertificate Certificate) *certificateRequest {
+	if e.CA == nil {
+		e.CA = &Certificate{}
+	}
+
+	return &certificateRequest{
+		Target: e.CA,
+		CA:     rootCA,
+		Certificates: []*Certificate{
+			&defaultCertificate,
+			&e.Certificate,
+			caCertificate(EtcdCACN),
+			e.CA,
+		},
+	}
+}
+
 func (e *Etcd) initializeCertificatesMaps(servers map[string]string) {
 	if e.PeerCertificates == nil && len(e.Peers) != 0 {
 		e.PeerCertificates = map[string]*Certificate{}
@@ -0,0 +1,83 @@
+package pki
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// hoursPerDay is used to calculate number of days remaining until certificate expiry.
+const hoursPerDay = 24
+
+// CertificateInfo holds information about generated certificate.
+type CertificateInfo struct {
+	// Name is a name of the certificate in the PKI, e.g. 'etcd/peer/controller01'.
+	Name string `json:"name"`
+
+	// CommonName is a CN field of the certificate.
+	CommonName string `json:"commonName"`
+
+	// DNSNames is a list of DNS names, for which the certificate is valid.
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// IPAddresses is a list of IP addresses, for which the certificate is valid.
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+
+	// Issuer is a CN field of the certificate issuer.
+	Issuer string `json:"issuer"`
+
+	// SerialNumber is a serial number of the certificate, hex encoded.
+	SerialNumber string `json:"serialNumber"`
+
+	// NotAfter is an expiry time of the certificate.
+	NotAfter time.Time `json:"notAfter"`
+
+	// DaysRemaining is a number of full days remaining until the certificate expires. It is
+	// negative if the certificate is already expired.
+	DaysRemaining int `json:"daysRemaining"`
+
+	// KeyType is a type of the certificate key, e.g. 'rsa' or 'ed25519'.
+	KeyType string `json:"keyType"`
+}
+
+// Inspect returns information about all generated certificates in the PKI, sorted by their
+// name. Remaining days until expiry are calculated using given time.
+func (p *PKI) Inspect(now time.Time) ([]CertificateInfo, error) {
+	certificates := p.Certificates()
+
+	names := []string{}
+
+	for name := range certificates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	infos := []CertificateInfo{}
+
+	for _, name := range names {
+		cert, err := certificates[name].DecodeX509Certificate()
+		if err != nil {
+			return nil, fmt.Errorf("decoding %q certificate: %w", name, err)
+		}
+
+		info := CertificateInfo{
+			Name:          name,
+			CommonName:    cert.Subject.CommonName,
+			DNSNames:      cert.DNSNames,
+			Issuer:        cert.Issuer.CommonName,
+			SerialNumber:  fmt.Sprintf("%x", cert.SerialNumber),
+			NotAfter:      cert.NotAfter,
+			DaysRemaining: int(cert.NotAfter.Sub(now).Hours() / hoursPerDay),
+			KeyType:       keyType(cert.PublicKey),
+		}
+
+		for _, ip := range cert.IPAddresses {
+			info.IPAddresses = append(info.IPAddresses, ip.String())
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,71 @@
+package pki_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	infos, err := p.Inspect(time.Now())
+	if err != nil {
+		t.Fatalf("Inspecting generated PKI should work, got: %v", err)
+	}
+
+	if len(infos) != len(p.Certificates()) {
+		t.Fatalf("Expected information about %d certificates, got %d", len(p.Certificates()), len(infos))
+	}
+
+	if !sort.SliceIsSorted(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name }) {
+		t.Fatalf("Certificates should be sorted by name, got: %+v", infos)
+	}
+
+	for _, info := range infos {
+		if info.Name != "etcd/peer/controller01" {
+			continue
+		}
+
+		if info.Issuer != pki.EtcdCACN {
+			t.Fatalf("Expected issuer %q, got %q", pki.EtcdCACN, info.Issuer)
+		}
+
+		if len(info.IPAddresses) == 0 || info.IPAddresses[0] != "192.168.1.10" {
+			t.Fatalf("Expected peer IP address in SANs, got: %v", info.IPAddresses)
+		}
+
+		if info.KeyType != pki.KeyTypeRSA {
+			t.Fatalf("Expected key type %q, got %q", pki.KeyTypeRSA, info.KeyType)
+		}
+
+		if info.DaysRemaining != 364 {
+			t.Fatalf("Expected 364 full days remaining with default validity duration, got %d", info.DaysRemaining)
+		}
+
+		return
+	}
+
+	t.Fatalf("Etcd peer certificate should be inspected, got: %+v", infos)
+}
+
+func TestInspectExpired(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	infos, err := p.Inspect(time.Now().Add(2 * 8760 * time.Hour))
+	if err != nil {
+		t.Fatalf("Inspecting generated PKI should work, got: %v", err)
+	}
+
+	for _, info := range infos {
+		if info.DaysRemaining >= 0 {
+			t.Fatalf("Certificate %q should be expired, got %d days remaining", info.Name, info.DaysRemaining)
+		}
+	}
+}
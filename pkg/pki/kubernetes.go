@@ -197,6 +197,10 @@ func (k *Kubernetes) kubeControllerManagerCR(defaultCertificate Certificate) *ce
 
 // Generate generates Kubernetes PKI.
 func (k *Kubernetes) Generate(rootCA *Certificate, defaultCertificate Certificate) error {
+	if err := k.validateServiceAccountKeyType(defaultCertificate); err != nil {
+		return err
+	}
+
 	crs := []*certificateRequest{
 		k.kubernetesCACR(rootCA, defaultCertificate),
 		k.kubernetesFrontProxyCACR(rootCA, defaultCertificate),
@@ -297,3 +301,19 @@ func defaultKubeAPIServerFrontProxyClientCertificate() *Certificate {
 		KeyUsage:   clientUsage(),
 	}
 }
+
+// validateServiceAccountKeyType checks, if configured key type of service account certificate
+// is supported by Kubernetes for signing service account tokens.
+func (k *Kubernetes) validateServiceAccountKeyType(defaultCertificate Certificate) error {
+	cert, err := buildCertificate(&defaultCertificate, &k.Certificate, k.ServiceAccountCertificate)
+	if err != nil {
+		return fmt.Errorf("building Kubernetes service account certificate configuration: %w", err)
+	}
+
+	if cert.KeyType == KeyTypeEd25519 {
+		return fmt.Errorf("service account certificate can't use %q key type, "+
+			"as it is not supported for signing service account tokens", cert.KeyType)
+	}
+
+	return nil
+}
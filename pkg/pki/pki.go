@@ -3,6 +3,7 @@ package pki
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -18,6 +19,7 @@ import (
 
 	"sigs.k8s.io/yaml"
 
+	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -37,15 +39,43 @@ const (
 	// is will be renewed.
 	RenewThreshold = "720h"
 
+	// defaultRenewThresholdValidityFraction defines, which fraction of the validity duration default
+	// RenewThreshold may take at most.
+	defaultRenewThresholdValidityFraction = 3
+
 	// X509CertificatePEMHeader is a PEM format header used while encoding X.509 certificates.
 	X509CertificatePEMHeader = "CERTIFICATE"
 
+	// CertificateRequestPEMHeader is a PEM format header used while encoding certificate signing requests.
+	CertificateRequestPEMHeader = "CERTIFICATE REQUEST"
+
 	// RSAPrivateKeyPEMHeader is a PEM format header user while encoding RSA private keys.
 	RSAPrivateKeyPEMHeader = "RSA PRIVATE KEY"
 
 	// RSAPublicKeyPEMHeader is a PEM format header user while encoding RSA public keys.
 	RSAPublicKeyPEMHeader = "RSA PUBLIC KEY"
 
+	// ECPrivateKeyPEMHeader is a PEM format header used while encoding ECDSA private keys.
+	ECPrivateKeyPEMHeader = "EC PRIVATE KEY"
+
+	// PrivateKeyPEMHeader is a PEM format header used while encoding private keys in PKCS8 format.
+	PrivateKeyPEMHeader = "PRIVATE KEY"
+
+	// PublicKeyPEMHeader is a PEM format header used while encoding non-RSA public keys.
+	PublicKeyPEMHeader = "PUBLIC KEY"
+
+	// KeyTypeRSA is a key type for RSA private keys. Length of the key is controlled by RSABits.
+	KeyTypeRSA = "rsa"
+
+	// KeyTypeECDSAP256 is a key type for ECDSA private keys using NIST P-256 curve.
+	KeyTypeECDSAP256 = "ecdsa-p256"
+
+	// KeyTypeECDSAP384 is a key type for ECDSA private keys using NIST P-384 curve.
+	KeyTypeECDSAP384 = "ecdsa-p384"
+
+	// KeyTypeEd25519 is a key type for Ed25519 private keys.
+	KeyTypeEd25519 = "ed25519"
+
 	// RootCACN is a default CN for root CA certificate.
 	RootCACN = "root-ca"
 )
@@ -91,6 +121,20 @@ type Certificate struct {
 	// Example value: '2048'.
 	RSABits int `json:"rsaBits,omitempty"`
 
+	// KeyType defines type of private key to generate. Valid values are:
+	// - "rsa"
+	// - "ecdsa-p256"
+	// - "ecdsa-p384"
+	// - "ed25519"
+	//
+	// If not set, RSA private key is generated and already generated private key of any
+	// type is kept. If set and existing private key has different type, both private key
+	// and X.509 certificate will be re-generated.
+	//
+	// Ed25519 keys can't be used for service account certificate, as Kubernetes does not
+	// support them for signing service account tokens.
+	KeyType string `json:"keyType,omitempty"`
+
 	// ValidityDuration defines how long generated certificates should be valid.
 	//
 	// Example value: '24h'.
@@ -139,10 +183,22 @@ type Certificate struct {
 	// X509Certificate stores generated certificate in X.509 certificate format, PEM encoded.
 	X509Certificate types.Certificate `json:"x509Certificate,omitempty"`
 
-	// PublicKey stores generate RSA public key, PEM encoded.
+	// X509CertificateChain stores X.509 certificates of intermediate CAs, which signed the certificate,
+	// PEM encoded, starting from the issuer. Self-signed root CA certificate is not included.
+	//
+	// It must be set for CA certificates signed by external CA through other intermediate CAs. For
+	// certificates signed by CA from the PKI, it is populated during generation.
+	X509CertificateChain types.Certificate `json:"x509CertificateChain,omitempty"`
+
+	// PreviousX509Certificate stores previous X.509 certificate of the CA certificate, PEM encoded,
+	// while CA rotation is in progress. It is included in the trust bundle, until rotation is finished.
+	PreviousX509Certificate types.Certificate `json:"previousX509Certificate,omitempty"`
+
+	// PublicKey stores generated public key, PEM encoded.
 	PublicKey string `json:"publicKey,omitempty"`
 
-	// PrivateKey stores generates RSA private key in PKCS1 format, PEM encoded.
+	// PrivateKey stores generated private key, PEM encoded. RSA keys are stored in PKCS1 format,
+	// ECDSA keys in SEC 1 format and Ed25519 keys in PKCS8 format.
 	PrivateKey types.PrivateKey `json:"privateKey,omitempty"`
 }
 
@@ -159,6 +215,9 @@ type PKI struct {
 
 	// Kubernetes contains configuration and generated all Kubernetes certificates and private keys.
 	Kubernetes *Kubernetes `json:"kubernetes,omitempty"`
+
+	// CARotation stores state of CA certificates rotation. It is set only while rotation is in progress.
+	CARotation *CARotation `json:"caRotation,omitempty"`
 }
 
 func serverUsage() []string {
@@ -223,7 +282,13 @@ func buildAndGenerate(crs ...*certificateRequest) error {
 			return fmt.Errorf("target certificate is not set")
 		}
 
+		chain, err := certificateChain(cert, certRequest.CA)
+		if err != nil {
+			return fmt.Errorf("building certificate chain: %w", err)
+		}
+
 		certRequest.Target.X509Certificate = cert.X509Certificate
+		certRequest.Target.X509CertificateChain = chain
 		certRequest.Target.PrivateKey = cert.PrivateKey
 		certRequest.Target.PublicKey = cert.PublicKey
 	}
@@ -236,6 +301,12 @@ func (p *PKI) generateRootCA() error {
 		p.RootCA = &Certificate{}
 	}
 
+	// Root CA certificate without private key is managed externally, for example by the offline
+	// root CA of the organization, so it can't be generated.
+	if p.RootCA.external() {
+		return nil
+	}
+
 	certRequest := &certificateRequest{
 		Target: p.RootCA,
 		Certificates: []*Certificate{
@@ -277,6 +348,134 @@ func (p *PKI) Generate() error {
 	return nil
 }
 
+// Certificates returns all generated certificates of the PKI, where key is a name of the certificate,
+// e.g. 'root-ca' or 'etcd/peer/controller01'. Certificates, which are not generated yet, are omitted.
+func (p *PKI) Certificates() map[string]*Certificate {
+	certificates := map[string]*Certificate{
+		"root-ca": p.RootCA,
+	}
+
+	if e := p.Etcd; e != nil {
+		certificates["etcd/ca"] = e.CA
+
+		for group, certs := range map[string]map[string]*Certificate{
+			"peer":   e.PeerCertificates,
+			"server": e.ServerCertificates,
+			"client": e.ClientCertificates,
+		} {
+			for name, cert := range certs {
+				certificates[fmt.Sprintf("etcd/%s/%s", group, name)] = cert
+			}
+		}
+	}
+
+	if k := p.Kubernetes; k != nil {
+		certificates["kubernetes/ca"] = k.CA
+		certificates["kubernetes/front-proxy-ca"] = k.FrontProxyCA
+		certificates["kubernetes/admin"] = k.AdminCertificate
+		certificates["kubernetes/kube-controller-manager"] = k.KubeControllerManagerCertificate
+		certificates["kubernetes/kube-scheduler"] = k.KubeSchedulerCertificate
+		certificates["kubernetes/service-account"] = k.ServiceAccountCertificate
+
+		if a := k.KubeAPIServer; a != nil {
+			certificates["kubernetes/kube-apiserver/server"] = a.ServerCertificate
+			certificates["kubernetes/kube-apiserver/kubelet-client"] = a.KubeletCertificate
+			certificates["kubernetes/kube-apiserver/front-proxy-client"] = a.FrontProxyClientCertificate
+		}
+	}
+
+	for name, cert := range certificates {
+		if cert == nil || cert.X509Certificate == "" {
+			delete(certificates, name)
+		}
+	}
+
+	return certificates
+}
+
+// namedCertificates is a list of certificates, which are merged to build configuration of
+// the named certificate.
+type namedCertificates struct {
+	name         string
+	certificates []*Certificate
+}
+
+// Validate validates PKI configuration without generating any certificates.
+//
+// Certificate settings are validated after merging them with inherited defaults, the same
+// way as they are merged during generation.
+func (p *PKI) Validate() error {
+	var errors util.ValidateErrors
+
+	certificates := []namedCertificates{
+		{"default certificate", []*Certificate{&p.Certificate}},
+		{"root CA certificate", []*Certificate{&p.Certificate, p.RootCA}},
+	}
+
+	if e := p.Etcd; e != nil {
+		certificates = append(certificates,
+			namedCertificates{"etcd default certificate", []*Certificate{&p.Certificate, &e.Certificate}},
+			namedCertificates{"etcd CA certificate", []*Certificate{&p.Certificate, &e.Certificate, e.CA}},
+		)
+	}
+
+	if k := p.Kubernetes; k != nil {
+		certificates = append(certificates,
+			namedCertificates{"Kubernetes default certificate", []*Certificate{&p.Certificate, &k.Certificate}},
+			namedCertificates{"Kubernetes CA certificate", []*Certificate{&p.Certificate, &k.Certificate, k.CA}},
+			namedCertificates{
+				"Kubernetes front proxy CA certificate",
+				[]*Certificate{&p.Certificate, &k.Certificate, k.FrontProxyCA},
+			},
+		)
+
+		certificates = append(certificates, namedCertificates{
+			"Kubernetes service account certificate",
+			[]*Certificate{&p.Certificate, &k.Certificate, k.ServiceAccountCertificate},
+		})
+
+		if err := k.validateServiceAccountKeyType(p.Certificate); err != nil {
+			errors = append(errors, err)
+		}
+
+		if a := k.KubeAPIServer; a != nil {
+			certificates = append(certificates, namedCertificates{
+				"kube-apiserver default certificate",
+				[]*Certificate{&p.Certificate, &k.Certificate, &a.Certificate},
+			})
+
+			for _, i := range a.ServerIPs {
+				if ip := net.ParseIP(i); ip == nil {
+					errors = append(errors, fmt.Errorf("parsing kube-apiserver server IP address %q", i))
+				}
+			}
+		}
+	}
+
+	errors = append(errors, p.validateCAChains()...)
+
+	if p.CARotation != nil {
+		if err := p.CARotation.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating CA rotation: %w", err))
+		}
+	}
+
+	for _, c := range certificates {
+		cert, err := buildCertificate(c.certificates...)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("building %s configuration: %w", c.name, err))
+
+			continue
+		}
+
+		if err := cert.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating %s: %w", c.name, err))
+		}
+	}
+
+	return errors.Return()
+}
+
 // buildCertificate merges N number of given certificates. Properties of last given certificate takes
 // precedence over previous ones.
 func buildCertificate(certs ...*Certificate) (*Certificate, error) {
@@ -284,7 +483,6 @@ func buildCertificate(certs ...*Certificate) (*Certificate, error) {
 		Organization:     Organization,
 		RSABits:          RSABits,
 		ValidityDuration: ValidityDuration,
-		RenewThreshold:   RenewThreshold,
 	}
 
 	for _, c := range certs {
@@ -298,18 +496,42 @@ func buildCertificate(certs ...*Certificate) (*Certificate, error) {
 		}
 	}
 
+	if cert.RenewThreshold == "" {
+		cert.RenewThreshold = defaultRenewThreshold(cert.ValidityDuration)
+	}
+
 	return cert, nil
 }
 
-func (c *Certificate) decodePrivateKey() (*rsa.PrivateKey, error) {
+// defaultRenewThreshold returns renew threshold for certificates, which do not have it set explicitly.
+// For certificates with short validity duration, default RenewThreshold is capped at third of the
+// validity duration, so they are not renewed on every generation.
+func defaultRenewThreshold(validityDuration string) string {
+	validity, err := time.ParseDuration(validityDuration)
+	if err != nil {
+		// Invalid validity duration is reported by Validate().
+		return RenewThreshold
+	}
+
+	//nolint:errcheck // Constant is always valid.
+	renewThreshold, _ := time.ParseDuration(RenewThreshold)
+
+	if maxRenewThreshold := validity / defaultRenewThresholdValidityFraction; renewThreshold > maxRenewThreshold {
+		return maxRenewThreshold.String()
+	}
+
+	return RenewThreshold
+}
+
+func (c *Certificate) decodePrivateKey() (crypto.Signer, error) {
 	der, _ := pem.Decode([]byte(c.PrivateKey))
 	if der == nil {
 		return nil, fmt.Errorf("private key is not defined in valid PEM format")
 	}
 
-	k, err := x509.ParsePKCS1PrivateKey(der.Bytes)
+	k, err := parseSigner(der.Bytes)
 	if err != nil {
-		return nil, fmt.Errorf("parsing private key to PKCS1 format: %w", err)
+		return nil, fmt.Errorf("parsing private key: %w", err)
 	}
 
 	return k, nil
@@ -331,17 +553,24 @@ func (c *Certificate) DecodeX509Certificate() (*x509.Certificate, error) {
 	return cert, nil
 }
 
-// persistPublicKey persist given RSA public key into the certificate object.
-func (c *Certificate) persistPublicKey(k interface{}) error {
+// persistPublicKey persist given public key into the certificate object.
+func (c *Certificate) persistPublicKey(k crypto.PublicKey) error {
 	pubBytes, err := x509.MarshalPKIXPublicKey(k)
 	if err != nil {
-		return fmt.Errorf("marshaling RSA public key: %w", err)
+		return fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	header := PublicKeyPEMHeader
+
+	// Keep the header used for RSA public keys before other key types were supported.
+	if _, ok := k.(*rsa.PublicKey); ok {
+		header = RSAPublicKeyPEMHeader
 	}
 
 	var buf bytes.Buffer
 
-	if err := pem.Encode(&buf, &pem.Block{Type: RSAPublicKeyPEMHeader, Bytes: pubBytes}); err != nil {
-		return fmt.Errorf("encoding RSA public key: %w", err)
+	if err := pem.Encode(&buf, &pem.Block{Type: header, Bytes: pubBytes}); err != nil {
+		return fmt.Errorf("encoding public key: %w", err)
 	}
 
 	c.PublicKey = buf.String()
@@ -349,51 +578,79 @@ func (c *Certificate) persistPublicKey(k interface{}) error {
 	return nil
 }
 
-func (c *Certificate) generatePrivateKey() (*rsa.PrivateKey, error) {
-	// generate RSA private key.
-	privateKey, err := rsa.GenerateKey(rand.Reader, c.RSABits)
+func (c *Certificate) generatePrivateKey() (crypto.Signer, error) {
+	privateKey, block, err := generateSigner(c.KeyType, c.RSABits)
 	if err != nil {
-		return nil, fmt.Errorf("generating RSA key: %w", err)
+		return nil, fmt.Errorf("generating private key: %w", err)
 	}
 
-	privBytes := x509.MarshalPKCS1PrivateKey(privateKey)
-
 	var buf bytes.Buffer
-	if err := pem.Encode(&buf, &pem.Block{Type: RSAPrivateKeyPEMHeader, Bytes: privBytes}); err != nil {
-		return nil, fmt.Errorf("encoding RSA private key: %w", err)
+	if err := pem.Encode(&buf, block); err != nil {
+		return nil, fmt.Errorf("encoding private key: %w", err)
 	}
 
 	c.PrivateKey = types.PrivateKey(buf.String())
 
 	if err := c.persistPublicKey(privateKey.Public()); err != nil {
-		return nil, fmt.Errorf("persisting RSA public key: %w", err)
+		return nil, fmt.Errorf("persisting public key: %w", err)
 	}
 
 	return privateKey, nil
 }
 
-func (c *Certificate) getPrivateKey() (*rsa.PrivateKey, error) {
-	if c.PrivateKey != "" {
-		return c.decodePrivateKey()
+// getPrivateKey returns existing private key or generates new one, if there is no private key
+// or if existing private key has different type than configured.
+func (c *Certificate) getPrivateKey() (crypto.Signer, error) {
+	if c.PrivateKey == "" {
+		return c.generatePrivateKey()
 	}
 
-	return c.generatePrivateKey()
+	privateKey, err := c.decodePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.KeyType != "" && keyType(privateKey.Public()) != c.KeyType {
+		return c.generatePrivateKey()
+	}
+
+	return privateKey, nil
 }
 
 // Validate validates the certificate configuration.
 func (c *Certificate) Validate() error {
-	if _, err := time.ParseDuration(c.ValidityDuration); err != nil {
+	validityDuration, err := time.ParseDuration(c.ValidityDuration)
+	if err != nil {
 		return fmt.Errorf("parsing validity duration %q for certificate: %w", c.ValidityDuration, err)
 	}
 
+	if c.RenewThreshold != "" {
+		renewThreshold, err := time.ParseDuration(c.RenewThreshold)
+		if err != nil {
+			return fmt.Errorf("parsing renew threshold %q for certificate: %w", c.RenewThreshold, err)
+		}
+
+		// Otherwise certificate would be renewed on every generation.
+		if renewThreshold >= validityDuration {
+			return fmt.Errorf("renew threshold %q must be shorter than validity duration %q",
+				c.RenewThreshold, c.ValidityDuration)
+		}
+	}
+
 	for _, i := range c.IPAddresses {
 		if ip := net.ParseIP(i); ip == nil {
 			return fmt.Errorf("parsing IP address %q", i)
 		}
 	}
 
-	if c.RSABits == 0 {
-		return fmt.Errorf("RSA bits can't be 0")
+	switch c.KeyType {
+	case "", KeyTypeRSA:
+		if c.RSABits == 0 {
+			return fmt.Errorf("RSA bits can't be 0")
+		}
+	case KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeEd25519:
+	default:
+		return fmt.Errorf("unsupported key type %q", c.KeyType)
 	}
 
 	return nil
@@ -419,7 +676,7 @@ func (c *Certificate) decodeKeyUsage() (x509.KeyUsage, []x509.ExtKeyUsage) {
 	return x509.KeyUsage(keyUsage), extendedKeyUsage
 }
 
-func (c *Certificate) generateX509Certificate(certPK *rsa.PrivateKey, caCert *Certificate) error {
+func (c *Certificate) generateX509Certificate(certPK crypto.Signer, caCert *Certificate) error {
 	var serialNumberLimitBase uint = 128
 
 	// Generate serial number for X.509 certificate.
@@ -436,6 +693,11 @@ func (c *Certificate) generateX509Certificate(certPK *rsa.PrivateKey, caCert *Ce
 
 	keyUsage, extendedKeyUsage := c.decodeKeyUsage()
 
+	// Key encipherment is only valid for RSA keys, as other key types can't be used for encryption.
+	if _, ok := certPK.Public().(*rsa.PublicKey); !ok {
+		keyUsage &^= x509.KeyUsageKeyEncipherment
+	}
+
 	cert := x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
@@ -459,6 +721,11 @@ func (c *Certificate) generateX509Certificate(certPK *rsa.PrivateKey, caCert *Ce
 	caPK := certPK
 	x509CACert := &cert
 
+	if caCert != nil && caCert.external() {
+		return fmt.Errorf("CA private key is not available, certificate must be signed externally using " +
+			"certificate signing request")
+	}
+
 	if caCert != nil {
 		x509CACert, caPK, err = caCert.decodeKeypair()
 		if err != nil {
@@ -466,7 +733,7 @@ func (c *Certificate) generateX509Certificate(certPK *rsa.PrivateKey, caCert *Ce
 		}
 	}
 
-	subjectKeyID, err := bigIntHash(certPK.N)
+	subjectKeyID, err := subjectKeyID(certPK.Public())
 	if err != nil {
 		return fmt.Errorf("generating certificate subject Key ID: %w", err)
 	}
@@ -476,8 +743,8 @@ func (c *Certificate) generateX509Certificate(certPK *rsa.PrivateKey, caCert *Ce
 	return c.createAndPersist(&cert, x509CACert, certPK, caPK)
 }
 
-func (c *Certificate) createAndPersist(cert, caCert *x509.Certificate, certPK, caPK *rsa.PrivateKey) error {
-	der, err := x509.CreateCertificate(rand.Reader, cert, caCert, &certPK.PublicKey, caPK)
+func (c *Certificate) createAndPersist(cert, caCert *x509.Certificate, certPK, caPK crypto.Signer) error {
+	der, err := x509.CreateCertificate(rand.Reader, cert, caCert, certPK.Public(), caPK)
 	if err != nil {
 		return fmt.Errorf("creating certificate: %w", err)
 	}
@@ -496,8 +763,25 @@ func bigIntHash(n *big.Int) ([]byte, error) {
 	return hash.Sum(nil), nil
 }
 
+// subjectKeyID returns subject key ID for given public key. For RSA keys, hash of the modulus
+// is used, for other keys hash of public key in PKIX format.
+func subjectKeyID(publicKey crypto.PublicKey) ([]byte, error) {
+	if rsaPublicKey, ok := publicKey.(*rsa.PublicKey); ok {
+		return bigIntHash(rsaPublicKey.N)
+	}
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	hash := sha256.Sum256(pubBytes)
+
+	return hash[:], nil
+}
+
 // decodeKeypair decodes both X.509 certificate and private key.
-func (c *Certificate) decodeKeypair() (*x509.Certificate, *rsa.PrivateKey, error) {
+func (c *Certificate) decodeKeypair() (*x509.Certificate, crypto.Signer, error) {
 	privateKey, err := c.decodePrivateKey()
 	if err != nil {
 		return nil, nil, fmt.Errorf("decoding private key: %w", err)
@@ -528,19 +812,20 @@ func (c *Certificate) persistX509Certificate(der []byte) error {
 //
 // This function currently supports:
 //
-// - Generating new RSA private key and public key.
+// - Generating new RSA, ECDSA or Ed25519 private key and public key.
 //
 // - Generating new X.509 certificates.
 //
 // - Re-generating X.509 certificate if IP addresses changes.
 //
-// NOT implemented functionality:
+// - Re-generating private key and X.509 certificate if key type changes.
 //
-// - Renewing certificates based on expiry time.
+// - Re-generating X.509 certificate if it's not signed by given CA, e.g. when CA private key changes.
 //
-// - Renewing X.509 certificate after RSA private key renewal.
+// - Renewing X.509 certificate, which expires within the renew threshold. Private key is kept.
 //
-// - Renewing issued certificate during CA renewal.
+// Certificates signed by CA, which private key is not available, can't be generated. They must be
+// signed externally, see PKI.CertificateSigningRequests.
 func (c *Certificate) Generate(caCert *Certificate) error {
 	if err := c.Validate(); err != nil {
 		return fmt.Errorf("validating the certificate: %w", err)
@@ -556,12 +841,30 @@ func (c *Certificate) Generate(caCert *Certificate) error {
 
 // ensureX509Certificate checks if the certificate is up to date and if not, triggers
 // certificate generation.
-func (c *Certificate) ensureX509Certificate(privateKey *rsa.PrivateKey, caCert *Certificate) error {
+func (c *Certificate) ensureX509Certificate(privateKey crypto.Signer, caCert *Certificate) error {
 	upToDate, err := c.IsX509CertificateUpToDate()
 	if err != nil {
 		return fmt.Errorf("checking if X.509 certificate is up to date: %w", err)
 	}
 
+	if upToDate {
+		// Private key might have been re-generated, so the certificate must be issued again.
+		upToDate, err = c.x509CertificateMatchesKey(privateKey)
+		if err != nil {
+			return fmt.Errorf("checking if X.509 certificate matches private key: %w", err)
+		}
+	}
+
+	// Private key of the CA might have been re-generated as well, e.g. when it's key type changes,
+	// so certificates signed by it must be issued again. Certificates signed by external CA can't be
+	// issued again anyway.
+	if upToDate && caCert != nil && !caCert.external() {
+		upToDate, err = c.x509CertificateSignedBy(caCert)
+		if err != nil {
+			return fmt.Errorf("checking if X.509 certificate is signed by CA: %w", err)
+		}
+	}
+
 	if !upToDate {
 		return c.generateX509Certificate(privateKey, caCert)
 	}
@@ -584,7 +887,7 @@ func ipAddressesUpToDate(cert *x509.Certificate, configuredIPs []string) bool {
 }
 
 // IsX509CertificateUpToDate checks, if generated X.509 certificate is up to date
-// with it's configuration.
+// with it's configuration and if it does not expire within the renew threshold.
 func (c *Certificate) IsX509CertificateUpToDate() (bool, error) {
 	if c.X509Certificate == "" {
 		return false, nil
@@ -599,5 +902,72 @@ func (c *Certificate) IsX509CertificateUpToDate() (bool, error) {
 		return false, nil
 	}
 
-	return true, nil
+	if c.KeyType != "" && keyType(cert.PublicKey) != c.KeyType {
+		return false, nil
+	}
+
+	expiring, err := c.expiresWithinRenewThreshold(cert)
+	if err != nil {
+		return true, fmt.Errorf("checking certificate expiry: %w", err)
+	}
+
+	return !expiring, nil
+}
+
+// expiresWithinRenewThreshold checks, if given certificate expires within configured renew
+// threshold. If renew threshold is not set, certificate is never considered expiring.
+func (c *Certificate) expiresWithinRenewThreshold(cert *x509.Certificate) (bool, error) {
+	if c.RenewThreshold == "" {
+		return false, nil
+	}
+
+	renewThreshold, err := time.ParseDuration(c.RenewThreshold)
+	if err != nil {
+		return false, fmt.Errorf("parsing renew threshold %q: %w", c.RenewThreshold, err)
+	}
+
+	return time.Until(cert.NotAfter) < renewThreshold, nil
+}
+
+// x509CertificateSignedBy checks, if generated X.509 certificate has been signed by given CA
+// certificate. While CA rotation is in progress, certificates signed by previous CA certificate
+// are also accepted.
+func (c *Certificate) x509CertificateSignedBy(caCert *Certificate) (bool, error) {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("decoding X.509 certificate: %w", err)
+	}
+
+	for _, caX509Certificate := range []types.Certificate{caCert.X509Certificate, caCert.PreviousX509Certificate} {
+		if caX509Certificate == "" {
+			continue
+		}
+
+		x509CACert, err := (&Certificate{X509Certificate: caX509Certificate}).DecodeX509Certificate()
+		if err != nil {
+			return false, fmt.Errorf("decoding CA X.509 certificate: %w", err)
+		}
+
+		if cert.CheckSignatureFrom(x509CACert) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// x509CertificateMatchesKey checks, if generated X.509 certificate has been issued for given
+// private key.
+func (c *Certificate) x509CertificateMatchesKey(privateKey crypto.Signer) (bool, error) {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("decoding X.509 certificate: %w", err)
+	}
+
+	publicKey, ok := privateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false, fmt.Errorf("unsupported public key type %T", privateKey.Public())
+	}
+
+	return publicKey.Equal(cert.PublicKey), nil
 }
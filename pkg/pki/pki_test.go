@@ -1,12 +1,18 @@
 package pki_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/pki"
 )
 
@@ -51,13 +57,14 @@ func TestGenerateDontCopyAllSettings(t *testing.T) {
 	}
 
 	cert := &pki.Certificate{
-		X509Certificate: pkii.Kubernetes.KubeAPIServer.ServerCertificate.X509Certificate,
-		PrivateKey:      pkii.Kubernetes.KubeAPIServer.ServerCertificate.PrivateKey,
-		PublicKey:       pkii.Kubernetes.KubeAPIServer.ServerCertificate.PublicKey,
+		X509Certificate:      pkii.Kubernetes.KubeAPIServer.ServerCertificate.X509Certificate,
+		X509CertificateChain: pkii.Kubernetes.KubeAPIServer.ServerCertificate.X509CertificateChain,
+		PrivateKey:           pkii.Kubernetes.KubeAPIServer.ServerCertificate.PrivateKey,
+		PublicKey:            pkii.Kubernetes.KubeAPIServer.ServerCertificate.PublicKey,
 	}
 
 	if diff := cmp.Diff(pkii.Kubernetes.KubeAPIServer.ServerCertificate, cert); diff != "" {
-		t.Fatalf("Generated certificate should only have X.509 certificate and private key field populated, got: %v", diff)
+		t.Fatalf("Generated certificate should only have X.509 certificate, chain and keys populated, got: %v", diff)
 	}
 }
 
@@ -335,6 +342,384 @@ func TestGenerateDontRecreate(t *testing.T) {
 	}
 }
 
+func TestGenerateKeyTypes(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]func(interface{}) bool{
+		pki.KeyTypeRSA: func(k interface{}) bool {
+			_, ok := k.(*rsa.PublicKey)
+
+			return ok
+		},
+		pki.KeyTypeECDSAP256: func(k interface{}) bool {
+			ecdsaKey, ok := k.(*ecdsa.PublicKey)
+
+			return ok && ecdsaKey.Curve == elliptic.P256()
+		},
+		pki.KeyTypeECDSAP384: func(k interface{}) bool {
+			ecdsaKey, ok := k.(*ecdsa.PublicKey)
+
+			return ok && ecdsaKey.Curve == elliptic.P384()
+		},
+		pki.KeyTypeEd25519: func(k interface{}) bool {
+			_, ok := k.(ed25519.PublicKey)
+
+			return ok
+		},
+	}
+
+	for keyType, isExpectedKey := range cases {
+		keyType, isExpectedKey := keyType, isExpectedKey
+
+		t.Run(keyType, func(t *testing.T) {
+			t.Parallel()
+
+			p := &pki.PKI{
+				Certificate: pki.Certificate{
+					KeyType: keyType,
+				},
+				Etcd: &pki.Etcd{
+					Servers: map[string]string{
+						"controller01": "192.168.1.10",
+					},
+				},
+			}
+
+			if err := p.Generate(); err != nil {
+				t.Fatalf("Generating PKI should succeed, got: %v", err)
+			}
+
+			serverCertificate := p.Etcd.ServerCertificates["controller01"]
+
+			cert, err := serverCertificate.DecodeX509Certificate()
+			if err != nil {
+				t.Fatalf("Decoding generated certificate should succeed, got: %v", err)
+			}
+
+			if !isExpectedKey(cert.PublicKey) {
+				t.Fatalf("Unexpected public key type %T in generated certificate", cert.PublicKey)
+			}
+
+			if err := pki.ValidatePrivateKey(string(serverCertificate.PrivateKey)); err != nil {
+				t.Fatalf("Generated private key should be valid, got: %v", err)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AppendCertsFromPEM([]byte(p.Etcd.CA.X509Certificate))
+
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+				t.Fatalf("Generated certificate should be signed by etcd CA, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyTypeChange(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	privateKey := p.RootCA.PrivateKey
+	cert := p.RootCA.X509Certificate
+
+	p.Certificate.KeyType = pki.KeyTypeECDSAP256
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Re-generating PKI certificates should succeed, got: %v", err)
+	}
+
+	if privateKey == p.RootCA.PrivateKey {
+		t.Fatalf("Private key should be re-generated when key type changes")
+	}
+
+	if cert == p.RootCA.X509Certificate {
+		t.Fatalf("Certificate should be re-generated when key type changes")
+	}
+
+	upToDate, err := p.RootCA.IsX509CertificateUpToDate()
+	if err != nil {
+		t.Fatalf("Checking if certificate is up to date should succeed, got: %v", err)
+	}
+
+	if !upToDate {
+		t.Fatalf("Re-generated certificate should be up to date")
+	}
+}
+
+func TestGenerateKeepExistingKeyWithoutKeyType(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Certificate: pki.Certificate{
+			KeyType: pki.KeyTypeECDSAP384,
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	cert := p.RootCA.X509Certificate
+
+	p.Certificate.KeyType = ""
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Re-generating PKI certificates should succeed, got: %v", err)
+	}
+
+	if cert != p.RootCA.X509Certificate {
+		t.Fatalf("Without key type set, existing keys and certificates should be kept")
+	}
+}
+
+// verifyIssuedBy checks, that given certificate is signed by given CA certificate.
+func verifyIssuedBy(t *testing.T, name string, cert, caCert *pki.Certificate) {
+	t.Helper()
+
+	x509Cert, err := cert.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding %q certificate should succeed, got: %v", name, err)
+	}
+
+	x509CACert, err := caCert.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding CA certificate of %q should succeed, got: %v", name, err)
+	}
+
+	if err := x509Cert.CheckSignatureFrom(x509CACert); err != nil {
+		t.Fatalf("Certificate %q should be signed by it's CA, got: %v", name, err)
+	}
+}
+
+func TestGenerateRootCAKeyTypeChangeReissuesCertificates(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Etcd: &pki.Etcd{
+			Servers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	p.RootCA.KeyType = pki.KeyTypeECDSAP256
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Re-generating PKI with different root CA key type should succeed, got: %v", err)
+	}
+
+	verifyIssuedBy(t, "root-ca", p.RootCA, p.RootCA)
+	verifyIssuedBy(t, "etcd/ca", p.Etcd.CA, p.RootCA)
+	verifyIssuedBy(t, "etcd/server/controller01", p.Etcd.ServerCertificates["controller01"], p.Etcd.CA)
+	verifyIssuedBy(t, "kubernetes/ca", p.Kubernetes.CA, p.RootCA)
+	verifyIssuedBy(t, "kubernetes/front-proxy-ca", p.Kubernetes.FrontProxyCA, p.RootCA)
+	verifyIssuedBy(t, "kubernetes/admin", p.Kubernetes.AdminCertificate, p.Kubernetes.CA)
+	verifyIssuedBy(t, "kubernetes/kube-apiserver/server", p.Kubernetes.KubeAPIServer.ServerCertificate,
+		p.Kubernetes.CA)
+}
+
+func TestGenerateIntermediateCAKeyTypeChangeReissuesCertificates(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	p.Kubernetes.CA.KeyType = pki.KeyTypeEd25519
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Re-generating PKI with different CA key type should succeed, got: %v", err)
+	}
+
+	verifyIssuedBy(t, "kubernetes/ca", p.Kubernetes.CA, p.RootCA)
+	verifyIssuedBy(t, "kubernetes/admin", p.Kubernetes.AdminCertificate, p.Kubernetes.CA)
+	verifyIssuedBy(t, "kubernetes/kube-controller-manager", p.Kubernetes.KubeControllerManagerCertificate,
+		p.Kubernetes.CA)
+	verifyIssuedBy(t, "kubernetes/kube-apiserver/kubelet-client", p.Kubernetes.KubeAPIServer.KubeletCertificate,
+		p.Kubernetes.CA)
+}
+
+func TestValidateBadKeyType(t *testing.T) {
+	t.Parallel()
+
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		KeyType:          "dsa",
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Certificate with unsupported key type should be invalid")
+	}
+}
+
+func TestValidateNonRSAKeyTypeIgnoresRSABits(t *testing.T) {
+	t.Parallel()
+
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		KeyType:          pki.KeyTypeEd25519,
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Certificate with non-RSA key type should not require RSA bits, got: %v", err)
+	}
+}
+
+func TestGenerateRenewExpiring(t *testing.T) {
+	t.Parallel()
+
+	c := &pki.Certificate{
+		ValidityDuration: "1h",
+		RSABits:          2048,
+	}
+
+	if err := c.Generate(nil); err != nil {
+		t.Fatalf("Generating certificate should succeed, got: %v", err)
+	}
+
+	privateKey := c.PrivateKey
+	cert := c.X509Certificate
+
+	// Certificate now expires within the renew threshold.
+	c.ValidityDuration = "24h"
+	c.RenewThreshold = "2h"
+
+	if err := c.Generate(nil); err != nil {
+		t.Fatalf("Renewing certificate should succeed, got: %v", err)
+	}
+
+	if cert == c.X509Certificate {
+		t.Fatalf("Certificate expiring within renew threshold should be renewed")
+	}
+
+	if privateKey != c.PrivateKey {
+		t.Fatalf("Private key should be kept when renewing the certificate")
+	}
+
+	c.RenewThreshold = "1h"
+	cert = c.X509Certificate
+
+	if err := c.Generate(nil); err != nil {
+		t.Fatalf("Generating certificate again should succeed, got: %v", err)
+	}
+
+	if cert != c.X509Certificate {
+		t.Fatalf("Certificate not expiring within renew threshold should not be renewed")
+	}
+}
+
+func TestValidateRenewThreshold(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"unparsable":            "doh",
+		"longer than validity":  "48h",
+		"equal to the validity": "24h",
+	}
+
+	for name, renewThreshold := range cases {
+		renewThreshold := renewThreshold
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &pki.Certificate{
+				ValidityDuration: "24h",
+				RenewThreshold:   renewThreshold,
+				RSABits:          2048,
+			}
+
+			if err := c.Validate(); err == nil {
+				t.Fatalf("Certificate with renew threshold %q should be invalid", renewThreshold)
+			}
+		})
+	}
+}
+
+func TestGenerateShortValidityDefaultRenewThreshold(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Certificate: pki.Certificate{
+			ValidityDuration: "168h",
+		},
+		Etcd: &pki.Etcd{
+			Peers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+		},
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Short validity duration without renew threshold should be valid, got: %v", err)
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with short validity duration should work, got: %v", err)
+	}
+
+	cert := p.Etcd.CA.X509Certificate
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI again should work, got: %v", err)
+	}
+
+	if cert != p.Etcd.CA.X509Certificate {
+		t.Fatalf("Fresh certificate with short validity duration should not be renewed")
+	}
+}
+
+// PKI.Certificates() tests.
+func TestPKICertificates(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Etcd: &pki.Etcd{
+			Peers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if len(p.Certificates()) != 0 {
+		t.Fatalf("Certificates which are not generated should be omitted")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating valid PKI should work, got: %v", err)
+	}
+
+	certificates := p.Certificates()
+
+	for _, name := range []string{
+		"root-ca",
+		"etcd/ca",
+		"etcd/peer/controller01",
+		"etcd/server/controller01",
+		"kubernetes/ca",
+		"kubernetes/kube-apiserver/server",
+		"kubernetes/service-account",
+	} {
+		if _, ok := certificates[name]; !ok {
+			t.Fatalf("Certificate %q should be returned, got: %v", name, certificates)
+		}
+	}
+}
+
 func TestIsX509CertificateUpToDateBadCert(t *testing.T) {
 	t.Parallel()
 
@@ -346,3 +731,76 @@ func TestIsX509CertificateUpToDateBadCert(t *testing.T) {
 		t.Fatalf("Checking if certificate is up to date should fail on bad certificate")
 	}
 }
+
+// PKI.Validate() tests.
+func TestPKIValidate(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Etcd: &pki.Etcd{},
+		Kubernetes: &pki.Kubernetes{
+			KubeAPIServer: &pki.KubeAPIServer{
+				ServerIPs: []string{"1.1.1.1"},
+			},
+		},
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validating PKI with default settings should succeed, got: %v", err)
+	}
+}
+
+func TestPKIValidateServiceAccountEd25519(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Certificate: pki.Certificate{
+				KeyType: pki.KeyTypeEd25519,
+			},
+		},
+	}
+
+	if err := p.Validate(); err == nil {
+		t.Fatalf("Validating PKI with Ed25519 service account certificate should fail")
+	}
+
+	p.Kubernetes.ServiceAccountCertificate = &pki.Certificate{
+		KeyType: pki.KeyTypeECDSAP256,
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validating PKI with ECDSA service account certificate should succeed, got: %v", err)
+	}
+}
+
+func TestPKIValidateBad(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			ValidityDuration: "foo",
+		},
+		Etcd: &pki.Etcd{
+			CA: &pki.Certificate{
+				IPAddresses: []string{"bar"},
+			},
+		},
+		Kubernetes: &pki.Kubernetes{
+			KubeAPIServer: &pki.KubeAPIServer{
+				ServerIPs: []string{"baz"},
+			},
+		},
+	}
+
+	err := p.Validate()
+	if err == nil {
+		t.Fatalf("Validating PKI with bad settings should fail")
+	}
+
+	var validateErrors util.ValidateErrors
+
+	if !errors.As(err, &validateErrors) || len(validateErrors) != 3 {
+		t.Fatalf("All errors should be reported, got: %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package pki
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -38,3 +44,92 @@ func parsePrivateKey(rawPrivateKey []byte) error {
 
 	return fmt.Errorf("unsupported private key format, tried PKCS8, PKCS1 and EC formats")
 }
+
+// parseSigner parses given private key in PKCS1, EC or PKCS8 format into a key,
+// which can be used for signing certificates.
+func parseSigner(rawPrivateKey []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS1PrivateKey(rawPrivateKey); err == nil {
+		return k, nil
+	}
+
+	if k, err := x509.ParseECPrivateKey(rawPrivateKey); err == nil {
+		return k, nil
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(rawPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key format, tried PKCS1, EC and PKCS8 formats")
+	}
+
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", k)
+	}
+
+	return signer, nil
+}
+
+// generateSigner generates new private key of given type and returns it together
+// with PEM block containing encoded key.
+func generateSigner(keyType string, rsaBits int) (crypto.Signer, *pem.Block, error) {
+	switch keyType {
+	case "", KeyTypeRSA:
+		k, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating RSA key: %w", err)
+		}
+
+		return k, &pem.Block{Type: RSAPrivateKeyPEMHeader, Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
+	case KeyTypeECDSAP256, KeyTypeECDSAP384:
+		curve := elliptic.P256()
+		if keyType == KeyTypeECDSAP384 {
+			curve = elliptic.P384()
+		}
+
+		k, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating ECDSA key: %w", err)
+		}
+
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshaling ECDSA key: %w", err)
+		}
+
+		return k, &pem.Block{Type: ECPrivateKeyPEMHeader, Bytes: der}, nil
+	case KeyTypeEd25519:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating Ed25519 key: %w", err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshaling Ed25519 key: %w", err)
+		}
+
+		return k, &pem.Block{Type: PrivateKeyPEMHeader, Bytes: der}, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// keyType returns key type of given public key. If key type is not supported,
+// empty string is returned.
+func keyType(publicKey crypto.PublicKey) string {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return KeyTypeRSA
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return KeyTypeECDSAP256
+		case elliptic.P384():
+			return KeyTypeECDSAP384
+		}
+	case ed25519.PublicKey:
+		return KeyTypeEd25519
+	}
+
+	return ""
+}
@@ -38,6 +38,10 @@ aHR0cHM6Ly93d3cueW91dHViZS5jb20vd2F0Y2g/dj1kUXc0dzlXZ1hjUQo=
 			utiltest.GenerateRSAPrivateKey(t),
 			false,
 		},
+		"ed25519": {
+			utiltest.GenerateEd25519PrivateKey(t),
+			false,
+		},
 	}
 
 	for n, testCase := range cases {
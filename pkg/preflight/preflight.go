@@ -0,0 +1,619 @@
+// Package preflight allows to check, if hosts meet the requirements of the resource before
+// deploying it, so problems like unreachable container runtime, mismatched cgroup driver or
+// ports already in use are reported upfront, instead of in the middle of the deployment.
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/versions"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+const (
+	// StatusPass means, that host meets the requirement.
+	StatusPass Status = "pass"
+
+	// StatusWarn means, that requirement could not be verified or host is close to not meeting it.
+	StatusWarn Status = "warn"
+
+	// StatusFail means, that host does not meet the requirement and deployment will most likely fail.
+	StatusFail Status = "fail"
+
+	// DiskSpaceWarnThreshold is amount of free disk space in bytes in container runtime root
+	// directory, below which warning is reported.
+	DiskSpaceWarnThreshold = 2 << 30
+
+	// DiskSpaceFailThreshold is amount of free disk space in bytes in container runtime root
+	// directory, below which check fails.
+	DiskSpaceFailThreshold = 512 << 20
+
+	// CheckConnection is a name of the check verifying, that host is reachable.
+	CheckConnection = "connection"
+
+	// CheckRuntime is a name of the check verifying, that container runtime is reachable and compatible.
+	CheckRuntime = "runtime"
+
+	// CheckCgroupDriver is a name of the check verifying cgroup driver used by container runtime.
+	CheckCgroupDriver = "cgroup driver"
+
+	// CheckPorts is a name of the check verifying, that ports required by new containers are available.
+	CheckPorts = "ports"
+
+	// CheckDiskSpace is a name of the check verifying free disk space for container images.
+	CheckDiskSpace = "disk space"
+
+	// listeningPortsCommand lists TCP and UDP sockets listening on the host.
+	listeningPortsCommand = "ss -Htuln"
+
+	// defaultRootDir is a directory, where free disk space is checked, if container runtime
+	// does not report its root directory.
+	defaultRootDir = "/"
+)
+
+// Status is a result status of a single check.
+type Status string
+
+// Requirements describes, what container requires from the host, which can't be determined
+// from the container configuration.
+type Requirements struct {
+	// Ports is a list of ports, which container listens on using host network.
+	Ports []int
+
+	// CgroupDriver is a cgroup driver, which container runtime must use, e.g. 'systemd'.
+	CgroupDriver string
+}
+
+// Requirer is implemented by resources, which containers have requirements, which can't be
+// determined from the container configuration, e.g. ports opened by containers using host network.
+type Requirer interface {
+	// PreflightRequirements returns requirements of the containers, indexed by container name.
+	PreflightRequirements() map[string]Requirements
+}
+
+// Result is a result of a single check.
+type Result struct {
+	// Check is a name of the check.
+	Check string `json:"check"`
+
+	// Status is a result status of the check.
+	Status Status `json:"status"`
+
+	// Message describes the result.
+	Message string `json:"message"`
+}
+
+// HostReport holds results of all checks executed on a single host.
+type HostReport struct {
+	// Host identifies checked host, e.g. its SSH address.
+	Host string `json:"host"`
+
+	// Results holds results of all checks executed on the host.
+	Results []Result `json:"results"`
+}
+
+// Report holds results of pre-flight checks of all hosts used by the resource.
+type Report struct {
+	// Hosts holds check results for each host.
+	Hosts []HostReport `json:"hosts"`
+}
+
+// target groups containers, which will be deployed on the same host using the same container runtime.
+type target struct {
+	// name identifies the host in the report.
+	name string
+
+	// host is a configuration of the host.
+	host host.Host
+
+	// runtimeConfig is a configuration of the container runtime used by the containers.
+	runtimeConfig runtime.Config
+
+	// ports maps ports required by new containers to container names.
+	ports map[int]string
+
+	// cgroupDrivers maps cgroup drivers required by the containers to container names.
+	cgroupDrivers map[string]string
+}
+
+// Check runs pre-flight checks on all hosts, where containers of given resource will be deployed.
+//
+// CheckCurrentState() must be called on the resource before, so ports required by already existing
+// containers are not reported as used.
+func Check(resource types.Resource) *Report {
+	requirements := map[string]Requirements{}
+
+	if r, ok := resource.(Requirer); ok {
+		requirements = r.PreflightRequirements()
+	}
+
+	previousState := resource.Containers().ToExported().PreviousState
+
+	report := &Report{
+		Hosts: []HostReport{},
+	}
+
+	for _, t := range targets(resource.Containers().DesiredState(), previousState, requirements) {
+		report.Hosts = append(report.Hosts, t.check())
+	}
+
+	return report
+}
+
+// targets groups containers from given desired state by the host and container runtime they use.
+func targets(
+	desiredState, previousState container.ContainersState,
+	requirements map[string]Requirements,
+) []*target {
+	names := []string{}
+
+	for name := range desiredState {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	keys := []string{}
+	targets := map[string]*target{}
+
+	for _, name := range names {
+		hcc := desiredState[name]
+
+		c, err := hcc.Container.New()
+		if err != nil {
+			// Resources are validated before, so this should never happen.
+			continue
+		}
+
+		key := targetKey(hcc.Host, c.RuntimeConfig())
+
+		t, ok := targets[key]
+		if !ok {
+			t = &target{
+				name:          hostName(hcc.Host),
+				host:          hcc.Host,
+				runtimeConfig: c.RuntimeConfig(),
+				ports:         map[int]string{},
+				cgroupDrivers: map[string]string{},
+			}
+
+			targets[key] = t
+			keys = append(keys, key)
+		}
+
+		t.add(name, hcc, requirements[name], exists(previousState, name))
+	}
+
+	result := []*target{}
+
+	for _, key := range keys {
+		result = append(result, targets[key])
+	}
+
+	return result
+}
+
+// targetKey returns key identifying given host and container runtime address.
+func targetKey(h host.Host, runtimeConfig runtime.Config) string {
+	// Host configuration always consists of serializable types.
+	key, _ := json.Marshal(h) //nolint:errchkjson // Serialization can't fail.
+
+	return fmt.Sprintf("%s-%s", key, runtimeConfig.GetAddress())
+}
+
+// hostName returns name of the host used in the report.
+func hostName(h host.Host) string {
+	if h.SSHConfig != nil {
+		return h.SSHConfig.Address
+	}
+
+	return "localhost"
+}
+
+// exists returns true, if container with given name already exists according to given state.
+func exists(state container.ContainersState, name string) bool {
+	hcc, ok := state[name]
+
+	return ok && hcc.Container.Status != nil && hcc.Container.Status.Exists()
+}
+
+// add adds requirements of given container to the target. Ports are only required by containers,
+// which do not exist yet, as existing containers already use them.
+func (t *target) add(name string, hcc *container.HostConfiguredContainer, r Requirements, exists bool) {
+	if r.CgroupDriver != "" {
+		t.cgroupDrivers[r.CgroupDriver] = name
+	}
+
+	if exists {
+		return
+	}
+
+	for _, port := range r.Ports {
+		t.ports[port] = name
+	}
+
+	for _, p := range hcc.Container.Config.Ports {
+		t.ports[p.Port] = name
+	}
+}
+
+// check runs all checks on the target host.
+func (t *target) check() HostReport {
+	report := HostReport{
+		Host:    t.name,
+		Results: []Result{},
+	}
+
+	hc, err := t.connect()
+	if err != nil {
+		report.add(CheckConnection, StatusFail, "%v", err)
+
+		return report
+	}
+
+	defer func() {
+		if err := hc.Close(); err != nil {
+			fmt.Printf("Failed closing host connection: %v\n", err)
+		}
+	}()
+
+	report.add(CheckConnection, StatusPass, "connected")
+
+	info := t.checkRuntime(hc, &report)
+
+	rootDir := defaultRootDir
+
+	if info != nil {
+		t.checkCgroupDriver(info, &report)
+
+		if info.RootDir != "" {
+			rootDir = info.RootDir
+		}
+	}
+
+	t.checkPorts(hc, info, &report)
+
+	checkDiskSpace(hc, rootDir, &report)
+
+	return report
+}
+
+// connect establishes connection to the target host.
+func (t *target) connect() (transport.Connected, error) {
+	h, err := t.host.New()
+	if err != nil {
+		return nil, fmt.Errorf("initializing host: %w", err)
+	}
+
+	hc, err := h.Connect()
+
+	// Transport is only needed for establishing the connection.
+	if closeErr := h.Close(); closeErr != nil {
+		fmt.Printf("Failed closing host transport: %v\n", closeErr)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	return hc, nil
+}
+
+// checkRuntime checks, if container runtime is reachable and compatible. It returns information
+// about the runtime, if it is available.
+func (t *target) checkRuntime(hc transport.Connected, report *HostReport) *runtime.Info {
+	address := t.runtimeConfig.GetAddress()
+
+	forwardedAddress, err := hc.ForwardUnixSocket(address)
+	if err != nil {
+		report.add(CheckRuntime, StatusFail, "forwarding container runtime socket %q: %v", address, err)
+
+		return nil
+	}
+
+	defer func() {
+		if err := hc.CloseForward(forwardedAddress); err != nil {
+			fmt.Printf("Failed closing forwarded container runtime socket: %v\n", err)
+		}
+	}()
+
+	// Restore original address once runtime is created, as runtime configuration is shared with the container.
+	t.runtimeConfig.SetAddress(forwardedAddress)
+	r, err := t.runtimeConfig.New()
+	t.runtimeConfig.SetAddress(address)
+
+	if err != nil {
+		report.add(CheckRuntime, StatusFail, "initializing container runtime: %v", err)
+
+		return nil
+	}
+
+	informer, ok := r.(runtime.Informer)
+	if !ok {
+		report.add(CheckRuntime, StatusWarn, "container runtime does not support pre-flight checks")
+
+		return nil
+	}
+
+	info, err := informer.Info()
+	if err != nil {
+		report.add(CheckRuntime, StatusFail, "container runtime is not reachable at %q: %v", address, err)
+
+		return nil
+	}
+
+	if status, message := checkAPIVersion(info); status != StatusPass {
+		report.add(CheckRuntime, status, message)
+
+		return info
+	}
+
+	report.add(CheckRuntime, StatusPass, "%s %s, API version %s", info.Name, info.Version, info.APIVersion)
+
+	return info
+}
+
+// checkAPIVersion checks, if API version used by the client is supported by the container runtime.
+func checkAPIVersion(info *runtime.Info) (Status, string) {
+	if info.ClientAPIVersion == "" {
+		return StatusPass, ""
+	}
+
+	if info.APIVersion != "" && versions.LessThan(info.APIVersion, info.ClientAPIVersion) {
+		return StatusFail, fmt.Sprintf("%s %s supports API version up to %s, but %s is required",
+			info.Name, info.Version, info.APIVersion, info.ClientAPIVersion)
+	}
+
+	if info.MinAPIVersion != "" && versions.GreaterThan(info.MinAPIVersion, info.ClientAPIVersion) {
+		return StatusFail, fmt.Sprintf("%s %s requires API version at least %s, but %s is used",
+			info.Name, info.Version, info.MinAPIVersion, info.ClientAPIVersion)
+	}
+
+	return StatusPass, ""
+}
+
+// checkCgroupDriver checks, if container runtime uses cgroup driver required by the containers.
+func (t *target) checkCgroupDriver(info *runtime.Info, report *HostReport) {
+	for _, driver := range sortedKeys(t.cgroupDrivers) {
+		if info.CgroupDriver != driver {
+			report.add(CheckCgroupDriver, StatusFail, "container runtime uses %q cgroup driver, but %q requires %q",
+				info.CgroupDriver, t.cgroupDrivers[driver], driver)
+
+			continue
+		}
+
+		report.add(CheckCgroupDriver, StatusPass, "%q", driver)
+	}
+}
+
+// checkPorts checks, if ports required by new containers are not used by other containers or processes.
+func (t *target) checkPorts(hc transport.Connected, info *runtime.Info, report *HostReport) {
+	if len(t.ports) == 0 {
+		return
+	}
+
+	used := map[int]string{}
+
+	if info != nil {
+		for _, binding := range info.PortBindings {
+			used[binding.Port] = fmt.Sprintf("container %q", binding.Container)
+		}
+	}
+
+	listening, err := listeningPorts(hc)
+
+	for _, port := range listening {
+		if _, ok := used[port]; !ok {
+			used[port] = "another process"
+		}
+	}
+
+	ports := []int{}
+
+	for port := range t.ports {
+		ports = append(ports, port)
+	}
+
+	sort.Ints(ports)
+
+	conflicts := []string{}
+
+	for _, port := range ports {
+		if user, ok := used[port]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("port %d required by %q is used by %s", port, t.ports[port], user))
+		}
+	}
+
+	switch {
+	case len(conflicts) > 0:
+		report.add(CheckPorts, StatusFail, "%s", strings.Join(conflicts, ", "))
+	case err != nil:
+		report.add(CheckPorts, StatusWarn, "unable to list ports used by processes: %v", err)
+	default:
+		report.add(CheckPorts, StatusPass, "%s available", joinInts(ports))
+	}
+}
+
+// listeningPorts returns ports, on which processes on the host listen.
+func listeningPorts(hc transport.Connected) ([]int, error) {
+	stdout, stderr, exitCode, err := hc.Exec(listeningPortsCommand, nil)
+	if err != nil {
+		return nil, fmt.Errorf("running %q: %w", listeningPortsCommand, err)
+	}
+
+	if exitCode != 0 {
+		return nil, fmt.Errorf("running %q: exit code %d: %s",
+			listeningPortsCommand, exitCode, strings.TrimSpace(string(stderr)))
+	}
+
+	return parseListeningPorts(string(stdout)), nil
+}
+
+// parseListeningPorts parses output of 'ss -Htuln' command and returns listening ports.
+func parseListeningPorts(output string) []int {
+	ports := []int{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+
+		// Expected format: 'tcp LISTEN 0 4096 127.0.0.1:2379 0.0.0.0:*'.
+		localAddressField := 4
+
+		if len(fields) <= localAddressField {
+			continue
+		}
+
+		localAddress := fields[localAddressField]
+
+		port, err := strconv.Atoi(localAddress[strings.LastIndex(localAddress, ":")+1:])
+		if err != nil {
+			continue
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports
+}
+
+// checkDiskSpace checks, if there is enough free disk space in given directory.
+func checkDiskSpace(hc transport.Connected, dir string, report *HostReport) {
+	command := fmt.Sprintf("df -Pk '%s'", strings.ReplaceAll(dir, "'", `'\''`))
+
+	stdout, stderr, exitCode, err := hc.Exec(command, nil)
+
+	switch {
+	case err != nil:
+		report.add(CheckDiskSpace, StatusWarn, "running %q: %v", command, err)
+
+		return
+	case exitCode != 0:
+		report.add(CheckDiskSpace, StatusWarn, "running %q: exit code %d: %s", command, exitCode,
+			strings.TrimSpace(string(stderr)))
+
+		return
+	}
+
+	available, err := parseAvailableDiskSpace(string(stdout))
+	if err != nil {
+		report.add(CheckDiskSpace, StatusWarn, "parsing output of %q: %v", command, err)
+
+		return
+	}
+
+	message := fmt.Sprintf("%d MiB available in %q", available>>20, dir)
+
+	switch {
+	case available < DiskSpaceFailThreshold:
+		report.add(CheckDiskSpace, StatusFail, "only %s, at least %d MiB is required", message, DiskSpaceFailThreshold>>20)
+	case available < DiskSpaceWarnThreshold:
+		report.add(CheckDiskSpace, StatusWarn, "only %s, at least %d MiB is recommended", message, DiskSpaceWarnThreshold>>20)
+	default:
+		report.add(CheckDiskSpace, StatusPass, message)
+	}
+}
+
+// parseAvailableDiskSpace parses output of 'df -Pk' command and returns available disk space in bytes.
+func parseAvailableDiskSpace(output string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+
+	// Expected format: header line and 'Filesystem 1024-blocks Used Available Capacity Mounted-on' line.
+	expectedLines := 2
+	availableField := 3
+
+	if len(lines) < expectedLines {
+		return 0, fmt.Errorf("unexpected output %q", output)
+	}
+
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) <= availableField {
+		return 0, fmt.Errorf("unexpected output %q", output)
+	}
+
+	available, err := strconv.ParseInt(fields[availableField], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing available space %q: %w", fields[availableField], err)
+	}
+
+	return available << 10, nil
+}
+
+// add adds result of the check to the report.
+func (h *HostReport) add(check string, status Status, format string, args ...interface{}) {
+	h.Results = append(h.Results, Result{
+		Check:   check,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Failed returns true, if any of the checks failed.
+func (r *Report) Failed() bool {
+	return r.count(StatusFail) > 0
+}
+
+// count returns number of results with given status.
+func (r *Report) count(status Status) int {
+	count := 0
+
+	for _, h := range r.Hosts {
+		for _, result := range h.Results {
+			if result.Status == status {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// Text returns human-readable form of the report.
+func (r *Report) Text() string {
+	var sb strings.Builder
+
+	for _, h := range r.Hosts {
+		fmt.Fprintf(&sb, "%s:\n", h.Host)
+
+		for _, result := range h.Results {
+			fmt.Fprintf(&sb, "  [%s] %s: %s\n", strings.ToUpper(string(result.Status)), result.Check, result.Message)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\nChecked %d hosts: %d checks passed, %d warnings, %d failures\n",
+		len(r.Hosts), r.count(StatusPass), r.count(StatusWarn), r.count(StatusFail))
+
+	return sb.String()
+}
+
+// sortedKeys returns sorted keys of given map.
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// joinInts returns comma-separated list of given numbers.
+func joinInts(numbers []int) string {
+	s := []string{}
+
+	for _, n := range numbers {
+		s = append(s, strconv.Itoa(n))
+	}
+
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,261 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/container/runtime"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+)
+
+// testResource is a types.Resource implementation with configurable pre-flight requirements.
+type testResource struct {
+	container.ContainersInterface
+
+	requirements map[string]Requirements
+}
+
+func (r *testResource) Containers() container.ContainersInterface {
+	return r.ContainersInterface
+}
+
+func (r *testResource) PreflightRequirements() map[string]Requirements {
+	return r.requirements
+}
+
+func newTestResource(t *testing.T, fakeClient *docker.FakeClient, requirements map[string]Requirements) *testResource {
+	t.Helper()
+
+	containers := &container.Containers{
+		DesiredState: container.ContainersState{
+			"foo": &container.HostConfiguredContainer{
+				Host: host.Host{
+					DirectConfig: &direct.Config{},
+				},
+				Container: container.Container{
+					Config: types.ContainerConfig{
+						Name:  "foo",
+						Image: "busybox",
+					},
+					Runtime: container.RuntimeConfig{
+						Docker: &docker.Config{
+							ClientGetter: func(...client.Opt) (docker.Client, error) {
+								return fakeClient, nil
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c, err := containers.New()
+	if err != nil {
+		t.Fatalf("Creating containers should succeed, got: %v", err)
+	}
+
+	return &testResource{
+		ContainersInterface: c,
+		requirements:        requirements,
+	}
+}
+
+func resultStatus(t *testing.T, report *Report, check string) Status {
+	t.Helper()
+
+	if len(report.Hosts) != 1 {
+		t.Fatalf("Expected report for one host, got: %+v", report.Hosts)
+	}
+
+	for _, result := range report.Hosts[0].Results {
+		if result.Check == check {
+			return result.Status
+		}
+	}
+
+	t.Fatalf("Check %q not found in the report: %+v", check, report.Hosts[0].Results)
+
+	return ""
+}
+
+func TestCheckCgroupDriverMismatch(t *testing.T) {
+	t.Parallel()
+
+	fakeClient := &docker.FakeClient{
+		InfoF: func(context.Context) (dockertypes.Info, error) {
+			return dockertypes.Info{CgroupDriver: "cgroupfs"}, nil
+		},
+	}
+
+	report := Check(newTestResource(t, fakeClient, map[string]Requirements{
+		"foo": {CgroupDriver: "systemd"},
+	}))
+
+	if status := resultStatus(t, report, CheckCgroupDriver); status != StatusFail {
+		t.Fatalf("Cgroup driver check should fail, got %q", status)
+	}
+
+	if !report.Failed() {
+		t.Fatalf("Report should be failed")
+	}
+}
+
+func TestCheckPortUsedByContainer(t *testing.T) {
+	t.Parallel()
+
+	fakeClient := &docker.FakeClient{
+		ContainerListF: func(context.Context, dockertypes.ContainerListOptions) ([]dockertypes.Container, error) {
+			return []dockertypes.Container{
+				{
+					Names: []string{"/bar"},
+					Ports: []dockertypes.Port{{PrivatePort: 2379, PublicPort: 2379, Type: "tcp"}},
+				},
+			}, nil
+		},
+	}
+
+	report := Check(newTestResource(t, fakeClient, map[string]Requirements{
+		"foo": {Ports: []int{2379}},
+	}))
+
+	if status := resultStatus(t, report, CheckPorts); status != StatusFail {
+		t.Fatalf("Ports check should fail, got %q", status)
+	}
+}
+
+func TestCheckRuntimeUnreachable(t *testing.T) {
+	t.Parallel()
+
+	fakeClient := &docker.FakeClient{
+		InfoF: func(context.Context) (dockertypes.Info, error) {
+			return dockertypes.Info{}, fmt.Errorf("dial unix /run/docker.sock: no such file or directory")
+		},
+	}
+
+	report := Check(newTestResource(t, fakeClient, nil))
+
+	if status := resultStatus(t, report, CheckRuntime); status != StatusFail {
+		t.Fatalf("Runtime check should fail, got %q", status)
+	}
+
+	if status := resultStatus(t, report, CheckConnection); status != StatusPass {
+		t.Fatalf("Connection check should pass, got %q", status)
+	}
+}
+
+func TestCheckAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		info     runtime.Info
+		expected Status
+	}{
+		"supported": {
+			info:     runtime.Info{APIVersion: "1.43", MinAPIVersion: "1.12", ClientAPIVersion: "1.40"},
+			expected: StatusPass,
+		},
+		"too old": {
+			info:     runtime.Info{APIVersion: "1.20", ClientAPIVersion: "1.40"},
+			expected: StatusFail,
+		},
+		"too new": {
+			info:     runtime.Info{APIVersion: "1.50", MinAPIVersion: "1.44", ClientAPIVersion: "1.40"},
+			expected: StatusFail,
+		},
+		"unknown client version": {
+			info:     runtime.Info{APIVersion: "1.20"},
+			expected: StatusPass,
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if status, message := checkAPIVersion(&testCase.info); status != testCase.expected {
+				t.Fatalf("Expected status %q, got %q: %s", testCase.expected, status, message)
+			}
+		})
+	}
+}
+
+func TestParseListeningPorts(t *testing.T) {
+	t.Parallel()
+
+	output := `tcp   LISTEN 0      4096       127.0.0.1:2379       0.0.0.0:*
+tcp   LISTEN 0      4096            [::]:10250         [::]:*
+udp   UNCONN 0      0         0.0.0.0%eth0:68         0.0.0.0:*
+`
+
+	if ports, expected := parseListeningPorts(output), []int{2379, 10250, 68}; !reflect.DeepEqual(ports, expected) {
+		t.Fatalf("Expected ports %v, got %v", expected, ports)
+	}
+}
+
+func TestParseAvailableDiskSpace(t *testing.T) {
+	t.Parallel()
+
+	output := `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1        102400000 51200000  51200000      50% /
+`
+
+	available, err := parseAvailableDiskSpace(output)
+	if err != nil {
+		t.Fatalf("Parsing should succeed, got: %v", err)
+	}
+
+	if expected := int64(51200000) << 10; available != expected {
+		t.Fatalf("Expected %d bytes available, got %d", expected, available)
+	}
+}
+
+func TestParseAvailableDiskSpaceBadOutput(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseAvailableDiskSpace("df: /foo: No such file or directory"); err == nil {
+		t.Fatalf("Parsing unexpected output should fail")
+	}
+}
+
+func TestReportText(t *testing.T) {
+	t.Parallel()
+
+	report := &Report{
+		Hosts: []HostReport{
+			{
+				Host: "foo",
+				Results: []Result{
+					{Check: CheckConnection, Status: StatusPass, Message: "connected"},
+					{Check: CheckPorts, Status: StatusFail, Message: "port 2379 is used"},
+				},
+			},
+		},
+	}
+
+	text := report.Text()
+
+	expectedLines := []string{
+		"foo:",
+		"[PASS] connection: connected",
+		"[FAIL] ports: port 2379 is used",
+		"1 failures",
+	}
+
+	for _, expected := range expectedLines {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("Report should contain %q, got:\n%s", expected, text)
+		}
+	}
+}
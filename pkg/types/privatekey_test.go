@@ -81,6 +81,16 @@ func TestParsePrivateKeyEC(t *testing.T) {
 	}
 }
 
+func TestParsePrivateKeyEd25519(t *testing.T) {
+	t.Parallel()
+
+	d := fmt.Sprintf("bar: |\n%s", util.Indent(strings.TrimSpace(utiltest.GenerateEd25519PrivateKey(t)), "  "))
+
+	if err := yaml.Unmarshal([]byte(d), &Foo{}); err != nil {
+		t.Fatalf("Parsing valid Ed25519 private key should succeed, got: %v", err)
+	}
+}
+
 func TestParsePrivateKeyBad(t *testing.T) {
 	t.Parallel()
 